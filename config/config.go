@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,14 +14,18 @@ type MySQLConfig struct {
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	DBName   string `yaml:"dbname"`
+	ServerID uint32 `yaml:"server_id"` //replica server id the binlog reader registers with in cdc mode, random when zero
 }
 
 type PostgreSQLConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	DBName   string `yaml:"dbname"`
+	Host              string `yaml:"host"`
+	Port              int    `yaml:"port"`
+	User              string `yaml:"user"`
+	Password          string `yaml:"password"`
+	DBName            string `yaml:"dbname"`
+	ReplicationSlot   string `yaml:"replication_slot"`   //logical replication slot read in cdc mode, data_migration_tool when empty
+	Publication       string `yaml:"publication"`        //publication of the captured tables read by pgoutput, data_migration_tool when empty
+	ReplicationPlugin string `yaml:"replication_plugin"` //logical decoding plugin of the slot, pgoutput (default) or wal2json
 }
 
 type MongoDBConfig struct {
@@ -32,12 +37,100 @@ type MongoDBConfig struct {
 	URI      string `yaml:"uri"` //incase of direct URI connection string
 }
 
+type SQLiteConfig struct {
+	Path string `yaml:"path"` //database file, created when it does not exist
+}
+
+// settings for the scheduled migration mode
+type ScheduleConfig struct {
+	Cron string `yaml:"cron"` //standard 5 field cron expression or descriptor like @hourly
+	Mode string `yaml:"mode"` //migration mode run on every tick, full or incremental
+}
+
+// settings for discovering the tables of the source database
+type DiscoveryConfig struct {
+	Schemas []string `yaml:"schemas"` //schemas to discover, the default schema of the connection when empty
+	Include []string `yaml:"include"` //glob patterns of tables to migrate
+	Exclude []string `yaml:"exclude"` //glob patterns of tables to skip
+}
+
+// timeouts of single database operations, eg. "30s" or "5m", zero waits without a limit
+type TimeoutConfig struct {
+	Connect time.Duration `yaml:"connect"` //connecting and pinging the database
+	Query   time.Duration `yaml:"query"`   //running one query, or reading one batch of a streamed table or collection
+	Import  time.Duration `yaml:"import"`  //writing one batch of rows in its transaction
+}
+
+// one step of the transformations applied to the rows of a table before they are written, setting exactly one operation
+type TransformStep struct {
+	Rename  map[string]string      `yaml:"rename"`  //old column name to new name
+	Drop    []string               `yaml:"drop"`    //columns removed from every row
+	Cast    map[string]string      `yaml:"cast"`    //column to type, string, int, float or bool
+	Default map[string]interface{} `yaml:"default"` //column to the value replacing a NULL or missing value
+	Concat  *ConcatTransform       `yaml:"concat"`
+	Split   *SplitTransform        `yaml:"split"`
+	Replace *ReplaceTransform      `yaml:"replace"`
+	Derive  *DeriveTransform       `yaml:"derive"`
+}
+
+// joining the values of several columns into a new column
+type ConcatTransform struct {
+	Column    string   `yaml:"column"`
+	From      []string `yaml:"from"`
+	Separator string   `yaml:"separator"`
+}
+
+// splitting the value of a column into several new columns
+type SplitTransform struct {
+	Column    string   `yaml:"column"`
+	Into      []string `yaml:"into"`
+	Separator string   `yaml:"separator"`
+}
+
+// replacing the matches of a regular expression in the values of a column
+type ReplaceTransform struct {
+	Column  string `yaml:"column"`
+	Pattern string `yaml:"pattern"`
+	With    string `yaml:"with"` //replacement, may refer to groups like ${1}
+}
+
+// computing a column from a template referring to other columns like "{first_name} {last_name}"
+type DeriveTransform struct {
+	Column   string `yaml:"column"`
+	Template string `yaml:"template"`
+}
+
+// names source tables and columns get in the target
+type MappingConfig struct {
+	Schema     string                  `yaml:"schema"`      //target schema of the tables not mapped to a qualified name
+	ColumnCase string                  `yaml:"column_case"` //"snake_case" converts the columns not mapped, eg. userId to user_id
+	Tables     map[string]TableMapping `yaml:"tables"`      //keyed by source table
+}
+
+// target name and column names of a source table
+type TableMapping struct {
+	Target  string            `yaml:"target"`  //target table, may be schema qualified like sales.orders
+	Columns map[string]string `yaml:"columns"` //source column to target column
+}
+
 // config struct to map config.yaml
 type Config struct {
 	MySQL       MySQLConfig      `yaml:"mysql"`
 	PostgreSQL  PostgreSQLConfig `yaml:"postgresql"`
 	MongoDB     MongoDBConfig    `yaml:"mongodb"`
-	SQLFilePath string           `yaml:"sqlfile_path"`
+	SQLite      SQLiteConfig     `yaml:"sqlite"`
+	SQLFilePath string           `yaml:"sqlfile_path"` //optional, overrides discovery from the live catalog
+	Discovery   DiscoveryConfig  `yaml:"discovery"`
+	Schedule    ScheduleConfig   `yaml:"schedule"`
+	Timeouts    TimeoutConfig    `yaml:"timeouts"`
+	//overrides of the default type mappings, keyed by source_to_target then by source type, eg. mysql_to_postgresql: {tinyint: BOOLEAN}
+	TypeMappings map[string]map[string]string `yaml:"type_mappings"`
+	//transformations applied to the rows of each table before they are written, keyed by table
+	Transforms map[string][]TransformStep `yaml:"transforms"`
+	Mappings   MappingConfig              `yaml:"mappings"`
+	//conditions restricting the rows read from each source table, keyed by table. SQL WHERE fragments for
+	//MySQL, PostgreSQL and SQLite, JSON query documents for MongoDB
+	Filters map[string]string `yaml:"filters"`
 }
 
 func LoadConfig(filepath string) (*Config, error) {
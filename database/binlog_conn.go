@@ -0,0 +1,443 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// capability flags of the MySQL client/server protocol used by the replication connection
+const (
+	clientLongPassword     = 0x00000001
+	clientLongFlag         = 0x00000004
+	clientProtocol41       = 0x00000200
+	clientTransactions     = 0x00002000
+	clientSecureConnection = 0x00008000
+	clientPluginAuth       = 0x00080000
+)
+
+// commands sent over the replication connection
+const (
+	comQuery           = 0x03
+	comRegisterSlave   = 0x15
+	comBinlogDump      = 0x12
+	comBinlogDumpGTID  = 0x1e
+	maxPacketSize      = 1<<24 - 1
+	utf8mb4GeneralCI   = 45
+	defaultBinlogReply = 16 << 20 //max packet size announced to the server
+)
+
+// plain connection speaking the MySQL protocol, database/sql drivers cannot request a binlog dump
+type binlogConn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	sequence byte
+}
+
+// dialing the server and authenticating, mysql_native_password and caching_sha2_password are supported.
+// The connection is not encrypted, caching_sha2_password exchanges the password with the server's RSA key
+func dialBinlogConn(ctx context.Context, host string, port int, user, password string) (*binlogConn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect for reading the binlog, %w", err)
+	}
+	bc := &binlogConn{conn: conn, reader: bufio.NewReaderSize(conn, 64<<10)}
+
+	//bounding the handshake by ctx, the stream itself runs without a deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := bc.handshake(user, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return bc, nil
+}
+
+// reading one protocol packet, joining packets split at the 16MB limit
+func (bc *binlogConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(bc.reader, header[:]); err != nil {
+			if err == io.EOF {
+				//the server closed the connection
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read packet header, %w", err)
+		}
+		length := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		bc.sequence = header[3] + 1
+
+		chunk := make([]byte, length)
+		if _, err := io.ReadFull(bc.reader, chunk); err != nil {
+			return nil, fmt.Errorf("failed to read packet, %w", err)
+		}
+		if payload == nil && length < maxPacketSize {
+			return chunk, nil
+		}
+		payload = append(payload, chunk...)
+		if length < maxPacketSize {
+			return payload, nil
+		}
+	}
+}
+
+// writing one protocol packet, splitting payloads at the 16MB limit
+func (bc *binlogConn) writePacket(payload []byte) error {
+	for {
+		length := len(payload)
+		if length > maxPacketSize {
+			length = maxPacketSize
+		}
+		packet := make([]byte, 4+length)
+		packet[0], packet[1], packet[2] = byte(length), byte(length>>8), byte(length>>16)
+		packet[3] = bc.sequence
+		copy(packet[4:], payload[:length])
+		bc.sequence++
+		if _, err := bc.conn.Write(packet); err != nil {
+			return fmt.Errorf("failed to write packet, %w", err)
+		}
+
+		payload = payload[length:]
+		if length < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// sending a command, every command starts a new packet sequence
+func (bc *binlogConn) writeCommand(command byte, data []byte) error {
+	bc.sequence = 0
+	return bc.writePacket(append([]byte{command}, data...))
+}
+
+// running a statement without a result set like SET
+func (bc *binlogConn) exec(query string) error {
+	if err := bc.writeCommand(comQuery, []byte(query)); err != nil {
+		return err
+	}
+	packet, err := bc.readPacket()
+	if err != nil {
+		return err
+	}
+	if err := packetError(packet); err != nil {
+		return fmt.Errorf("failed to run %s, %w", query, err)
+	}
+	if len(packet) == 0 || packet[0] != 0x00 {
+		return fmt.Errorf("unexpected result for %s", query)
+	}
+	return nil
+}
+
+// releasing the connection
+func (bc *binlogConn) Close() error {
+	return bc.conn.Close()
+}
+
+// server error sent in an ERR packet
+type serverError struct {
+	Code    uint16
+	Message string
+}
+
+func (e *serverError) Error() string {
+	return fmt.Sprintf("Error %d: %s", e.Code, e.Message)
+}
+
+// returning the error of an ERR packet, nil for other packets
+func packetError(packet []byte) error {
+	if len(packet) < 3 || packet[0] != 0xff {
+		return nil
+	}
+	code := binary.LittleEndian.Uint16(packet[1:3])
+	message := packet[3:]
+	//skipping the SQL state marker and state
+	if len(message) >= 6 && message[0] == '#' {
+		message = message[6:]
+	}
+	return &serverError{Code: code, Message: string(message)}
+}
+
+// reading the initial handshake of the server and authenticating
+func (bc *binlogConn) handshake(user, password string) error {
+	packet, err := bc.readPacket()
+	if err != nil {
+		return err
+	}
+	if err := packetError(packet); err != nil {
+		return err
+	}
+	if len(packet) < 1 || packet[0] != 10 {
+		return fmt.Errorf("unsupported MySQL protocol version")
+	}
+
+	//protocol version, server version and connection id
+	pos := 1 + bytes.IndexByte(packet[1:], 0) + 1 + 4
+	if pos+8+1+2 > len(packet) {
+		return fmt.Errorf("malformed handshake packet")
+	}
+	scramble := append([]byte{}, packet[pos:pos+8]...)
+	pos += 8 + 1
+	capabilities := uint32(binary.LittleEndian.Uint16(packet[pos : pos+2]))
+	pos += 2
+	plugin := "mysql_native_password"
+	if len(packet) >= pos+1+2+2+1+10 {
+		pos += 1 + 2
+		capabilities |= uint32(binary.LittleEndian.Uint16(packet[pos:pos+2])) << 16
+		pos += 2
+		scrambleLength := int(packet[pos])
+		pos += 1 + 10
+		if capabilities&clientSecureConnection != 0 {
+			rest := scrambleLength - 8
+			if rest < 13 {
+				rest = 13
+			}
+			if pos+rest <= len(packet) {
+				//the second part of the scramble is terminated by a zero byte
+				scramble = append(scramble, bytes.TrimRight(packet[pos:pos+rest], "\x00")...)
+				pos += rest
+			}
+		}
+		if capabilities&clientPluginAuth != 0 && pos < len(packet) {
+			plugin = string(bytes.TrimRight(packet[pos:], "\x00"))
+		}
+	}
+	if capabilities&clientProtocol41 == 0 {
+		return fmt.Errorf("server does not support the 4.1 protocol")
+	}
+
+	authData, err := scramblePassword(plugin, password, scramble)
+	if err != nil {
+		return err
+	}
+
+	var response bytes.Buffer
+	flags := uint32(clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions | clientSecureConnection | clientPluginAuth)
+	binary.Write(&response, binary.LittleEndian, flags)
+	binary.Write(&response, binary.LittleEndian, uint32(defaultBinlogReply))
+	response.WriteByte(utf8mb4GeneralCI)
+	response.Write(make([]byte, 23))
+	response.WriteString(user)
+	response.WriteByte(0)
+	response.WriteByte(byte(len(authData)))
+	response.Write(authData)
+	response.WriteString(plugin)
+	response.WriteByte(0)
+	if err := bc.writePacket(response.Bytes()); err != nil {
+		return err
+	}
+	return bc.authenticate(plugin, password, scramble)
+}
+
+// following the authentication exchange until the server accepts or rejects the login
+func (bc *binlogConn) authenticate(plugin, password string, scramble []byte) error {
+	for {
+		packet, err := bc.readPacket()
+		if err != nil {
+			return err
+		}
+		if err := packetError(packet); err != nil {
+			return fmt.Errorf("authentication failed, %w", err)
+		}
+		if len(packet) == 0 {
+			return fmt.Errorf("empty authentication packet")
+		}
+
+		switch packet[0] {
+		case 0x00:
+			return nil
+		case 0xfe:
+			//switching to the plugin requested by the server, with a new scramble
+			rest := packet[1:]
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				return fmt.Errorf("malformed authentication switch request")
+			}
+			plugin = string(rest[:end])
+			scramble = bytes.TrimRight(rest[end+1:], "\x00")
+			authData, err := scramblePassword(plugin, password, scramble)
+			if err != nil {
+				return err
+			}
+			if err := bc.writePacket(authData); err != nil {
+				return err
+			}
+		case 0x01:
+			if plugin != "caching_sha2_password" || len(packet) < 2 {
+				return fmt.Errorf("unexpected authentication data for %s", plugin)
+			}
+			switch {
+			case packet[1] == 3:
+				//fast authentication succeeded, the OK packet follows
+			case packet[1] == 4:
+				//full authentication, the password is sent encrypted with the public key of the server
+				if err := bc.writePacket([]byte{2}); err != nil {
+					return err
+				}
+				keyPacket, err := bc.readPacket()
+				if err != nil {
+					return err
+				}
+				if err := packetError(keyPacket); err != nil {
+					return err
+				}
+				if len(keyPacket) < 2 {
+					return fmt.Errorf("malformed public key packet")
+				}
+				encrypted, err := encryptPassword(password, scramble, keyPacket[1:])
+				if err != nil {
+					return err
+				}
+				if err := bc.writePacket(encrypted); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected caching_sha2_password state %d", packet[1])
+			}
+		default:
+			return fmt.Errorf("unexpected authentication packet 0x%02x", packet[0])
+		}
+	}
+}
+
+// computing the authentication response of a plugin from the password and the server scramble
+func scramblePassword(plugin, password string, scramble []byte) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case "mysql_native_password":
+		//SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		if len(scramble) > 20 {
+			scramble = scramble[:20]
+		}
+		hash := sha1.New()
+		hash.Write(scramble)
+		hash.Write(stage2[:])
+		result := hash.Sum(nil)
+		for i := range result {
+			result[i] ^= stage1[i]
+		}
+		return result, nil
+	case "caching_sha2_password":
+		//SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		stage1 := sha256.Sum256([]byte(password))
+		stage2 := sha256.Sum256(stage1[:])
+		hash := sha256.New()
+		hash.Write(stage2[:])
+		hash.Write(scramble)
+		result := hash.Sum(nil)
+		for i := range result {
+			result[i] ^= stage1[i]
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication plugin %s", plugin)
+	}
+}
+
+// encrypting the zero terminated password XORed with the scramble with the RSA public key of the server
+func encryptPassword(password string, scramble, publicKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key of the server")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of the server, %v", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key of the server is not an RSA key")
+	}
+
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, key, plain, nil)
+}
+
+// registering as a replica, so the connection shows up in SHOW REPLICAS
+func (bc *binlogConn) registerReplica(serverID uint32) error {
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, serverID)
+	data.WriteByte(0) //hostname
+	data.WriteByte(0) //user
+	data.WriteByte(0) //password
+	binary.Write(&data, binary.LittleEndian, uint16(0))
+	binary.Write(&data, binary.LittleEndian, uint32(0)) //replication rank
+	binary.Write(&data, binary.LittleEndian, uint32(0)) //source id
+	if err := bc.writeCommand(comRegisterSlave, data.Bytes()); err != nil {
+		return err
+	}
+	packet, err := bc.readPacket()
+	if err != nil {
+		return err
+	}
+	if err := packetError(packet); err != nil {
+		return fmt.Errorf("failed to register as replica, %w", err)
+	}
+	return nil
+}
+
+// requesting the binlog from a file position
+func (bc *binlogConn) dumpFromPosition(serverID uint32, file string, position uint32) error {
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, position)
+	binary.Write(&data, binary.LittleEndian, uint16(0))
+	binary.Write(&data, binary.LittleEndian, serverID)
+	data.WriteString(file)
+	return bc.writeCommand(comBinlogDump, data.Bytes())
+}
+
+// requesting the binlog after the transactions of an executed GTID set
+func (bc *binlogConn) dumpFromGTIDSet(serverID uint32, executed gtidSet) error {
+	encoded := executed.encode()
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, uint16(0))
+	binary.Write(&data, binary.LittleEndian, serverID)
+	binary.Write(&data, binary.LittleEndian, uint32(0)) //file name length, empty with a GTID set
+	binary.Write(&data, binary.LittleEndian, uint64(4))
+	binary.Write(&data, binary.LittleEndian, uint32(len(encoded)))
+	data.Write(encoded)
+	return bc.writeCommand(comBinlogDumpGTID, data.Bytes())
+}
+
+// reading the next event of a binlog dump, without the leading OK byte
+func (bc *binlogConn) readEvent() ([]byte, error) {
+	packet, err := bc.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if err := packetError(packet); err != nil {
+		return nil, err
+	}
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("empty binlog packet")
+	}
+	switch packet[0] {
+	case 0x00:
+		return packet[1:], nil
+	case 0xfe:
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("unexpected binlog packet 0x%02x", packet[0])
+	}
+}
@@ -0,0 +1,847 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binlog event types handled by the change stream, the others only advance the position
+const (
+	queryEvent             = 0x02
+	rotateEvent            = 0x04
+	formatDescriptionEvent = 0x0f
+	xidEvent               = 0x10
+	tableMapEvent          = 0x13
+	writeRowsEventV1       = 0x17
+	updateRowsEventV1      = 0x18
+	deleteRowsEventV1      = 0x19
+	writeRowsEventV2       = 0x1e
+	updateRowsEventV2      = 0x1f
+	deleteRowsEventV2      = 0x20
+	gtidEvent              = 0x21
+	partialUpdateRowsEvent = 0x27
+
+	binlogEventHeaderSize = 19
+	binlogChecksumCRC32   = 1
+)
+
+// MySQL column types as written in table map events
+const (
+	mysqlTypeDecimal    = 0x00
+	mysqlTypeTiny       = 0x01
+	mysqlTypeShort      = 0x02
+	mysqlTypeLong       = 0x03
+	mysqlTypeFloat      = 0x04
+	mysqlTypeDouble     = 0x05
+	mysqlTypeNull       = 0x06
+	mysqlTypeTimestamp  = 0x07
+	mysqlTypeLongLong   = 0x08
+	mysqlTypeInt24      = 0x09
+	mysqlTypeDate       = 0x0a
+	mysqlTypeTime       = 0x0b
+	mysqlTypeDateTime   = 0x0c
+	mysqlTypeYear       = 0x0d
+	mysqlTypeNewDate    = 0x0e
+	mysqlTypeVarchar    = 0x0f
+	mysqlTypeBit        = 0x10
+	mysqlTypeTimestamp2 = 0x11
+	mysqlTypeDateTime2  = 0x12
+	mysqlTypeTime2      = 0x13
+	mysqlTypeJSON       = 0xf5
+	mysqlTypeNewDecimal = 0xf6
+	mysqlTypeEnum       = 0xf7
+	mysqlTypeSet        = 0xf8
+	mysqlTypeTinyBlob   = 0xf9
+	mysqlTypeMediumBlob = 0xfa
+	mysqlTypeLongBlob   = 0xfb
+	mysqlTypeBlob       = 0xfc
+	mysqlTypeVarString  = 0xfd
+	mysqlTypeString     = 0xfe
+	mysqlTypeGeometry   = 0xff
+)
+
+// common header of every binlog event
+type binlogEventHeader struct {
+	Timestamp uint32
+	Type      byte
+	ServerID  uint32
+	EventSize uint32
+	LogPos    uint32 //offset of the next event in the binlog file, 0 for events generated by the dump itself
+	Flags     uint16
+}
+
+// splitting an event into its header and body, without the trailing checksum
+func parseBinlogEvent(event []byte, checksum bool) (binlogEventHeader, []byte, error) {
+	if len(event) < binlogEventHeaderSize {
+		return binlogEventHeader{}, nil, fmt.Errorf("binlog event of %d bytes is shorter than its header", len(event))
+	}
+	header := binlogEventHeader{
+		Timestamp: binary.LittleEndian.Uint32(event[0:4]),
+		Type:      event[4],
+		ServerID:  binary.LittleEndian.Uint32(event[5:9]),
+		EventSize: binary.LittleEndian.Uint32(event[9:13]),
+		LogPos:    binary.LittleEndian.Uint32(event[13:17]),
+		Flags:     binary.LittleEndian.Uint16(event[17:19]),
+	}
+	body := event[binlogEventHeaderSize:]
+	//the format description carries the checksum algorithm itself and is always checksummed by servers that support it
+	if checksum && header.Type != formatDescriptionEvent {
+		if len(body) < 4 {
+			return header, nil, fmt.Errorf("binlog event of type %d is too short for its checksum", header.Type)
+		}
+		body = body[:len(body)-4]
+	}
+	return header, body, nil
+}
+
+// reading whether the events following a format description end with a CRC32 checksum
+func parseFormatDescription(body []byte) (bool, error) {
+	//binlog version, server version, create timestamp and header length precede the post header lengths
+	if len(body) < 2+50+4+1 {
+		return false, fmt.Errorf("malformed format description event")
+	}
+	//servers since 5.6.1 end the event with the checksum algorithm and the checksum of the event
+	if len(body) < 2+50+4+1+5 {
+		return false, nil
+	}
+	return body[len(body)-5] == binlogChecksumCRC32, nil
+}
+
+// reading the next binlog file and offset from a rotate event
+func parseRotate(body []byte) (BinlogPosition, error) {
+	if len(body) < 8 {
+		return BinlogPosition{}, fmt.Errorf("malformed rotate event")
+	}
+	return BinlogPosition{
+		File:   string(body[8:]),
+		Offset: uint32(binary.LittleEndian.Uint64(body[0:8])),
+	}, nil
+}
+
+// reading the source server uuid and transaction number from a GTID event
+func parseGTID(body []byte) (string, int64, error) {
+	if len(body) < 1+16+8 {
+		return "", 0, fmt.Errorf("malformed GTID event")
+	}
+	return formatServerUUID(body[1:17]), int64(binary.LittleEndian.Uint64(body[17:25])), nil
+}
+
+// reading the statement of a query event, BEGIN, COMMIT or DDL in row based replication
+func parseQuery(body []byte) (schema, query string, err error) {
+	//thread id, execution time, schema length, error code and status variables length
+	if len(body) < 13 {
+		return "", "", fmt.Errorf("malformed query event")
+	}
+	schemaLength := int(body[8])
+	statusLength := int(binary.LittleEndian.Uint16(body[11:13]))
+	start := 13 + statusLength
+	if len(body) < start+schemaLength+1 {
+		return "", "", fmt.Errorf("malformed query event")
+	}
+	schema = string(body[start : start+schemaLength])
+	query = string(body[start+schemaLength+1:])
+	return schema, query, nil
+}
+
+// column types and metadata of a table, announced before the row events of each statement
+type binlogTableMap struct {
+	TableID  uint64
+	Schema   string
+	Table    string
+	Types    []byte
+	Metadata []uint16
+}
+
+// parsing a table map event
+func parseTableMap(body []byte) (*binlogTableMap, error) {
+	r := &binlogReader{data: body}
+	tableMap := &binlogTableMap{TableID: r.uint(6)}
+	r.skip(2) //flags
+
+	tableMap.Schema = string(r.bytes(int(r.byte())))
+	r.skip(1)
+	tableMap.Table = string(r.bytes(int(r.byte())))
+	r.skip(1)
+
+	columnCount := int(r.lengthEncoded())
+	tableMap.Types = r.bytes(columnCount)
+	metadata := &binlogReader{data: r.bytes(int(r.lengthEncoded()))}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed table map event, %v", r.err)
+	}
+
+	tableMap.Metadata = make([]uint16, columnCount)
+	for i, columnType := range tableMap.Types {
+		switch columnType {
+		case mysqlTypeFloat, mysqlTypeDouble, mysqlTypeBlob, mysqlTypeGeometry, mysqlTypeJSON,
+			mysqlTypeTimestamp2, mysqlTypeDateTime2, mysqlTypeTime2:
+			tableMap.Metadata[i] = uint16(metadata.byte())
+		case mysqlTypeVarchar, mysqlTypeVarString:
+			tableMap.Metadata[i] = uint16(metadata.uint(2))
+		case mysqlTypeBit, mysqlTypeNewDecimal, mysqlTypeString, mysqlTypeEnum, mysqlTypeSet:
+			//stored high byte first, precision then scale for decimals, real type then length for strings
+			high := metadata.byte()
+			tableMap.Metadata[i] = uint16(high)<<8 | uint16(metadata.byte())
+		}
+	}
+	if metadata.err != nil {
+		return nil, fmt.Errorf("malformed metadata of table map event for %s.%s, %v", tableMap.Schema, tableMap.Table, metadata.err)
+	}
+	return tableMap, nil
+}
+
+// name and type details of a column, read from information_schema as row events only carry column positions
+type binlogColumn struct {
+	Name     string
+	Unsigned bool
+	Values   []string //values of an enum or set column in definition order
+	Binary   bool     //BINARY column, its values are written without the zero bytes padding them to the column length
+}
+
+// row images of a write, update or delete rows event, each row as the values of the present columns
+type binlogRows struct {
+	TableID uint64
+	Before  [][]interface{} //deleted rows, and the rows before an update
+	After   [][]interface{} //inserted rows, and the rows after an update
+	//columns present in the before and after images, all of them unless binlog_row_image is not FULL
+	BeforeColumns []bool
+	AfterColumns  []bool
+}
+
+// parsing a rows event with the column types of its table map
+func parseRowsEvent(eventType byte, body []byte, tableMap *binlogTableMap, columns []binlogColumn) (*binlogRows, error) {
+	r := &binlogReader{data: body}
+	rows := &binlogRows{TableID: r.uint(6)}
+	r.skip(2) //flags
+	if eventType == writeRowsEventV2 || eventType == updateRowsEventV2 || eventType == deleteRowsEventV2 {
+		//the extra data length includes its own two bytes
+		extra := int(r.uint(2))
+		r.skip(extra - 2)
+	}
+
+	columnCount := int(r.lengthEncoded())
+	if r.err == nil && columnCount != len(tableMap.Types) {
+		return nil, fmt.Errorf("rows event of %s.%s has %d columns, its table map has %d", tableMap.Schema, tableMap.Table, columnCount, len(tableMap.Types))
+	}
+	present := r.bitmap(columnCount)
+	update := eventType == updateRowsEventV1 || eventType == updateRowsEventV2
+	afterPresent := present
+	if update {
+		afterPresent = r.bitmap(columnCount)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed rows event for %s.%s, %v", tableMap.Schema, tableMap.Table, r.err)
+	}
+
+	switch {
+	case update:
+		rows.BeforeColumns, rows.AfterColumns = present, afterPresent
+	case eventType == writeRowsEventV1 || eventType == writeRowsEventV2:
+		rows.AfterColumns = present
+	default:
+		rows.BeforeColumns = present
+	}
+
+	for r.remaining() > 0 {
+		image, err := readRowImage(r, tableMap, columns, present)
+		if err != nil {
+			return nil, err
+		}
+		if !update {
+			if rows.AfterColumns != nil {
+				rows.After = append(rows.After, image)
+			} else {
+				rows.Before = append(rows.Before, image)
+			}
+			continue
+		}
+		after, err := readRowImage(r, tableMap, columns, afterPresent)
+		if err != nil {
+			return nil, err
+		}
+		rows.Before = append(rows.Before, image)
+		rows.After = append(rows.After, after)
+	}
+	return rows, nil
+}
+
+// reading the values of one row image, nil for columns that are NULL or not present
+func readRowImage(r *binlogReader, tableMap *binlogTableMap, columns []binlogColumn, present []bool) ([]interface{}, error) {
+	presentCount := 0
+	for _, isPresent := range present {
+		if isPresent {
+			presentCount++
+		}
+	}
+	nulls := r.bitmap(presentCount)
+
+	values := make([]interface{}, len(present))
+	nullIndex := 0
+	for i, isPresent := range present {
+		if !isPresent {
+			continue
+		}
+		isNull := nulls[nullIndex]
+		nullIndex++
+		if isNull {
+			continue
+		}
+		value, err := decodeBinlogValue(r, tableMap.Types[i], tableMap.Metadata[i], columns[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode column %s of %s.%s, %v", columns[i].Name, tableMap.Schema, tableMap.Table, err)
+		}
+		values[i] = value
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed row of %s.%s, %v", tableMap.Schema, tableMap.Table, r.err)
+	}
+	return values, nil
+}
+
+// decoding a column value into the Go type a SELECT through the MySQL driver returns, strings for text, decimal
+// and time columns, time.Time for dates and timestamps
+func decodeBinlogValue(r *binlogReader, columnType byte, metadata uint16, column binlogColumn) (interface{}, error) {
+	switch columnType {
+	case mysqlTypeTiny:
+		if column.Unsigned {
+			return int64(r.byte()), nil
+		}
+		return int64(int8(r.byte())), nil
+	case mysqlTypeShort:
+		if column.Unsigned {
+			return int64(r.uint(2)), nil
+		}
+		return int64(int16(r.uint(2))), nil
+	case mysqlTypeInt24:
+		value := int64(r.uint(3))
+		if !column.Unsigned && value&0x800000 != 0 {
+			value -= 1 << 24
+		}
+		return value, nil
+	case mysqlTypeLong:
+		if column.Unsigned {
+			return int64(r.uint(4)), nil
+		}
+		return int64(int32(r.uint(4))), nil
+	case mysqlTypeLongLong:
+		value := r.uint(8)
+		if column.Unsigned && value > math.MaxInt64 {
+			return value, nil
+		}
+		return int64(value), nil
+	case mysqlTypeFloat:
+		return float64(math.Float32frombits(uint32(r.uint(4)))), nil
+	case mysqlTypeDouble:
+		return math.Float64frombits(r.uint(8)), nil
+	case mysqlTypeYear:
+		year := int64(r.byte())
+		if year != 0 {
+			year += 1900
+		}
+		return year, nil
+	case mysqlTypeNewDecimal:
+		return decodeBinlogDecimal(r, int(metadata>>8), int(metadata&0xff))
+	case mysqlTypeDate, mysqlTypeNewDate:
+		packed := r.uint(3)
+		if packed == 0 {
+			return time.Time{}, nil
+		}
+		return time.Date(int(packed>>9), time.Month((packed>>5)&15), int(packed&31), 0, 0, 0, 0, time.UTC), nil
+	case mysqlTypeTime:
+		packed := r.uint(3)
+		return fmt.Sprintf("%02d:%02d:%02d", packed/10000, packed/100%100, packed%100), nil
+	case mysqlTypeTime2:
+		return decodeBinlogTime2(r, int(metadata)), nil
+	case mysqlTypeTimestamp:
+		return time.Unix(int64(r.uint(4)), 0).UTC(), nil
+	case mysqlTypeTimestamp2:
+		seconds := int64(r.uintBigEndian(4))
+		return time.Unix(seconds, int64(readFractionalSeconds(r, int(metadata)))*1000).UTC(), nil
+	case mysqlTypeDateTime:
+		packed := r.uint(8)
+		if packed == 0 {
+			return time.Time{}, nil
+		}
+		date, clock := packed/1000000, packed%1000000
+		return time.Date(int(date/10000), time.Month(date/100%100), int(date%100),
+			int(clock/10000), int(clock/100%100), int(clock%100), 0, time.UTC), nil
+	case mysqlTypeDateTime2:
+		return decodeBinlogDateTime2(r, int(metadata)), nil
+	case mysqlTypeBit:
+		//the metadata holds the bits of the partial byte, then the full bytes
+		length := int(metadata&0xff) + (int(metadata>>8)+7)/8
+		return int64(r.uintBigEndian(length)), nil
+	case mysqlTypeVarchar, mysqlTypeVarString:
+		lengthBytes := 1
+		if metadata > 255 {
+			lengthBytes = 2
+		}
+		return string(r.bytes(int(r.uint(lengthBytes)))), nil
+	case mysqlTypeBlob, mysqlTypeGeometry:
+		return string(r.bytes(int(r.uint(int(metadata))))), nil
+	case mysqlTypeJSON:
+		data := r.bytes(int(r.uint(int(metadata))))
+		if r.err != nil {
+			return nil, r.err
+		}
+		return decodeBinlogJSON(data)
+	case mysqlTypeString, mysqlTypeEnum, mysqlTypeSet:
+		return decodeBinlogString(r, metadata, column)
+	case mysqlTypeNull:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported column type %d", columnType)
+	}
+}
+
+// decoding a CHAR, BINARY, ENUM or SET value, the real type and length are packed into the metadata
+func decodeBinlogString(r *binlogReader, metadata uint16, column binlogColumn) (interface{}, error) {
+	realType, length := byte(metadata>>8), int(metadata&0xff)
+	//lengths above 255 keep their high bits in the unused bits of the real type
+	if realType&0x30 != 0x30 {
+		length |= int((realType&0x30)^0x30) << 4
+		realType |= 0x30
+	}
+
+	switch realType {
+	case mysqlTypeEnum:
+		index := int(r.uint(length))
+		if index == 0 {
+			return "", nil
+		}
+		if index <= len(column.Values) {
+			return column.Values[index-1], nil
+		}
+		return int64(index), nil
+	case mysqlTypeSet:
+		bits := r.uint(length)
+		if column.Values == nil {
+			return int64(bits), nil
+		}
+		var members []string
+		for i, value := range column.Values {
+			if bits&(1<<uint(i)) != 0 {
+				members = append(members, value)
+			}
+		}
+		return strings.Join(members, ","), nil
+	default:
+		lengthBytes := 1
+		if length > 255 {
+			lengthBytes = 2
+		}
+		value := r.bytes(int(r.uint(lengthBytes)))
+		if column.Binary && len(value) < length {
+			//padding like a SELECT returns the value
+			value = append(append([]byte{}, value...), make([]byte, length-len(value))...)
+		}
+		return string(value), nil
+	}
+}
+
+// bytes used by 0 to 9 decimal digits packed into binary
+var decimalDigitBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decoding a DECIMAL value into its string form, digits are packed nine to four bytes in big endian
+// and negative values are stored inverted
+func decodeBinlogDecimal(r *binlogReader, precision, scale int) (string, error) {
+	integral := precision - scale
+	fullIntegral, partialIntegral := integral/9, integral%9
+	fullFractional, partialFractional := scale/9, scale%9
+	size := fullIntegral*4 + decimalDigitBytes[partialIntegral] + fullFractional*4 + decimalDigitBytes[partialFractional]
+
+	data := append([]byte{}, r.bytes(size)...)
+	if r.err != nil || size == 0 {
+		return "", fmt.Errorf("malformed decimal(%d,%d)", precision, scale)
+	}
+	negative := data[0]&0x80 == 0
+	data[0] ^= 0x80
+	if negative {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+	}
+
+	digits := &binlogReader{data: data}
+	var integer strings.Builder
+	if partialIntegral > 0 {
+		integer.WriteString(strconv.FormatUint(digits.uintBigEndian(decimalDigitBytes[partialIntegral]), 10))
+	}
+	for i := 0; i < fullIntegral; i++ {
+		fmt.Fprintf(&integer, "%09d", digits.uintBigEndian(4))
+	}
+
+	var value strings.Builder
+	if negative {
+		value.WriteByte('-')
+	}
+	trimmed := strings.TrimLeft(integer.String(), "0")
+	if trimmed == "" {
+		trimmed = "0"
+	}
+	value.WriteString(trimmed)
+	if scale > 0 {
+		value.WriteByte('.')
+		for i := 0; i < fullFractional; i++ {
+			fmt.Fprintf(&value, "%09d", digits.uintBigEndian(4))
+		}
+		if partialFractional > 0 {
+			fmt.Fprintf(&value, "%0*d", partialFractional, digits.uintBigEndian(decimalDigitBytes[partialFractional]))
+		}
+	}
+	return value.String(), nil
+}
+
+// reading the fractional seconds stored after TIME2, DATETIME2 and TIMESTAMP2 values as microseconds
+func readFractionalSeconds(r *binlogReader, precision int) int {
+	switch precision {
+	case 1, 2:
+		return int(r.byte()) * 10000
+	case 3, 4:
+		return int(r.uintBigEndian(2)) * 100
+	case 5, 6:
+		return int(r.uintBigEndian(3))
+	default:
+		return 0
+	}
+}
+
+// decoding a DATETIME2 value, year and month are packed together as year*13+month
+func decodeBinlogDateTime2(r *binlogReader, precision int) time.Time {
+	packed := int64(r.uintBigEndian(5)) - 0x8000000000
+	micros := readFractionalSeconds(r, precision)
+
+	date, clock := packed>>17, packed%(1<<17)
+	if packed == 0 && micros == 0 {
+		//zero dates like 0000-00-00 00:00:00, which the MySQL driver also returns as the zero time
+		return time.Time{}
+	}
+	yearMonth := date >> 5
+	return time.Date(int(yearMonth/13), time.Month(yearMonth%13), int(date%(1<<5)),
+		int(clock>>12), int((clock>>6)%(1<<6)), int(clock%(1<<6)), micros*1000, time.UTC)
+}
+
+// decoding a TIME2 value into its string form, it may be negative and exceed 24 hours
+func decodeBinlogTime2(r *binlogReader, precision int) string {
+	//the integer part and fraction are stored as one signed value offset to be positive
+	var packed int64
+	switch precision {
+	case 1, 2:
+		integer := int64(r.uintBigEndian(3)) - 0x800000
+		fraction := int64(r.byte())
+		if integer < 0 && fraction != 0 {
+			integer++
+			fraction -= 0x100
+		}
+		packed = integer<<24 + fraction*10000
+	case 3, 4:
+		integer := int64(r.uintBigEndian(3)) - 0x800000
+		fraction := int64(r.uintBigEndian(2))
+		if integer < 0 && fraction != 0 {
+			integer++
+			fraction -= 0x10000
+		}
+		packed = integer<<24 + fraction*100
+	case 5, 6:
+		packed = int64(r.uintBigEndian(6)) - 0x800000000000
+	default:
+		packed = (int64(r.uintBigEndian(3)) - 0x800000) << 24
+	}
+
+	sign := ""
+	if packed < 0 {
+		sign, packed = "-", -packed
+	}
+	clock, micros := packed>>24, packed%(1<<24)
+	formatted := fmt.Sprintf("%s%02d:%02d:%02d", sign, (clock>>12)%(1<<10), (clock>>6)%(1<<6), clock%(1<<6))
+	if precision > 0 && precision <= 6 {
+		//showing as many fractional digits as the column has, like a SELECT
+		formatted += fmt.Sprintf(".%0*d", precision, micros/int64(math.Pow10(6-precision)))
+	}
+	return formatted
+}
+
+// types of the values in MySQL's binary JSON format
+const (
+	jsonSmallObject = 0x00
+	jsonLargeObject = 0x01
+	jsonSmallArray  = 0x02
+	jsonLargeArray  = 0x03
+	jsonLiteral     = 0x04
+	jsonInt16       = 0x05
+	jsonUint16      = 0x06
+	jsonInt32       = 0x07
+	jsonUint32      = 0x08
+	jsonInt64       = 0x09
+	jsonUint64      = 0x0a
+	jsonDouble      = 0x0b
+	jsonString      = 0x0c
+	jsonOpaque      = 0x0f
+)
+
+// decoding a JSON column into its text form, like a SELECT returns it
+func decodeBinlogJSON(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "null", nil
+	}
+	value, err := decodeJSONValue(data[0], data[1:])
+	if err != nil {
+		return "", fmt.Errorf("malformed JSON value, %v", err)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// decoding a binary JSON value of the given type into Go maps, slices and scalars
+func decodeJSONValue(valueType byte, data []byte) (interface{}, error) {
+	switch valueType {
+	case jsonSmallObject, jsonLargeObject, jsonSmallArray, jsonLargeArray:
+		large := valueType == jsonLargeObject || valueType == jsonLargeArray
+		return decodeJSONContainer(data, valueType <= jsonLargeObject, large)
+	case jsonLiteral:
+		if len(data) < 1 {
+			return nil, fmt.Errorf("truncated literal")
+		}
+		switch data[0] {
+		case 0x00:
+			return nil, nil
+		case 0x01:
+			return true, nil
+		case 0x02:
+			return false, nil
+		}
+		return nil, fmt.Errorf("unknown literal %d", data[0])
+	}
+
+	r := &binlogReader{data: data}
+	var value interface{}
+	switch valueType {
+	case jsonInt16:
+		value = int16(r.uint(2))
+	case jsonUint16:
+		value = uint16(r.uint(2))
+	case jsonInt32:
+		value = int32(r.uint(4))
+	case jsonUint32:
+		value = uint32(r.uint(4))
+	case jsonInt64:
+		value = int64(r.uint(8))
+	case jsonUint64:
+		value = r.uint(8)
+	case jsonDouble:
+		value = math.Float64frombits(r.uint(8))
+	case jsonString:
+		value = string(r.bytes(int(r.jsonLength())))
+	case jsonOpaque:
+		columnType := r.byte()
+		opaque := r.bytes(int(r.jsonLength()))
+		if r.err != nil {
+			return nil, r.err
+		}
+		if columnType == mysqlTypeNewDecimal && len(opaque) >= 2 {
+			decimal, err := decodeBinlogDecimal(&binlogReader{data: opaque[2:]}, int(opaque[0]), int(opaque[1]))
+			if err != nil {
+				return nil, err
+			}
+			return json.Number(decimal), nil
+		}
+		//other opaque values like dates keep MySQL's notation for values it cannot show as JSON
+		value = fmt.Sprintf("base64:type%d:%s", columnType, base64.StdEncoding.EncodeToString(opaque))
+	default:
+		return nil, fmt.Errorf("unknown JSON type %d", valueType)
+	}
+	return value, r.err
+}
+
+// decoding a JSON object or array, entries refer to their keys and values by offsets from the container start
+func decodeJSONContainer(data []byte, object, large bool) (interface{}, error) {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	r := &binlogReader{data: data}
+	count := int(r.uint(offsetSize))
+	size := int(r.uint(offsetSize))
+	if r.err != nil || size > len(data) {
+		return nil, fmt.Errorf("truncated container")
+	}
+
+	keys := make([]string, count)
+	if object {
+		for i := range keys {
+			offset, length := int(r.uint(offsetSize)), int(r.uint(2))
+			if offset+length > size {
+				return nil, fmt.Errorf("key out of bounds")
+			}
+			keys[i] = string(data[offset : offset+length])
+		}
+	}
+
+	values := make([]interface{}, count)
+	for i := range values {
+		valueType := r.byte()
+		//literals and 16 bit integers, and 32 bit integers of large containers, are stored inline
+		inline := valueType == jsonLiteral || valueType == jsonInt16 || valueType == jsonUint16 ||
+			(large && (valueType == jsonInt32 || valueType == jsonUint32))
+		entry := r.bytes(offsetSize)
+		if r.err != nil {
+			return nil, r.err
+		}
+		var err error
+		if inline {
+			values[i], err = decodeJSONValue(valueType, entry)
+		} else {
+			offset := int((&binlogReader{data: entry}).uint(offsetSize))
+			if offset >= size {
+				return nil, fmt.Errorf("value out of bounds")
+			}
+			values[i], err = decodeJSONValue(valueType, data[offset:size])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !object {
+		return values, nil
+	}
+	decoded := make(map[string]interface{}, count)
+	for i, key := range keys {
+		decoded[key] = values[i]
+	}
+	return decoded, nil
+}
+
+// parsing the values of an enum or set column from its COLUMN_TYPE like enum('a','b')
+func parseEnumValues(columnType string) []string {
+	open, close := strings.IndexByte(columnType, '('), strings.LastIndexByte(columnType, ')')
+	if open < 0 || close < open {
+		return nil
+	}
+	var values []string
+	var current bytes.Buffer
+	quoted := false
+	definition := columnType[open+1 : close]
+	for i := 0; i < len(definition); i++ {
+		c := definition[i]
+		switch {
+		case c == '\'' && quoted && i+1 < len(definition) && definition[i+1] == '\'':
+			//quotes inside a value are doubled
+			current.WriteByte('\'')
+			i++
+		case c == '\'':
+			quoted = !quoted
+			if !quoted {
+				values = append(values, current.String())
+				current.Reset()
+			}
+		case quoted:
+			current.WriteByte(c)
+		}
+	}
+	return values
+}
+
+// reader over the little endian fields of a binlog event, the first error sticks and later reads return zeros
+type binlogReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *binlogReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *binlogReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("unexpected end of event data")
+		return nil
+	}
+	value := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return value
+}
+
+func (r *binlogReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *binlogReader) byte() byte {
+	if value := r.bytes(1); value != nil {
+		return value[0]
+	}
+	return 0
+}
+
+// reading an unsigned little endian integer of up to 8 bytes
+func (r *binlogReader) uint(n int) uint64 {
+	var value uint64
+	for i, b := range r.bytes(n) {
+		value |= uint64(b) << (8 * uint(i))
+	}
+	return value
+}
+
+// reading an unsigned big endian integer of up to 8 bytes
+func (r *binlogReader) uintBigEndian(n int) uint64 {
+	var value uint64
+	for _, b := range r.bytes(n) {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+// reading a length encoded integer of the client/server protocol
+func (r *binlogReader) lengthEncoded() uint64 {
+	first := r.byte()
+	switch first {
+	case 0xfc:
+		return r.uint(2)
+	case 0xfd:
+		return r.uint(3)
+	case 0xfe:
+		return r.uint(8)
+	default:
+		return uint64(first)
+	}
+}
+
+// reading the variable length integer of binary JSON, 7 bits per byte with the high bit marking more bytes
+func (r *binlogReader) jsonLength() uint64 {
+	var length uint64
+	for shift := uint(0); shift < 35; shift += 7 {
+		b := r.byte()
+		length |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return length
+}
+
+// reading a bitmap of n bits, least significant bit first
+func (r *binlogReader) bitmap(n int) []bool {
+	data := r.bytes((n + 7) / 8)
+	if data == nil && n > 0 {
+		return make([]bool, n)
+	}
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = data[i/8]&(1<<uint(i%8)) != 0
+	}
+	return bits
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// position in the binlog of a MySQL server, a file offset or, when GTIDs are enabled, the set of executed GTIDs
+type BinlogPosition struct {
+	File    string
+	Offset  uint32
+	GTIDSet string
+}
+
+// formatting the position as "binlog.000042:1337", or as "gtid:<executed set>" when it has a GTID set
+func (bp BinlogPosition) String() string {
+	if bp.GTIDSet != "" {
+		return "gtid:" + bp.GTIDSet
+	}
+	return fmt.Sprintf("%s:%d", bp.File, bp.Offset)
+}
+
+// parsing a position formatted by String
+func ParseBinlogPosition(position string) (BinlogPosition, error) {
+	position = strings.TrimSpace(position)
+	if set, ok := strings.CutPrefix(position, "gtid:"); ok {
+		parsed, err := parseGTIDSet(set)
+		if err != nil {
+			return BinlogPosition{}, err
+		}
+		return BinlogPosition{GTIDSet: parsed.String()}, nil
+	}
+
+	separator := strings.LastIndex(position, ":")
+	if separator <= 0 {
+		return BinlogPosition{}, fmt.Errorf("invalid binlog position %q, expected file:offset or gtid:<set>", position)
+	}
+	offset, err := strconv.ParseUint(position[separator+1:], 10, 32)
+	if err != nil {
+		return BinlogPosition{}, fmt.Errorf("invalid offset in binlog position %q, %v", position, err)
+	}
+	return BinlogPosition{File: position[:separator], Offset: uint32(offset)}, nil
+}
+
+// reporting whether the position is at or past target. A GTID set has to contain every transaction of the
+// target set, a file offset has to be in a later file or at a later offset of the same file
+func (bp BinlogPosition) Reached(target BinlogPosition) (bool, error) {
+	if (bp.GTIDSet == "") != (target.GTIDSet == "") {
+		return false, fmt.Errorf("cannot compare binlog positions %s and %s", bp, target)
+	}
+	if target.GTIDSet != "" {
+		executed, err := parseGTIDSet(bp.GTIDSet)
+		if err != nil {
+			return false, err
+		}
+		wanted, err := parseGTIDSet(target.GTIDSet)
+		if err != nil {
+			return false, err
+		}
+		return executed.contains(wanted), nil
+	}
+
+	if bp.File != target.File {
+		return binlogFileNumber(bp.File) > binlogFileNumber(target.File), nil
+	}
+	return bp.Offset >= target.Offset, nil
+}
+
+// returning the sequence number of a binlog file like binlog.000042, -1 for a name without one
+func binlogFileNumber(file string) int64 {
+	number, err := strconv.ParseInt(file[strings.LastIndex(file, ".")+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return number
+}
+
+// range of transaction numbers of a source server, both ends included
+type gtidInterval struct {
+	start, end int64
+}
+
+// executed GTIDs, the transaction number intervals of each source server uuid
+type gtidSet map[string][]gtidInterval
+
+// parsing a GTID set like "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,..."
+func parseGTIDSet(set string) (gtidSet, error) {
+	parsed := make(gtidSet)
+	for _, part := range strings.Split(set, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		uuid := strings.ToLower(fields[0])
+		if sid, err := hex.DecodeString(strings.ReplaceAll(uuid, "-", "")); err != nil || len(sid) != 16 || len(fields) < 2 {
+			return nil, fmt.Errorf("invalid GTID set %q", set)
+		}
+		for _, field := range fields[1:] {
+			bounds := strings.SplitN(field, "-", 2)
+			start, err := strconv.ParseInt(bounds[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GTID interval %q, %v", field, err)
+			}
+			end := start
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid GTID interval %q, %v", field, err)
+				}
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid GTID interval %q", field)
+			}
+			parsed.addInterval(uuid, gtidInterval{start, end})
+		}
+	}
+	return parsed, nil
+}
+
+// adding a single executed transaction
+func (gs gtidSet) add(uuid string, gno int64) {
+	gs.addInterval(strings.ToLower(uuid), gtidInterval{gno, gno})
+}
+
+// adding an interval, merging it with the intervals it overlaps or touches
+func (gs gtidSet) addInterval(uuid string, interval gtidInterval) {
+	intervals := append(gs[uuid], interval)
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+
+	merged := intervals[:1]
+	for _, next := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if next.start <= last.end+1 {
+			if next.end > last.end {
+				last.end = next.end
+			}
+			continue
+		}
+		merged = append(merged, next)
+	}
+	gs[uuid] = merged
+}
+
+// formatting the set like MySQL does, uuids sorted
+func (gs gtidSet) String() string {
+	uuids := make([]string, 0, len(gs))
+	for uuid := range gs {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	parts := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		var b strings.Builder
+		b.WriteString(uuid)
+		for _, interval := range gs[uuid] {
+			if interval.start == interval.end {
+				fmt.Fprintf(&b, ":%d", interval.start)
+			} else {
+				fmt.Fprintf(&b, ":%d-%d", interval.start, interval.end)
+			}
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// checking that every transaction of other is in the set, the intervals of a set are merged so each interval of
+// other has to lie within a single one
+func (gs gtidSet) contains(other gtidSet) bool {
+	for uuid, intervals := range other {
+		for _, interval := range intervals {
+			found := false
+			for _, executed := range gs[uuid] {
+				if executed.start <= interval.start && interval.end <= executed.end {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// encoding the set for COM_BINLOG_DUMP_GTID, interval ends are exclusive on the wire
+func (gs gtidSet) encode() []byte {
+	uuids := make([]string, 0, len(gs))
+	for uuid := range gs {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, uint64(len(uuids)))
+	for _, uuid := range uuids {
+		sid, _ := hex.DecodeString(strings.ReplaceAll(uuid, "-", ""))
+		data.Write(sid)
+		binary.Write(&data, binary.LittleEndian, uint64(len(gs[uuid])))
+		for _, interval := range gs[uuid] {
+			binary.Write(&data, binary.LittleEndian, interval.start)
+			binary.Write(&data, binary.LittleEndian, interval.end+1)
+		}
+	}
+	return data.Bytes()
+}
+
+// formatting the 16 bytes of a server uuid
+func formatServerUUID(sid []byte) string {
+	encoded := hex.EncodeToString(sid)
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:32]
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseBinlogPosition(t *testing.T) {
+	position, err := ParseBinlogPosition("binlog.000042:1337")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if position.File != "binlog.000042" || position.Offset != 1337 || position.String() != "binlog.000042:1337" {
+		t.Errorf("unexpected position %+v", position)
+	}
+
+	position, err = ParseBinlogPosition("gtid:3E11FA47-71CA-11E1-9E33-C80AA9429562:6-9:1-5,00000000-0000-0000-0000-000000000001:3")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	//intervals are merged and uuids sorted like MySQL formats them
+	expected := "gtid:00000000-0000-0000-0000-000000000001:3,3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9"
+	if position.String() != expected {
+		t.Errorf("expected %s, got %s", expected, position.String())
+	}
+
+	for _, invalid := range []string{"", "binlog.000042", "binlog.000042:x", "gtid:not-a-uuid:1", "gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:5-3"} {
+		if _, err := ParseBinlogPosition(invalid); err == nil {
+			t.Errorf("expected an error for position %q", invalid)
+		}
+	}
+}
+
+func TestGTIDSetAdd(t *testing.T) {
+	set, err := parseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	set.add("3E11FA47-71CA-11E1-9E33-C80AA9429562", 6)
+	set.add("3e11fa47-71ca-11e1-9e33-c80aa9429562", 9)
+	if set.String() != "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7:9" {
+		t.Errorf("unexpected set %s", set.String())
+	}
+}
+
+func TestGTIDSetEncode(t *testing.T) {
+	set, err := parseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	//one sid with one interval, the end is exclusive
+	expected := "0100000000000000" + "3e11fa4771ca11e19e33c80aa9429562" + "0100000000000000" + "0100000000000000" + "0600000000000000"
+	if encoded := hex.EncodeToString(set.encode()); encoded != expected {
+		t.Errorf("expected %s, got %s", expected, encoded)
+	}
+}
+
+func TestBinlogPositionReached(t *testing.T) {
+	cases := []struct {
+		position, target string
+		reached          bool
+	}{
+		{"binlog.000042:1337", "binlog.000042:1337", true},
+		{"binlog.000042:1000", "binlog.000042:1337", false},
+		{"binlog.000043:4", "binlog.000042:1337", true},
+		{"binlog.000042:2000", "binlog.000043:4", false},
+		{"gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9", "gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7", true},
+		{"gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7", "gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7", false},
+		{"gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9", "gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9,00000000-0000-0000-0000-000000000001:3", false},
+	}
+	for _, c := range cases {
+		position, _ := ParseBinlogPosition(c.position)
+		target, _ := ParseBinlogPosition(c.target)
+		reached, err := position.Reached(target)
+		if err != nil || reached != c.reached {
+			t.Errorf("expected %s reaching %s to be %v, got %v, %v", c.position, c.target, c.reached, reached, err)
+		}
+	}
+
+	position, _ := ParseBinlogPosition("binlog.000042:1337")
+	target, _ := ParseBinlogPosition("gtid:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9")
+	if _, err := position.Reached(target); err == nil {
+		t.Error("expected an error comparing a file offset with a GTID set")
+	}
+}
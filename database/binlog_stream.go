@@ -0,0 +1,431 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// returning the current binlog position, the executed GTID set when GTIDs are enabled, otherwise the
+// file and offset of the binary log status
+func (c *MySQLClient) CurrentChangePosition(ctx context.Context, tables []string) (string, error) {
+	if c.DB == nil {
+		return "", fmt.Errorf("db connection not established")
+	}
+	ctx, cancel := operationContext(ctx, c.Timeouts.Query)
+	defer cancel()
+
+	var gtidMode string
+	if err := c.DB.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_mode").Scan(&gtidMode); err == nil && strings.EqualFold(gtidMode, "ON") {
+		var executed string
+		if err := c.DB.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+			return "", fmt.Errorf("failed to read the executed GTID set, %v", err)
+		}
+		//gtid_executed is wrapped over several lines for many source servers
+		executed = strings.ReplaceAll(executed, "\n", "")
+		parsed, err := parseGTIDSet(executed)
+		if err != nil {
+			return "", err
+		}
+		return BinlogPosition{GTIDSet: parsed.String()}.String(), nil
+	}
+
+	//MySQL 8.4 removed SHOW MASTER STATUS in favour of SHOW BINARY LOG STATUS
+	position, err := c.binaryLogStatus(ctx, "SHOW BINARY LOG STATUS")
+	if err != nil {
+		position, err = c.binaryLogStatus(ctx, "SHOW MASTER STATUS")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the binlog position, %v", err)
+	}
+	return position.String(), nil
+}
+
+// reading the file and offset of the current binlog from a status statement
+func (c *MySQLClient) binaryLogStatus(ctx context.Context, statement string) (BinlogPosition, error) {
+	rows, err := c.DB.QueryContext(ctx, statement)
+	if err != nil {
+		return BinlogPosition{}, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return BinlogPosition{}, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return BinlogPosition{}, err
+		}
+		return BinlogPosition{}, fmt.Errorf("binary logging is disabled, enable log_bin to capture changes")
+	}
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(sql.RawBytes)
+	}
+	if err := rows.Scan(values...); err != nil {
+		return BinlogPosition{}, err
+	}
+	if len(columns) < 2 {
+		return BinlogPosition{}, fmt.Errorf("unexpected result of %s", statement)
+	}
+	return ParseBinlogPosition(string(*values[0].(*sql.RawBytes)) + ":" + string(*values[1].(*sql.RawBytes)))
+}
+
+// reporting whether the transaction at position is at or past target, both read from the same server
+func (c *MySQLClient) ChangePositionReached(position, target string) (bool, error) {
+	applied, err := ParseBinlogPosition(position)
+	if err != nil {
+		return false, err
+	}
+	wanted, err := ParseBinlogPosition(target)
+	if err != nil {
+		return false, err
+	}
+	return applied.Reached(wanted)
+}
+
+// opening a binlog stream of the changes to tables committed after position. The server must write row based
+// binlogs with full row images, binlog_format=ROW and binlog_row_image=FULL, and the user needs the
+// REPLICATION SLAVE and REPLICATION CLIENT privileges
+func (c *MySQLClient) OpenChangeStream(ctx context.Context, tables []string, position string) (ChangeStream, error) {
+	if c.DB == nil {
+		return nil, fmt.Errorf("db connection not established")
+	}
+	start, err := ParseBinlogPosition(position)
+	if err != nil {
+		return nil, err
+	}
+
+	//events sent before the first format description, like the rotate event starting the dump, are checksummed
+	//when the server checksums its binlogs
+	var checksum string
+	if err := c.DB.QueryRowContext(ctx, "SELECT @@GLOBAL.binlog_checksum").Scan(&checksum); err != nil {
+		return nil, fmt.Errorf("failed to read the binlog checksum, %v", err)
+	}
+
+	connectCtx, cancel := operationContext(ctx, c.Timeouts.Connect)
+	defer cancel()
+	conn, err := dialBinlogConn(connectCtx, c.Host, c.Port, c.User, c.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	serverID := c.ReplicaServerID
+	if serverID == 0 {
+		//a random id in a range replicas rarely use, two readers with the same id disconnect each other
+		serverID = 1<<30 + uint32(rand.Int31n(1<<30))
+	}
+
+	//asking for the checksums the server writes, and heartbeats keeping the connection alive while the source is idle
+	setup := []string{
+		"SET @master_binlog_checksum = @@GLOBAL.binlog_checksum",
+		"SET @source_binlog_checksum = @@GLOBAL.binlog_checksum",
+		fmt.Sprintf("SET @master_heartbeat_period = %d", int64(binlogHeartbeatPeriod)),
+		fmt.Sprintf("SET @source_heartbeat_period = %d", int64(binlogHeartbeatPeriod)),
+	}
+	for _, statement := range setup {
+		if err := conn.exec(statement); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := conn.registerReplica(serverID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	stream := &mysqlChangeStream{
+		client:    c,
+		conn:      conn,
+		tables:    make(map[string]string, len(tables)),
+		tableMaps: make(map[uint64]*binlogTableMap),
+		columns:   make(map[string][]binlogColumn),
+		position:  start,
+		file:      start.File,
+		checksum:  strings.EqualFold(checksum, "CRC32"),
+	}
+	for _, table := range tables {
+		stream.tables[strings.ToLower(table)] = table
+	}
+
+	if start.GTIDSet != "" {
+		executed, err := parseGTIDSet(start.GTIDSet)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		stream.executed = executed
+		err = conn.dumpFromGTIDSet(serverID, executed)
+	} else {
+		err = conn.dumpFromPosition(serverID, start.File, start.Offset)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return stream, nil
+}
+
+// interval of the heartbeats the server sends on an idle binlog stream
+const binlogHeartbeatPeriod = 30 * time.Second
+
+// change stream reading row events from the binlog of a MySQL server
+type mysqlChangeStream struct {
+	client    *MySQLClient
+	conn      *binlogConn
+	tables    map[string]string //streamed tables by lowercased name, to the name they were configured with
+	tableMaps map[uint64]*binlogTableMap
+	columns   map[string][]binlogColumn //columns of the streamed tables by configured name
+	checksum  bool
+
+	position BinlogPosition //position after the last returned transaction
+	file     string         //binlog file currently read, announced by rotate events
+	executed gtidSet        //executed GTIDs in GTID mode, nil otherwise
+	gtid     *gtidSetEntry  //GTID of the transaction being read
+	broken   error          //error that left the connection in an unknown state
+}
+
+// GTID of a transaction
+type gtidSetEntry struct {
+	uuid string
+	gno  int64
+}
+
+// reading events until the next transaction is committed
+func (s *mysqlChangeStream) Next(ctx context.Context) (*ChangeTransaction, error) {
+	if s.broken != nil {
+		return nil, s.broken
+	}
+	//interrupting the blocked read when ctx is cancelled, the connection cannot be used afterwards
+	stop := context.AfterFunc(ctx, func() {
+		s.conn.conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	transaction := &ChangeTransaction{}
+	for {
+		event, err := s.conn.readEvent()
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			} else {
+				err = fmt.Errorf("failed to read the binlog, %w", err)
+			}
+			s.broken = err
+			return nil, err
+		}
+
+		header, body, err := parseBinlogEvent(event, s.checksum)
+		if err != nil {
+			s.broken = err
+			return nil, err
+		}
+		committed, err := s.handleEvent(header, body, transaction)
+		if err != nil {
+			s.broken = err
+			return nil, err
+		}
+		if committed {
+			return transaction, nil
+		}
+	}
+}
+
+// applying an event to the transaction being read, returns true once the transaction is committed
+func (s *mysqlChangeStream) handleEvent(header binlogEventHeader, body []byte, transaction *ChangeTransaction) (bool, error) {
+	switch header.Type {
+	case formatDescriptionEvent:
+		checksum, err := parseFormatDescription(body)
+		if err != nil {
+			return false, err
+		}
+		s.checksum = checksum
+	case rotateEvent:
+		next, err := parseRotate(body)
+		if err != nil {
+			return false, err
+		}
+		s.file = next.File
+		if s.executed == nil {
+			s.position = next
+		}
+	case gtidEvent:
+		uuid, gno, err := parseGTID(body)
+		if err != nil {
+			return false, err
+		}
+		s.gtid = &gtidSetEntry{uuid: uuid, gno: gno}
+	case tableMapEvent:
+		tableMap, err := parseTableMap(body)
+		if err != nil {
+			return false, err
+		}
+		s.tableMaps[tableMap.TableID] = tableMap
+	case writeRowsEventV1, updateRowsEventV1, deleteRowsEventV1, writeRowsEventV2, updateRowsEventV2, deleteRowsEventV2:
+		changes, err := s.rowChanges(header.Type, body)
+		if err != nil {
+			return false, err
+		}
+		transaction.Changes = append(transaction.Changes, changes...)
+	case partialUpdateRowsEvent:
+		return false, fmt.Errorf("partial JSON updates cannot be captured, set binlog_row_value_options to an empty value")
+	case xidEvent:
+		s.commit(header, transaction)
+		return true, nil
+	case queryEvent:
+		_, query, err := parseQuery(body)
+		if err != nil {
+			return false, err
+		}
+		query = strings.TrimSpace(query)
+		if strings.EqualFold(query, "BEGIN") {
+			return false, nil
+		}
+		//COMMIT ends transactions on non-transactional tables, other statements are DDL committed on their own
+		//and may change the columns of the streamed tables
+		if !strings.EqualFold(query, "COMMIT") {
+			s.columns = make(map[string][]binlogColumn)
+		}
+		s.commit(header, transaction)
+		return true, nil
+	}
+	return false, nil
+}
+
+// recording the position after a committed transaction
+func (s *mysqlChangeStream) commit(header binlogEventHeader, transaction *ChangeTransaction) {
+	if header.LogPos > 0 {
+		s.position = BinlogPosition{File: s.file, Offset: header.LogPos}
+	}
+	if s.executed != nil {
+		if s.gtid != nil {
+			s.executed.add(s.gtid.uuid, s.gtid.gno)
+		}
+		s.position = BinlogPosition{GTIDSet: s.executed.String()}
+	}
+	s.gtid = nil
+	transaction.Position = s.position.String()
+	transaction.CommitTime = time.Unix(int64(header.Timestamp), 0)
+}
+
+// converting the rows of a rows event into row changes, rows of tables that are not streamed are skipped
+func (s *mysqlChangeStream) rowChanges(eventType byte, body []byte) ([]RowChange, error) {
+	if len(body) < 6 {
+		return nil, fmt.Errorf("malformed rows event")
+	}
+	tableID := (&binlogReader{data: body}).uint(6)
+	tableMap, ok := s.tableMaps[tableID]
+	if !ok {
+		return nil, fmt.Errorf("rows event for unknown table id %d", tableID)
+	}
+	table, ok := s.streamedTable(tableMap)
+	if !ok {
+		return nil, nil
+	}
+
+	columns, err := s.tableColumns(table, tableMap)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := parseRowsEvent(eventType, body, tableMap, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	toMap := func(values []interface{}, present []bool) map[string]interface{} {
+		row := make(map[string]interface{}, len(values)+1)
+		for i, value := range values {
+			if present[i] {
+				row[columns[i].Name] = value
+			}
+		}
+		row["_source_table"] = table
+		return row
+	}
+
+	var changes []RowChange
+	switch eventType {
+	case writeRowsEventV1, writeRowsEventV2:
+		for _, values := range rows.After {
+			changes = append(changes, RowChange{Operation: ChangeInsert, Table: table, Row: toMap(values, rows.AfterColumns)})
+		}
+	case updateRowsEventV1, updateRowsEventV2:
+		for i, values := range rows.After {
+			changes = append(changes, RowChange{
+				Operation: ChangeUpdate,
+				Table:     table,
+				Row:       toMap(values, rows.AfterColumns),
+				Before:    toMap(rows.Before[i], rows.BeforeColumns),
+			})
+		}
+	default:
+		for _, values := range rows.Before {
+			changes = append(changes, RowChange{Operation: ChangeDelete, Table: table, Row: toMap(values, rows.BeforeColumns)})
+		}
+	}
+	return changes, nil
+}
+
+// returning the configured name of a mapped table when it is streamed, unqualified names refer to the connected database
+func (s *mysqlChangeStream) streamedTable(tableMap *binlogTableMap) (string, bool) {
+	if table, ok := s.tables[strings.ToLower(tableMap.Schema+"."+tableMap.Table)]; ok {
+		return table, true
+	}
+	if strings.EqualFold(tableMap.Schema, s.client.DBName) {
+		table, ok := s.tables[strings.ToLower(tableMap.Table)]
+		return table, ok
+	}
+	return "", false
+}
+
+// returning the columns of a streamed table from information_schema, read again after DDL
+func (s *mysqlChangeStream) tableColumns(table string, tableMap *binlogTableMap) ([]binlogColumn, error) {
+	if columns, ok := s.columns[table]; ok && len(columns) == len(tableMap.Types) {
+		return columns, nil
+	}
+
+	query := `SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`
+	rows, err := s.client.DB.Query(query, tableMap.Schema, tableMap.Table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of table %s, %v", table, err)
+	}
+	defer rows.Close()
+
+	var columns []binlogColumn
+	for rows.Next() {
+		var name, columnType string
+		if err := rows.Scan(&name, &columnType); err != nil {
+			return nil, fmt.Errorf("failed to scan column of table %s, %v", table, err)
+		}
+		lowered := strings.ToLower(columnType)
+		column := binlogColumn{Name: name, Unsigned: strings.Contains(lowered, "unsigned"), Binary: strings.HasPrefix(lowered, "binary(")}
+		if strings.HasPrefix(lowered, "enum(") || strings.HasPrefix(lowered, "set(") {
+			column.Values = parseEnumValues(columnType)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	//the table changed again after the event was written, its columns cannot be matched by position
+	if len(columns) != len(tableMap.Types) {
+		return nil, fmt.Errorf("table %s has %d columns, its binlog events have %d", table, len(columns), len(tableMap.Types))
+	}
+	s.columns[table] = columns
+	return columns, nil
+}
+
+// the server keeps binlogs until they expire, there is nothing to acknowledge
+func (s *mysqlChangeStream) Ack(position string) error {
+	return nil
+}
+
+// closing the replication connection, the server drops the replica with it
+func (s *mysqlChangeStream) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// replication server answering the handshake, the setup statements and a binlog dump with the given events
+func serveTestBinlog(t *testing.T, listener net.Listener, events [][]byte) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	bc := &binlogConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	handshake := []byte{10}
+	handshake = append(handshake, "8.0.36\x00"...)
+	handshake = append(handshake, 1, 0, 0, 0)
+	handshake = append(handshake, "abcdefgh"...)
+	handshake = append(handshake, 0)
+	handshake = binary.LittleEndian.AppendUint16(handshake, uint16(clientProtocol41|clientSecureConnection))
+	handshake = append(handshake, utf8mb4GeneralCI, 2, 0)
+	handshake = binary.LittleEndian.AppendUint16(handshake, uint16(clientPluginAuth>>16))
+	handshake = append(handshake, 21)
+	handshake = append(handshake, make([]byte, 10)...)
+	handshake = append(handshake, "ijklmnopqrst\x00"...)
+	handshake = append(handshake, "mysql_native_password\x00"...)
+	if bc.writePacket(handshake) != nil {
+		return
+	}
+
+	ok := []byte{0, 0, 0, 2, 0, 0, 0}
+	//login, four SET statements and the replica registration
+	for i := 0; i < 6; i++ {
+		if _, err := bc.readPacket(); err != nil {
+			t.Errorf("fake server failed to read packet %d, %v", i, err)
+			return
+		}
+		if bc.writePacket(ok) != nil {
+			return
+		}
+	}
+
+	dump, err := bc.readPacket()
+	if err != nil || dump[0] != comBinlogDump {
+		t.Errorf("expected a binlog dump request, got %v, %v", dump, err)
+		return
+	}
+	for _, event := range events {
+		if bc.writePacket(append([]byte{0}, event...)) != nil {
+			return
+		}
+	}
+	//keeping the connection open like an idle server until the client disconnects
+	io.Copy(io.Discard, conn)
+}
+
+func TestMySQLChangeStream(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err)
+	}
+	defer listener.Close()
+
+	fde := binary.LittleEndian.AppendUint16(nil, 4)
+	fde = append(fde, make([]byte, 50+4)...)
+	fde = append(fde, binlogEventHeaderSize, 0, 0, binlogChecksumCRC32, 0, 0, 0, 0)
+	rotate := append(binary.LittleEndian.AppendUint64(nil, 4), "binlog.000042"...)
+	query := func(statement string) []byte {
+		body := make([]byte, 13)
+		body[8] = 4
+		body = append(body, "shop\x00"...)
+		return append(body, statement...)
+	}
+
+	events := [][]byte{
+		binlogTestEvent(rotateEvent, 0, rotate, true),
+		binlogTestEvent(formatDescriptionEvent, 0, fde, false),
+		binlogTestEvent(queryEvent, 200, query("BEGIN"), true),
+		binlogTestEvent(tableMapEvent, 250, usersTableMapBody(42), true),
+		binlogTestEvent(writeRowsEventV2, 300, usersRowsBody(42, writeRowsEventV2, usersRowImage(1, "Susheel"), usersRowImage(2, "Alex")), true),
+		binlogTestEvent(xidEvent, 331, binary.LittleEndian.AppendUint64(nil, 99), true),
+		binlogTestEvent(queryEvent, 400, query("BEGIN"), true),
+		binlogTestEvent(tableMapEvent, 450, usersTableMapBody(42), true),
+		binlogTestEvent(updateRowsEventV2, 500, usersRowsBody(42, updateRowsEventV2, usersRowImage(1, "Susheel"), usersRowImage(1, "Sathyaraj")), true),
+		binlogTestEvent(deleteRowsEventV2, 550, usersRowsBody(42, deleteRowsEventV2, usersRowImage(2, "Alex")), true),
+		binlogTestEvent(xidEvent, 581, binary.LittleEndian.AppendUint64(nil, 100), true),
+	}
+	go serveTestBinlog(t, listener, events)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT @@GLOBAL.binlog_checksum").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("CRC32"))
+	mock.ExpectQuery("(?i)SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS").WithArgs("shop", "users").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "COLUMN_TYPE"}).AddRow("id", "int").AddRow("name", "varchar(255)").AddRow("balance", "decimal(10,2)"))
+
+	address := listener.Addr().(*net.TCPAddr)
+	client := &MySQLClient{DB: db, Host: "127.0.0.1", Port: address.Port, User: "replicator", Password: "secret", DBName: "shop"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.OpenChangeStream(ctx, []string{"users"}, "binlog.000042:4")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer stream.Close()
+
+	first, err := stream.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if first.Position != "binlog.000042:331" || len(first.Changes) != 2 {
+		t.Fatalf("unexpected first transaction at %s with %d changes", first.Position, len(first.Changes))
+	}
+	insert := first.Changes[1]
+	if insert.Operation != ChangeInsert || insert.Row["id"] != int64(2) || insert.Row["name"] != "Alex" || insert.Row["_source_table"] != "users" {
+		t.Errorf("unexpected insert %+v", insert)
+	}
+
+	second, err := stream.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if second.Position != "binlog.000042:581" || len(second.Changes) != 2 {
+		t.Fatalf("unexpected second transaction at %s with %d changes", second.Position, len(second.Changes))
+	}
+	update, deleted := second.Changes[0], second.Changes[1]
+	if update.Operation != ChangeUpdate || update.Row["name"] != "Sathyaraj" || update.Before["name"] != "Susheel" {
+		t.Errorf("unexpected update %+v", update)
+	}
+	if deleted.Operation != ChangeDelete || deleted.Row["id"] != int64(2) {
+		t.Errorf("unexpected delete %+v", deleted)
+	}
+
+	//a cancelled wait for the next transaction returns the context error
+	waitCtx, stop := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer stop()
+	if _, err := stream.Next(waitCtx); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLChangeStreamDecodesColumnsByTheirDefinition(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err)
+	}
+	defer listener.Close()
+
+	fde := binary.LittleEndian.AppendUint16(nil, 4)
+	fde = append(fde, make([]byte, 50+4)...)
+	fde = append(fde, binlogEventHeaderSize, 0, 0, binlogChecksumCRC32, 0, 0, 0, 0)
+	fixture := func(hexEvent string) []byte {
+		event, _ := hex.DecodeString(hexEvent)
+		return event
+	}
+	events := [][]byte{
+		binlogTestEvent(formatDescriptionEvent, 0, fde, false),
+		fixture(allTypesTableMapEvent),
+		fixture(allTypesWriteRowsEvent),
+		binlogTestEvent(xidEvent, 1431, binary.LittleEndian.AppendUint64(nil, 7), true),
+	}
+	go serveTestBinlog(t, listener, events)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT @@GLOBAL.binlog_checksum").WillReturnRows(sqlmock.NewRows([]string{"checksum"}).AddRow("CRC32"))
+	definitions := sqlmock.NewRows([]string{"COLUMN_NAME", "COLUMN_TYPE"})
+	for _, column := range allTypesColumns() {
+		columnType := "int"
+		switch {
+		case column.Unsigned:
+			columnType = "bigint unsigned"
+		case column.Name == "c_enum":
+			columnType = "enum('new','paid','shipped')"
+		case column.Name == "c_set":
+			columnType = "set('a','b','c')"
+		case column.Binary:
+			columnType = "binary(4)"
+		}
+		definitions.AddRow(column.Name, columnType)
+	}
+	mock.ExpectQuery("(?i)SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS").WithArgs("shop", "all_types").WillReturnRows(definitions)
+
+	address := listener.Addr().(*net.TCPAddr)
+	client := &MySQLClient{DB: db, Host: "127.0.0.1", Port: address.Port, User: "replicator", Password: "secret", DBName: "shop"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.OpenChangeStream(ctx, []string{"all_types"}, "binlog.000042:4")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer stream.Close()
+
+	transaction, err := stream.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(transaction.Changes) != 2 {
+		t.Fatalf("expected two inserted rows, got %d", len(transaction.Changes))
+	}
+	//unsigned, enum, set and binary values depend on the column definitions
+	row := transaction.Changes[0].Row
+	if row["c_bigint_u"] != uint64(math.MaxUint64) || row["c_tinyint_u"] != int64(255) || row["c_enum"] != "paid" ||
+		row["c_set"] != "a,c" || row["c_binary"] != "ab\x00\x00" || row["_source_table"] != "all_types" {
+		t.Errorf("unexpected row %v", row)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
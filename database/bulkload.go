@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	//max_allowed_packet of a default MySQL server, used when the server setting could not be read
+	defaultMaxAllowedPacket = 4 << 20
+	//highest number of placeholders MySQL accepts in one prepared statement
+	mysqlMaxPlaceholders = 65535
+	//highest number of variables SQLite accepts in one statement since 3.32
+	sqliteMaxVariables = 32766
+	//upper bound of the bytes a value other than a string takes in a statement
+	fixedValueSize = 16
+)
+
+// returning the values of a row in column order
+func rowValues(row map[string]interface{}, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = row[col]
+	}
+	return values
+}
+
+// inserting rows of one table with one prepared INSERT per row
+func insertTableRows(ctx context.Context, tx *sql.Tx, tableName string, columns []string, rows []map[string]interface{}, placeholder func(i int) string) error {
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = placeholder(i + 1)
+	}
+
+	insertSQL := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES(%s)",
+		tableName,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement, %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, rowValues(row, columns)...); err != nil {
+			return fmt.Errorf("failed to insert row, %w", err)
+		}
+	}
+	return nil
+}
+
+// streaming rows of one table into PostgreSQL with COPY FROM STDIN.
+// Unquoted names are folded to lower case by PostgreSQL, COPY quotes them so they are folded here
+func copyTableRows(ctx context.Context, tx *sql.Tx, tableName string, columns []string, rows []map[string]interface{}) error {
+	copyColumns := make([]string, len(columns))
+	for i, col := range columns {
+		copyColumns[i] = strings.ToLower(col)
+	}
+	schema, name := splitQualifiedName(strings.ToLower(tableName))
+
+	var copySQL string
+	if schema != "" {
+		copySQL = pq.CopyInSchema(schema, name, copyColumns...)
+	} else {
+		copySQL = pq.CopyIn(name, copyColumns...)
+	}
+	stmt, err := tx.PrepareContext(ctx, copySQL)
+	if err != nil {
+		return fmt.Errorf("failed to start copy into table %s, %w", tableName, err)
+	}
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, rowValues(row, columns)...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy row, %w", err)
+		}
+	}
+	//an Exec without values flushes the buffered rows and ends the copy
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to copy rows into table %s, %w", tableName, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to finish copy into table %s, %w", tableName, err)
+	}
+	return nil
+}
+
+// estimating the bytes a value adds to a statement sent to the server
+func estimateValueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(len(v)) + 9
+	case []byte:
+		return int64(len(v)) + 9
+	default:
+		return fixedValueSize
+	}
+}
+
+// inserting rows of one table with multi-row INSERT statements, each kept below maxPacket bytes
+// and maxPlaceholders bound values
+func insertMultiRows(ctx context.Context, tx *sql.Tx, tableName string, columns []string, rows []map[string]interface{}, maxPacket int64, maxPlaceholders int) error {
+	if maxPacket <= 0 {
+		maxPacket = defaultMaxAllowedPacket
+	}
+	//leaving headroom for the protocol overhead not covered by the estimate
+	limit := maxPacket / 10 * 9
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", tableName, strings.Join(columns, ", "))
+	rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	maxRows := maxPlaceholders / max(1, len(columns))
+
+	var values []interface{}
+	var tuples []string
+	size := int64(len(prefix))
+	flush := func() error {
+		if len(tuples) == 0 {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, prefix+strings.Join(tuples, ", "), values...); err != nil {
+			return fmt.Errorf("failed to insert %d rows into table %s, %w", len(tuples), tableName, err)
+		}
+		values, tuples = values[:0], tuples[:0]
+		size = int64(len(prefix))
+		return nil
+	}
+
+	for _, row := range rows {
+		rowSize := int64(len(rowPlaceholders)) + 2
+		for _, col := range columns {
+			rowSize += estimateValueSize(row[col])
+		}
+		if len(tuples) > 0 && (size+rowSize > limit || len(tuples) >= maxRows) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		values = append(values, rowValues(row, columns)...)
+		tuples = append(tuples, rowPlaceholders)
+		size += rowSize
+	}
+	return flush()
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPostgreSQLImportDataWithCopy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"ID": 1, "name": "Susheel", "_source_table": "sales.Users"},
+		{"ID": 2, "name": "Sathyaraj", "_source_table": "sales.Users"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS sales.Users").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt := mock.ExpectPrepare(`^COPY "sales"\."users" \("id", "name"\) FROM STDIN$`)
+	copyStmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt.ExpectExec().WithArgs(2, "Sathyaraj").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	client := &PostgreSQLClient{DB: db, BulkLoad: true}
+	if err := client.ImportData(data); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLImportDataWithMultiRowInserts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	var data []map[string]interface{}
+	for i := 1; i <= 5; i++ {
+		data = append(data, map[string]interface{}{"id": i, "name": strings.Repeat("x", 40), "_source_table": "users"})
+	}
+
+	//every row is estimated at 73 bytes, so 90% of a 256 byte packet fits the prefix and two rows
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`^INSERT INTO users \(id, name\) VALUES \(\?, \?\), \(\?, \?\)$`).WithArgs(1, data[0]["name"], 2, data[1]["name"]).WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec(`^INSERT INTO users \(id, name\) VALUES \(\?, \?\), \(\?, \?\)$`).WithArgs(3, data[2]["name"], 4, data[3]["name"]).WillReturnResult(sqlmock.NewResult(4, 2))
+	mock.ExpectExec(`^INSERT INTO users \(id, name\) VALUES \(\?, \?\)$`).WithArgs(5, data[4]["name"]).WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+
+	client := &MySQLClient{DB: db, BulkLoad: true, MaxAllowedPacket: 256}
+	if err := client.ImportData(data); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLImportDataRowByRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+		{"id": 2, "name": "Sathyaraj", "_source_table": "users"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	insertStmt := mock.ExpectPrepare(`^INSERT INTO users \(id, name\) VALUES\(\?, \?\)$`)
+	insertStmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(1, 1))
+	insertStmt.ExpectExec().WithArgs(2, "Sathyaraj").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	client := &MySQLClient{DB: db, BulkLoad: false}
+	if err := client.ImportData(data); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+// rows of the table written by the import benchmarks
+func benchmarkRows(tableName string, count int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, count)
+	for i := range rows {
+		rows[i] = map[string]interface{}{
+			"id":            int64(i + 1),
+			"name":          fmt.Sprintf("customer %d", i+1),
+			"email":         fmt.Sprintf("customer%d@example.com", i+1),
+			"balance":       float64(i) * 1.5,
+			"_source_table": tableName,
+		}
+	}
+	return rows
+}
+
+// importing the same batch with the bulk path and with one INSERT per row, skipped without a reachable database
+func benchmarkImportData(b *testing.B, client DatabaseClient, bulkClient BulkLoadClient, dropTable func() error) {
+	if err := client.Connect(); err != nil {
+		b.Skipf("database not available, %v", err)
+	}
+	defer client.Close()
+
+	rows := benchmarkRows("bench_import_data", 1000)
+	for _, bulk := range []bool{true, false} {
+		name := "RowByRow"
+		if bulk {
+			name = "BulkLoad"
+		}
+		b.Run(name, func(b *testing.B) {
+			bulkClient.SetBulkLoad(bulk)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				if err := dropTable(); err != nil {
+					b.Fatalf("failed to drop benchmark table, %v", err)
+				}
+				b.StartTimer()
+				if err := client.ImportData(rows); err != nil {
+					b.Fatalf("failed to import rows, %v", err)
+				}
+			}
+			b.ReportMetric(float64(len(rows)*b.N)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+	dropTable()
+}
+
+func BenchmarkPostgreSQLImportData(b *testing.B) {
+	cfg := testConfig.PostgreSQL
+	client := NewPostgreSQLClient(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName)
+	benchmarkImportData(b, client, client, func() error { return client.DropTable("bench_import_data") })
+}
+
+func BenchmarkMySQLImportData(b *testing.B) {
+	cfg := testConfig.MySQL
+	client := NewMySQLClient(cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName)
+	benchmarkImportData(b, client, client, func() error { return client.DropTable("bench_import_data") })
+}
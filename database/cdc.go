@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// kind of a captured row change
+type ChangeOperation string
+
+const (
+	ChangeInsert ChangeOperation = "insert"
+	ChangeUpdate ChangeOperation = "update"
+	ChangeDelete ChangeOperation = "delete"
+)
+
+// a single captured change of a source row
+type RowChange struct {
+	Operation ChangeOperation
+	Table     string
+	//the row after an insert or update, the deleted row for a delete. Carries _source_table like the rows of FetchAllData
+	Row map[string]interface{}
+	//the row before an update when the source records it, nil otherwise
+	Before map[string]interface{}
+}
+
+// the row changes of one committed source transaction in commit order, with the source position right after its commit
+type ChangeTransaction struct {
+	Changes    []RowChange
+	Position   string
+	CommitTime time.Time
+}
+
+// reading the committed transactions of a source in commit order
+type ChangeStream interface {
+	//blocking until the next transaction is committed, returns ctx.Err() once ctx is cancelled.
+	//Transactions without changes of the streamed tables are returned with no changes, they only advance the position
+	Next(ctx context.Context) (*ChangeTransaction, error)
+	//acknowledging that the transactions up to position are applied to the target, so the source may discard them.
+	//Called while Next blocks in another goroutine
+	Ack(position string) error
+	//releasing the replication connection
+	Close() error
+}
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// definition of a secondary or unique index
+type IndexDefinition struct {
+	Name    string
+	Columns []string //indexed columns in key order
+	Unique  bool
+}
+
+// definition of a foreign key referencing the key of another table
+type ForeignKeyDefinition struct {
+	Name              string
+	Columns           []string
+	ReferencedTable   string //bare name in the default schema, schema.table otherwise
+	ReferencedColumns []string
+	OnDelete          string //referential action like CASCADE or SET NULL, empty for the database default
+	OnUpdate          string
+}
+
+// primary key, indexes and foreign keys of a table as read from the catalog
+type TableConstraints struct {
+	Table       string
+	PrimaryKey  []string
+	Indexes     []IndexDefinition
+	ForeignKeys []ForeignKeyDefinition
+}
+
+// checking if an index on the same columns and with the same uniqueness exists, names are not compared
+// as they are changed when the index is recreated in another database
+func (tc *TableConstraints) HasIndex(index IndexDefinition) bool {
+	for _, existing := range tc.Indexes {
+		if existing.Unique == index.Unique && sameColumns(existing.Columns, index.Columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// checking if the primary key or a unique index is on exactly the given columns, in any order
+func (tc *TableConstraints) HasUniqueKey(columns []string) bool {
+	if sameColumnSet(tc.PrimaryKey, columns) {
+		return true
+	}
+	for _, index := range tc.Indexes {
+		if index.Unique && sameColumnSet(index.Columns, columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// checking if a foreign key between the same columns exists
+func (tc *TableConstraints) HasForeignKey(foreignKey ForeignKeyDefinition) bool {
+	for _, existing := range tc.ForeignKeys {
+		if strings.EqualFold(existing.ReferencedTable, foreignKey.ReferencedTable) &&
+			sameColumns(existing.Columns, foreignKey.Columns) &&
+			sameColumns(existing.ReferencedColumns, foreignKey.ReferencedColumns) {
+			return true
+		}
+	}
+	return false
+}
+
+// comparing two column lists in order, ignoring case
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// comparing two column lists in any order, ignoring case
+func sameColumnSet(a, b []string) bool {
+	if len(a) != len(b) || len(a) == 0 {
+		return false
+	}
+	for _, column := range a {
+		found := false
+		for _, other := range b {
+			if strings.EqualFold(column, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// reading the indexes of a table, the query returns one row of index name, uniqueness and column per
+// indexed column ordered by index and position, a NULL column marks an expression index
+func queryIndexes(db *sql.DB, query, schema, tableName string) ([]IndexDefinition, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	rows, err := db.Query(query, schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	var indexes []IndexDefinition
+	skipped := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var unique bool
+		var column sql.NullString
+		if err := rows.Scan(&name, &unique, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan index column, %v", err)
+		}
+
+		if !column.Valid {
+			if !skipped[name] {
+				log.Printf("Warning: index %s of table %s is on an expression, skipping it", name, tableName)
+			}
+			skipped[name] = true
+			continue
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, IndexDefinition{Name: name, Unique: unique})
+		}
+		last := &indexes[len(indexes)-1]
+		last.Columns = append(last.Columns, column.String)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+
+	//dropping expression indexes that also have plain columns
+	result := indexes[:0]
+	for _, index := range indexes {
+		if !skipped[index.Name] {
+			result = append(result, index)
+		}
+	}
+	return result, nil
+}
+
+// reading the foreign keys of a table, the query returns one row of constraint name, column, referenced
+// table, referenced column, update rule and delete rule per column ordered by constraint and position
+func queryForeignKeys(db *sql.DB, query, schema, tableName string) ([]ForeignKeyDefinition, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	rows, err := db.Query(query, schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	var foreignKeys []ForeignKeyDefinition
+	for rows.Next() {
+		var name, column, referencedTable, referencedColumn, onUpdate, onDelete string
+		if err := rows.Scan(&name, &column, &referencedTable, &referencedColumn, &onUpdate, &onDelete); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key column, %v", err)
+		}
+
+		if len(foreignKeys) == 0 || foreignKeys[len(foreignKeys)-1].Name != name {
+			foreignKeys = append(foreignKeys, ForeignKeyDefinition{
+				Name:            name,
+				ReferencedTable: referencedTable,
+				OnUpdate:        referentialAction(onUpdate),
+				OnDelete:        referentialAction(onDelete),
+			})
+		}
+		last := &foreignKeys[len(foreignKeys)-1]
+		last.Columns = append(last.Columns, column)
+		last.ReferencedColumns = append(last.ReferencedColumns, referencedColumn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	return foreignKeys, nil
+}
+
+// normalizing a referential action, NO ACTION is the default of both databases and left out
+func referentialAction(rule string) string {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	switch rule {
+	case "CASCADE", "SET NULL", "SET DEFAULT", "RESTRICT":
+		return rule
+	default:
+		return ""
+	}
+}
+
+// building the statement adding a primary key to an existing table
+func addPrimaryKeyDDL(tableName string, columns []string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD PRIMARY KEY (%s)", sanitizeIdentifier(tableName), strings.Join(columns, ", "))
+}
+
+// building the statement creating an index, PostgreSQL supports skipping existing ones
+func createIndexDDL(tableName, indexName string, index IndexDefinition, ifNotExists bool) string {
+	statement := "CREATE INDEX "
+	if index.Unique {
+		statement = "CREATE UNIQUE INDEX "
+	}
+	if ifNotExists {
+		statement += "IF NOT EXISTS "
+	}
+	return fmt.Sprintf("%s%s ON %s (%s)", statement, sanitizeIdentifier(indexName), sanitizeIdentifier(tableName), strings.Join(index.Columns, ", "))
+}
+
+// building the statement adding a foreign key to an existing table
+func addForeignKeyDDL(tableName string, foreignKey ForeignKeyDefinition) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", sanitizeIdentifier(tableName), foreignKeyClause(foreignKey))
+}
+
+// building the CONSTRAINT clause of a foreign key
+func foreignKeyClause(foreignKey ForeignKeyDefinition) string {
+	clause := fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		sanitizeIdentifier(foreignKey.Name), strings.Join(foreignKey.Columns, ", "),
+		sanitizeIdentifier(foreignKey.ReferencedTable), strings.Join(foreignKey.ReferencedColumns, ", "))
+	if foreignKey.OnDelete != "" {
+		clause += " ON DELETE " + foreignKey.OnDelete
+	}
+	if foreignKey.OnUpdate != "" {
+		clause += " ON UPDATE " + foreignKey.OnUpdate
+	}
+	return clause
+}
+
+// checking a foreign key before recreating it
+func validateForeignKey(tableName string, foreignKey ForeignKeyDefinition) error {
+	if len(foreignKey.Columns) == 0 || len(foreignKey.Columns) != len(foreignKey.ReferencedColumns) {
+		return fmt.Errorf("foreign key %s of table %s has %d columns referencing %d columns", foreignKey.Name, tableName, len(foreignKey.Columns), len(foreignKey.ReferencedColumns))
+	}
+	return nil
+}
+
+// executing a constraint statement
+func execConstraintDDL(db *sql.DB, statement string) error {
+	if db == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if _, err := db.Exec(statement); err != nil {
+		return fmt.Errorf("failed to execute %s, %v", statement, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMySQLDescribeConstraints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("(?i)FROM information_schema.KEY_COLUMN_USAGE").WithArgs("", "orders").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	mock.ExpectQuery("(?i)FROM information_schema.STATISTICS").WithArgs("", "orders").
+		WillReturnRows(sqlmock.NewRows([]string{"INDEX_NAME", "UNIQUE", "COLUMN_NAME"}).
+			AddRow("idx_expr", false, nil).
+			AddRow("idx_status_date", false, "status").
+			AddRow("idx_status_date", false, "created_at").
+			AddRow("uq_number", true, "number"))
+	mock.ExpectQuery("(?i)FROM information_schema.KEY_COLUMN_USAGE kcu").WithArgs("", "orders").
+		WillReturnRows(sqlmock.NewRows([]string{"CONSTRAINT_NAME", "COLUMN_NAME", "REFERENCED_TABLE", "REFERENCED_COLUMN_NAME", "UPDATE_RULE", "DELETE_RULE"}).
+			AddRow("fk_item", "product_id", "items", "product_id", "NO ACTION", "CASCADE").
+			AddRow("fk_item", "variant", "items", "variant", "NO ACTION", "CASCADE"))
+
+	client := &MySQLClient{DB: db}
+	constraints, err := client.DescribeConstraints("orders")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := &TableConstraints{
+		Table:      "orders",
+		PrimaryKey: []string{"id"},
+		Indexes: []IndexDefinition{
+			{Name: "idx_status_date", Columns: []string{"status", "created_at"}},
+			{Name: "uq_number", Columns: []string{"number"}, Unique: true},
+		},
+		ForeignKeys: []ForeignKeyDefinition{
+			{Name: "fk_item", Columns: []string{"product_id", "variant"}, ReferencedTable: "items", ReferencedColumns: []string{"product_id", "variant"}, OnDelete: "CASCADE"},
+		},
+	}
+	if !reflect.DeepEqual(constraints, expected) {
+		t.Errorf("Expected constraints %+v, got %+v", expected, constraints)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgreSQLAddConstraints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("^ALTER TABLE orders ADD PRIMARY KEY \\(id\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^CREATE UNIQUE INDEX IF NOT EXISTS orders_uq_number ON sales.orders \\(number\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^CREATE INDEX IF NOT EXISTS orders_status_idx ON orders \\(status, created_at\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^ALTER TABLE orders ADD CONSTRAINT fk_customer FOREIGN KEY \\(customer_id\\) REFERENCES customers \\(id\\) ON DELETE SET NULL$").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	client := &PostgreSQLClient{DB: db}
+	if err := client.AddPrimaryKey("orders", []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.AddIndex("sales.orders", IndexDefinition{Name: "uq_number", Columns: []string{"number"}, Unique: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.AddIndex("orders", IndexDefinition{Name: "orders_status_idx", Columns: []string{"status", "created_at"}}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	foreignKey := ForeignKeyDefinition{Name: "fk_customer", Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}, OnDelete: "SET NULL"}
+	if err := client.AddForeignKey("orders", foreignKey); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	foreignKey.ReferencedColumns = nil
+	if err := client.AddForeignKey("orders", foreignKey); err == nil {
+		t.Errorf("expected error for foreign key without referenced columns, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLAddIndexOnTextColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	expectColumns := func() {
+		columns := []string{"COLUMN_NAME", "DATA_TYPE", "COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE", "IS_NULLABLE", "COLUMN_DEFAULT", "EXTRA"}
+		mock.ExpectQuery("(?i)FROM information_schema.COLUMNS").WithArgs("", "users").WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("id", "int", "int(11)", nil, 10, 0, "NO", nil, "").
+			AddRow("email", "longtext", "longtext", 4294967295, nil, nil, "NO", nil, "").
+			AddRow("profile", "json", "json", nil, nil, nil, "YES", nil, ""))
+		mock.ExpectQuery("(?i)FROM information_schema.KEY_COLUMN_USAGE").WithArgs("", "users").
+			WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}))
+	}
+	expectColumns()
+	mock.ExpectExec("^CREATE UNIQUE INDEX uq_email ON users \\(email\\(191\\), id\\)$").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectColumns()
+
+	client := &MySQLClient{DB: db}
+	if err := client.AddIndex("users", IndexDefinition{Name: "uq_email", Columns: []string{"email", "id"}, Unique: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.AddIndex("users", IndexDefinition{Name: "idx_profile", Columns: []string{"profile"}}); err == nil {
+		t.Errorf("expected error for an index on a JSON column, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestTableConstraintsMatching(t *testing.T) {
+	constraints := &TableConstraints{
+		Indexes:     []IndexDefinition{{Name: "orders_idx_status", Columns: []string{"Status"}}},
+		ForeignKeys: []ForeignKeyDefinition{{Name: "orders_customer_id_fkey", Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}}},
+	}
+
+	if !constraints.HasIndex(IndexDefinition{Name: "idx_status", Columns: []string{"status"}}) {
+		t.Errorf("Expected index on the same columns to match regardless of its name")
+	}
+	if constraints.HasIndex(IndexDefinition{Name: "idx_status", Columns: []string{"status"}, Unique: true}) {
+		t.Errorf("Expected unique index not to match a plain index")
+	}
+	if !constraints.HasForeignKey(ForeignKeyDefinition{Name: "fk_customer", Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}}) {
+		t.Errorf("Expected foreign key between the same columns to match")
+	}
+	if constraints.HasForeignKey(ForeignKeyDefinition{Columns: []string{"customer_id"}, ReferencedTable: "clients", ReferencedColumns: []string{"id"}}) {
+		t.Errorf("Expected foreign key to another table not to match")
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// filter for the tables discovered in a source, patterns are shell globs like orders_* or sales.*
+type TableFilter struct {
+	Schemas []string //schemas to discover, the connection's default schema when empty
+	Include []string //patterns a table has to match, every table when empty
+	Exclude []string //patterns of tables to leave out
+}
+
+// checking that all patterns of the filter are valid globs
+func (f TableFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid table pattern %q, %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// checking if a table passes the filter. Patterns containing a dot are matched
+// against the schema qualified name, others against the bare table name
+func (f TableFilter) Matches(table string) bool {
+	if len(f.Include) > 0 && !matchesAnyPattern(f.Include, table) {
+		return false
+	}
+	return !matchesAnyPattern(f.Exclude, table)
+}
+
+// returning the tables passing the filter, keeping their order
+func (f TableFilter) Apply(tables []string) []string {
+	filtered := make([]string, 0, len(tables))
+	for _, table := range tables {
+		if f.Matches(table) {
+			filtered = append(filtered, table)
+		}
+	}
+	return filtered
+}
+
+// checking a table name against glob patterns
+func matchesAnyPattern(patterns []string, table string) bool {
+	name := table
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		name = table[i+1:]
+	}
+
+	for _, pattern := range patterns {
+		target := name
+		if strings.Contains(pattern, ".") {
+			target = table
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// listing base tables from information_schema. The query returns schema, table name and whether the
+// schema is the connection's default one, tables outside the default schema are returned as schema.table
+func discoverSQLTables(db *sql.DB, query string, args []interface{}, filter TableFilter) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query information_schema for tables, %v", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var schema, name string
+		var isDefaultSchema bool
+		if err := rows.Scan(&schema, &name, &isDefaultSchema); err != nil {
+			return nil, fmt.Errorf("failed to scan table name, %v", err)
+		}
+		if !isDefaultSchema {
+			name = schema + "." + name
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	return filter.Apply(tables), nil
+}
+
+// building the schema condition of a discovery query
+func schemaCondition(column, defaultSchema string, schemas []string, placeholder func(i int) string) (string, []interface{}) {
+	if len(schemas) == 0 {
+		return fmt.Sprintf("%s = %s", column, defaultSchema), nil
+	}
+
+	placeholders := make([]string, len(schemas))
+	args := make([]interface{}, len(schemas))
+	for i, schema := range schemas {
+		placeholders[i] = placeholder(i + 1)
+		args[i] = schema
+	}
+	return fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")), args
+}
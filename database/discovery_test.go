@@ -0,0 +1,77 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTableFilter(t *testing.T) {
+	filter := TableFilter{
+		Include: []string{"orders*", "customers", "sales.*"},
+		Exclude: []string{"*_archive"},
+	}
+
+	tables := []string{"orders", "orders_2024", "orders_archive", "customers", "payments", "sales.targets", "hr.customers"}
+	expected := []string{"orders", "orders_2024", "customers", "sales.targets", "hr.customers"}
+
+	if result := filter.Apply(tables); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	if err := (TableFilter{Include: []string{"orders["}}).Validate(); err == nil {
+		t.Errorf("expected error for invalid pattern, got nil")
+	}
+}
+
+func TestMySQLDiscoverTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"TABLE_SCHEMA", "TABLE_NAME", "is_default"}).
+		AddRow("shop", "customers", true).
+		AddRow("shop", "orders", true).
+		AddRow("shop", "orders_archive", true)
+	mock.ExpectQuery("(?i)FROM information_schema.TABLES\\s+WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA = DATABASE\\(\\)").WillReturnRows(mockRows)
+
+	client := &MySQLClient{DB: db}
+	tables, err := client.DiscoverTables(TableFilter{Exclude: []string{"*_archive"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []string{"customers", "orders"}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("expected %v, got %v", expected, tables)
+	}
+}
+
+func TestPostgreSQLDiscoverTablesInSchemas(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"table_schema", "table_name", "is_default"}).
+		AddRow("public", "users", true).
+		AddRow("sales", "orders", false)
+	mock.ExpectQuery("(?i)FROM information_schema.tables\\s+WHERE table_type = 'BASE TABLE' AND table_schema IN \\(\\$1, \\$2\\)").
+		WithArgs("public", "sales").
+		WillReturnRows(mockRows)
+
+	client := &PostgreSQLClient{DB: db}
+	tables, err := client.DiscoverTables(TableFilter{Schemas: []string{"public", "sales"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []string{"users", "sales.orders"}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("expected %v, got %v", expected, tables)
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// row filters of the source tables keyed by table, SQL WHERE fragments like "tenant_id = 42" for MySQL,
+// PostgreSQL and SQLite and JSON query documents like {"tenant_id": 42} for MongoDB. Tables without a filter are read whole
+type RowFilters map[string]string
+
+// returning the filter of a table, matching its name case-insensitively, empty when it has none
+func (f RowFilters) For(table string) string {
+	if filter, ok := f[table]; ok {
+		return strings.TrimSpace(filter)
+	}
+	for name, filter := range f {
+		if strings.EqualFold(name, table) {
+			return strings.TrimSpace(filter)
+		}
+	}
+	return ""
+}
+
+// returning the filtered tables missing from tables, their filter is most likely misspelled
+func (f RowFilters) UnknownTables(tables []string) []string {
+	known := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		known[strings.ToLower(table)] = true
+	}
+	var unknown []string
+	for name := range f {
+		if !known[strings.ToLower(name)] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// checking that every filter is a single SQL condition, a second statement after a semicolon is rejected
+func (f RowFilters) validateSQL() error {
+	for table, filter := range f {
+		trimmed := strings.TrimSpace(filter)
+		if trimmed == "" {
+			return fmt.Errorf("filter of table %s is empty", table)
+		}
+		if strings.Contains(strings.TrimSuffix(trimmed, ";"), ";") {
+			return fmt.Errorf("filter of table %s must be a single WHERE condition, got %q", table, filter)
+		}
+	}
+	return nil
+}
+
+// joining the filter of a table with further conditions into a WHERE clause, empty without any condition
+func whereClause(filter string, conditions ...string) string {
+	var parts []string
+	if filter = strings.TrimSuffix(strings.TrimSpace(filter), ";"); filter != "" {
+		parts = append(parts, "("+filter+")")
+	}
+	parts = append(parts, conditions...)
+	if len(parts) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(parts, " AND ")
+}
+
+// parsing the JSON query documents of MongoDB filters, extended JSON like {"$date": "2024-01-01T00:00:00Z"} is accepted
+func parseMongoFilters(filters RowFilters) (map[string]bson.M, error) {
+	parsed := make(map[string]bson.M, len(filters))
+	for collection, filter := range filters {
+		var document bson.M
+		if err := bson.UnmarshalExtJSON([]byte(filter), false, &document); err != nil {
+			return nil, fmt.Errorf("filter of collection %s is not a JSON query document, %v", collection, err)
+		}
+		parsed[strings.ToLower(collection)] = document
+	}
+	return parsed, nil
+}
+
+// combining the filter of a collection with a further condition, either may be empty
+func combineMongoFilters(filter, condition bson.M) bson.M {
+	switch {
+	case len(filter) == 0 && len(condition) == 0:
+		return bson.M{}
+	case len(filter) == 0:
+		return condition
+	case len(condition) == 0:
+		return filter
+	}
+	return bson.M{"$and": bson.A{filter, condition}}
+}
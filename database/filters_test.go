@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMySQLStreamsFilteredRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT \\* FROM orders WHERE \\(tenant_id = 42\\);$").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(1, 42).AddRow(2, 42))
+	mock.ExpectQuery("^SELECT \\* FROM orders WHERE \\(tenant_id = 42\\) AND id > \\? ORDER BY id;$").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(2, 42))
+	mock.ExpectQuery("^SELECT \\* FROM customers;$").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	client := &MySQLClient{DB: db}
+	if err := client.SetRowFilters(RowFilters{"Orders": "tenant_id = 42;"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	iterator, err := client.StreamTable(context.Background(), "orders", 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !iterator.Next() || len(iterator.Batch()) != 2 {
+		t.Errorf("expected the 2 rows of tenant 42, got %v %v", iterator.Batch(), iterator.Err())
+	}
+	iterator.Close()
+
+	iterator, err = client.StreamTableAfter(context.Background(), "orders", "id", 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	iterator.Close()
+
+	if _, err := client.FetchAllData([]string{"customers"}); err != nil {
+		t.Errorf("expected tables without a filter to be read whole, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations %v", err)
+	}
+}
+
+func TestPostgreSQLStreamsFilteredKeyRange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT \\* FROM events WHERE \\(kind <> 'debug'\\) AND id >= \\$1 AND id < \\$2 ORDER BY id;$").
+		WithArgs(int64(1), int64(1001)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery("^SELECT \\* FROM events WHERE \\(kind <> 'debug'\\) AND updated_at >= \\$1 ORDER BY updated_at;$").
+		WithArgs("2024-01-01").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+	client := &PostgreSQLClient{DB: db}
+	if err := client.SetRowFilters(RowFilters{"events": "kind <> 'debug'"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	iterator, err := client.StreamTableRange(context.Background(), "events", KeyRange{Column: "id", Start: 1, End: 1001}, nil, 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	iterator.Close()
+	iterator, err = client.StreamTableSince(context.Background(), "events", "updated_at", "2024-01-01", 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	iterator.Close()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations %v", err)
+	}
+}
+
+func TestRowFiltersValidation(t *testing.T) {
+	client := &PostgreSQLClient{}
+	if err := client.SetRowFilters(RowFilters{"users": "id > 0; DROP TABLE users"}); err == nil {
+		t.Errorf("expected a second statement to be rejected")
+	}
+	if err := client.SetRowFilters(RowFilters{"users": " "}); err == nil {
+		t.Errorf("expected an empty filter to be rejected")
+	}
+
+	mongoClient := &MongoDBClient{}
+	if err := mongoClient.SetRowFilters(RowFilters{"events": "tenant_id = 42"}); err == nil {
+		t.Errorf("expected a SQL filter to be rejected for MongoDB")
+	}
+	if err := mongoClient.SetRowFilters(RowFilters{"Events": `{"tenant_id": 42, "created_at": {"$gte": {"$date": "2023-01-01T00:00:00Z"}}}`}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	filter := mongoClient.filterFor("events", bson.M{"_id": bson.M{"$gt": 7}})
+	conditions, ok := filter["$and"].(bson.A)
+	if !ok || len(conditions) != 2 || fmt.Sprint(conditions[0].(bson.M)["tenant_id"]) != "42" {
+		t.Errorf("expected the filter and the key condition to be combined, got %v", filter)
+	}
+	if filter := mongoClient.filterFor("users", nil); len(filter) != 0 {
+		t.Errorf("expected collections without a filter to be read whole, got %v", filter)
+	}
+
+	if unknown := (RowFilters{"orders": "id > 0", "ordres": "id > 0"}).UnknownTables([]string{"Orders"}); fmt.Sprint(unknown) != "[ordres]" {
+		t.Errorf("expected the misspelled table to be reported, got %v", unknown)
+	}
+}
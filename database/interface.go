@@ -1,6 +1,11 @@
 package database
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
+)
 
 // Interface for database operations
 type DatabaseClient interface {
@@ -13,6 +18,109 @@ type DatabaseClient interface {
 	ImportDataConcurrently(data []map[string]interface{}, batchsize int) error
 }
 
+// Interface for clients whose connection, reads and imports can be cancelled through a context
+type ContextClient interface {
+	ConnectContext(ctx context.Context) error
+	FetchAllDataContext(ctx context.Context, tables []string) ([]map[string]interface{}, error)
+	ImportDataContext(ctx context.Context, data []map[string]interface{}) error
+}
+
+// Interface for clients that can read a table batch by batch instead of materialising it
+type StreamingClient interface {
+	StreamTable(ctx context.Context, tableName string, batchSize int) (RowIterator, error)
+}
+
+// Interface for clients that can push a watermark filter down to the source query
+type IncrementalClient interface {
+	StreamTableSince(ctx context.Context, tableName, column string, watermark interface{}, batchSize int) (RowIterator, error)
+}
+
+// Interface for clients that can stream a table ordered by a key column, starting after a given key
+type KeysetClient interface {
+	StreamTableAfter(ctx context.Context, tableName, keyColumn string, lastKey interface{}, batchSize int) (RowIterator, error)
+}
+
+// Interface for clients that can split a table into key ranges and read each range on its own,
+// so a single large table can be read concurrently
+type RangeClient interface {
+	SplitTable(tableName string, rowsPerRange int64) ([]KeyRange, error)
+	StreamTableRange(ctx context.Context, tableName string, keyRange KeyRange, lastKey interface{}, batchSize int) (RowIterator, error)
+}
+
+// Interface for clients that can name the database they connect to, keying the state kept between runs
+type LocationClient interface {
+	Location() string
+}
+
+// Interface for clients that can insert or update rows identified by key columns
+type UpsertClient interface {
+	UpsertData(data []map[string]interface{}, keyColumns []string) error
+}
+
+// Interface for clients that can list their tables from the live catalog
+type DiscoveryClient interface {
+	DiscoverTables(filter TableFilter) ([]string, error)
+}
+
+// Interface for clients that can capture the row changes committed to their tables
+type ChangeCaptureClient interface {
+	//returning the position of the latest committed change, a stream of the tables opened there only sees later changes.
+	//Sources that need to prepare capturing the tables, like a replication slot, do so here
+	CurrentChangePosition(ctx context.Context, tables []string) (string, error)
+	//opening a stream of the changes of the tables committed after position
+	OpenChangeStream(ctx context.Context, tables []string, position string) (ChangeStream, error)
+	//reporting whether a stream that returned the transaction at position returned every change committed up to target
+	ChangePositionReached(position, target string) (bool, error)
+}
+
+// Interface for clients that can stop the writes to their database, used to cut over online migrations
+type ReadOnlyClient interface {
+	SetReadOnly(ctx context.Context, readOnly bool) error
+}
+
+// Interface for clients that can read table definitions from their catalog and create tables from them
+type SchemaClient interface {
+	DescribeTable(tableName string) (*TableSchema, error)
+	CreateTable(schema *TableSchema) error
+}
+
+// Interface for clients that can restrict the rows read from each table to those matching a filter
+type FilterClient interface {
+	SetRowFilters(filters RowFilters) error
+}
+
+// Interface for clients that can read and recreate primary keys, indexes and foreign keys
+type ConstraintClient interface {
+	DescribeConstraints(tableName string) (*TableConstraints, error)
+	AddPrimaryKey(tableName string, columns []string) error
+	AddIndex(tableName string, index IndexDefinition) error
+	AddForeignKey(tableName string, foreignKey ForeignKeyDefinition) error
+}
+
+// Interface for clients that can write rows with a conflict handling mode
+type WriteModeClient interface {
+	WriteData(data []map[string]interface{}, mode WriteMode, keyColumns []string) error
+}
+
+// Interface for clients with a bulk loading path for ImportData that can be switched off
+type BulkLoadClient interface {
+	SetBulkLoad(enabled bool)
+}
+
+// Interface for clients reporting the progress of the batches written by ImportDataConcurrently
+type ProgressClient interface {
+	SetProgressTracker(tracker *monitoring.ProcessTracker)
+}
+
+// Interface for clients that can undo a migration on the target
+type RollbackClient interface {
+	//returning if the table exists in the catalog and its number of rows, a missing table is not an error
+	TableRowCount(tableName string) (bool, int64, error)
+	PrimaryKeyColumns(tableName string) ([]string, error)
+	DeleteRows(tableName string, keyColumns []string, rows []map[string]interface{}) (int64, error)
+	DropTable(tableName string) error
+}
+
 type TargetDatabase interface {
 	Connect() error
 	InsertData(data []map[string]interface{}) error
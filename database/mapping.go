@@ -0,0 +1,204 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+)
+
+// type for mapping source tables and columns to the names they get in the target.
+// A nil mapping keeps every name as it is
+type NameMapping struct {
+	schema     string
+	snakeCase  bool
+	tables     map[string]string            //lowercase source table to target table
+	columns    map[string]map[string]string //lowercase source table to source column to target column
+	configured map[string]string            //lowercase source table to its name in the config
+}
+
+// creating the mapping configured in config.yaml
+func NewNameMapping(cfg config.MappingConfig) (*NameMapping, error) {
+	mapping := &NameMapping{
+		schema:     cfg.Schema,
+		tables:     make(map[string]string, len(cfg.Tables)),
+		columns:    make(map[string]map[string]string, len(cfg.Tables)),
+		configured: make(map[string]string, len(cfg.Tables)),
+	}
+	switch strings.ToLower(cfg.ColumnCase) {
+	case "", "as-is":
+	case "snake_case":
+		mapping.snakeCase = true
+	default:
+		return nil, fmt.Errorf("unsupported column case %s, use snake_case or as-is", cfg.ColumnCase)
+	}
+
+	for source, table := range cfg.Tables {
+		key := strings.ToLower(source)
+		if other, ok := mapping.configured[key]; ok {
+			return nil, fmt.Errorf("tables %s and %s of the mapping differ only in case", other, source)
+		}
+		mapping.configured[key] = source
+		if table.Target != "" {
+			mapping.tables[key] = table.Target
+		}
+
+		targets := make(map[string]string, len(table.Columns))
+		for column, target := range table.Columns {
+			if target == "" {
+				return nil, fmt.Errorf("column %s of table %s is mapped to an empty name", column, source)
+			}
+			targets[column] = target
+		}
+		mapping.columns[key] = targets
+	}
+	return mapping, nil
+}
+
+// returning the target table of a source table
+func (nm *NameMapping) TargetTable(table string) string {
+	if nm == nil {
+		return table
+	}
+	if target, ok := nm.tables[strings.ToLower(table)]; ok {
+		return target
+	}
+	if nm.schema != "" {
+		_, name := splitQualifiedName(table)
+		return nm.schema + "." + name
+	}
+	return table
+}
+
+// returning the target column of a column of a source table
+func (nm *NameMapping) TargetColumn(table, column string) string {
+	if nm == nil || column == "_source_table" {
+		return column
+	}
+	if target, ok := nm.columns[strings.ToLower(table)][column]; ok {
+		return target
+	}
+	if nm.snakeCase {
+		return toSnakeCase(column)
+	}
+	return column
+}
+
+// returning the target columns of columns of a source table
+func (nm *NameMapping) TargetColumns(table string, columns []string) []string {
+	if nm == nil {
+		return columns
+	}
+	mapped := make([]string, len(columns))
+	for i, column := range columns {
+		mapped[i] = nm.TargetColumn(table, column)
+	}
+	return mapped
+}
+
+// returning a copy of a row with target column names, its _source_table set to the target table
+func (nm *NameMapping) MapRow(row map[string]interface{}) map[string]interface{} {
+	if nm == nil {
+		return row
+	}
+	table, _ := row["_source_table"].(string)
+	mapped := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		mapped[nm.TargetColumn(table, column)] = value
+	}
+	if table != "" {
+		mapped["_source_table"] = nm.TargetTable(table)
+	}
+	return mapped
+}
+
+// returning copies of rows with target names
+func (nm *NameMapping) MapRows(rows []map[string]interface{}) []map[string]interface{} {
+	if nm == nil {
+		return rows
+	}
+	mapped := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		mapped[i] = nm.MapRow(row)
+	}
+	return mapped
+}
+
+// returning a copy of a translated schema of a source table with target names
+func (nm *NameMapping) MapSchema(schema *TableSchema) *TableSchema {
+	if nm == nil || schema == nil {
+		return schema
+	}
+	mapped := *schema
+	mapped.Name = nm.TargetTable(schema.Name)
+	mapped.Columns = make([]ColumnDefinition, len(schema.Columns))
+	for i, column := range schema.Columns {
+		column.Name = nm.TargetColumn(schema.Name, column.Name)
+		mapped.Columns[i] = column
+	}
+	mapped.PrimaryKey = nm.TargetColumns(schema.Name, schema.PrimaryKey)
+	return &mapped
+}
+
+// returning a copy of the constraints of a source table with target names
+func (nm *NameMapping) MapConstraints(constraints *TableConstraints) *TableConstraints {
+	if nm == nil || constraints == nil {
+		return constraints
+	}
+	table := constraints.Table
+	mapped := &TableConstraints{
+		Table:      nm.TargetTable(table),
+		PrimaryKey: nm.TargetColumns(table, constraints.PrimaryKey),
+	}
+	for _, index := range constraints.Indexes {
+		index.Columns = nm.TargetColumns(table, index.Columns)
+		mapped.Indexes = append(mapped.Indexes, index)
+	}
+	for _, foreignKey := range constraints.ForeignKeys {
+		foreignKey.Columns = nm.TargetColumns(table, foreignKey.Columns)
+		foreignKey.ReferencedColumns = nm.TargetColumns(foreignKey.ReferencedTable, foreignKey.ReferencedColumns)
+		foreignKey.ReferencedTable = nm.TargetTable(foreignKey.ReferencedTable)
+		mapped.ForeignKeys = append(mapped.ForeignKeys, foreignKey)
+	}
+	return mapped
+}
+
+// returning the configured source tables missing from tables, their mapping is most likely misspelled
+func (nm *NameMapping) UnknownTables(tables []string) []string {
+	if nm == nil {
+		return nil
+	}
+	known := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		known[strings.ToLower(table)] = true
+	}
+	var unknown []string
+	for key, source := range nm.configured {
+		if !known[key] {
+			unknown = append(unknown, source)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// converting camelCase and PascalCase names to snake_case, eg. userId to user_id and HTTPStatus to http_status
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			//starting a word after a lower case letter or digit, or before the last capital of an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+)
+
+func newTestNameMapping(t *testing.T) *NameMapping {
+	mapping, err := NewNameMapping(config.MappingConfig{
+		Schema:     "analytics",
+		ColumnCase: "snake_case",
+		Tables: map[string]config.TableMapping{
+			"userAccounts": {Target: "sales.user_accounts", Columns: map[string]string{"userId": "account_id"}},
+			"orders":       {Columns: map[string]string{"orderID": "id"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mapping, %v", err)
+	}
+	return mapping
+}
+
+func TestNameMappingTargetNames(t *testing.T) {
+	mapping := newTestNameMapping(t)
+
+	tables := map[string]string{
+		"userAccounts": "sales.user_accounts",
+		"useraccounts": "sales.user_accounts",
+		"orders":       "analytics.orders",
+		"public.items": "analytics.items",
+	}
+	for source, expected := range tables {
+		if target := mapping.TargetTable(source); target != expected {
+			t.Errorf("Expected table %s to be mapped to %s, got %s", source, expected, target)
+		}
+	}
+
+	columns := map[string]string{
+		"userId":       "account_id",
+		"createdAt":    "created_at",
+		"HTTPStatus":   "http_status",
+		"address2Line": "address2_line",
+		"name":         "name",
+	}
+	for source, expected := range columns {
+		if target := mapping.TargetColumn("userAccounts", source); target != expected {
+			t.Errorf("Expected column %s to be mapped to %s, got %s", source, expected, target)
+		}
+	}
+
+	var unmapped *NameMapping
+	if unmapped.TargetTable("userAccounts") != "userAccounts" || unmapped.TargetColumn("userAccounts", "userId") != "userId" {
+		t.Errorf("Expected a nil mapping to keep the source names")
+	}
+}
+
+func TestNameMappingMapsRowsSchemasAndConstraints(t *testing.T) {
+	mapping := newTestNameMapping(t)
+
+	row := map[string]interface{}{"_source_table": "userAccounts", "userId": 1, "firstName": "Susheel"}
+	expected := map[string]interface{}{"_source_table": "sales.user_accounts", "account_id": 1, "first_name": "Susheel"}
+	if mapped := mapping.MapRow(row); fmt.Sprint(mapped) != fmt.Sprint(expected) {
+		t.Errorf("Expected row %v, got %v", expected, mapped)
+	}
+	if _, ok := row["account_id"]; ok {
+		t.Errorf("Expected the source row to be left unchanged, got %v", row)
+	}
+
+	schema := mapping.MapSchema(&TableSchema{
+		Name:       "userAccounts",
+		Columns:    []ColumnDefinition{{Name: "userId", DataType: "INTEGER"}, {Name: "firstName", DataType: "TEXT"}},
+		PrimaryKey: []string{"userId"},
+	})
+	if schema.Name != "sales.user_accounts" || schema.Columns[0].Name != "account_id" || schema.Columns[1].Name != "first_name" || fmt.Sprint(schema.PrimaryKey) != "[account_id]" {
+		t.Errorf("Expected the schema under target names, got %+v", schema)
+	}
+
+	constraints := mapping.MapConstraints(&TableConstraints{
+		Table:       "orders",
+		PrimaryKey:  []string{"orderID"},
+		Indexes:     []IndexDefinition{{Name: "idx_orders_user", Columns: []string{"userId", "createdAt"}}},
+		ForeignKeys: []ForeignKeyDefinition{{Name: "fk_orders_user", Columns: []string{"userId"}, ReferencedTable: "userAccounts", ReferencedColumns: []string{"userId"}}},
+	})
+	foreignKey := constraints.ForeignKeys[0]
+	if fmt.Sprint(constraints.PrimaryKey) != "[id]" || fmt.Sprint(constraints.Indexes[0].Columns) != "[user_id created_at]" {
+		t.Errorf("Expected the key and index under target names, got %+v", constraints)
+	}
+	if foreignKey.ReferencedTable != "sales.user_accounts" || fmt.Sprint(foreignKey.Columns) != "[user_id]" || fmt.Sprint(foreignKey.ReferencedColumns) != "[account_id]" {
+		t.Errorf("Expected the foreign key to reference the mapped table and column, got %+v", foreignKey)
+	}
+
+	if unknown := mapping.UnknownTables([]string{"orders"}); fmt.Sprint(unknown) != "[userAccounts]" {
+		t.Errorf("Expected userAccounts to be reported as not migrated, got %v", unknown)
+	}
+}
+
+func TestNewNameMappingRejectsInvalidConfig(t *testing.T) {
+	if _, err := NewNameMapping(config.MappingConfig{ColumnCase: "kebab-case"}); err == nil {
+		t.Errorf("Expected an unsupported column case to be rejected")
+	}
+	_, err := NewNameMapping(config.MappingConfig{Tables: map[string]config.TableMapping{"users": {Columns: map[string]string{"id": ""}}}})
+	if err == nil {
+		t.Errorf("Expected a column mapped to an empty name to be rejected")
+	}
+}
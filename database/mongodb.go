@@ -3,12 +3,19 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -18,6 +25,10 @@ type MongoDBClient struct {
 	DBName   string
 	Client   *mongo.Client
 	Database *mongo.Database
+	Timeouts config.TimeoutConfig //unset timeouts keep the defaults of 10s to connect, 30s per query and 60s per import
+	Filters  map[string]bson.M    //query documents restricting the documents read, keyed by lowercase collection
+
+	Progress *monitoring.ProcessTracker //progress of the batches of ImportDataConcurrently, nil when not reported
 	ctx      context.Context
 }
 
@@ -42,19 +53,55 @@ func NewMongoDBClientFromConfig(cfg *config.Config) *MongoDBClient {
 	)
 
 	return &MongoDBClient{
-		URI:    uri,
-		DBName: cfg.MongoDB.DBName,
-		ctx:    context.Background(),
+		URI:      uri,
+		DBName:   cfg.MongoDB.DBName,
+		Timeouts: cfg.Timeouts,
+		ctx:      context.Background(),
+	}
+}
+
+// reporting the rows of every batch written by ImportDataConcurrently to tracker
+func (m *MongoDBClient) SetProgressTracker(tracker *monitoring.ProcessTracker) {
+	m.Progress = tracker
+}
+
+// restricting the documents read from each collection to those matching its JSON query document
+func (m *MongoDBClient) SetRowFilters(filters RowFilters) error {
+	parsed, err := parseMongoFilters(filters)
+	if err != nil {
+		return err
+	}
+	m.Filters = parsed
+	return nil
+}
+
+// returning the filter of a collection combined with a further condition
+func (m *MongoDBClient) filterFor(collectionName string, condition bson.M) bson.M {
+	return combineMongoFilters(m.Filters[strings.ToLower(collectionName)], condition)
+}
+
+// naming the database as hosts/database, leaving the credentials of the URI out
+func (m *MongoDBClient) Location() string {
+	uri, err := url.Parse(m.URI)
+	if err != nil {
+		return m.DBName
 	}
+	return uri.Host + "/" + m.DBName
 }
 
 // connecting to mongoDB
 func (m *MongoDBClient) Connect() error {
+	return m.ConnectContext(m.ctx)
+}
+
+// connecting to mongoDB, giving up when ctx is cancelled or the connect timeout expires. Operations without a
+// context of their own are cancelled with ctx as well
+func (m *MongoDBClient) ConnectContext(parent context.Context) error {
 	//setting client options
 	clientOptions := options.Client().ApplyURI(m.URI)
 
 	//setting timeout for connection
-	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(parent, timeoutOrDefault(m.Timeouts.Connect, 10*time.Second))
 	defer cancel()
 
 	//connecting to mongodb
@@ -70,6 +117,7 @@ func (m *MongoDBClient) Connect() error {
 
 	m.Client = client
 	m.Database = client.Database(m.DBName)
+	m.ctx = parent
 
 	fmt.Println("Successfully connected to mongoDB")
 	return nil
@@ -78,7 +126,8 @@ func (m *MongoDBClient) Connect() error {
 // closing the mongodb connection
 func (m *MongoDBClient) Close() error {
 	if m.Client != nil {
-		ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+		//disconnecting after the caller's context is cancelled, like at the end of an interrupted run
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(m.ctx), timeoutOrDefault(m.Timeouts.Connect, 5*time.Second))
 		defer cancel()
 		return m.Client.Disconnect(ctx)
 	}
@@ -94,6 +143,11 @@ func (m *MongoDBClient) ExecuteQuery(query string) (*sql.Rows, error) {
 
 // fetching data from all specified collections
 func (m *MongoDBClient) FetchAllData(collections []string) ([]map[string]interface{}, error) {
+	return m.FetchAllDataContext(m.ctx, collections)
+}
+
+// fetching all documents of the collections, giving up when ctx is cancelled or the query timeout expires
+func (m *MongoDBClient) FetchAllDataContext(parent context.Context, collections []string) ([]map[string]interface{}, error) {
 	if m.Database == nil {
 		return nil, fmt.Errorf("database connection cannot be established")
 	}
@@ -104,10 +158,10 @@ func (m *MongoDBClient) FetchAllData(collections []string) ([]map[string]interfa
 		collection := m.Database.Collection(collectionName)
 
 		//creating context with timeout
-		ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+		ctx, cancel := context.WithTimeout(parent, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
 
 		//finding all documents
-		cursor, err := collection.Find(ctx, bson.M{})
+		cursor, err := collection.Find(ctx, m.filterFor(collectionName, nil))
 		if err != nil {
 			cancel()
 			return nil, fmt.Errorf("error fetching data from collection %s,%v", collectionName, err)
@@ -124,9 +178,9 @@ func (m *MongoDBClient) FetchAllData(collections []string) ([]map[string]interfa
 		cursor.Close(ctx)
 		cancel()
 
-		//Adding collection info into each document
+		//converting each document into a row with collection info
 		for i := range collectionResult {
-			collectionResult[i]["_source_table"] = collectionName
+			collectionResult[i] = mongoRow(collectionName, collectionResult[i])
 		}
 
 		allResults = append(allResults, collectionResult...)
@@ -135,8 +189,112 @@ func (m *MongoDBClient) FetchAllData(collections []string) ([]map[string]interfa
 	return allResults, nil
 }
 
+// streaming documents of a single collection in batches without loading the whole collection
+func (m *MongoDBClient) StreamTable(ctx context.Context, collectionName string, batchSize int) (RowIterator, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	//the query timeout bounds the find and every batch read, the cursor lives as long as the caller keeps iterating
+	stream := newStreamContext(ctx, m.Timeouts.Query)
+
+	findOptions := options.Find().SetBatchSize(int32(batchSize))
+	cursor, err := m.streamFind(stream, collectionName, m.filterFor(collectionName, nil), findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from collection %s,%w", collectionName, err)
+	}
+
+	return &mongoRowIterator{
+		cursor:         cursor,
+		stream:         stream,
+		collectionName: collectionName,
+		batchSize:      batchSize,
+	}, nil
+}
+
+// running the find opening a stream of the collection, bounded by the query timeout of the stream
+func (m *MongoDBClient) streamFind(stream *streamContext, collectionName string, filter interface{}, findOptions *options.FindOptions) (*mongo.Cursor, error) {
+	done := stream.read()
+	defer done()
+	cursor, err := m.Database.Collection(collectionName).Find(stream, filter, findOptions)
+	if err != nil {
+		stream.close()
+		return nil, stream.failed(err)
+	}
+	return cursor, nil
+}
+
+// streaming documents whose watermark field is at or past the given watermark, sorted by that field. Documents
+// at the watermark are read again, as documents written after the last run may share its value
+func (m *MongoDBClient) StreamTableSince(ctx context.Context, collectionName, field string, watermark interface{}, batchSize int) (RowIterator, error) {
+	if watermark == nil {
+		return m.StreamTable(ctx, collectionName, batchSize)
+	}
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	stream := newStreamContext(ctx, m.Timeouts.Query)
+
+	filter := m.filterFor(collectionName, bson.M{field: bson.M{"$gte": watermark}})
+	findOptions := options.Find().SetBatchSize(int32(batchSize)).SetSort(bson.D{{Key: field, Value: 1}})
+	cursor, err := m.streamFind(stream, collectionName, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching incremental data from collection %s,%w", collectionName, err)
+	}
+
+	return &mongoRowIterator{
+		cursor:         cursor,
+		stream:         stream,
+		collectionName: collectionName,
+		batchSize:      batchSize,
+	}, nil
+}
+
+// streaming documents sorted by a key field, only documents after lastKey unless it is nil
+func (m *MongoDBClient) StreamTableAfter(ctx context.Context, collectionName, keyField string, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	condition := bson.M{}
+	if lastKey != nil {
+		condition[keyField] = bson.M{"$gt": lastKey}
+	}
+	filter := m.filterFor(collectionName, condition)
+
+	stream := newStreamContext(ctx, m.Timeouts.Query)
+
+	findOptions := options.Find().SetBatchSize(int32(batchSize)).SetSort(bson.D{{Key: keyField, Value: 1}})
+	cursor, err := m.streamFind(stream, collectionName, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from collection %s after key %v,%w", collectionName, lastKey, err)
+	}
+
+	return &mongoRowIterator{
+		cursor:         cursor,
+		stream:         stream,
+		collectionName: collectionName,
+		batchSize:      batchSize,
+	}, nil
+}
+
 // importing data into the mongodb collections
 func (m *MongoDBClient) ImportData(data []map[string]interface{}) error {
+	return m.ImportDataContext(m.ctx, data)
+}
+
+// importing documents into their collections, giving up when ctx is cancelled or the import timeout expires
+func (m *MongoDBClient) ImportDataContext(parent context.Context, data []map[string]interface{}) error {
 	if m.Database == nil {
 		return fmt.Errorf("database connection cannot be establshed")
 	}
@@ -144,14 +302,21 @@ func (m *MongoDBClient) ImportData(data []map[string]interface{}) error {
 		return fmt.Errorf("no data to import")
 	}
 
-	//grouping data by collection
+	//grouping data by collection, remembering the index of each document in data
 	collectionData := make(map[string][]interface{})
-	for _, row := range data {
+	collectionRows := make(map[string][]int)
+	for i, row := range data {
 		collectionName, ok := row["_source_table"].(string)
 		if !ok {
 			return fmt.Errorf("row missing source table info")
 		}
 
+		//giving rows from SQL sources their _id here instead of on the server, the row keeps it
+		//so the rollback snapshot can identify the document
+		if row["_id"] == nil {
+			row["_id"] = primitive.NewObjectID()
+		}
+
 		//removing _source_table field before inserting
 		document := make(map[string]interface{})
 		for key, value := range row {
@@ -160,8 +325,10 @@ func (m *MongoDBClient) ImportData(data []map[string]interface{}) error {
 			}
 		}
 		collectionData[collectionName] = append(collectionData[collectionName], document)
+		collectionRows[collectionName] = append(collectionRows[collectionName], i)
 	}
 	//inserting data into  each collection
+	failed := make(map[int]error)
 	for collectionName, documents := range collectionData {
 		if len(documents) == 0 {
 			continue
@@ -169,17 +336,418 @@ func (m *MongoDBClient) ImportData(data []map[string]interface{}) error {
 		collection := m.Database.Collection(collectionName)
 
 		//creating context with timeout
-		ctx, cancel := context.WithTimeout(m.ctx, 60*time.Second)
+		ctx, cancel := context.WithTimeout(parent, timeoutOrDefault(m.Timeouts.Import, 60*time.Second))
 
 		//inserting many documents
-		result, err := collection.InsertMany(ctx, documents)
+		err := insertDocuments(ctx, collection, documents)
+		cancel()
+
+		//the other documents of an unordered insert are written, reporting only the failed ones
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil && !IsTransientError(err) {
+			for _, writeErr := range bulkErr.WriteErrors {
+				failed[collectionRows[collectionName][writeErr.Index]] = fmt.Errorf("failed to insert data into the collection %s:%w", collectionName, writeErr)
+			}
+			continue
+		}
 		if err != nil {
-			cancel()
-			return fmt.Errorf("failed to insert data into the collection %s:%v", collectionName, err)
+			return fmt.Errorf("failed to insert data into the collection %s:%w", collectionName, err)
 		}
+		fmt.Printf("Successfully imported %d documents into collection %s", len(documents), collectionName)
+	}
+	if len(failed) > 0 {
+		return &RowWriteError{Failed: failed}
+	}
+	return nil
+}
+
+// inserting documents unordered, so a failing document does not stop the others. Inserts are not transactional,
+// a document whose _id exists already was written by an earlier attempt of a retried batch and counts as written
+func insertDocuments(ctx context.Context, collection *mongo.Collection, documents []interface{}) error {
+	_, err := collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if err == nil || !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return err
+	}
+
+	var remaining []mongo.BulkWriteError
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !isDuplicateIDError(writeErr.WriteError) {
+			remaining = append(remaining, writeErr)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	bulkErr.WriteErrors = remaining
+	return bulkErr
+}
+
+// checking if a write failed because a document with the same _id exists, other unique indexes are real conflicts
+func isDuplicateIDError(writeErr mongo.WriteError) bool {
+	if writeErr.Code != 11000 {
+		return false
+	}
+	if _, err := writeErr.Raw.LookupErr("keyPattern", "_id"); err == nil {
+		return true
+	}
+	return strings.Contains(writeErr.Message, "index: _id_ ")
+}
+
+// inserting documents or replacing the existing ones with the same key field values
+func (m *MongoDBClient) UpsertData(data []map[string]interface{}, keyFields []string) error {
+	if m.Database == nil {
+		return fmt.Errorf("database connection cannot be establshed")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+	if len(keyFields) == 0 {
+		return fmt.Errorf("no key fields given for upserting documents")
+	}
+
+	//grouping replace models by collection
+	collectionModels := make(map[string][]mongo.WriteModel)
+	for _, row := range data {
+		collectionName, ok := row["_source_table"].(string)
+		if !ok {
+			return fmt.Errorf("row missing source table info")
+		}
+
+		document := make(map[string]interface{})
+		for key, value := range row {
+			if key != "_source_table" {
+				document[key] = value
+			}
+		}
+
+		filter := bson.M{}
+		for _, key := range keyFields {
+			value, ok := document[key]
+			if !ok || value == nil {
+				return fmt.Errorf("document in collection %s has no value for key field %s", collectionName, key)
+			}
+			filter[key] = value
+		}
+
+		model := mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(document).SetUpsert(true)
+		collectionModels[collectionName] = append(collectionModels[collectionName], model)
+	}
+
+	for collectionName, models := range collectionModels {
+		ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Import, 60*time.Second))
+		result, err := m.Database.Collection(collectionName).BulkWrite(ctx, models)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to upsert data into the collection %s:%w", collectionName, err)
+		}
+		fmt.Printf("Successfully upserted %d documents into collection %s (%d inserted, %d replaced)\n", len(models), collectionName, result.UpsertedCount, result.ModifiedCount)
+	}
+	return nil
+}
+
+// writing documents with the given write mode using upserting bulk writes keyed by keyFields
+func (m *MongoDBClient) WriteData(data []map[string]interface{}, mode WriteMode, keyFields []string) error {
+	switch mode {
+	case WriteModeInsert, "":
+		return m.ImportData(data)
+	case WriteModeReplace:
+		return m.UpsertData(data, keyFields)
+	case WriteModeUpsert, WriteModeSkipExisting:
+	default:
+		return fmt.Errorf("unsupported write mode %s", mode)
+	}
+
+	if m.Database == nil {
+		return fmt.Errorf("database connection cannot be establshed")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+	if len(keyFields) == 0 {
+		return fmt.Errorf("no key fields given for writing documents")
+	}
 
+	//grouping update models by collection
+	collectionModels := make(map[string][]mongo.WriteModel)
+	for _, row := range data {
+		collectionName, ok := row["_source_table"].(string)
+		if !ok {
+			return fmt.Errorf("row missing source table info")
+		}
+
+		filter := bson.M{}
+		fields := bson.M{}
+		for key, value := range row {
+			if key == "_source_table" {
+				continue
+			}
+			if containsColumn(keyFields, key) {
+				filter[key] = value
+			} else {
+				fields[key] = value
+			}
+		}
+		for _, key := range keyFields {
+			if filter[key] == nil {
+				return fmt.Errorf("document in collection %s has no value for key field %s", collectionName, key)
+			}
+		}
+
+		//key fields are taken from the filter when the document is inserted
+		operator := "$set"
+		if mode == WriteModeSkipExisting {
+			operator = "$setOnInsert"
+		}
+
+		model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{operator: fields}).SetUpsert(true)
+		collectionModels[collectionName] = append(collectionModels[collectionName], model)
+	}
+
+	for collectionName, models := range collectionModels {
+		ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Import, 60*time.Second))
+		result, err := m.Database.Collection(collectionName).BulkWrite(ctx, models)
 		cancel()
-		fmt.Printf("Successfully imported %d documents into collection %s", len(result.InsertedIDs), collectionName)
+		if err != nil {
+			return fmt.Errorf("failed to write data into the collection %s:%w", collectionName, err)
+		}
+		fmt.Printf("Successfully wrote %d documents into collection %s in %s mode (%d inserted, %d updated)\n", len(models), collectionName, mode, result.UpsertedCount, result.ModifiedCount)
+	}
+	return nil
+}
+
+// number of documents sampled to infer the fields of a collection
+const schemaSampleSize = 100
+
+// inferring the fields of a collection from a sample of its documents, as collections have no catalog
+func (m *MongoDBClient) DescribeTable(collectionName string) (*TableSchema, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection cannot be establshed")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
+	defer cancel()
+
+	cursor, err := m.Database.Collection(collectionName).Find(ctx, bson.M{}, options.Find().SetLimit(schemaSampleSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample collection %s, %v", collectionName, err)
+	}
+	defer cursor.Close(ctx)
+
+	fieldTypes := make(map[string]map[string]bool)
+	fieldCounts := make(map[string]int)
+	documents := 0
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err := cursor.Decode(&document); err != nil {
+			return nil, fmt.Errorf("failed to decode document, %v", err)
+		}
+		documents++
+		for field, value := range document {
+			if fieldTypes[field] == nil {
+				fieldTypes[field] = make(map[string]bool)
+			}
+			fieldTypes[field][bsonTypeName(value)] = true
+			fieldCounts[field]++
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error, %v", err)
+	}
+	if documents == 0 {
+		return nil, fmt.Errorf("collection %s has no documents to infer fields from", collectionName)
+	}
+
+	//_id first, other fields in name order
+	fields := make([]string, 0, len(fieldTypes))
+	for field := range fieldTypes {
+		if field != "_id" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	if _, ok := fieldTypes["_id"]; ok {
+		fields = append([]string{"_id"}, fields...)
+	}
+
+	schema := &TableSchema{Name: collectionName, Dialect: "mongodb", PrimaryKey: []string{"_id"}}
+	for _, field := range fields {
+		types := fieldTypes[field]
+		schema.Columns = append(schema.Columns, ColumnDefinition{
+			Name:       field,
+			DataType:   mergeBSONTypes(types),
+			ColumnType: mergeBSONTypes(types),
+			//fields missing in some documents or holding null are nullable
+			Nullable: fieldCounts[field] < documents || types["null"],
+		})
+	}
+	return schema, nil
+}
+
+// creating the collection, documents need no further definition
+func (m *MongoDBClient) CreateTable(schema *TableSchema) error {
+	if m.Database == nil {
+		return fmt.Errorf("database connection cannot be establshed")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
+	defer cancel()
+
+	existing, err := m.Database.ListCollectionNames(ctx, bson.M{"name": schema.Name})
+	if err != nil {
+		return fmt.Errorf("failed to list collections, %v", err)
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+	if err := m.Database.CreateCollection(ctx, schema.Name); err != nil {
+		return fmt.Errorf("failed to create collection %s:%v", schema.Name, err)
+	}
+	return nil
+}
+
+// returning the BSON type name of a decoded value
+func bsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case primitive.Decimal128:
+		return "decimal"
+	case bool:
+		return "bool"
+	case primitive.DateTime:
+		return "date"
+	case primitive.Timestamp:
+		return "timestamp"
+	case primitive.ObjectID:
+		return "objectId"
+	case bson.M, bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	case primitive.Binary:
+		return "binData"
+	default:
+		return "string"
+	}
+}
+
+// merging the types seen for a field across documents into one type
+func mergeBSONTypes(types map[string]bool) string {
+	seen := make([]string, 0, len(types))
+	for name := range types {
+		if name != "null" {
+			seen = append(seen, name)
+		}
+	}
+	sort.Strings(seen)
+
+	switch {
+	case len(seen) == 0:
+		return "null"
+	case len(seen) == 1:
+		return seen[0]
+	}
+
+	//widening numbers, anything else mixed is kept as string
+	numeric := map[string]bool{"int": true, "long": true, "double": true}
+	for _, name := range seen {
+		if !numeric[name] {
+			return "string"
+		}
+	}
+	if types["double"] {
+		return "double"
+	}
+	return "long"
+}
+
+// checking the collection list for a collection and counting its documents if it exists
+func (m *MongoDBClient) TableRowCount(collectionName string) (bool, int64, error) {
+	if m.Database == nil {
+		return false, 0, fmt.Errorf("database connection not established")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
+	defer cancel()
+
+	existing, err := m.Database.ListCollectionNames(ctx, bson.M{"name": collectionName})
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to list collections, %v", err)
+	}
+	if len(existing) == 0 {
+		return false, 0, nil
+	}
+	count, err := m.Database.Collection(collectionName).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to count documents of collection %s, %v", collectionName, err)
+	}
+	return true, count, nil
+}
+
+// returning the key of a collection, documents are always identified by _id
+func (m *MongoDBClient) PrimaryKeyColumns(collectionName string) ([]string, error) {
+	return []string{"_id"}, nil
+}
+
+// deleting the documents matching the key field values, returns the number of documents deleted
+func (m *MongoDBClient) DeleteRows(collectionName string, keyFields []string, rows []map[string]interface{}) (int64, error) {
+	if m.Database == nil {
+		return 0, fmt.Errorf("database connection cannot be establshed")
+	}
+	if len(keyFields) == 0 {
+		return 0, fmt.Errorf("no key fields given for deleting from collection %s", collectionName)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(rows))
+	for _, row := range rows {
+		filter := bson.M{}
+		for _, key := range keyFields {
+			value, ok := row[key]
+			if !ok || value == nil {
+				return 0, fmt.Errorf("document in collection %s has no value for key field %s", collectionName, key)
+			}
+			//object ids come back from the JSON snapshot as hex strings
+			if hex, isString := value.(string); isString && key == "_id" {
+				if objectID, err := primitive.ObjectIDFromHex(hex); err == nil {
+					value = objectID
+				}
+			}
+			filter[key] = value
+		}
+		models = append(models, mongo.NewDeleteOneModel().SetFilter(filter))
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Import, 60*time.Second))
+	defer cancel()
+	result, err := m.Database.Collection(collectionName).BulkWrite(ctx, models)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete documents from collection %s:%v", collectionName, err)
+	}
+	return result.DeletedCount, nil
+}
+
+// dropping a collection
+func (m *MongoDBClient) DropTable(collectionName string) error {
+	if m.Database == nil {
+		return fmt.Errorf("database connection cannot be establshed")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
+	defer cancel()
+	if err := m.Database.Collection(collectionName).Drop(ctx); err != nil {
+		return fmt.Errorf("failed to drop collection %s:%v", collectionName, err)
 	}
 	return nil
 }
@@ -198,9 +766,9 @@ func (m *MongoDBClient) ImportDataConcurrently(data []map[string]interface{}, ba
 	if batchSize <= 0 {
 		batchSize = 1000 //Default  batchsize
 	}
-	processor := NewBatchProcessor(batchSize)
-
-	return processor.ProcessInBatches(data, m.ImportData)
+	//writing non-overlapping batches concurrently, each ImportData call with its own inserts
+	writer := NewBatchWriter(DefaultBatchWriters, batchSize, m.Progress)
+	return writer.Write(data, m.ImportData)
 }
 
 //Helper functions
@@ -210,7 +778,7 @@ func (m *MongoDBClient) GetCollectionNames() ([]string, error) {
 	if m.Database == nil {
 		return nil, fmt.Errorf("database connection not established")
 	}
-	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 10*time.Second))
 	defer cancel()
 
 	return m.Database.ListCollectionNames(ctx, bson.M{})
@@ -233,7 +801,7 @@ func (m *MongoDBClient) CreateIndex(collectionName string, keys map[string]int)
 		Keys: indexKeys,
 	}
 
-	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 10*time.Second))
 	defer cancel()
 
 	_, err := collection.Indexes().CreateOne(ctx, indexModel)
@@ -245,6 +813,123 @@ func (m *MongoDBClient) CreateIndex(collectionName string, keys map[string]int)
 	return nil
 }
 
+// error code of listIndexes on a collection that does not exist
+const mongoNamespaceNotFound = 26
+
+// error code of creating an index whose keys are already indexed under another name
+const mongoIndexOptionsConflict = 85
+
+// reading the indexes of a collection, documents are keyed by _id and there are no foreign keys
+func (m *MongoDBClient) DescribeConstraints(collectionName string) (*TableConstraints, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection cannot be establshed")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
+	defer cancel()
+
+	constraints := &TableConstraints{Table: collectionName, PrimaryKey: []string{"_id"}}
+	cursor, err := m.Database.Collection(collectionName).Indexes().List(ctx)
+	if err != nil {
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && commandErr.Code == mongoNamespaceNotFound {
+			return constraints, nil
+		}
+		return nil, fmt.Errorf("failed to list indexes of collection %s, %v", collectionName, err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var specification struct {
+			Name   string `bson:"name"`
+			Key    bson.D `bson:"key"`
+			Unique bool   `bson:"unique"`
+		}
+		if err := cursor.Decode(&specification); err != nil {
+			return nil, fmt.Errorf("failed to decode index, %v", err)
+		}
+		if specification.Name == "_id_" {
+			continue
+		}
+
+		index := IndexDefinition{Name: specification.Name, Unique: specification.Unique}
+		for _, key := range specification.Key {
+			//text, hashed and geo indexes have no equivalent in SQL databases
+			if _, isString := key.Value.(string); isString {
+				log.Printf("Warning: index %s of collection %s is a %v index, skipping it", specification.Name, collectionName, key.Value)
+				index.Columns = nil
+				break
+			}
+			index.Columns = append(index.Columns, key.Key)
+		}
+		if len(index.Columns) > 0 {
+			constraints.Indexes = append(constraints.Indexes, index)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error, %v", err)
+	}
+	return constraints, nil
+}
+
+// creating a unique index on the key fields, a key other than _id comes from a SQL primary key
+func (m *MongoDBClient) AddPrimaryKey(collectionName string, fields []string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no primary key fields given for collection %s", collectionName)
+	}
+	if len(fields) == 1 && fields[0] == "_id" {
+		return nil
+	}
+	return m.createIndex(collectionName, IndexDefinition{Name: "pk_" + strings.Join(fields, "_"), Columns: fields, Unique: true})
+}
+
+// creating an ascending index on the fields of the index
+func (m *MongoDBClient) AddIndex(collectionName string, index IndexDefinition) error {
+	if len(index.Columns) == 0 {
+		return fmt.Errorf("index %s of collection %s has no fields", index.Name, collectionName)
+	}
+	return m.createIndex(collectionName, index)
+}
+
+// indexing the referencing fields of a foreign key, MongoDB does not enforce references but lookups
+// on them stay fast
+func (m *MongoDBClient) AddForeignKey(collectionName string, foreignKey ForeignKeyDefinition) error {
+	if len(foreignKey.Columns) == 0 {
+		return fmt.Errorf("foreign key %s of collection %s has no fields", foreignKey.Name, collectionName)
+	}
+	err := m.createIndex(collectionName, IndexDefinition{Name: foreignKey.Name, Columns: foreignKey.Columns})
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == mongoIndexOptionsConflict {
+		//the fields are already indexed under another name
+		return nil
+	}
+	return err
+}
+
+// creating an index with the fields in key order
+func (m *MongoDBClient) createIndex(collectionName string, index IndexDefinition) error {
+	if m.Database == nil {
+		return fmt.Errorf("database connection cannot be establshed")
+	}
+
+	keys := bson.D{}
+	for _, field := range index.Columns {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	indexOptions := options.Index().SetUnique(index.Unique)
+	if index.Name != "" {
+		indexOptions.SetName(index.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 10*time.Minute))
+	defer cancel()
+
+	if _, err := m.Database.Collection(collectionName).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: indexOptions}); err != nil {
+		return fmt.Errorf("failed to create index %s on collection %s, %w", index.Name, collectionName, err)
+	}
+	return nil
+}
+
 // to counting documents in a collection
 func (m *MongoDBClient) CountDocuments(collectionName string, filter map[string]interface{}) (int64, error) {
 	if m.Database == nil {
@@ -258,7 +943,7 @@ func (m *MongoDBClient) CountDocuments(collectionName string, filter map[string]
 		bsonFilter[key] = value
 	}
 
-	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	ctx, cancel := context.WithTimeout(m.ctx, timeoutOrDefault(m.Timeouts.Query, 10*time.Second))
 	defer cancel()
 
 	return collection.CountDocuments(ctx, bsonFilter)
@@ -335,3 +1020,64 @@ func (p *MongoCollectionParser) ParseCollectionsFromSQL(sqlFilePath string) ([]s
 	}
 	return collections, nil
 }
+
+// converting a document into a row with the values SQL targets store for the types DescribeTable reports,
+// object ids as hex strings and embedded documents and arrays as JSON. Every read and captured change
+// hands out its documents through here
+func mongoRow(collection string, document map[string]interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(document)+1)
+	for field, value := range document {
+		switch value.(type) {
+		case bson.M, bson.D, bson.A, map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(plainBSONValue(value))
+			if err != nil {
+				row[field] = fmt.Sprintf("%v", value)
+				continue
+			}
+			row[field] = string(encoded)
+		default:
+			row[field] = plainBSONValue(value)
+		}
+	}
+	row["_source_table"] = collection
+	return row
+}
+
+// converting BSON specific values into plain Go values, recursing into documents and arrays
+func plainBSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0).UTC()
+	case primitive.Decimal128:
+		return v.String()
+	case primitive.Binary:
+		return v.Data
+	case bson.M:
+		plain := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			plain[key] = plainBSONValue(nested)
+		}
+		return plain
+	case map[string]interface{}:
+		return plainBSONValue(bson.M(v))
+	case bson.D:
+		plain := make(map[string]interface{}, len(v))
+		for _, element := range v {
+			plain[element.Key] = plainBSONValue(element.Value)
+		}
+		return plain
+	case bson.A:
+		plain := make([]interface{}, len(v))
+		for i, nested := range v {
+			plain[i] = plainBSONValue(nested)
+		}
+		return plain
+	case []interface{}:
+		return plainBSONValue(bson.A(v))
+	}
+	return value
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// returning the resume token of the latest change of the collections, MongoDB 4.0.7 or later returns one
+// for a change stream without events
+func (m *MongoDBClient) CurrentChangePosition(ctx context.Context, collections []string) (string, error) {
+	if m.Database == nil {
+		return "", fmt.Errorf("database connection cannot be establshed")
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(m.Timeouts.Query, 30*time.Second))
+	defer cancel()
+
+	stream, err := m.Database.Watch(ctx, mongoChangePipeline(collections))
+	if err != nil {
+		return "", fmt.Errorf("failed to open change stream of database %s, %w", m.DBName, err)
+	}
+	defer stream.Close(ctx)
+
+	token := stream.ResumeToken()
+	if token == nil {
+		return "", fmt.Errorf("database %s returned no resume token, change streams need MongoDB 4.0.7 or later", m.DBName)
+	}
+	return encodeResumeToken(token)
+}
+
+// opening a change stream of the collections resuming after the change of the resume token in position, every
+// collection of the database is watched when collections is empty. The server must run as a replica set or
+// sharded cluster, and the oplog must still hold the change of the token
+func (m *MongoDBClient) OpenChangeStream(ctx context.Context, collections []string, position string) (ChangeStream, error) {
+	if m.Database == nil {
+		return nil, fmt.Errorf("database connection cannot be establshed")
+	}
+	token, err := parseResumeToken(position)
+	if err != nil {
+		return nil, err
+	}
+
+	//updates are captured with the whole document as it is when the change is read
+	streamOptions := options.ChangeStream().SetResumeAfter(token).SetFullDocument(options.UpdateLookup)
+	stream, err := m.Database.Watch(ctx, mongoChangePipeline(collections), streamOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change stream of database %s, %w", m.DBName, err)
+	}
+	return &mongoChangeStream{stream: stream, position: position}, nil
+}
+
+// reporting whether the resume token in position sorts at or after the one in target, the tokens of MongoDB 4.2
+// and later order changes by their hex encoded _data
+func (m *MongoDBClient) ChangePositionReached(position, target string) (bool, error) {
+	applied, err := resumeTokenData(position)
+	if err != nil {
+		return false, err
+	}
+	wanted, err := resumeTokenData(target)
+	if err != nil {
+		return false, err
+	}
+	return strings.ToUpper(applied) >= strings.ToUpper(wanted), nil
+}
+
+// pipeline passing the row changes of the collections and the event ending the stream
+func mongoChangePipeline(collections []string) mongo.Pipeline {
+	match := bson.D{{Key: "operationType", Value: bson.M{"$in": bson.A{"insert", "update", "replace", "delete", "invalidate"}}}}
+	if len(collections) > 0 {
+		names := make(bson.A, len(collections))
+		for i, collection := range collections {
+			names[i] = collection
+		}
+		match = append(match, bson.E{Key: "$or", Value: bson.A{bson.M{"ns.coll": bson.M{"$in": names}}, bson.M{"operationType": "invalidate"}}})
+	}
+	return mongo.Pipeline{{{Key: "$match", Value: match}}}
+}
+
+// encoding a resume token as extended JSON, eg. {"_data":"8263..."}
+func encodeResumeToken(token bson.Raw) (string, error) {
+	encoded, err := bson.MarshalExtJSON(token, true, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode resume token, %v", err)
+	}
+	return string(encoded), nil
+}
+
+// parsing a resume token encoded by encodeResumeToken
+func parseResumeToken(position string) (bson.Raw, error) {
+	var token bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(position), true, &token); err != nil {
+		return nil, fmt.Errorf("invalid resume token %q, expected a document like {\"_data\":\"8263...\"}, %v", position, err)
+	}
+	return token, nil
+}
+
+// returning the _data of a resume token encoded by encodeResumeToken
+func resumeTokenData(position string) (string, error) {
+	token, err := parseResumeToken(position)
+	if err != nil {
+		return "", err
+	}
+	data, ok := token.Lookup("_data").StringValueOK()
+	if !ok {
+		return "", fmt.Errorf("resume token %s has no _data string, comparing positions needs MongoDB 4.2 or later", position)
+	}
+	return data, nil
+}
+
+// change event of a change stream, only the fields used for row changes
+type mongoChangeEvent struct {
+	ID            bson.Raw            `bson:"_id"`
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	Namespace     struct {
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey  bson.M `bson:"documentKey"`
+	FullDocument bson.M `bson:"fullDocument"`
+}
+
+// change stream reading the change events of a MongoDB database
+type mongoChangeStream struct {
+	stream   *mongo.ChangeStream
+	position string //resume token of the last returned transaction
+}
+
+// blocking until the next change event, every event already received with it is returned in the same
+// transaction. The events of a multi document transaction are applied like separate changes. While no event
+// arrives, a transaction without changes moves the position to the resume token the server advanced to
+func (s *mongoChangeStream) Next(ctx context.Context) (*ChangeTransaction, error) {
+	for !s.stream.TryNext(ctx) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := s.stream.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read the change stream, %w", err)
+		}
+		if s.stream.ID() == 0 {
+			return nil, fmt.Errorf("change stream closed")
+		}
+		if token := s.stream.ResumeToken(); token != nil {
+			position, err := encodeResumeToken(token)
+			if err != nil {
+				return nil, err
+			}
+			if position != s.position {
+				s.position = position
+				return &ChangeTransaction{Position: position, CommitTime: time.Now()}, nil
+			}
+		}
+	}
+
+	transaction := &ChangeTransaction{}
+	for {
+		var event mongoChangeEvent
+		if err := s.stream.Decode(&event); err != nil {
+			return nil, fmt.Errorf("failed to decode change event, %v", err)
+		}
+		change, err := mongoRowChange(&event)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			transaction.Changes = append(transaction.Changes, *change)
+		}
+		position, err := encodeResumeToken(event.ID)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Position = position
+		transaction.CommitTime = time.Unix(int64(event.ClusterTime.T), 0)
+
+		if s.stream.RemainingBatchLength() == 0 || !s.stream.TryNext(ctx) {
+			break
+		}
+	}
+	if err := s.stream.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the change stream, %w", err)
+	}
+	s.position = transaction.Position
+	return transaction, nil
+}
+
+// converting a change event into a row change, nil for an update of a document deleted before it was looked up,
+// the delete follows later in the stream
+func mongoRowChange(event *mongoChangeEvent) (*RowChange, error) {
+	collection := event.Namespace.Collection
+	switch event.OperationType {
+	case "insert", "update", "replace":
+		if event.FullDocument == nil {
+			return nil, nil
+		}
+		operation := ChangeUpdate
+		if event.OperationType == "insert" {
+			operation = ChangeInsert
+		}
+		return &RowChange{Operation: operation, Table: collection, Row: mongoRow(collection, event.FullDocument)}, nil
+	case "delete":
+		return &RowChange{Operation: ChangeDelete, Table: collection, Row: mongoRow(collection, event.DocumentKey)}, nil
+	case "invalidate":
+		return nil, fmt.Errorf("change stream was invalidated, the database or a watched collection was dropped or renamed")
+	}
+	return nil, nil
+}
+
+// the server keeps changes in its oplog for as long as it is configured to, there is nothing to acknowledge
+func (s *mongoChangeStream) Ack(position string) error {
+	return nil
+}
+
+// closing the change stream cursor
+func (s *mongoChangeStream) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.stream.Close(ctx)
+}
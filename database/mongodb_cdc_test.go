@@ -0,0 +1,104 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResumeTokenRoundTrip(t *testing.T) {
+	token, err := bson.Marshal(bson.D{{Key: "_data", Value: "8265E7A1B2000000012B022C0100296E5A1004"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	position, err := encodeResumeToken(token)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if position != `{"_data":"8265E7A1B2000000012B022C0100296E5A1004"}` {
+		t.Errorf("unexpected position %s", position)
+	}
+
+	parsed, err := parseResumeToken(position)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bson.Raw(token).Lookup("_data").Equal(parsed.Lookup("_data")) {
+		t.Errorf("expected the token back, got %s", parsed)
+	}
+	if _, err := parseResumeToken("binlog.000042:4"); err == nil {
+		t.Error("expected an error for a position that is no resume token")
+	}
+}
+
+func TestMongoRowChange(t *testing.T) {
+	id := primitive.NewObjectID()
+	created := time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC)
+	price, _ := primitive.ParseDecimal128("19.99")
+
+	insert := &mongoChangeEvent{OperationType: "insert", FullDocument: bson.M{
+		"_id":     id,
+		"created": primitive.NewDateTimeFromTime(created),
+		"price":   price,
+		"address": bson.M{"city": "Berlin"},
+		"tags":    bson.A{"new", int32(1)},
+	}}
+	insert.Namespace.Collection = "orders"
+	change, err := mongoRowChange(insert)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	row := change.Row
+	if change.Operation != ChangeInsert || row["_id"] != id.Hex() || row["_source_table"] != "orders" {
+		t.Errorf("unexpected insert %+v", change)
+	}
+	if row["created"] != created || row["price"] != "19.99" {
+		t.Errorf("expected a time and a decimal string, got %v and %v", row["created"], row["price"])
+	}
+	if row["address"] != `{"city":"Berlin"}` || row["tags"] != `["new",1]` {
+		t.Errorf("expected embedded documents and arrays as JSON, got %v and %v", row["address"], row["tags"])
+	}
+
+	//the document was deleted before the update was read
+	update := &mongoChangeEvent{OperationType: "update", DocumentKey: bson.M{"_id": id}}
+	if change, err := mongoRowChange(update); err != nil || change != nil {
+		t.Errorf("expected the update to be skipped, got %+v, %v", change, err)
+	}
+
+	remove := &mongoChangeEvent{OperationType: "delete", DocumentKey: bson.M{"_id": id}}
+	remove.Namespace.Collection = "orders"
+	change, err = mongoRowChange(remove)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if change.Operation != ChangeDelete || len(change.Row) != 2 || change.Row["_id"] != id.Hex() {
+		t.Errorf("unexpected delete %+v", change)
+	}
+
+	if _, err := mongoRowChange(&mongoChangeEvent{OperationType: "invalidate"}); err == nil {
+		t.Error("expected an error for an invalidated stream")
+	}
+}
+
+func TestMongoChangePositionReached(t *testing.T) {
+	client := &MongoDBClient{}
+	cases := []struct {
+		position, target string
+		reached          bool
+	}{
+		{`{"_data":"8265E1A2B3000000012B"}`, `{"_data":"8265E1A2B3000000012B"}`, true},
+		{`{"_data":"8265E1A2B4000000012B"}`, `{"_data":"8265e1a2b3000000012b"}`, true},
+		{`{"_data":"8265E1A2B3000000012B"}`, `{"_data":"8265E1A2B4000000012B"}`, false},
+	}
+	for _, c := range cases {
+		reached, err := client.ChangePositionReached(c.position, c.target)
+		if err != nil || reached != c.reached {
+			t.Errorf("expected %s reaching %s to be %v, got %v, %v", c.position, c.target, c.reached, reached, err)
+		}
+	}
+	if _, err := client.ChangePositionReached(`{"_data":{"$binary":{"base64":"AQ==","subType":"00"}}}`, `{"_data":"82"}`); err == nil {
+		t.Error("expected an error for a resume token without _data string")
+	}
+}
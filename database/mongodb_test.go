@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// connecting a client to the mock deployment of the test
+func newMockMongoDBClient(mt *mtest.T) *MongoDBClient {
+	client := NewMongoDBClient("", "test")
+	client.Client, client.Database = mt.Client, mt.DB
+	return client
+}
+
+func TestMongoDBImportRetryIsIdempotent(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("retry", func(mt *mtest.T) {
+		client := newMockMongoDBClient(mt)
+		rows := []map[string]interface{}{
+			{"_source_table": "users", "id": 1, "name": "Susheel"},
+			{"_source_table": "users", "id": 2, "name": "Sathyaraj"},
+		}
+
+		//the first attempt wrote the first document before failing
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 91, Message: "shutdown in progress"}))
+		if err := client.ImportData(rows); err == nil {
+			t.Fatal("expected the failed insert to be reported")
+		}
+		first := mt.GetStartedEvent().Command
+
+		//the retry finds the first document written already
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error collection: test.users index: _id_ dup key: { _id: 1 }"}))
+		if err := client.ImportData(rows); err != nil {
+			t.Fatalf("expected the duplicate _id of the retry to count as written, got %v", err)
+		}
+		second := mt.GetStartedEvent().Command
+
+		if ordered, ok := second.Lookup("ordered").BooleanOK(); !ok || ordered {
+			t.Errorf("expected an unordered insert, got %v", second.Lookup("ordered"))
+		}
+		for i := range rows {
+			firstID := first.Lookup("documents").Array().Index(uint(i)).Value().Document().Lookup("_id")
+			secondID := second.Lookup("documents").Array().Index(uint(i)).Value().Document().Lookup("_id")
+			if firstID.Type != bson.TypeObjectID || !firstID.Equal(secondID) {
+				t.Errorf("expected document %d to keep its _id across attempts, got %v and %v", i, firstID, secondID)
+			}
+		}
+
+		//a duplicate on another unique index is a conflict
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "E11000 duplicate key error collection: test.users index: email_1 dup key: { email: \"a\" }"}))
+		if err := client.ImportData(rows); err == nil {
+			t.Error("expected a duplicate on another index to fail the insert")
+		}
+	})
+}
+
+func TestMongoDBImportReportsFailedDocuments(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("failed", func(mt *mtest.T) {
+		client := newMockMongoDBClient(mt)
+		rows := []map[string]interface{}{
+			{"_source_table": "users", "id": 1, "email": "a"},
+			{"_source_table": "users", "id": 2, "email": "a"},
+			{"_source_table": "users", "id": 3, "email": "b"},
+		}
+
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "E11000 duplicate key error collection: test.users index: email_1 dup key: { email: \"a\" }"}))
+		err := client.ImportData(rows)
+		var rowErr *RowWriteError
+		if !errors.As(err, &rowErr) || len(rowErr.Failed) != 1 || rowErr.Failed[1] == nil {
+			t.Fatalf("expected only the second document to be reported as failed, got %v", err)
+		}
+	})
+}
+
+func TestMongoDBWritesUseConfiguredTimeouts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("timeouts", func(mt *mtest.T) {
+		client := newMockMongoDBClient(mt)
+		rows := []map[string]interface{}{{"_source_table": "users", "id": 1, "name": "Susheel"}}
+
+		client.Timeouts.Import = time.Nanosecond
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		if err := client.UpsertData(rows, []string{"id"}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the upsert to stop at the import timeout, got %v", err)
+		}
+
+		//operations without a context of their own stop with the context the client connected with
+		client.Timeouts.Import = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		client.ctx = ctx
+		cancel()
+		if err := client.DropTable("users"); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Errorf("expected the drop to stop with the cancelled context, got %v", err)
+		}
+	})
+}
+
+func TestMongoDBStreamTableNormalisesValues(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("stream", func(mt *mtest.T) {
+		client := newMockMongoDBClient(mt)
+		id := primitive.NewObjectID()
+		created := time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC)
+
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: id},
+			{Key: "created", Value: primitive.NewDateTimeFromTime(created)},
+			{Key: "address", Value: bson.D{{Key: "city", Value: "Berlin"}}},
+			{Key: "tags", Value: bson.A{"new", int32(1)}},
+		}))
+		iterator, err := client.StreamTable(context.Background(), "orders", 10)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer iterator.Close()
+		if !iterator.Next() {
+			t.Fatalf("expected a batch, got %v", iterator.Err())
+		}
+
+		//read like captured changes, so full and CDC runs write the same values
+		row := iterator.Batch()[0]
+		if row["_id"] != id.Hex() || row["_source_table"] != "orders" || row["created"] != created {
+			t.Errorf("expected a hex id and a time, got %v", row)
+		}
+		if row["address"] != `{"city":"Berlin"}` || row["tags"] != `["new",1]` {
+			t.Errorf("expected embedded documents and arrays as JSON, got %v and %v", row["address"], row["tags"])
+		}
+	})
+}
+
+func TestMongoDBChangeStreamMovesIdlePosition(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("idle", func(mt *mtest.T) {
+		client := newMockMongoDBClient(mt)
+		cursor := func(batch string, token string) bson.D {
+			return bson.D{
+				{Key: "ok", Value: 1},
+				{Key: "cursor", Value: bson.D{
+					{Key: "id", Value: int64(1)},
+					{Key: "ns", Value: "test.$cmd.aggregate"},
+					{Key: batch, Value: bson.A{}},
+					{Key: "postBatchResumeToken", Value: bson.D{{Key: "_data", Value: token}}},
+				}},
+			}
+		}
+		//the last response answers killCursors when the stream is closed
+		mt.AddMockResponses(cursor("firstBatch", "8265E1A2B3000000012B"), cursor("nextBatch", "8265E1A2B4000000012B"), mtest.CreateSuccessResponse())
+
+		stream, err := client.OpenChangeStream(context.Background(), []string{"users"}, `{"_data":"8265E1A2B3000000012B"}`)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		defer stream.Close()
+		//no event arrived, the server moved the stream past the writes to other collections
+		transaction, err := stream.Next(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if transaction.Position != `{"_data":"8265E1A2B4000000012B"}` || len(transaction.Changes) != 0 {
+			t.Errorf("expected a transaction without changes at the advanced resume token, got %+v", transaction)
+		}
+	})
+}
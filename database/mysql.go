@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -19,6 +21,14 @@ type MySQLClient struct {
 	Port     int
 	DBName   string
 	DB       *sql.DB
+	BulkLoad bool //loading rows with multi-row INSERT statements instead of one per row
+
+	MaxAllowedPacket int64 //max_allowed_packet of the server, bounding the size of a multi-row INSERT
+	Timeouts         config.TimeoutConfig
+	Filters          RowFilters //WHERE conditions restricting the rows read from each table
+	ReplicaServerID  uint32     //server id the binlog reader registers with, must differ from every server and replica
+
+	Progress *monitoring.ProcessTracker //progress of the batches of ImportDataConcurrently, nil when not reported
 }
 
 // create a MySQL client using manual parameters, (for tests)
@@ -29,6 +39,7 @@ func NewMySQLClient(user, password, host string, port int, dbname string) *MySQL
 		Host:     host,
 		Port:     port,
 		DBName:   dbname,
+		BulkLoad: true,
 	}
 }
 
@@ -40,11 +51,25 @@ func NewMYSQLClientFromConfig(cfg *config.Config) *MySQLClient {
 		Host:     cfg.MySQL.Host,
 		Port:     cfg.MySQL.Port,
 		DBName:   cfg.MySQL.DBName,
+		BulkLoad: true,
+		Timeouts: cfg.Timeouts,
+
+		ReplicaServerID: cfg.MySQL.ServerID,
 	}
 }
 
+// naming the database as host:port/database
+func (c *MySQLClient) Location() string {
+	return fmt.Sprintf("%s:%d/%s", c.Host, c.Port, c.DBName)
+}
+
 // to connect with the MySQL DB
 func (c *MySQLClient) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// to connect with the MySQL DB, giving up when ctx is cancelled or the connect timeout expires
+func (c *MySQLClient) ConnectContext(ctx context.Context) error {
 	// DSN for MySQL
 	//format: user:password@tcp(host:port)/name
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true", c.User, c.Password, c.Host, c.Port, c.DBName)
@@ -56,16 +81,43 @@ func (c *MySQLClient) Connect() error {
 	}
 
 	//test the connection
-	if err = db.Ping(); err != nil {
+	ctx, cancel := operationContext(ctx, c.Timeouts.Connect)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
 		return fmt.Errorf("failed to ping to the SQL database, %v", err)
 	}
 
 	c.DB = db
 
+	//reading the packet limit sizing multi-row inserts, the default is used when it cannot be read
+	if err := db.QueryRowContext(ctx, "SELECT @@max_allowed_packet").Scan(&c.MaxAllowedPacket); err != nil {
+		c.MaxAllowedPacket = defaultMaxAllowedPacket
+	}
+
 	fmt.Println("Successfully connected to MySQL database... ")
 	return nil
 }
 
+// reporting the rows of every batch written by ImportDataConcurrently to tracker
+func (c *MySQLClient) SetProgressTracker(tracker *monitoring.ProcessTracker) {
+	c.Progress = tracker
+}
+
+// loading rows with multi-row INSERT statements, or with one INSERT per row when disabled
+func (c *MySQLClient) SetBulkLoad(enabled bool) {
+	c.BulkLoad = enabled
+}
+
+// restricting the rows read from each table to those matching its WHERE condition
+func (c *MySQLClient) SetRowFilters(filters RowFilters) error {
+	if err := filters.validateSQL(); err != nil {
+		return err
+	}
+	c.Filters = filters
+	return nil
+}
+
 // closes the database connection
 func (c *MySQLClient) Close() error {
 	if c.DB != nil {
@@ -84,6 +136,11 @@ func (c *MySQLClient) ExecuteQuery(query string) (*sql.Rows, error) {
 
 // fetches all data from all the specified tables
 func (c *MySQLClient) FetchAllData(tables []string) ([]map[string]interface{}, error) {
+	return c.FetchAllDataContext(context.Background(), tables)
+}
+
+// fetching all rows of the tables, cancelling the running query when ctx is cancelled or the query timeout expires
+func (c *MySQLClient) FetchAllDataContext(ctx context.Context, tables []string) ([]map[string]interface{}, error) {
 	if c.DB == nil {
 		return nil, fmt.Errorf("db connection not established")
 	}
@@ -93,9 +150,9 @@ func (c *MySQLClient) FetchAllData(tables []string) ([]map[string]interface{}, e
 	for _, tableName := range tables {
 		//sanitize table to prevent sql injection
 		sanitizedTableName := sanitizeIdentifier(tableName)
-		query := fmt.Sprintf("SELECT * FROM %s;", sanitizedTableName)
+		query := fmt.Sprintf("SELECT * FROM %s%s;", sanitizedTableName, whereClause(c.Filters.For(tableName)))
 
-		results, err := c.fetchDataFromTable(query)
+		results, err := c.fetchDataFromTable(ctx, query)
 		if err != nil {
 			return nil, fmt.Errorf("error fetching data from the table %s: %v", tableName, err)
 		}
@@ -110,8 +167,10 @@ func (c *MySQLClient) FetchAllData(tables []string) ([]map[string]interface{}, e
 }
 
 // executes a query and returns the result as a slice of maps
-func (c *MySQLClient) fetchDataFromTable(query string) ([]map[string]interface{}, error) {
-	rows, err := c.DB.Query(query)
+func (c *MySQLClient) fetchDataFromTable(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	ctx, cancel := operationContext(ctx, c.Timeouts.Query)
+	defer cancel()
+	rows, err := c.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query %v", err)
 	}
@@ -164,6 +223,125 @@ func (c *MySQLClient) fetchDataFromTable(query string) ([]map[string]interface{}
 	return results, nil
 }
 
+// streaming rows of a single table in batches without loading the whole table
+func (c *MySQLClient) StreamTable(ctx context.Context, tableName string, batchSize int) (RowIterator, error) {
+	if c.DB == nil {
+		return nil, fmt.Errorf("db connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	query := fmt.Sprintf("SELECT * FROM %s%s;", sanitizedTableName, whereClause(c.Filters.For(tableName)))
+
+	stream := newStreamContext(ctx, c.Timeouts.Query)
+	rows, err := stream.query(c.DB, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// streaming rows whose watermark column is at or past the given watermark, ordered by that column. Rows at
+// the watermark are read again, as rows committed after the last run may share its value
+func (c *MySQLClient) StreamTableSince(ctx context.Context, tableName, column string, watermark interface{}, batchSize int) (RowIterator, error) {
+	if watermark == nil {
+		return c.StreamTable(ctx, tableName, batchSize)
+	}
+	if c.DB == nil {
+		return nil, fmt.Errorf("db connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	sanitizedColumn := sanitizeIdentifier(column)
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizedTableName,
+		whereClause(c.Filters.For(tableName), sanitizedColumn+" >= ?"), sanitizedColumn)
+
+	stream := newStreamContext(ctx, c.Timeouts.Query)
+	rows, err := stream.query(c.DB, query, watermark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute incremental query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// streaming rows ordered by a key column, only rows after lastKey unless it is nil
+func (c *MySQLClient) StreamTableAfter(ctx context.Context, tableName, keyColumn string, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if c.DB == nil {
+		return nil, fmt.Errorf("db connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	sanitizedColumn := sanitizeIdentifier(keyColumn)
+	var conditions []string
+	var args []interface{}
+	if lastKey != nil {
+		conditions = append(conditions, sanitizedColumn+" > ?")
+		args = append(args, lastKey)
+	}
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizedTableName, whereClause(c.Filters.For(tableName), conditions...), sanitizedColumn)
+
+	stream := newStreamContext(ctx, c.Timeouts.Query)
+	rows, err := stream.query(c.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute keyset query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// splitting a table with a single column integer primary key into ranges of about rowsPerRange rows,
+// nil when the table has no such key or fits into one range
+func (c *MySQLClient) SplitTable(tableName string, rowsPerRange int64) ([]KeyRange, error) {
+	schema, err := c.DescribeTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	keyColumn := integerKeyColumn(schema)
+	if keyColumn == "" {
+		return nil, nil
+	}
+
+	return queryKeyRanges(c.DB, tableName, keyColumn, c.Filters.For(tableName), rowsPerRange)
+}
+
+// streaming the rows of a key range ordered by its key, only rows after lastKey unless it is nil
+func (c *MySQLClient) StreamTableRange(ctx context.Context, tableName string, keyRange KeyRange, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if c.DB == nil {
+		return nil, fmt.Errorf("db connection not established")
+	}
+	if !keyRange.IsKeyed() {
+		return nil, fmt.Errorf("mysql cannot read table %s by %s ranges", tableName, keyRange.Column)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	placeholder := func(i int) string { return "?" }
+	query, args := rangeQuery(tableName, keyRange, lastKey, c.Filters.For(tableName), placeholder)
+	stream := newStreamContext(ctx, c.Timeouts.Query)
+	rows, err := stream.query(c.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute range query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// discovering the base tables of the current database, or of the filter's schemas, from information_schema
+func (c *MySQLClient) DiscoverTables(filter TableFilter) ([]string, error) {
+	placeholder := func(i int) string { return "?" }
+	condition, args := schemaCondition("TABLE_SCHEMA", "DATABASE()", filter.Schemas, placeholder)
+	query := fmt.Sprintf(`SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_SCHEMA = DATABASE() FROM information_schema.TABLES
+		WHERE TABLE_TYPE = 'BASE TABLE' AND %s ORDER BY TABLE_SCHEMA, TABLE_NAME`, condition)
+	return discoverSQLTables(c.DB, query, args, filter)
+}
+
 // fetching data from multiple tablesusing worker pools
 func (c *MySQLClient) FetchAllDataConcurrently(tables []string, numWorkers int) ([]map[string]interface{}, error) {
 	if numWorkers <= 0 {
@@ -173,6 +351,11 @@ func (c *MySQLClient) FetchAllDataConcurrently(tables []string, numWorkers int)
 }
 
 func (c *MySQLClient) ImportData(data []map[string]interface{}) error {
+	return c.ImportDataContext(context.Background(), data)
+}
+
+// importing rows with one transaction per table, rolled back when ctx is cancelled or the import timeout expires
+func (c *MySQLClient) ImportDataContext(ctx context.Context, data []map[string]interface{}) error {
 	if c.DB == nil {
 		return fmt.Errorf("database connection not established")
 	}
@@ -197,76 +380,313 @@ func (c *MySQLClient) ImportData(data []map[string]interface{}) error {
 		}
 		//get column names apart from _source_table
 		first_row := rows[0]
-		columns := make([]string, 0, len(first_row)-1)
-		for col := range first_row {
-			if col != "_source_table" {
-				columns = append(columns, col)
-			}
-		}
+		columns := rowColumns(first_row)
 
 		//Designing Transaction
-		tx, err := c.DB.Begin()
+		txCtx, cancel := operationContext(ctx, c.Timeouts.Import)
+		defer cancel()
+		tx, err := c.DB.BeginTx(txCtx, nil)
 		if err != nil {
-			return fmt.Errorf("failed to begin transaction, %v", err)
+			return fmt.Errorf("failed to begin transaction, %w", err)
 		}
 
 		//Creating table if not present
 		createTableSQL := generateMySQLCreateTableSQL(tableName, first_row)
-		_, err = tx.Exec(createTableSQL)
+		_, err = tx.ExecContext(txCtx, createTableSQL)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to create a table %s, %v", tableName, err)
+			return fmt.Errorf("failed to create a table %s, %w", tableName, err)
 		}
 
-		//Preparing insert statement
-		placeholder := make([]string, len(columns))
-		for i := range placeholder {
-			placeholder[i] = "?"
+		//Inserting rows with multi-row statements unless row by row inserts were selected
+		if c.BulkLoad {
+			err = insertMultiRows(txCtx, tx, tableName, columns, rows, c.MaxAllowedPacket, mysqlMaxPlaceholders)
+		} else {
+			placeholder := func(i int) string { return "?" }
+			err = insertTableRows(txCtx, tx, tableName, columns, rows, placeholder)
 		}
-
-		insertSQL := fmt.Sprintf(
-			"INSERT INTO %s (%s) VALUES(%s)",
-			tableName,
-			strings.Join(columns, ", "),
-			strings.Join(placeholder, ", "),
-		)
-		stmt, err := tx.Prepare(insertSQL)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to prepare statement, %v", err)
-		}
-		defer stmt.Close()
-
-		//Inserting rows
-		for _, row := range rows {
-			values := make([]interface{}, len(columns))
-			for i, col := range columns {
-				values[i] = row[col]
-			}
-			_, err := stmt.Exec(values...)
-			if err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to insert row, %v", err)
-			}
+			return err
 		}
 		//Commit transaction
 		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction, %v", err)
+			return fmt.Errorf("failed to commit transaction, %w", err)
 		}
 		fmt.Printf("Successfully imported %d rows into table %s", len(rows), tableName)
 	}
 	return nil
 }
 
+// inserting rows or replacing the existing ones with the same key column values
+func (c *MySQLClient) UpsertData(data []map[string]interface{}, keyColumns []string) error {
+	if c.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	placeholder := func(i int) string { return "?" }
+	for tableName, rows := range tableData {
+		createTableSQL := generateMySQLCreateTableSQL(tableName, rows[0])
+		if err := upsertTableRows(c.DB, tableName, rows, keyColumns, createTableSQL, placeholder); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully upserted %d rows into table %s\n", len(rows), tableName)
+	}
+	return nil
+}
+
+// writing rows with the given write mode, conflicts are detected on any primary or unique key of the table
+func (c *MySQLClient) WriteData(data []map[string]interface{}, mode WriteMode, keyColumns []string) error {
+	switch mode {
+	case WriteModeInsert, "":
+		return c.ImportData(data)
+	case WriteModeReplace:
+		return c.UpsertData(data, keyColumns)
+	case WriteModeUpsert, WriteModeSkipExisting:
+	default:
+		return fmt.Errorf("unsupported write mode %s", mode)
+	}
+
+	if c.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	placeholder := func(i int) string { return "?" }
+	for tableName, rows := range tableData {
+		createTableSQL := generateMySQLCreateTableSQL(tableName, rows[0], keyColumns...)
+		buildInsertSQL := func(columns []string) string {
+			return mysqlConflictInsertSQL(tableName, columns, keyColumns, mode)
+		}
+		if err := writeTableRows(c.DB, c.DescribeConstraints, tableName, rows, mode, keyColumns, createTableSQL, buildInsertSQL, placeholder); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully wrote %d rows into table %s in %s mode\n", len(rows), tableName, mode)
+	}
+	return nil
+}
+
+// checking the catalog for a table and counting its rows if it exists
+func (c *MySQLClient) TableRowCount(tableName string) (bool, int64, error) {
+	query := `SELECT COUNT(*) FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?`
+	return queryTableRowCount(c.DB, query, tableName)
+}
+
+// returning the primary key columns of a table from information_schema
+func (c *MySQLClient) PrimaryKeyColumns(tableName string) ([]string, error) {
+	query := `SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
+		ORDER BY ORDINAL_POSITION`
+	schema, name := splitQualifiedName(tableName)
+	return queryPrimaryKeyColumns(c.DB, query, schema, name)
+}
+
+// MySQL types whose defaults are reported as plain numbers
+var mysqlNumericTypes = map[string]bool{
+	"tinyint": true, "smallint": true, "mediumint": true, "int": true, "integer": true, "bigint": true,
+	"decimal": true, "float": true, "double": true, "bit": true, "year": true, "boolean": true,
+}
+
+// reading the column definitions and primary key of a table from information_schema
+func (c *MySQLClient) DescribeTable(tableName string) (*TableSchema, error) {
+	if c.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	query := `SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE,
+		IS_NULLABLE, COLUMN_DEFAULT, EXTRA
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?
+		ORDER BY ORDINAL_POSITION`
+	schemaName, name := splitQualifiedName(tableName)
+	rows, err := c.DB.Query(query, schemaName, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	schema := &TableSchema{Name: tableName, Dialect: "mysql"}
+	for rows.Next() {
+		var column ColumnDefinition
+		var isNullable, extra string
+		var length, precision, scale sql.NullInt64
+		var columnDefault sql.NullString
+		if err := rows.Scan(&column.Name, &column.DataType, &column.ColumnType, &length, &precision, &scale, &isNullable, &columnDefault, &extra); err != nil {
+			return nil, fmt.Errorf("failed to scan column definition, %v", err)
+		}
+
+		column.DataType = strings.ToLower(column.DataType)
+		column.Length = length.Int64
+		column.Nullable = strings.EqualFold(isNullable, "YES")
+		column.AutoIncrement = strings.Contains(strings.ToLower(extra), "auto_increment")
+		//precision of integer and floating point types is implied by the type
+		if column.DataType == "decimal" || column.DataType == "bit" {
+			column.Precision, column.Scale = precision.Int64, scale.Int64
+		}
+
+		var defaultValue *string
+		if columnDefault.Valid {
+			defaultValue = &columnDefault.String
+		}
+		column.Default = normalizeMySQLDefault(defaultValue, extra, mysqlNumericTypes[column.DataType], column.Name)
+		schema.Columns = append(schema.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+
+	if schema.PrimaryKey, err = c.PrimaryKeyColumns(tableName); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// creating a table from a schema translated to mysql
+func (c *MySQLClient) CreateTable(schema *TableSchema) error {
+	if c.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if schema.Dialect != "mysql" {
+		return fmt.Errorf("schema of table %s is in %s, translate it to mysql first", schema.Name, schema.Dialect)
+	}
+
+	if _, err := c.DB.Exec(mysqlCreateTableDDL(schema)); err != nil {
+		return fmt.Errorf("failed to create table %s, %v", schema.Name, err)
+	}
+	return nil
+}
+
+// reading the primary key, indexes and foreign keys of a table from information_schema
+func (c *MySQLClient) DescribeConstraints(tableName string) (*TableConstraints, error) {
+	indexQuery := `SELECT INDEX_NAME, NON_UNIQUE = 0, COLUMN_NAME FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY'
+		ORDER BY INDEX_NAME, SEQ_IN_INDEX`
+	foreignKeyQuery := `SELECT kcu.CONSTRAINT_NAME, kcu.COLUMN_NAME,
+		IF(kcu.REFERENCED_TABLE_SCHEMA = DATABASE(), kcu.REFERENCED_TABLE_NAME, CONCAT(kcu.REFERENCED_TABLE_SCHEMA, '.', kcu.REFERENCED_TABLE_NAME)),
+		kcu.REFERENCED_COLUMN_NAME, rc.UPDATE_RULE, rc.DELETE_RULE
+		FROM information_schema.KEY_COLUMN_USAGE kcu
+		JOIN information_schema.REFERENTIAL_CONSTRAINTS rc
+		ON rc.CONSTRAINT_SCHEMA = kcu.CONSTRAINT_SCHEMA AND rc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME
+		WHERE kcu.TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND kcu.TABLE_NAME = ? AND kcu.REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY kcu.CONSTRAINT_NAME, kcu.ORDINAL_POSITION`
+	schemaName, name := splitQualifiedName(tableName)
+
+	constraints := &TableConstraints{Table: tableName}
+	var err error
+	if constraints.PrimaryKey, err = c.PrimaryKeyColumns(tableName); err != nil {
+		return nil, err
+	}
+	if constraints.Indexes, err = queryIndexes(c.DB, indexQuery, schemaName, name); err != nil {
+		return nil, err
+	}
+	if constraints.ForeignKeys, err = queryForeignKeys(c.DB, foreignKeyQuery, schemaName, name); err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// adding a primary key to a table
+func (c *MySQLClient) AddPrimaryKey(tableName string, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no primary key columns given for table %s", tableName)
+	}
+	keyParts, err := c.keyParts(tableName, columns)
+	if err != nil {
+		return err
+	}
+	return execConstraintDDL(c.DB, addPrimaryKeyDDL(tableName, keyParts))
+}
+
+// characters of TEXT and BLOB columns indexed, 191 utf8mb4 characters stay within the 767 byte key limit of older row formats
+const mysqlIndexPrefixLength = 191
+
+// returning the key parts indexing the columns of a table, MySQL only indexes a prefix of TEXT and BLOB columns
+// and fails with error 1170 without a prefix length. A unique index then only keeps the prefixes unique
+func (c *MySQLClient) keyParts(tableName string, columns []string) ([]string, error) {
+	schema, err := c.DescribeTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	dataTypes := make(map[string]string, len(schema.Columns))
+	for _, column := range schema.Columns {
+		dataTypes[strings.ToLower(column.Name)] = column.DataType
+	}
+
+	keyParts := make([]string, len(columns))
+	for i, column := range columns {
+		dataType := dataTypes[strings.ToLower(column)]
+		switch {
+		case strings.EqualFold(dataType, "json"):
+			return nil, fmt.Errorf("column %s of table %s is JSON, which MySQL cannot index", column, tableName)
+		case mysqlLargeObjectType.MatchString(dataType):
+			keyParts[i] = fmt.Sprintf("%s(%d)", column, mysqlIndexPrefixLength)
+		default:
+			keyParts[i] = column
+		}
+	}
+	return keyParts, nil
+}
+
+// creating a secondary or unique index, index names only have to be unique per table in MySQL
+func (c *MySQLClient) AddIndex(tableName string, index IndexDefinition) error {
+	if len(index.Columns) == 0 {
+		return fmt.Errorf("index %s of table %s has no columns", index.Name, tableName)
+	}
+	keyParts, err := c.keyParts(tableName, index.Columns)
+	if err != nil {
+		return err
+	}
+	index.Columns = keyParts
+	return execConstraintDDL(c.DB, createIndexDDL(tableName, index.Name, index, false))
+}
+
+// adding a foreign key to a table
+func (c *MySQLClient) AddForeignKey(tableName string, foreignKey ForeignKeyDefinition) error {
+	if err := validateForeignKey(tableName, foreignKey); err != nil {
+		return err
+	}
+	return execConstraintDDL(c.DB, addForeignKeyDDL(tableName, foreignKey))
+}
+
+// deleting the rows matching the key column values, returns the number of rows deleted
+func (c *MySQLClient) DeleteRows(tableName string, keyColumns []string, rows []map[string]interface{}) (int64, error) {
+	placeholder := func(i int) string { return "?" }
+	return deleteTableRows(c.DB, tableName, keyColumns, rows, placeholder)
+}
+
+// dropping a table
+func (c *MySQLClient) DropTable(tableName string) error {
+	return dropSQLTable(c.DB, tableName)
+}
+
 // importing data concurrently
 func (c *MySQLClient) ImportDataConcurrently(data []map[string]interface{}, batchsize int) error {
 	if batchsize <= 0 {
 		batchsize = 1000 //default batch size
 	}
 
-	processor := NewBatchProcessor(batchsize)
-
-	return processor.ProcessInBatches(data, c.ImportData)
+	//writing non-overlapping batches concurrently, each ImportData call runs in its own transaction
+	writer := NewBatchWriter(DefaultBatchWriters, batchsize, c.Progress)
+	return writer.Write(data, c.ImportData)
 }
 
 // SQLParser provides methods for parsingSQL files
@@ -372,7 +792,7 @@ func FetchDataFromConfig(cfg *config.Config) ([]map[string]interface{}, error) {
 }
 
 // Helper function  for MYSQL create table
-func generateMySQLCreateTableSQL(tableName string, sampleRow map[string]interface{}) string {
+func generateMySQLCreateTableSQL(tableName string, sampleRow map[string]interface{}, keyColumns ...string) string {
 	columns := make([]string, 0, len(sampleRow)-1)
 	for col, val := range sampleRow {
 		if col == "_source_table" {
@@ -389,6 +809,10 @@ func generateMySQLCreateTableSQL(tableName string, sampleRow map[string]interfac
 			dataType = "BOOLEAN"
 		case string:
 			dataType = "TEXT"
+			//TEXT columns cannot be part of a primary key without a prefix length
+			if containsColumn(keyColumns, col) {
+				dataType = "VARCHAR(255)"
+			}
 		case []byte:
 			dataType = "BLOB"
 		case nil:
@@ -398,6 +822,9 @@ func generateMySQLCreateTableSQL(tableName string, sampleRow map[string]interfac
 		}
 		columns = append(columns, fmt.Sprintf("%s %s", col, dataType))
 	}
+	if len(keyColumns) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keyColumns, ", ")))
+	}
 	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", tableName, strings.Join(columns, ", "))
 }
 
@@ -0,0 +1,361 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logical decoding output plugins supported by change data capture
+const (
+	pluginPgoutput = "pgoutput"
+	pluginWal2JSON = "wal2json"
+)
+
+// parsing a log sequence number in its text form, eg. 16/B374D848
+func parseLSN(lsn string) (uint64, error) {
+	high, low, ok := strings.Cut(strings.TrimSpace(lsn), "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q, expected the form 16/B374D848", lsn)
+	}
+	h, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q, %v", lsn, err)
+	}
+	l, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q, %v", lsn, err)
+	}
+	return h<<32 | l, nil
+}
+
+// formatting a log sequence number like PostgreSQL does
+func formatLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", lsn>>32, uint32(lsn))
+}
+
+// column of a relation announced by pgoutput
+type pgoutputColumn struct {
+	Name    string
+	Key     bool //part of the replica identity
+	TypeOID uint32
+}
+
+// table announced by a pgoutput relation message before its first change
+type pgoutputRelation struct {
+	ID        uint32
+	Namespace string
+	Name      string
+	Columns   []pgoutputColumn
+}
+
+// decoded pgoutput message, only the fields of its kind are set
+type pgoutputMessage struct {
+	Kind       byte
+	EndLSN     uint64    //commit
+	CommitTime time.Time //begin and commit
+	Relation   *pgoutputRelation
+	RelationID uint32              //insert, update and delete
+	Old        []pgoutputTupleData //key or full old row of an update or delete, nil when not sent
+	OldKeyOnly bool                //old tuple holds only the replica identity columns
+	New        []pgoutputTupleData
+}
+
+// value of a column in a tuple, unchanged TOASTed values are not sent by the server
+type pgoutputTupleData struct {
+	Null      bool
+	Unchanged bool
+	Text      string
+}
+
+// PostgreSQL timestamps count microseconds since 2000-01-01
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// decoding one message of the pgoutput protocol version 1
+func parsePgoutputMessage(data []byte) (*pgoutputMessage, error) {
+	r := &pgoutputReader{data: data}
+	message := &pgoutputMessage{Kind: r.byte()}
+	switch message.Kind {
+	case 'B':
+		r.uint64() //final LSN of the transaction
+		message.CommitTime = postgresEpoch.Add(time.Duration(int64(r.uint64())) * time.Microsecond)
+		r.uint32() //xid
+	case 'C':
+		r.byte() //flags
+		r.uint64()
+		message.EndLSN = r.uint64()
+		message.CommitTime = postgresEpoch.Add(time.Duration(int64(r.uint64())) * time.Microsecond)
+	case 'R':
+		relation := &pgoutputRelation{ID: r.uint32(), Namespace: r.string(), Name: r.string()}
+		r.byte() //replica identity setting
+		columns := int(r.uint16())
+		for i := 0; i < columns && r.err == nil; i++ {
+			flags := r.byte()
+			column := pgoutputColumn{Key: flags&1 != 0, Name: r.string(), TypeOID: r.uint32()}
+			r.uint32() //type modifier
+			relation.Columns = append(relation.Columns, column)
+		}
+		message.Relation = relation
+	case 'I':
+		message.RelationID = r.uint32()
+		if kind := r.byte(); kind != 'N' && r.err == nil {
+			return nil, fmt.Errorf("unexpected tuple %q in insert message", kind)
+		}
+		message.New = r.tuple()
+	case 'U':
+		message.RelationID = r.uint32()
+		kind := r.byte()
+		if kind == 'K' || kind == 'O' {
+			message.OldKeyOnly = kind == 'K'
+			message.Old = r.tuple()
+			kind = r.byte()
+		}
+		if kind != 'N' && r.err == nil {
+			return nil, fmt.Errorf("unexpected tuple %q in update message", kind)
+		}
+		message.New = r.tuple()
+	case 'D':
+		message.RelationID = r.uint32()
+		kind := r.byte()
+		if kind != 'K' && kind != 'O' && r.err == nil {
+			return nil, fmt.Errorf("unexpected tuple %q in delete message", kind)
+		}
+		message.OldKeyOnly = kind == 'K'
+		message.Old = r.tuple()
+	default:
+		//origin, type, truncate and logical messages carry no row changes
+		return message, nil
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed pgoutput message %q, %v", message.Kind, r.err)
+	}
+	return message, nil
+}
+
+// converting a pgoutput tuple of a relation into a row, columns outside the identity are left out of key only
+// tuples and unchanged TOASTed values, which the server does not send, are left out as well
+func pgoutputRow(relation *pgoutputRelation, tuple []pgoutputTupleData, keyOnly bool) (map[string]interface{}, error) {
+	if len(tuple) != len(relation.Columns) {
+		return nil, fmt.Errorf("table %s.%s has %d columns, its change has %d", relation.Namespace, relation.Name, len(relation.Columns), len(tuple))
+	}
+	row := make(map[string]interface{}, len(tuple)+1)
+	for i, value := range tuple {
+		column := relation.Columns[i]
+		if value.Unchanged || (keyOnly && !column.Key) {
+			continue
+		}
+		if value.Null {
+			row[column.Name] = nil
+			continue
+		}
+		row[column.Name] = decodePostgresText(postgresTypeNames[column.TypeOID], value.Text)
+	}
+	return row, nil
+}
+
+// names of the builtin types whose text form is converted, other types are kept as text
+var postgresTypeNames = map[uint32]string{
+	16:   "boolean",
+	17:   "bytea",
+	20:   "bigint",
+	21:   "smallint",
+	23:   "integer",
+	26:   "oid",
+	700:  "real",
+	701:  "double precision",
+	1082: "date",
+	1114: "timestamp without time zone",
+	1184: "timestamp with time zone",
+}
+
+// converting the text form of a value of the named type into the value FetchAllData returns for it
+func decodePostgresText(typeName, text string) interface{} {
+	//type names of wal2json carry modifiers like numeric(10,2) or timestamp(3) without time zone
+	if i := strings.Index(typeName, "("); i >= 0 {
+		if j := strings.Index(typeName[i:], ")"); j >= 0 {
+			typeName = typeName[:i] + typeName[i+j+1:]
+		}
+	}
+	switch typeName {
+	case "boolean":
+		return text == "t" || text == "true"
+	case "smallint", "integer", "bigint", "oid":
+		if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return value
+		}
+	case "real", "double precision":
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			return value
+		}
+	case "bytea":
+		if decoded, err := hex.DecodeString(strings.TrimPrefix(text, `\x`)); err == nil {
+			return string(decoded)
+		}
+	case "date":
+		if value, err := time.Parse("2006-01-02", text); err == nil {
+			return value
+		}
+	case "timestamp without time zone":
+		if value, err := time.Parse("2006-01-02 15:04:05.999999999", text); err == nil {
+			return value
+		}
+	case "timestamp with time zone":
+		//the offset is printed as +01, +05:30 or +05:30:15 depending on the zone
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999-07:00:00"} {
+			if value, err := time.Parse(layout, text); err == nil {
+				return value
+			}
+		}
+	}
+	//numeric, text, json and values like infinity or BC dates are kept as text
+	return text
+}
+
+// reader over a pgoutput message, remembering the first error instead of failing every call
+type pgoutputReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *pgoutputReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("message ends after %d bytes", len(r.data))
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *pgoutputReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// reading a zero terminated string
+func (r *pgoutputReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = fmt.Errorf("unterminated string")
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+// reading the column values of a tuple
+func (r *pgoutputReader) tuple() []pgoutputTupleData {
+	columns := int(r.uint16())
+	tuple := make([]pgoutputTupleData, 0, columns)
+	for i := 0; i < columns && r.err == nil; i++ {
+		switch kind := r.byte(); kind {
+		case 'n':
+			tuple = append(tuple, pgoutputTupleData{Null: true})
+		case 'u':
+			tuple = append(tuple, pgoutputTupleData{Unchanged: true})
+		case 't':
+			length := int(int32(r.uint32()))
+			tuple = append(tuple, pgoutputTupleData{Text: string(r.bytes(length))})
+		default:
+			if r.err == nil {
+				r.err = fmt.Errorf("unsupported tuple value %q", kind)
+			}
+		}
+	}
+	return tuple
+}
+
+// one change of the wal2json output format version 2
+type wal2jsonChange struct {
+	Action    string           `json:"action"`
+	Schema    string           `json:"schema"`
+	Table     string           `json:"table"`
+	Timestamp string           `json:"timestamp"`
+	Columns   []wal2jsonColumn `json:"columns"`
+	Identity  []wal2jsonColumn `json:"identity"` //replica identity columns of the old row of updates and deletes
+}
+
+type wal2jsonColumn struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// decoding a wal2json change, numbers are kept as json.Number until their column type is known
+func parseWal2JSONChange(data []byte) (*wal2jsonChange, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var change wal2jsonChange
+	if err := decoder.Decode(&change); err != nil {
+		return nil, fmt.Errorf("failed to decode wal2json change, %v", err)
+	}
+	return &change, nil
+}
+
+// converting wal2json columns into a row, nil when the change carries no columns
+func wal2jsonRow(columns []wal2jsonColumn) map[string]interface{} {
+	if len(columns) == 0 {
+		return nil
+	}
+	row := make(map[string]interface{}, len(columns)+1)
+	for _, column := range columns {
+		switch value := column.Value.(type) {
+		case json.Number:
+			typeName := column.Type
+			if i := strings.Index(typeName, "("); i >= 0 {
+				typeName = typeName[:i]
+			}
+			if typeName == "numeric" {
+				//keeping the exact digits like FetchAllData does
+				row[column.Name] = value.String()
+			} else if integer, err := value.Int64(); err == nil {
+				row[column.Name] = integer
+			} else if float, err := value.Float64(); err == nil {
+				row[column.Name] = float
+			} else {
+				row[column.Name] = value.String()
+			}
+		case string:
+			row[column.Name] = decodePostgresText(column.Type, value)
+		default:
+			row[column.Name] = value
+		}
+	}
+	return row
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// building pgoutput messages like the server sends them
+type pgoutputBuilder []byte
+
+func (b pgoutputBuilder) byte(v byte) pgoutputBuilder     { return append(b, v) }
+func (b pgoutputBuilder) uint16(v uint16) pgoutputBuilder { return binary.BigEndian.AppendUint16(b, v) }
+func (b pgoutputBuilder) uint32(v uint32) pgoutputBuilder { return binary.BigEndian.AppendUint32(b, v) }
+func (b pgoutputBuilder) uint64(v uint64) pgoutputBuilder { return binary.BigEndian.AppendUint64(b, v) }
+func (b pgoutputBuilder) string(v string) pgoutputBuilder { return append(append(b, v...), 0) }
+
+// appending a tuple, nil values are sent as NULL
+func (b pgoutputBuilder) tuple(values ...interface{}) pgoutputBuilder {
+	b = b.uint16(uint16(len(values)))
+	for _, value := range values {
+		switch value := value.(type) {
+		case nil:
+			b = b.byte('n')
+		case string:
+			b = b.byte('t').uint32(uint32(len(value)))
+			b = append(b, value...)
+		}
+	}
+	return b
+}
+
+// relation message of public.users(id integer key, name text, created_at timestamptz)
+func usersRelationMessage() []byte {
+	b := pgoutputBuilder{}.byte('R').uint32(16384).string("public").string("users").byte('d').uint16(3)
+	b = b.byte(1).string("id").uint32(23).uint32(0xffffffff)
+	b = b.byte(0).string("name").uint32(25).uint32(0xffffffff)
+	return b.byte(0).string("created_at").uint32(1184).uint32(0xffffffff)
+}
+
+func pgoutputBegin(commitTime time.Time) []byte {
+	return pgoutputBuilder{}.byte('B').uint64(0x16B3748).uint64(uint64(commitTime.Sub(postgresEpoch).Microseconds())).uint32(731)
+}
+
+func pgoutputCommit(end uint64, commitTime time.Time) []byte {
+	return pgoutputBuilder{}.byte('C').byte(0).uint64(end - 0x30).uint64(end).uint64(uint64(commitTime.Sub(postgresEpoch).Microseconds()))
+}
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := parseLSN("16/B374D848")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if lsn != 0x16B374D848 || formatLSN(lsn) != "16/B374D848" {
+		t.Errorf("unexpected LSN %X formatted as %s", lsn, formatLSN(lsn))
+	}
+	for _, invalid := range []string{"", "16", "x/1", "1/100000000"} {
+		if _, err := parseLSN(invalid); err == nil {
+			t.Errorf("expected an error for LSN %q", invalid)
+		}
+	}
+}
+
+func TestParsePgoutputMessages(t *testing.T) {
+	message, err := parsePgoutputMessage(usersRelationMessage())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	relation := message.Relation
+	if relation.ID != 16384 || relation.Namespace != "public" || relation.Name != "users" || len(relation.Columns) != 3 || !relation.Columns[0].Key || relation.Columns[1].Key {
+		t.Fatalf("unexpected relation %+v", relation)
+	}
+
+	update := pgoutputBuilder{}.byte('U').uint32(16384).byte('K').tuple("1", nil, nil).byte('N').tuple("10", "Susheel", "2024-03-05 10:20:30.5+01")
+	message, err = parsePgoutputMessage(update)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !message.OldKeyOnly || message.RelationID != 16384 {
+		t.Fatalf("unexpected update %+v", message)
+	}
+	before, err := pgoutputRow(relation, message.Old, message.OldKeyOnly)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(before) != 1 || before["id"] != int64(1) {
+		t.Errorf("expected only the key of the old row, got %v", before)
+	}
+	after, err := pgoutputRow(relation, message.New, false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	createdAt := time.Date(2024, 3, 5, 9, 20, 30, 500000000, time.UTC)
+	if after["id"] != int64(10) || after["name"] != "Susheel" || !after["created_at"].(time.Time).Equal(createdAt) {
+		t.Errorf("unexpected new row %v", after)
+	}
+
+	commitTime := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	message, err = parsePgoutputMessage(pgoutputCommit(0x16B3790, commitTime))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if message.EndLSN != 0x16B3790 || !message.CommitTime.Equal(commitTime) {
+		t.Errorf("unexpected commit %+v", message)
+	}
+
+	if _, err := parsePgoutputMessage(pgoutputBuilder{}.byte('I').uint32(16384).byte('N').uint16(2).byte('t').uint32(10)); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+func TestDecodePostgresText(t *testing.T) {
+	testCases := []struct {
+		typeName string
+		text     string
+		expected interface{}
+	}{
+		{"boolean", "t", true},
+		{"bigint", "-42", int64(-42)},
+		{"double precision", "1.5", 1.5},
+		{"numeric(10,2)", "1234.50", "1234.50"},
+		{"bytea", `\x6869`, "hi"},
+		{"date", "2024-03-05", time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"timestamp(3) without time zone", "2024-03-05 10:20:30.123", time.Date(2024, 3, 5, 10, 20, 30, 123000000, time.UTC)},
+		{"timestamp with time zone", "2024-03-05 10:20:30+05:30", time.Date(2024, 3, 5, 4, 50, 30, 0, time.UTC)},
+		{"timestamp without time zone", "infinity", "infinity"},
+		{"jsonb", `{"a": 1}`, `{"a": 1}`},
+	}
+
+	for _, tc := range testCases {
+		value := decodePostgresText(tc.typeName, tc.text)
+		if expected, ok := tc.expected.(time.Time); ok {
+			if actual, ok := value.(time.Time); !ok || !actual.Equal(expected) {
+				t.Errorf("%s %q: expected %v, got %v", tc.typeName, tc.text, expected, value)
+			}
+		} else if value != tc.expected {
+			t.Errorf("%s %q: expected %v (%T), got %v (%T)", tc.typeName, tc.text, tc.expected, tc.expected, value, value)
+		}
+	}
+}
+
+func TestWal2JSONRow(t *testing.T) {
+	change, err := parseWal2JSONChange([]byte(`{"action":"U","schema":"public","table":"users",
+		"columns":[{"name":"id","type":"integer","value":1},{"name":"balance","type":"numeric(10,2)","value":1234.50},{"name":"active","type":"boolean","value":true}],
+		"identity":[{"name":"id","type":"integer","value":1}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	row := wal2jsonRow(change.Columns)
+	if row["id"] != int64(1) || row["balance"] != "1234.50" || row["active"] != true {
+		t.Errorf("unexpected row %v", row)
+	}
+	if identity := wal2jsonRow(change.Identity); len(identity) != 1 || identity["id"] != int64(1) {
+		t.Errorf("unexpected identity %v", identity)
+	}
+}
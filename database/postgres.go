@@ -1,11 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
 	_ "github.com/lib/pq"
 )
 
@@ -16,6 +18,16 @@ type PostgreSQLClient struct {
 	Port     int
 	DBName   string
 	DB       *sql.DB
+	BulkLoad bool //loading rows with COPY instead of one INSERT per row
+	Timeouts config.TimeoutConfig
+	Filters  RowFilters //WHERE conditions restricting the rows read from each table
+
+	Progress *monitoring.ProcessTracker //progress of the batches of ImportDataConcurrently, nil when not reported
+
+	//change data capture settings, see CurrentChangePosition
+	ReplicationSlot   string
+	Publication       string
+	ReplicationPlugin string
 }
 
 func NewPostgreSQLClient(user, password, host string, port int, dbname string) *PostgreSQLClient {
@@ -25,6 +37,7 @@ func NewPostgreSQLClient(user, password, host string, port int, dbname string) *
 		Host:     host,
 		Port:     port,
 		DBName:   dbname,
+		BulkLoad: true,
 	}
 }
 
@@ -35,13 +48,29 @@ func NewPostgreSQLClientFromConfig(cfg *config.Config) *PostgreSQLClient {
 		Host:     cfg.PostgreSQL.Host,
 		Port:     cfg.PostgreSQL.Port,
 		DBName:   cfg.PostgreSQL.DBName,
+		BulkLoad: true,
+		Timeouts: cfg.Timeouts,
+
+		ReplicationSlot:   cfg.PostgreSQL.ReplicationSlot,
+		Publication:       cfg.PostgreSQL.Publication,
+		ReplicationPlugin: cfg.PostgreSQL.ReplicationPlugin,
 	}
 }
 
+// naming the database as host:port/database
+func (p *PostgreSQLClient) Location() string {
+	return fmt.Sprintf("%s:%d/%s", p.Host, p.Port, p.DBName)
+}
+
 // connect to Postgresql database
 func (p *PostgreSQLClient) Connect() error {
+	return p.ConnectContext(context.Background())
+}
+
+// connect to Postgresql database, giving up when ctx is cancelled or the connect timeout expires
+func (p *PostgreSQLClient) ConnectContext(ctx context.Context) error {
 	//DSN for postgresql
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", p.Host, p.Port, p.User, p.Password, p.DBName)
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable application_name=%s", p.Host, p.Port, p.User, p.Password, p.DBName, postgresApplicationName)
 
 	//open connection
 	db, err := sql.Open("postgres", dsn)
@@ -50,7 +79,10 @@ func (p *PostgreSQLClient) Connect() error {
 	}
 
 	//testing connection
-	if err = db.Ping(); err != nil {
+	ctx, cancel := operationContext(ctx, p.Timeouts.Connect)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
 		return fmt.Errorf("failed to ping postgresql database,%v", err)
 	}
 	p.DB = db
@@ -58,6 +90,25 @@ func (p *PostgreSQLClient) Connect() error {
 	return nil
 }
 
+// reporting the rows of every batch written by ImportDataConcurrently to tracker
+func (p *PostgreSQLClient) SetProgressTracker(tracker *monitoring.ProcessTracker) {
+	p.Progress = tracker
+}
+
+// loading rows with COPY, or with one INSERT per row when disabled
+func (p *PostgreSQLClient) SetBulkLoad(enabled bool) {
+	p.BulkLoad = enabled
+}
+
+// restricting the rows read from each table to those matching its WHERE condition
+func (p *PostgreSQLClient) SetRowFilters(filters RowFilters) error {
+	if err := filters.validateSQL(); err != nil {
+		return err
+	}
+	p.Filters = filters
+	return nil
+}
+
 // Close the database connection
 func (p *PostgreSQLClient) Close() error {
 	if p.DB != nil {
@@ -75,6 +126,11 @@ func (p *PostgreSQLClient) ExecuteQuery(query string) (*sql.Rows, error) {
 }
 
 func (p *PostgreSQLClient) FetchAllData(tables []string) ([]map[string]interface{}, error) {
+	return p.FetchAllDataContext(context.Background(), tables)
+}
+
+// fetching all rows of the tables, cancelling the running query when ctx is cancelled or the query timeout expires
+func (p *PostgreSQLClient) FetchAllDataContext(ctx context.Context, tables []string) ([]map[string]interface{}, error) {
 	if p.DB == nil {
 		return nil, fmt.Errorf("database connection not established")
 	}
@@ -83,9 +139,11 @@ func (p *PostgreSQLClient) FetchAllData(tables []string) ([]map[string]interface
 	for _, tableName := range tables {
 		//sanitise table name to prevent SQL injection
 		sanitizedTableName := sanitizeIdentifier(tableName)
-		query := fmt.Sprintf("SELECT * FROM %s;", sanitizedTableName)
+		query := fmt.Sprintf("SELECT * FROM %s%s;", sanitizedTableName, whereClause(p.Filters.For(tableName)))
 
-		rows, err := p.DB.Query(query)
+		queryCtx, cancel := operationContext(ctx, p.Timeouts.Query)
+		defer cancel()
+		rows, err := p.DB.QueryContext(queryCtx, query)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute query on table %s, %v", tableName, err)
 		}
@@ -135,6 +193,142 @@ func (p *PostgreSQLClient) FetchAllData(tables []string) ([]map[string]interface
 	return allResults, nil
 }
 
+// streaming rows of a single table in batches without loading the whole table
+func (p *PostgreSQLClient) StreamTable(ctx context.Context, tableName string, batchSize int) (RowIterator, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	query := fmt.Sprintf("SELECT * FROM %s%s;", sanitizedTableName, whereClause(p.Filters.For(tableName)))
+
+	stream := newStreamContext(ctx, p.Timeouts.Query)
+	rows, err := stream.query(p.DB, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// streaming rows whose watermark column is at or past the given watermark, ordered by that column. Rows at
+// the watermark are read again, as rows committed after the last run may share its value
+func (p *PostgreSQLClient) StreamTableSince(ctx context.Context, tableName, column string, watermark interface{}, batchSize int) (RowIterator, error) {
+	if watermark == nil {
+		return p.StreamTable(ctx, tableName, batchSize)
+	}
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	sanitizedColumn := sanitizeIdentifier(column)
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizedTableName,
+		whereClause(p.Filters.For(tableName), sanitizedColumn+" >= $1"), sanitizedColumn)
+
+	stream := newStreamContext(ctx, p.Timeouts.Query)
+	rows, err := stream.query(p.DB, query, watermark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute incremental query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// streaming rows ordered by a key column, only rows after lastKey unless it is nil
+func (p *PostgreSQLClient) StreamTableAfter(ctx context.Context, tableName, keyColumn string, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	sanitizedColumn := sanitizeIdentifier(keyColumn)
+	var conditions []string
+	var args []interface{}
+	if lastKey != nil {
+		conditions = append(conditions, sanitizedColumn+" > $1")
+		args = append(args, lastKey)
+	}
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizedTableName, whereClause(p.Filters.For(tableName), conditions...), sanitizedColumn)
+
+	stream := newStreamContext(ctx, p.Timeouts.Query)
+	rows, err := stream.query(p.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute keyset query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// splitting a table into ranges of about rowsPerRange rows by its single column integer primary key,
+// other tables are split into ranges of pages sized from the row estimate of the last ANALYZE
+func (p *PostgreSQLClient) SplitTable(tableName string, rowsPerRange int64) ([]KeyRange, error) {
+	schema, err := p.DescribeTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	keyColumn := integerKeyColumn(schema)
+	if keyColumn == "" {
+		return p.splitTablePages(tableName, rowsPerRange)
+	}
+
+	return queryKeyRanges(p.DB, tableName, keyColumn, p.Filters.For(tableName), rowsPerRange)
+}
+
+// splitting a table into ranges of pages holding about rowsPerRange rows, nil when the table was never analyzed
+func (p *PostgreSQLClient) splitTablePages(tableName string, rowsPerRange int64) ([]KeyRange, error) {
+	query := `SELECT pg_relation_size(c.oid) / current_setting('block_size')::bigint, c.reltuples::bigint
+		FROM pg_class c WHERE c.oid = $1::regclass`
+
+	var pages, tuples int64
+	if err := p.DB.QueryRow(query, tableName).Scan(&pages, &tuples); err != nil {
+		return nil, fmt.Errorf("failed to read size of table %s, %v", tableName, err)
+	}
+	if pages == 0 || tuples <= 0 {
+		return nil, nil
+	}
+
+	pagesPerRange := max(1, rowsPerRange*pages/tuples)
+	return splitKeyRange(ctidColumn, 0, pages-1, pagesPerRange), nil
+}
+
+// streaming the rows of a key or page range in key order, only rows after lastKey unless it is nil
+func (p *PostgreSQLClient) StreamTableRange(ctx context.Context, tableName string, keyRange KeyRange, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if lastKey != nil && !keyRange.IsKeyed() {
+		return nil, fmt.Errorf("page ranges of table %s cannot continue after a key", tableName)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+	query, args := rangeQuery(tableName, keyRange, lastKey, p.Filters.For(tableName), placeholder)
+	stream := newStreamContext(ctx, p.Timeouts.Query)
+	rows, err := stream.query(p.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute range query on table %s, %w", tableName, err)
+	}
+	return newSQLRowIterator(stream, rows, tableName, batchSize)
+}
+
+// discovering the base tables of the current schema, or of the filter's schemas, from information_schema
+func (p *PostgreSQLClient) DiscoverTables(filter TableFilter) ([]string, error) {
+	placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+	condition, args := schemaCondition("table_schema", "current_schema()", filter.Schemas, placeholder)
+	query := fmt.Sprintf(`SELECT table_schema, table_name, table_schema = current_schema() FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE' AND %s ORDER BY table_schema, table_name`, condition)
+	return discoverSQLTables(p.DB, query, args, filter)
+}
+
 // fecthes data from mulitple tables using workerpool
 func (p *PostgreSQLClient) FetchAllDataConcurrently(tables []string, numWorkers int) ([]map[string]interface{}, error) {
 	if numWorkers <= 0 {
@@ -144,6 +338,11 @@ func (p *PostgreSQLClient) FetchAllDataConcurrently(tables []string, numWorkers
 }
 
 func (p *PostgreSQLClient) ImportData(data []map[string]interface{}) error {
+	return p.ImportDataContext(context.Background(), data)
+}
+
+// importing rows with one transaction per table, rolled back when ctx is cancelled or the import timeout expires
+func (p *PostgreSQLClient) ImportDataContext(ctx context.Context, data []map[string]interface{}) error {
 	if p.DB == nil {
 		return fmt.Errorf("database connection not established")
 	}
@@ -167,79 +366,296 @@ func (p *PostgreSQLClient) ImportData(data []map[string]interface{}) error {
 		}
 		//get column names except _source_table
 		first_row := rows[0]
-		columns := make([]string, 0, len(first_row)-1)
-		for col := range first_row {
-			if col != "_source_table" {
-				columns = append(columns, col)
-			}
-		}
+		columns := rowColumns(first_row)
 
 		//Begin migration
-		tx, err := p.DB.Begin()
+		txCtx, cancel := operationContext(ctx, p.Timeouts.Import)
+		defer cancel()
+		tx, err := p.DB.BeginTx(txCtx, nil)
 		if err != nil {
-			return fmt.Errorf("failed to begin transation,%v", err)
+			return fmt.Errorf("failed to begin transation,%w", err)
 		}
 
 		//Creating table if not present
 		createTableSQL := generateCreateTableSQL(tableName, first_row)
-		_, err = tx.Exec(createTableSQL)
+		_, err = tx.ExecContext(txCtx, createTableSQL)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to create table %s, %v", tableName, err)
+			return fmt.Errorf("failed to create table %s, %w", tableName, err)
 		}
 
-		//Prepare insert statement
-		placeholder := make([]string, len(columns))
-		for i := range placeholder {
-			placeholder[i] = fmt.Sprintf("$%d", i+1)
+		//Inserting rows with COPY unless row by row inserts were selected
+		if p.BulkLoad {
+			err = copyTableRows(txCtx, tx, tableName, columns, rows)
+		} else {
+			placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+			err = insertTableRows(txCtx, tx, tableName, columns, rows, placeholder)
 		}
-
-		insertSQL := fmt.Sprintf(
-			"INSERT INTO %s (%s) VALUES(%s)",
-			tableName,
-			strings.Join(columns, ", "),
-			strings.Join(placeholder, ", "),
-		)
-		stmt, err := tx.Prepare(insertSQL)
 		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to prepare statement, %v", err)
-		}
-		defer stmt.Close()
-
-		//Insert row
-		for _, row := range rows {
-			values := make([]interface{}, len(columns))
-			for i, col := range columns {
-				values[i] = row[col]
-			}
-			_, err := stmt.Exec(values...)
-			if err != nil {
-				tx.Rollback()
-				return fmt.Errorf("failed to insert row, %v", err)
-			}
+			return err
 		}
 		//Commit transaction
 		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction, %v", err)
+			return fmt.Errorf("failed to commit transaction, %w", err)
 		}
 		fmt.Printf("Successfully imported %d rows into table %s \n", len(rows), tableName)
 	}
 	return nil
 }
 
+// inserting rows or replacing the existing ones with the same key column values
+func (p *PostgreSQLClient) UpsertData(data []map[string]interface{}, keyColumns []string) error {
+	if p.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+	for tableName, rows := range tableData {
+		createTableSQL := generateCreateTableSQL(tableName, rows[0])
+		if err := upsertTableRows(p.DB, tableName, rows, keyColumns, createTableSQL, placeholder); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully upserted %d rows into table %s \n", len(rows), tableName)
+	}
+	return nil
+}
+
+// writing rows with the given write mode, conflicts are detected on the key columns
+func (p *PostgreSQLClient) WriteData(data []map[string]interface{}, mode WriteMode, keyColumns []string) error {
+	switch mode {
+	case WriteModeInsert, "":
+		return p.ImportData(data)
+	case WriteModeReplace:
+		return p.UpsertData(data, keyColumns)
+	case WriteModeUpsert, WriteModeSkipExisting:
+	default:
+		return fmt.Errorf("unsupported write mode %s", mode)
+	}
+
+	if p.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+	for tableName, rows := range tableData {
+		createTableSQL := generateCreateTableSQL(tableName, rows[0], keyColumns...)
+		buildInsertSQL := func(columns []string) string {
+			return postgresConflictInsertSQL(tableName, columns, keyColumns, mode)
+		}
+		if err := writeTableRows(p.DB, p.DescribeConstraints, tableName, rows, mode, keyColumns, createTableSQL, buildInsertSQL, placeholder); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully wrote %d rows into table %s in %s mode\n", len(rows), tableName, mode)
+	}
+	return nil
+}
+
+// checking the catalog for a table and counting its rows if it exists
+func (p *PostgreSQLClient) TableRowCount(tableName string) (bool, int64, error) {
+	query := `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2`
+	return queryTableRowCount(p.DB, query, tableName)
+}
+
+// returning the primary key columns of a table from information_schema
+func (p *PostgreSQLClient) PrimaryKeyColumns(tableName string) ([]string, error) {
+	query := `SELECT kcu.column_name FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+		ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema AND tc.table_name = kcu.table_name
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND tc.table_name = $2
+		ORDER BY kcu.ordinal_position`
+	schema, name := splitQualifiedName(tableName)
+	return queryPrimaryKeyColumns(p.DB, query, schema, name)
+}
+
+// reading the column definitions and primary key of a table from information_schema
+func (p *PostgreSQLClient) DescribeTable(tableName string) (*TableSchema, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	query := `SELECT column_name, data_type, udt_name, character_maximum_length, numeric_precision, numeric_scale,
+		is_nullable, column_default, is_identity
+		FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2
+		ORDER BY ordinal_position`
+	schemaName, name := splitQualifiedName(tableName)
+	rows, err := p.DB.Query(query, schemaName, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	schema := &TableSchema{Name: tableName, Dialect: "postgresql"}
+	for rows.Next() {
+		var column ColumnDefinition
+		var udtName, isNullable, isIdentity string
+		var length, precision, scale sql.NullInt64
+		var columnDefault sql.NullString
+		if err := rows.Scan(&column.Name, &column.DataType, &udtName, &length, &precision, &scale, &isNullable, &columnDefault, &isIdentity); err != nil {
+			return nil, fmt.Errorf("failed to scan column definition, %v", err)
+		}
+
+		column.ColumnType = udtName
+		column.Length = length.Int64
+		column.Nullable = strings.EqualFold(isNullable, "YES")
+		//precision of integer and floating point types is implied by the type
+		if column.DataType == "numeric" {
+			column.Precision, column.Scale = precision.Int64, scale.Int64
+		}
+
+		var defaultValue *string
+		if columnDefault.Valid {
+			defaultValue = &columnDefault.String
+		}
+		column.Default, column.AutoIncrement = normalizePostgresDefault(defaultValue, column.Name)
+		if strings.EqualFold(isIdentity, "YES") {
+			column.AutoIncrement = true
+		}
+		schema.Columns = append(schema.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+
+	if schema.PrimaryKey, err = p.PrimaryKeyColumns(tableName); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// creating a table from a schema translated to postgresql
+func (p *PostgreSQLClient) CreateTable(schema *TableSchema) error {
+	if p.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if schema.Dialect != "postgresql" {
+		return fmt.Errorf("schema of table %s is in %s, translate it to postgresql first", schema.Name, schema.Dialect)
+	}
+
+	if _, err := p.DB.Exec(postgresCreateTableDDL(schema)); err != nil {
+		return fmt.Errorf("failed to create table %s, %v", schema.Name, err)
+	}
+	return nil
+}
+
+// reading the primary key, indexes and foreign keys of a table from pg_catalog
+func (p *PostgreSQLClient) DescribeConstraints(tableName string) (*TableConstraints, error) {
+	//partial indexes are left out, recreating them without their predicate could reject valid rows
+	indexQuery := `SELECT i.relname, ix.indisunique, a.attname
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, position)
+		LEFT JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum AND k.attnum > 0
+		WHERE n.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND t.relname = $2
+		AND NOT ix.indisprimary AND ix.indpred IS NULL
+		ORDER BY i.relname, k.position`
+	foreignKeyQuery := `SELECT c.conname, a.attname,
+		CASE WHEN rn.nspname = current_schema() THEN rt.relname ELSE rn.nspname || '.' || rt.relname END,
+		ra.attname,
+		CASE c.confupdtype WHEN 'c' THEN 'CASCADE' WHEN 'n' THEN 'SET NULL' WHEN 'd' THEN 'SET DEFAULT' WHEN 'r' THEN 'RESTRICT' ELSE 'NO ACTION' END,
+		CASE c.confdeltype WHEN 'c' THEN 'CASCADE' WHEN 'n' THEN 'SET NULL' WHEN 'd' THEN 'SET DEFAULT' WHEN 'r' THEN 'RESTRICT' ELSE 'NO ACTION' END
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_class rt ON rt.oid = c.confrelid
+		JOIN pg_namespace rn ON rn.oid = rt.relnamespace
+		CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, refattnum, position)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = k.refattnum
+		WHERE c.contype = 'f' AND n.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND t.relname = $2
+		ORDER BY c.conname, k.position`
+	schemaName, name := splitQualifiedName(tableName)
+
+	constraints := &TableConstraints{Table: tableName}
+	var err error
+	if constraints.PrimaryKey, err = p.PrimaryKeyColumns(tableName); err != nil {
+		return nil, err
+	}
+	if constraints.Indexes, err = queryIndexes(p.DB, indexQuery, schemaName, name); err != nil {
+		return nil, err
+	}
+	if constraints.ForeignKeys, err = queryForeignKeys(p.DB, foreignKeyQuery, schemaName, name); err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// adding a primary key to a table
+func (p *PostgreSQLClient) AddPrimaryKey(tableName string, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no primary key columns given for table %s", tableName)
+	}
+	return execConstraintDDL(p.DB, addPrimaryKeyDDL(tableName, columns))
+}
+
+// creating a secondary or unique index, index names are unique per schema in PostgreSQL so they are
+// prefixed with the table name unless they already are
+func (p *PostgreSQLClient) AddIndex(tableName string, index IndexDefinition) error {
+	if len(index.Columns) == 0 {
+		return fmt.Errorf("index %s of table %s has no columns", index.Name, tableName)
+	}
+	_, name := splitQualifiedName(tableName)
+	indexName := index.Name
+	if !strings.HasPrefix(strings.ToLower(indexName), strings.ToLower(name)+"_") {
+		indexName = name + "_" + indexName
+	}
+	return execConstraintDDL(p.DB, createIndexDDL(tableName, indexName, index, true))
+}
+
+// adding a foreign key to a table
+func (p *PostgreSQLClient) AddForeignKey(tableName string, foreignKey ForeignKeyDefinition) error {
+	if err := validateForeignKey(tableName, foreignKey); err != nil {
+		return err
+	}
+	return execConstraintDDL(p.DB, addForeignKeyDDL(tableName, foreignKey))
+}
+
+// deleting the rows matching the key column values, returns the number of rows deleted
+func (p *PostgreSQLClient) DeleteRows(tableName string, keyColumns []string, rows []map[string]interface{}) (int64, error) {
+	placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+	return deleteTableRows(p.DB, tableName, keyColumns, rows, placeholder)
+}
+
+// dropping a table
+func (p *PostgreSQLClient) DropTable(tableName string) error {
+	return dropSQLTable(p.DB, tableName)
+}
+
 // imports data uing batch processing
 func (p *PostgreSQLClient) ImportDataConcurrently(data []map[string]interface{}, batchsize int) error {
 	if batchsize <= 0 {
 		batchsize = 1000 //default size of the batch
 	}
-	processor := NewBatchProcessor(batchsize)
-
-	return processor.ProcessInBatches(data, p.ImportData)
+	//writing non-overlapping batches concurrently, each ImportData call runs in its own transaction
+	writer := NewBatchWriter(DefaultBatchWriters, batchsize, p.Progress)
+	return writer.Write(data, p.ImportData)
 }
 
 // Helper function
-func generateCreateTableSQL(tableName string, sampleRow map[string]interface{}) string {
+func generateCreateTableSQL(tableName string, sampleRow map[string]interface{}, keyColumns ...string) string {
 	columns := make([]string, 0, len(sampleRow)-1)
 	for col, val := range sampleRow {
 		if col == "_source_table" {
@@ -258,7 +674,7 @@ func generateCreateTableSQL(tableName string, sampleRow map[string]interface{})
 		case string:
 			dataType = "TEXT"
 		case []byte:
-			dataType = "BYTE"
+			dataType = "BYTEA"
 		case nil:
 			dataType = "TEXT"
 		default:
@@ -266,6 +682,9 @@ func generateCreateTableSQL(tableName string, sampleRow map[string]interface{})
 		}
 		columns = append(columns, fmt.Sprintf("%s %s", col, dataType))
 	}
+	if len(keyColumns) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keyColumns, ", ")))
+	}
 
 	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);",
 		tableName, strings.Join(columns, ", "))
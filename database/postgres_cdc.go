@@ -0,0 +1,516 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReplicationName     = "data_migration_tool" //slot and publication used when none is configured
+	postgresChangePollInterval = time.Second           //wait before peeking again at an idle slot
+	postgresChangeBatch        = 10000                 //changes peeked at once, transactions are never split
+)
+
+// returning the logical replication slot changes are read from
+func (p *PostgreSQLClient) replicationSlot() string {
+	if p.ReplicationSlot == "" {
+		return defaultReplicationName
+	}
+	return p.ReplicationSlot
+}
+
+// returning the publication of the captured tables, used by pgoutput
+func (p *PostgreSQLClient) publication() string {
+	if p.Publication == "" {
+		return defaultReplicationName
+	}
+	return p.Publication
+}
+
+// returning the logical decoding plugin of the slot
+func (p *PostgreSQLClient) replicationPlugin() string {
+	if p.ReplicationPlugin == "" {
+		return pluginPgoutput
+	}
+	return strings.ToLower(p.ReplicationPlugin)
+}
+
+// creating the publication and the replication slot when they do not exist yet and returning the flushed end of
+// the WAL, at least the position the slot confirmed. Changes committed after it are kept by the server until
+// they are acknowledged
+func (p *PostgreSQLClient) CurrentChangePosition(ctx context.Context, tables []string) (string, error) {
+	if p.DB == nil {
+		return "", fmt.Errorf("database connection not established")
+	}
+	ctx, cancel := operationContext(ctx, p.Timeouts.Query)
+	defer cancel()
+
+	//the publication has to exist before the slot, changes decoded before it was created are not published
+	if err := p.ensurePublication(ctx, tables); err != nil {
+		return "", err
+	}
+	confirmed, err := p.ensureReplicationSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	//the slot only confirmed the changes applied so far, the latest committed change may be further ahead
+	flushed, err := p.flushedPosition(ctx)
+	if err != nil {
+		return "", err
+	}
+	if flushed < confirmed {
+		flushed = confirmed
+	}
+	return formatLSN(flushed), nil
+}
+
+// reading the flushed end of the WAL, every transaction committed so far ends before it
+func (p *PostgreSQLClient) flushedPosition(ctx context.Context) (uint64, error) {
+	var flushed string
+	if err := p.DB.QueryRowContext(ctx, "SELECT pg_current_wal_flush_lsn()::text").Scan(&flushed); err != nil {
+		return 0, fmt.Errorf("failed to read the flushed WAL position, %v", err)
+	}
+	return parseLSN(flushed)
+}
+
+// reporting whether the transaction ending at position ends at or after target
+func (p *PostgreSQLClient) ChangePositionReached(position, target string) (bool, error) {
+	applied, err := parseLSN(position)
+	if err != nil {
+		return false, err
+	}
+	wanted, err := parseLSN(target)
+	if err != nil {
+		return false, err
+	}
+	return applied >= wanted, nil
+}
+
+// creating the publication of the tables, or adding the tables missing from an existing one
+func (p *PostgreSQLClient) ensurePublication(ctx context.Context, tables []string) error {
+	if p.replicationPlugin() != pluginPgoutput {
+		return nil
+	}
+	publication := p.publication()
+
+	var allTables bool
+	err := p.DB.QueryRowContext(ctx, "SELECT puballtables FROM pg_publication WHERE pubname = $1", publication).Scan(&allTables)
+	if err == sql.ErrNoRows {
+		if _, err := p.DB.ExecContext(ctx, fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s", publication, strings.Join(tables, ", "))); err != nil {
+			return fmt.Errorf("failed to create publication %s, %v", publication, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query publication %s, %v", publication, err)
+	}
+	if allTables {
+		return nil
+	}
+
+	schema, err := p.currentSchema(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := p.DB.QueryContext(ctx, "SELECT schemaname, tablename FROM pg_publication_tables WHERE pubname = $1", publication)
+	if err != nil {
+		return fmt.Errorf("failed to query tables of publication %s, %v", publication, err)
+	}
+	defer rows.Close()
+	published := make(map[string]bool)
+	for rows.Next() {
+		var tableSchema, table string
+		if err := rows.Scan(&tableSchema, &table); err != nil {
+			return fmt.Errorf("failed to scan table of publication %s, %v", publication, err)
+		}
+		published[strings.ToLower(tableSchema+"."+table)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during row iteration %v", err)
+	}
+
+	var missing []string
+	for _, table := range tables {
+		tableSchema, name := splitQualifiedName(table)
+		if tableSchema == "" {
+			tableSchema = schema
+		}
+		if !published[strings.ToLower(tableSchema+"."+name)] {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if _, err := p.DB.ExecContext(ctx, fmt.Sprintf("ALTER PUBLICATION %s ADD TABLE %s", publication, strings.Join(missing, ", "))); err != nil {
+		return fmt.Errorf("failed to add tables %v to publication %s, %v", missing, publication, err)
+	}
+	return nil
+}
+
+// creating the logical replication slot when it does not exist, returns the position it confirmed
+func (p *PostgreSQLClient) ensureReplicationSlot(ctx context.Context) (uint64, error) {
+	slot, plugin := p.replicationSlot(), p.replicationPlugin()
+
+	var slotPlugin, confirmed sql.NullString
+	err := p.DB.QueryRowContext(ctx, "SELECT plugin, confirmed_flush_lsn::text FROM pg_replication_slots WHERE slot_name = $1", slot).Scan(&slotPlugin, &confirmed)
+	if err == sql.ErrNoRows {
+		var created string
+		if err := p.DB.QueryRowContext(ctx, "SELECT lsn::text FROM pg_create_logical_replication_slot($1, $2)", slot, plugin).Scan(&created); err != nil {
+			return 0, fmt.Errorf("failed to create replication slot %s, %v", slot, err)
+		}
+		return parseLSN(created)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query replication slot %s, %v", slot, err)
+	}
+	if !slotPlugin.Valid || !confirmed.Valid {
+		return 0, fmt.Errorf("replication slot %s is not a logical replication slot", slot)
+	}
+	if slotPlugin.String != plugin {
+		return 0, fmt.Errorf("replication slot %s decodes changes with %s, not %s", slot, slotPlugin.String, plugin)
+	}
+	return parseLSN(confirmed.String)
+}
+
+// returning the schema unqualified table names refer to
+func (p *PostgreSQLClient) currentSchema(ctx context.Context) (string, error) {
+	var schema string
+	if err := p.DB.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		return "", fmt.Errorf("failed to query the current schema, %v", err)
+	}
+	return schema, nil
+}
+
+// opening a stream of the changes to tables committed after position. The server needs wal_level=logical and
+// the user the REPLICATION attribute, updates and deletes carry the key columns of the replica identity and the
+// whole old row only for tables with REPLICA IDENTITY FULL
+func (p *PostgreSQLClient) OpenChangeStream(ctx context.Context, tables []string, position string) (ChangeStream, error) {
+	if p.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	start, err := parseLSN(position)
+	if err != nil {
+		return nil, err
+	}
+	switch p.replicationPlugin() {
+	case pluginPgoutput, pluginWal2JSON:
+	default:
+		return nil, fmt.Errorf("unsupported logical decoding plugin %s, use pgoutput or wal2json", p.replicationPlugin())
+	}
+
+	queryCtx, cancel := operationContext(ctx, p.Timeouts.Query)
+	defer cancel()
+	if err := p.ensurePublication(queryCtx, tables); err != nil {
+		return nil, err
+	}
+	confirmed, err := p.ensureReplicationSlot(queryCtx)
+	if err != nil {
+		return nil, err
+	}
+	if start < confirmed {
+		return nil, fmt.Errorf("replication slot %s already confirmed the changes up to %s, the changes after %s are no longer available", p.replicationSlot(), formatLSN(confirmed), position)
+	}
+	schema, err := p.currentSchema(queryCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &postgresChangeStream{
+		client:    p,
+		tables:    make(map[string]string, len(tables)),
+		schema:    schema,
+		relations: make(map[uint32]*pgoutputRelation),
+		position:  start,
+		acked:     confirmed,
+	}
+	for _, table := range tables {
+		stream.tables[strings.ToLower(table)] = table
+	}
+	//the transactions up to position were applied by a run that stopped before acknowledging them
+	if start > confirmed {
+		if err := stream.Ack(position); err != nil {
+			return nil, err
+		}
+	}
+	return stream, nil
+}
+
+// change stream peeking at the transactions a logical replication slot decoded, the slot only moves on
+// once the transactions are acknowledged, so nothing is lost when capture stops before applying them
+type postgresChangeStream struct {
+	client    *PostgreSQLClient
+	tables    map[string]string //streamed tables by lowercased name, to the name they were configured with
+	schema    string            //schema of unqualified table names
+	relations map[uint32]*pgoutputRelation
+	pending   []*ChangeTransaction //peeked transactions not returned yet
+	position  uint64               //end of the last peeked transaction
+	acked     uint64               //position the slot confirmed
+	idle      bool                 //the last peek found no new transaction
+}
+
+// returning the next peeked transaction, peeking at the slot again while there is none. Once a peek at an idle
+// slot finds nothing committed up to the WAL position read before it, a transaction without changes moves the
+// position there, so the position keeps up with a source whose latest writes went to other tables
+func (s *postgresChangeStream) Next(ctx context.Context) (*ChangeTransaction, error) {
+	for {
+		if len(s.pending) > 0 {
+			transaction := s.pending[0]
+			s.pending = s.pending[1:]
+			return transaction, nil
+		}
+		var flushed uint64
+		if s.idle {
+			var err error
+			if flushed, err = s.client.flushedPosition(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, err
+			}
+		}
+		complete, err := s.peek(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		s.idle = len(s.pending) == 0
+		if !s.idle {
+			continue
+		}
+		if complete && flushed > s.position {
+			s.position = flushed
+			return &ChangeTransaction{Position: formatLSN(flushed), CommitTime: time.Now()}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(postgresChangePollInterval):
+		}
+	}
+}
+
+// peeking at the transactions decoded after the confirmed position of the slot, transactions returned before
+// but not acknowledged yet are skipped. Reports whether the peek read every change decoded so far
+func (s *postgresChangeStream) peek(ctx context.Context) (bool, error) {
+	slot := s.client.replicationSlot()
+	var rows *sql.Rows
+	var err error
+	if s.client.replicationPlugin() == pluginPgoutput {
+		rows, err = s.client.DB.QueryContext(ctx, `SELECT lsn::text, data FROM pg_logical_slot_peek_binary_changes($1, NULL, $2,
+			'proto_version', '1', 'publication_names', $3)`, slot, postgresChangeBatch, s.client.publication())
+	} else {
+		rows, err = s.client.DB.QueryContext(ctx, `SELECT lsn::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2,
+			'format-version', '2', 'include-timestamp', '1')`, slot, postgresChangeBatch)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to peek at replication slot %s, %w", slot, err)
+	}
+	defer rows.Close()
+
+	var transaction *ChangeTransaction
+	read := 0
+	for rows.Next() {
+		read++
+		var lsn string
+		var data []byte
+		if err := rows.Scan(&lsn, &data); err != nil {
+			return false, fmt.Errorf("failed to scan change of replication slot %s, %w", slot, err)
+		}
+		if s.client.replicationPlugin() == pluginPgoutput {
+			transaction, err = s.handlePgoutput(data, transaction)
+		} else {
+			transaction, err = s.handleWal2JSON(lsn, data, transaction)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to peek at replication slot %s, %w", slot, err)
+	}
+	//the peek stops at the first transaction end after postgresChangeBatch changes
+	return read < postgresChangeBatch, nil
+}
+
+// applying a pgoutput message to the transaction being read, returns the transaction read next
+func (s *postgresChangeStream) handlePgoutput(data []byte, transaction *ChangeTransaction) (*ChangeTransaction, error) {
+	message, err := parsePgoutputMessage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch message.Kind {
+	case 'B':
+		return &ChangeTransaction{CommitTime: message.CommitTime}, nil
+	case 'C':
+		if transaction == nil {
+			return nil, fmt.Errorf("commit without a transaction")
+		}
+		transaction.CommitTime = message.CommitTime
+		s.commit(transaction, message.EndLSN)
+		return nil, nil
+	case 'R':
+		s.relations[message.Relation.ID] = message.Relation
+		return transaction, nil
+	case 'I', 'U', 'D':
+	default:
+		//truncates cannot be replayed row by row, they are left to the operator like DDL
+		return transaction, nil
+	}
+
+	if transaction == nil {
+		return nil, fmt.Errorf("change outside of a transaction")
+	}
+	relation, ok := s.relations[message.RelationID]
+	if !ok {
+		return nil, fmt.Errorf("change of unknown relation %d", message.RelationID)
+	}
+	table, ok := s.streamedTable(relation.Namespace, relation.Name)
+	if !ok {
+		return transaction, nil
+	}
+
+	change := RowChange{Table: table}
+	switch message.Kind {
+	case 'I':
+		change.Operation = ChangeInsert
+		change.Row, err = pgoutputRow(relation, message.New, false)
+	case 'U':
+		change.Operation = ChangeUpdate
+		if change.Row, err = pgoutputRow(relation, message.New, false); err == nil && message.Old != nil {
+			change.Before, err = pgoutputRow(relation, message.Old, message.OldKeyOnly)
+		}
+		//unchanged TOASTed values are taken from the full old row when the table sends one
+		if err == nil && change.Before != nil && !message.OldKeyOnly {
+			for column, value := range change.Before {
+				if _, ok := change.Row[column]; !ok {
+					change.Row[column] = value
+				}
+			}
+		}
+	case 'D':
+		change.Operation = ChangeDelete
+		change.Row, err = pgoutputRow(relation, message.Old, message.OldKeyOnly)
+	}
+	if err != nil {
+		return nil, err
+	}
+	change.Row["_source_table"] = table
+	if change.Before != nil {
+		change.Before["_source_table"] = table
+	}
+	transaction.Changes = append(transaction.Changes, change)
+	return transaction, nil
+}
+
+// applying a wal2json change to the transaction being read, returns the transaction read next
+func (s *postgresChangeStream) handleWal2JSON(lsn string, data []byte, transaction *ChangeTransaction) (*ChangeTransaction, error) {
+	change, err := parseWal2JSONChange(data)
+	if err != nil {
+		return nil, err
+	}
+	commitTime, _ := decodePostgresText("timestamp with time zone", change.Timestamp).(time.Time)
+
+	switch change.Action {
+	case "B":
+		return &ChangeTransaction{CommitTime: commitTime}, nil
+	case "C":
+		if transaction == nil {
+			return nil, fmt.Errorf("commit without a transaction")
+		}
+		//the commit is reported at the end of its record, where the next transaction starts
+		end, err := parseLSN(lsn)
+		if err != nil {
+			return nil, err
+		}
+		if !commitTime.IsZero() {
+			transaction.CommitTime = commitTime
+		}
+		s.commit(transaction, end)
+		return nil, nil
+	case "I", "U", "D":
+	default:
+		//truncates and logical messages carry no row changes
+		return transaction, nil
+	}
+
+	if transaction == nil {
+		return nil, fmt.Errorf("change outside of a transaction")
+	}
+	table, ok := s.streamedTable(change.Schema, change.Table)
+	if !ok {
+		return transaction, nil
+	}
+
+	rowChange := RowChange{Table: table}
+	switch change.Action {
+	case "I":
+		rowChange.Operation, rowChange.Row = ChangeInsert, wal2jsonRow(change.Columns)
+	case "U":
+		rowChange.Operation, rowChange.Row, rowChange.Before = ChangeUpdate, wal2jsonRow(change.Columns), wal2jsonRow(change.Identity)
+	case "D":
+		rowChange.Operation, rowChange.Row = ChangeDelete, wal2jsonRow(change.Identity)
+	}
+	if rowChange.Row == nil {
+		return nil, fmt.Errorf("change of table %s without columns, the table needs a primary key or a replica identity", table)
+	}
+	rowChange.Row["_source_table"] = table
+	if rowChange.Before != nil {
+		rowChange.Before["_source_table"] = table
+	}
+	transaction.Changes = append(transaction.Changes, rowChange)
+	return transaction, nil
+}
+
+// queueing a committed transaction unless it was returned before
+func (s *postgresChangeStream) commit(transaction *ChangeTransaction, end uint64) {
+	if end <= s.position {
+		return
+	}
+	s.position = end
+	transaction.Position = formatLSN(end)
+	s.pending = append(s.pending, transaction)
+}
+
+// returning the configured name of a table when it is streamed, unqualified names refer to the current schema
+func (s *postgresChangeStream) streamedTable(schema, name string) (string, bool) {
+	if table, ok := s.tables[strings.ToLower(schema+"."+name)]; ok {
+		return table, true
+	}
+	if schema == s.schema {
+		table, ok := s.tables[strings.ToLower(name)]
+		return table, ok
+	}
+	return "", false
+}
+
+// moving the slot to position, letting the server discard the WAL of the applied transactions
+func (s *postgresChangeStream) Ack(position string) error {
+	lsn, err := parseLSN(position)
+	if err != nil {
+		return err
+	}
+	if lsn <= s.acked {
+		return nil
+	}
+
+	ctx, cancel := operationContext(context.Background(), s.client.Timeouts.Query)
+	defer cancel()
+	if _, err := s.client.DB.ExecContext(ctx, "SELECT pg_replication_slot_advance($1, $2::pg_lsn)", s.client.replicationSlot(), position); err != nil {
+		return fmt.Errorf("failed to advance replication slot %s to %s, %v", s.client.replicationSlot(), position, err)
+	}
+	s.acked = lsn
+	return nil
+}
+
+// the slot outlives the stream, capture continues from it on the next run
+func (s *postgresChangeStream) Close() error {
+	return nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPostgresCurrentChangePositionCreatesSlot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT puballtables FROM pg_publication WHERE pubname = \\$1").WithArgs("data_migration_tool").
+		WillReturnRows(sqlmock.NewRows([]string{"puballtables"}).AddRow(false))
+	mock.ExpectQuery("SELECT current_schema\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	mock.ExpectQuery("FROM pg_publication_tables WHERE pubname = \\$1").WithArgs("data_migration_tool").
+		WillReturnRows(sqlmock.NewRows([]string{"schemaname", "tablename"}).AddRow("public", "users"))
+	mock.ExpectExec("^ALTER PUBLICATION data_migration_tool ADD TABLE sales.orders$").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM pg_replication_slots WHERE slot_name = \\$1").WithArgs("data_migration_tool").
+		WillReturnRows(sqlmock.NewRows([]string{"plugin", "confirmed_flush_lsn"}))
+	mock.ExpectQuery("FROM pg_create_logical_replication_slot\\(\\$1, \\$2\\)").WithArgs("data_migration_tool", "pgoutput").
+		WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/16B3748"))
+	mock.ExpectQuery("SELECT pg_current_wal_flush_lsn\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/16B3780"))
+
+	client := &PostgreSQLClient{DB: db}
+	position, err := client.CurrentChangePosition(context.Background(), []string{"users", "sales.orders"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if position != "0/16B3780" {
+		t.Errorf("expected the flushed end of the WAL, got %s", position)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgresChangeStream(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM pg_publication WHERE pubname = \\$1").WithArgs("cdc_publication").
+		WillReturnRows(sqlmock.NewRows([]string{"puballtables"}).AddRow(true))
+	mock.ExpectQuery("SELECT plugin, confirmed_flush_lsn::text FROM pg_replication_slots").WithArgs("cdc_slot").
+		WillReturnRows(sqlmock.NewRows([]string{"plugin", "confirmed_flush_lsn"}).AddRow("pgoutput", "0/1000"))
+	mock.ExpectQuery("SELECT current_schema\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	//the saved position is ahead of the slot, the run before stopped between saving and acknowledging it
+	mock.ExpectExec("SELECT pg_replication_slot_advance\\(\\$1, \\$2::pg_lsn\\)").WithArgs("cdc_slot", "0/1100").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	commitTime := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	insert := pgoutputBuilder{}.byte('I').uint32(16384).byte('N').tuple("1", "Susheel", nil)
+	otherRelation := pgoutputBuilder{}.byte('R').uint32(16400).string("public").string("audit").byte('d').uint16(1).
+		byte(1).string("id").uint32(23).uint32(0xffffffff)
+	otherInsert := pgoutputBuilder{}.byte('I').uint32(16400).byte('N').tuple("7")
+	remove := pgoutputBuilder{}.byte('D').uint32(16384).byte('K').tuple("2", nil, nil)
+	peek := sqlmock.NewRows([]string{"lsn", "data"}).
+		//applied before the restart, skipped
+		AddRow("0/1050", pgoutputBegin(commitTime)).
+		AddRow("0/1050", usersRelationMessage()).
+		AddRow("0/1050", []byte(insert)).
+		AddRow("0/1100", pgoutputCommit(0x1100, commitTime)).
+		AddRow("0/1150", pgoutputBegin(commitTime)).
+		AddRow("0/1150", []byte(insert)).
+		AddRow("0/1150", []byte(otherRelation)).
+		AddRow("0/1150", []byte(otherInsert)).
+		AddRow("0/1150", []byte(remove)).
+		AddRow("0/1200", pgoutputCommit(0x1200, commitTime))
+	mock.ExpectQuery("FROM pg_logical_slot_peek_binary_changes\\(\\$1, NULL, \\$2").WithArgs("cdc_slot", postgresChangeBatch, "cdc_publication").WillReturnRows(peek)
+	mock.ExpectExec("SELECT pg_replication_slot_advance").WithArgs("cdc_slot", "0/1200").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM pg_logical_slot_peek_binary_changes").WillReturnRows(sqlmock.NewRows([]string{"lsn", "data"}))
+
+	client := &PostgreSQLClient{DB: db, ReplicationSlot: "cdc_slot", Publication: "cdc_publication"}
+	stream, err := client.OpenChangeStream(context.Background(), []string{"users"}, "0/1100")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer stream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	transaction, err := stream.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if transaction.Position != "0/1200" || !transaction.CommitTime.Equal(commitTime) || len(transaction.Changes) != 2 {
+		t.Fatalf("unexpected transaction %+v", transaction)
+	}
+	inserted, deleted := transaction.Changes[0], transaction.Changes[1]
+	if inserted.Operation != ChangeInsert || inserted.Row["id"] != int64(1) || inserted.Row["created_at"] != nil || inserted.Row["_source_table"] != "users" {
+		t.Errorf("unexpected insert %+v", inserted)
+	}
+	if deleted.Operation != ChangeDelete || len(deleted.Row) != 2 || deleted.Row["id"] != int64(2) {
+		t.Errorf("expected the key of the deleted row, got %+v", deleted)
+	}
+	if err := stream.Ack(transaction.Position); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	//an idle slot is peeked at again until ctx ends
+	waitCtx, stop := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer stop()
+	if _, err := stream.Next(waitCtx); err != context.DeadlineExceeded {
+		t.Errorf("expected the context deadline, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgresChangeStreamRejectsDiscardedPosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM pg_replication_slots").WillReturnRows(sqlmock.NewRows([]string{"plugin", "confirmed_flush_lsn"}).AddRow("wal2json", "0/2000"))
+
+	client := &PostgreSQLClient{DB: db, ReplicationPlugin: "wal2json"}
+	if _, err := client.OpenChangeStream(context.Background(), []string{"users"}, "0/1000"); err == nil {
+		t.Error("expected an error for a position the slot already moved past")
+	}
+}
+
+func TestPostgresChangeStreamWal2JSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM pg_replication_slots").WillReturnRows(sqlmock.NewRows([]string{"plugin", "confirmed_flush_lsn"}).AddRow("wal2json", "0/1000"))
+	mock.ExpectQuery("SELECT current_schema\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	peek := sqlmock.NewRows([]string{"lsn", "data"}).
+		AddRow("0/1050", `{"action":"B","timestamp":"2024-03-05 10:00:00+00"}`).
+		AddRow("0/1050", `{"action":"U","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":20},{"name":"name","type":"text","value":"Alex"}],"identity":[{"name":"id","type":"integer","value":2}]}`).
+		AddRow("0/1100", `{"action":"C","timestamp":"2024-03-05 10:00:00+00"}`)
+	mock.ExpectQuery("FROM pg_logical_slot_peek_changes\\(\\$1, NULL, \\$2").WithArgs("data_migration_tool", postgresChangeBatch).WillReturnRows(peek)
+
+	client := &PostgreSQLClient{DB: db, ReplicationPlugin: "wal2json"}
+	stream, err := client.OpenChangeStream(context.Background(), []string{"users"}, "0/1000")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	transaction, err := stream.Next(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if transaction.Position != "0/1100" || len(transaction.Changes) != 1 {
+		t.Fatalf("unexpected transaction %+v", transaction)
+	}
+	update := transaction.Changes[0]
+	if update.Operation != ChangeUpdate || update.Row["id"] != int64(20) || update.Before["id"] != int64(2) || update.Row["_source_table"] != "users" {
+		t.Errorf("unexpected update %+v", update)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgresChangeStreamMovesIdlePosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM pg_replication_slots").WillReturnRows(sqlmock.NewRows([]string{"plugin", "confirmed_flush_lsn"}).AddRow("wal2json", "0/1000"))
+	mock.ExpectQuery("SELECT current_schema\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"current_schema"}).AddRow("public"))
+	mock.ExpectQuery("FROM pg_logical_slot_peek_changes").WillReturnRows(sqlmock.NewRows([]string{"lsn", "data"}))
+	//only other tables were written since, nothing is decoded up to the flushed position read before the peek
+	mock.ExpectQuery("SELECT pg_current_wal_flush_lsn\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/1400"))
+	mock.ExpectQuery("FROM pg_logical_slot_peek_changes").WillReturnRows(sqlmock.NewRows([]string{"lsn", "data"}))
+
+	client := &PostgreSQLClient{DB: db, ReplicationPlugin: "wal2json"}
+	stream, err := client.OpenChangeStream(context.Background(), []string{"users"}, "0/1000")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	transaction, err := stream.Next(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if transaction.Position != "0/1400" || len(transaction.Changes) != 0 {
+		t.Errorf("expected a transaction without changes at the flushed position, got %+v", transaction)
+	}
+	if reached, err := client.ChangePositionReached(transaction.Position, "0/1400"); err != nil || !reached {
+		t.Errorf("expected the flushed position to be reached, got %v, %v", reached, err)
+	}
+	if reached, _ := client.ChangePositionReached("0/1200", "0/1400"); reached {
+		t.Error("expected an earlier position not to reach the flushed one")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// column splitting a PostgreSQL table without an integer key into ranges of pages
+const ctidColumn = "ctid"
+
+// most ranges a table is split into, each range has its own reader and checkpoint entry
+const maxKeyRanges = 1000
+
+// part of a table read independently of the others, the rows whose key lies in [Start, End),
+// the last range has no upper bound so rows added after splitting are read as well
+type KeyRange struct {
+	Column string `json:"column"` //integer primary key, or ctid for page ranges of PostgreSQL tables
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Last   bool   `json:"last,omitempty"`
+}
+
+// checking if the range is ordered by an integer key, ranges of pages have no key to resume after
+func (kr KeyRange) IsKeyed() bool {
+	return kr.Column != ctidColumn
+}
+
+// splitting the keys from first to last into ranges of rowsPerRange keys, at most maxKeyRanges, nil when they fit into one range
+func splitKeyRange(column string, first, last, rowsPerRange int64) []KeyRange {
+	if rowsPerRange <= 0 || last < first {
+		return nil
+	}
+	//widening the ranges so the keys are split into no more than maxKeyRanges
+	if span := (uint64(last)-uint64(first))/maxKeyRanges + 1; span > uint64(rowsPerRange) {
+		rowsPerRange = int64(span)
+	}
+	//the unsigned difference cannot overflow for keys spanning the whole int64 range
+	if uint64(last)-uint64(first) < uint64(rowsPerRange) {
+		return nil
+	}
+
+	var ranges []KeyRange
+	for start := first; ; start += rowsPerRange {
+		if uint64(last)-uint64(start) < uint64(rowsPerRange) {
+			ranges = append(ranges, KeyRange{Column: column, Start: start, End: last + 1, Last: true})
+			break
+		}
+		ranges = append(ranges, KeyRange{Column: column, Start: start, End: start + rowsPerRange})
+	}
+	return ranges
+}
+
+// returning the single column integer primary key of a table, empty when it has none
+func integerKeyColumn(schema *TableSchema) string {
+	if len(schema.PrimaryKey) != 1 {
+		return ""
+	}
+	for _, column := range schema.Columns {
+		if !strings.EqualFold(column.Name, schema.PrimaryKey[0]) {
+			continue
+		}
+		switch strings.ToLower(column.DataType) {
+		case "tinyint", "smallint", "mediumint", "int", "integer", "bigint":
+			return column.Name
+		}
+	}
+	return ""
+}
+
+// splitting the rows of a table passing its filter into ranges of about rowsPerRange rows, at most maxKeyRanges.
+// The boundaries are the first keys of equally sized tiles of the rows in key order, so sparse keys do not
+// produce empty ranges. nil when the rows fit into one range
+func queryKeyRanges(db *sql.DB, tableName, keyColumn, filter string, rowsPerRange int64) ([]KeyRange, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if rowsPerRange <= 0 {
+		return nil, nil
+	}
+	table, column := sanitizeIdentifier(tableName), sanitizeIdentifier(keyColumn)
+
+	var count int64
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s%s", table, whereClause(filter))).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to count rows of table %s, %v", tableName, err)
+	}
+	if count <= rowsPerRange {
+		return nil, nil
+	}
+
+	tiles := min((count+rowsPerRange-1)/rowsPerRange, maxKeyRanges)
+	query := fmt.Sprintf("SELECT MIN(%s) FROM (SELECT %s, NTILE(%d) OVER (ORDER BY %s) AS tile FROM %s%s) tiles GROUP BY tile ORDER BY tile",
+		column, column, tiles, column, table, whereClause(filter))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key ranges of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	var starts []int64
+	for rows.Next() {
+		var start int64
+		if err := rows.Scan(&start); err != nil {
+			return nil, fmt.Errorf("failed to read key ranges of table %s, %v", tableName, err)
+		}
+		starts = append(starts, start)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key ranges of table %s, %v", tableName, err)
+	}
+	if len(starts) < 2 {
+		return nil, nil
+	}
+
+	ranges := make([]KeyRange, len(starts))
+	for i, start := range starts {
+		ranges[i] = KeyRange{Column: keyColumn, Start: start}
+		if i+1 < len(starts) {
+			ranges[i].End = starts[i+1]
+		} else {
+			ranges[i].Last = true
+		}
+	}
+	return ranges, nil
+}
+
+// building the query reading the rows of a range passing the table filter in key order, after lastKey unless it is nil
+func rangeQuery(tableName string, keyRange KeyRange, lastKey interface{}, filter string, placeholder func(i int) string) (string, []interface{}) {
+	column := sanitizeIdentifier(keyRange.Column)
+	var conditions []string
+	var args []interface{}
+	bound := func(operator string, value interface{}, cast string) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s %s %s%s", column, operator, placeholder(len(args)), cast))
+	}
+
+	if keyRange.IsKeyed() {
+		bound(">=", keyRange.Start, "")
+		if !keyRange.Last {
+			bound("<", keyRange.End, "")
+		}
+		if lastKey != nil {
+			bound(">", lastKey, "")
+		}
+	} else {
+		//pages of a PostgreSQL table are compared as the tuple id of their first row
+		bound(">=", fmt.Sprintf("(%d,0)", keyRange.Start), "::tid")
+		if !keyRange.Last {
+			bound("<", fmt.Sprintf("(%d,0)", keyRange.End), "::tid")
+		}
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizeIdentifier(tableName), whereClause(filter, conditions...), column)
+	return query, args
+}
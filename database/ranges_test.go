@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSplitKeyRange(t *testing.T) {
+	expected := []KeyRange{
+		{Column: "id", Start: 1, End: 5},
+		{Column: "id", Start: 5, End: 9},
+		{Column: "id", Start: 9, End: 11, Last: true},
+	}
+	if ranges := splitKeyRange("id", 1, 10, 4); !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("expected ranges %v, got %v", expected, ranges)
+	}
+	if ranges := splitKeyRange("id", 1, 4, 4); ranges != nil {
+		t.Errorf("expected keys fitting one range not to be split, got %v", ranges)
+	}
+
+	//keys spanning the whole int64 range do not overflow
+	ranges := splitKeyRange("id", -1<<63, 1<<63-1, 1<<62)
+	if len(ranges) != 4 || !ranges[3].Last || ranges[3].Start != 1<<62 {
+		t.Errorf("expected 4 ranges over the int64 keys, got %v", ranges)
+	}
+
+	//small ranges over many keys are widened to stay below the cap
+	if ranges := splitKeyRange("ctid", 0, 1<<40, 1); len(ranges) > maxKeyRanges || !ranges[len(ranges)-1].Last {
+		t.Errorf("expected at most %d ranges, got %d", maxKeyRanges, len(ranges))
+	}
+}
+
+func TestMySQLSplitAndStreamTableRange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"COLUMN_NAME", "DATA_TYPE", "COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE", "IS_NULLABLE", "COLUMN_DEFAULT", "EXTRA"}
+	mock.ExpectQuery("(?i)FROM information_schema.COLUMNS").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("id", "bigint", "bigint", nil, 19, 0, "NO", nil, "auto_increment"))
+	mock.ExpectQuery("(?i)CONSTRAINT_NAME = 'PRIMARY'").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	mock.ExpectQuery("(?i)^SELECT COUNT\\(\\*\\) FROM events$").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2500))
+	//sparse keys, the boundaries follow the rows and not the span of the keys
+	mock.ExpectQuery("(?i)^SELECT MIN\\(id\\) FROM \\(SELECT id, NTILE\\(3\\) OVER \\(ORDER BY id\\) AS tile FROM events\\) tiles GROUP BY tile ORDER BY tile$").
+		WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(1).AddRow(1001).AddRow(9000000))
+	mock.ExpectQuery("(?i)^SELECT \\* FROM events WHERE id >= \\? AND id < \\? AND id > \\? ORDER BY id;$").
+		WithArgs(int64(1001), int64(9000000), int64(1500)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1501))
+
+	client := &MySQLClient{DB: db}
+	ranges, err := client.SplitTable("events", 1000)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(ranges) != 3 || ranges[1].Start != 1001 || ranges[1].End != 9000000 || !ranges[2].Last {
+		t.Fatalf("expected 3 ranges of about 1000 rows, got %v", ranges)
+	}
+
+	iterator, err := client.StreamTableRange(context.Background(), "events", ranges[1], int64(1500), 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+	if !iterator.Next() || len(iterator.Batch()) != 1 {
+		t.Errorf("expected the rows of the range after key 1500, got %v", iterator.Err())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgreSQLSplitTableByPages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"column_name", "data_type", "udt_name", "character_maximum_length", "numeric_precision", "numeric_scale", "is_nullable", "column_default", "is_identity"}
+	mock.ExpectQuery("(?i)FROM information_schema.columns").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("code", "text", "text", nil, nil, nil, "NO", nil, "NO"))
+	mock.ExpectQuery("(?i)SELECT kcu.column_name").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("code"))
+	mock.ExpectQuery("(?i)FROM pg_class c WHERE c.oid = \\$1::regclass").WithArgs("events").
+		WillReturnRows(sqlmock.NewRows([]string{"pages", "reltuples"}).AddRow(100, 10000))
+	mock.ExpectQuery("(?i)^SELECT \\* FROM events WHERE ctid >= \\$1::tid AND ctid < \\$2::tid ORDER BY ctid;$").
+		WithArgs("(40,0)", "(60,0)").
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).AddRow("a"))
+
+	client := &PostgreSQLClient{DB: db}
+	ranges, err := client.SplitTable("events", 2000)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	//100 rows per page, ranges of 20 pages
+	if len(ranges) != 5 || ranges[2].Column != "ctid" || ranges[2].Start != 40 || ranges[2].IsKeyed() {
+		t.Fatalf("expected 5 page ranges of 20 pages, got %v", ranges)
+	}
+
+	iterator, err := client.StreamTableRange(context.Background(), "events", ranges[2], nil, 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+	if !iterator.Next() {
+		t.Errorf("expected the rows of the page range, got %v", iterator.Err())
+	}
+	if _, err := client.StreamTableRange(context.Background(), "events", ranges[2], "a", 10); err == nil {
+		t.Errorf("expected page ranges to reject continuing after a key")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// application name of the tool's postgresql sessions, they are kept when the other sessions are ended for a cutover
+const postgresApplicationName = "data_migration_tool"
+
+// stopping every write to the server with super_read_only, which unlike read_only also applies to users with
+// SUPER, falling back to read_only on servers without it like MariaDB. The statement waits for running
+// transactions to commit. Needs the SUPER or SYSTEM_VARIABLES_ADMIN privilege
+func (c *MySQLClient) SetReadOnly(ctx context.Context, readOnly bool) error {
+	if c.DB == nil {
+		return fmt.Errorf("db connection not established")
+	}
+	ctx, cancel := operationContext(ctx, c.Timeouts.Query)
+	defer cancel()
+
+	if !readOnly {
+		//turning read_only off turns super_read_only off as well
+		if _, err := c.DB.ExecContext(ctx, "SET GLOBAL read_only = OFF"); err != nil {
+			return fmt.Errorf("failed to make mysql database writable, %v", err)
+		}
+		return nil
+	}
+	if _, err := c.DB.ExecContext(ctx, "SET GLOBAL super_read_only = ON"); err != nil {
+		if _, fallbackErr := c.DB.ExecContext(ctx, "SET GLOBAL read_only = ON"); fallbackErr != nil {
+			return fmt.Errorf("failed to make mysql database read-only, %v", err)
+		}
+	}
+	return nil
+}
+
+// making the database read-only by defaulting its new transactions to read-only and ending the sessions opened
+// before, except the tool's own. Applications reconnecting get read-only sessions, a session may still write
+// by explicitly starting a read write transaction. Needs ownership of the database and pg_signal_backend
+func (p *PostgreSQLClient) SetReadOnly(ctx context.Context, readOnly bool) error {
+	if p.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	ctx, cancel := operationContext(ctx, p.Timeouts.Query)
+	defer cancel()
+
+	database := `"` + strings.ReplaceAll(p.DBName, `"`, `""`) + `"`
+	if !readOnly {
+		if _, err := p.DB.ExecContext(ctx, fmt.Sprintf("ALTER DATABASE %s RESET default_transaction_read_only", database)); err != nil {
+			return fmt.Errorf("failed to make postgresql database writable, %v", err)
+		}
+		return nil
+	}
+
+	if _, err := p.DB.ExecContext(ctx, fmt.Sprintf("ALTER DATABASE %s SET default_transaction_read_only = on", database)); err != nil {
+		return fmt.Errorf("failed to make postgresql database read-only, %v", err)
+	}
+	//the setting only applies to new sessions, the open ones are ended so their writes stop as well
+	_, err := p.DB.ExecContext(ctx, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE datname = current_database() AND pid <> pg_backend_pid() AND backend_type = 'client backend' AND application_name <> $1`, postgresApplicationName)
+	if err != nil {
+		return fmt.Errorf("failed to end the open sessions of postgresql database %s, %v", p.DBName, err)
+	}
+	return nil
+}
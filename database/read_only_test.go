@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMySQLSetReadOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	//MariaDB has no super_read_only
+	mock.ExpectExec("SET GLOBAL super_read_only = ON").WillReturnError(fmt.Errorf("Unknown system variable 'super_read_only'"))
+	mock.ExpectExec("SET GLOBAL read_only = ON").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET GLOBAL read_only = OFF").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	client := &MySQLClient{DB: db}
+	if err := client.SetReadOnly(context.Background(), true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.SetReadOnly(context.Background(), false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgresSetReadOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`^ALTER DATABASE "shop" SET default_transaction_read_only = on$`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT pg_terminate_backend\\(pid\\) FROM pg_stat_activity").WithArgs(postgresApplicationName).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`^ALTER DATABASE "shop" RESET default_transaction_read_only$`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	client := &PostgreSQLClient{DB: db, DBName: "shop"}
+	if err := client.SetReadOnly(context.Background(), true); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.SetReadOnly(context.Background(), false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// policy for retrying database operations that failed with a transient error
+type RetryPolicy struct {
+	MaxAttempts    int           //attempts including the first one, 1 disables retries
+	InitialBackoff time.Duration //wait before the first retry, doubled for every further retry
+	MaxBackoff     time.Duration //upper bound of a single wait
+	Jitter         float64       //fraction of a wait that is randomised, 0.2 waits between 80% and 120% of the backoff
+}
+
+// returning the retry policy used unless one is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// returning the wait before the given retry, starting at 1
+func (rp RetryPolicy) Backoff(retry int) time.Duration {
+	backoff := rp.InitialBackoff
+	for i := 1; i < retry && backoff < rp.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if rp.MaxBackoff > 0 && backoff > rp.MaxBackoff {
+		backoff = rp.MaxBackoff
+	}
+	if rp.Jitter > 0 {
+		backoff = time.Duration(float64(backoff) * (1 + rp.Jitter*(2*rand.Float64()-1)))
+	}
+	return backoff
+}
+
+// running operation until it succeeds, fails with an error that is not transient, runs out of attempts
+// or ctx is cancelled. onRetry, when set, is called before waiting for every retry
+func (rp RetryPolicy) Do(ctx context.Context, operation func() error, onRetry func(retry int, err error, wait time.Duration)) error {
+	for attempt := 1; ; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+		if !IsTransientError(err) || ctx.Err() != nil {
+			return err
+		}
+		if attempt >= rp.MaxAttempts {
+			if attempt > 1 {
+				return fmt.Errorf("giving up after %d attempts, %w", attempt, err)
+			}
+			return err
+		}
+
+		wait := rp.Backoff(attempt)
+		if onRetry != nil {
+			onRetry(attempt, err, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// MySQL errors resolved by running the transaction again
+var mysqlTransientErrors = map[uint16]bool{
+	1205: true, //lock wait timeout exceeded
+	1213: true, //deadlock found when trying to get lock
+}
+
+// PostgreSQL error codes resolved by running the transaction again, besides connection exceptions of class 08
+var postgresTransientErrors = map[pq.ErrorCode]bool{
+	"40001": true, //serialization_failure
+	"40P01": true, //deadlock_detected
+	"57P01": true, //admin_shutdown
+	"57P02": true, //crash_shutdown
+	"57P03": true, //cannot_connect_now
+}
+
+// checking if an error is transient, ie. a deadlock, lock timeout, serialization failure or lost connection
+// that is likely to succeed when the operation is run again
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlTransientErrors[mysqlErr.Number]
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return postgresTransientErrors[pqErr.Code] || pqErr.Code.Class() == "08"
+	}
+
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) && (labeled.HasErrorLabel("RetryableWriteError") || labeled.HasErrorLabel("TransientTransactionError")) {
+		return true
+	}
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+
+	//connections dropped or timing out underneath any driver
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsTransientError(t *testing.T) {
+	testCases := []struct {
+		description string
+		err         error
+		transient   bool
+	}{
+		{"MySQL deadlock", &mysql.MySQLError{Number: 1213}, true},
+		{"MySQL lock wait timeout", fmt.Errorf("failed to insert row, %w", &mysql.MySQLError{Number: 1205}), true},
+		{"MySQL duplicate key", &mysql.MySQLError{Number: 1062}, false},
+		{"MySQL dropped connection", mysql.ErrInvalidConn, true},
+		{"PostgreSQL serialization failure", &pq.Error{Code: "40001"}, true},
+		{"PostgreSQL connection failure", &pq.Error{Code: "08006"}, true},
+		{"PostgreSQL unique violation", &pq.Error{Code: "23505"}, false},
+		{"MongoDB retryable write", mongo.CommandError{Code: 91, Labels: []string{"RetryableWriteError"}}, true},
+		{"MongoDB duplicate key", mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}, false},
+		{"timeout", context.DeadlineExceeded, true},
+		{"cancelled", context.Canceled, false},
+		{"other error", errors.New("disk full"), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if transient := IsTransientError(tc.err); transient != tc.transient {
+				t.Errorf("Expected transient %v for %v, got %v", tc.transient, tc.err, transient)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, want := range expected {
+		if got := policy.Backoff(i + 1); got != want {
+			t.Errorf("Expected backoff %v before retry %d, got %v", want, i+1, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("Expected a jittered backoff between 50ms and 150ms, got %v", got)
+		}
+	}
+}
+
+func TestRetryPolicyRetriesTransientErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+	attempts, retries := 0, 0
+	err := policy.Do(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return &mysql.MySQLError{Number: 1213}
+		}
+		return nil
+	}, func(retry int, err error, wait time.Duration) {
+		retries++
+	})
+	if err != nil || attempts != 3 || retries != 2 {
+		t.Errorf("Expected success on the 3rd attempt after 2 retries, got %v after %d attempts and %d retries", err, attempts, retries)
+	}
+
+	attempts = 0
+	err = policy.Do(context.Background(), func() error {
+		attempts++
+		return &pq.Error{Code: "40001"}
+	}, nil)
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || attempts != 5 {
+		t.Errorf("Expected to give up with the serialization failure after 5 attempts, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = policy.Do(context.Background(), func() error {
+		attempts++
+		return &mysql.MySQLError{Number: 1062}
+	}, nil)
+	if err == nil || attempts != 1 {
+		t.Errorf("Expected a duplicate key to fail without retrying, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestRetryPolicyStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second}
+	start := time.Now()
+	attempts := 0
+	err := policy.Do(ctx, func() error {
+		attempts++
+		return &mysql.MySQLError{Number: 1205}
+	}, nil)
+	if err == nil || attempts != 1 {
+		t.Errorf("Expected the cancelled wait to stop retrying, got %v after %d attempts", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the wait to end with its context, took %v", elapsed)
+	}
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// reading the primary key columns of a table from the catalog, in key order
+func queryPrimaryKeyColumns(db *sql.DB, query, schema, tableName string) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	rows, err := db.Query(query, schema, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query primary key of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to scan primary key column, %v", err)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	return columns, nil
+}
+
+// checking the catalog for a table and counting its rows if it exists
+func queryTableRowCount(db *sql.DB, existsQuery, tableName string) (bool, int64, error) {
+	if db == nil {
+		return false, 0, fmt.Errorf("database connection not established")
+	}
+
+	var tables int64
+	schema, name := splitQualifiedName(tableName)
+	if err := db.QueryRow(existsQuery, schema, name).Scan(&tables); err != nil {
+		return false, 0, fmt.Errorf("failed to look up table %s in the catalog, %v", tableName, err)
+	}
+	if tables == 0 {
+		return false, 0, nil
+	}
+
+	var count int64
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", sanitizeIdentifier(tableName))).Scan(&count); err != nil {
+		return false, 0, fmt.Errorf("failed to count rows of table %s, %v", tableName, err)
+	}
+	return true, count, nil
+}
+
+// deleting rows of one table identified by their key column values in a single transaction
+func deleteTableRows(db *sql.DB, tableName string, keyColumns []string, rows []map[string]interface{}, placeholder func(i int) string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection not established")
+	}
+	if len(keyColumns) == 0 {
+		return 0, fmt.Errorf("no key columns given for deleting from table %s", tableName)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	conditions := make([]string, len(keyColumns))
+	for i, key := range keyColumns {
+		conditions[i] = fmt.Sprintf("%s = %s", sanitizeIdentifier(key), placeholder(i+1))
+	}
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s", sanitizeIdentifier(tableName), strings.Join(conditions, " AND "))
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction, %v", err)
+	}
+
+	stmt, err := tx.Prepare(deleteSQL)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to prepare delete statement, %v", err)
+	}
+	defer stmt.Close()
+
+	var deleted int64
+	for _, row := range rows {
+		keyValues := make([]interface{}, len(keyColumns))
+		for i, key := range keyColumns {
+			if row[key] == nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("row in table %s has no value for key column %s", tableName, key)
+			}
+			keyValues[i] = row[key]
+		}
+
+		result, err := stmt.Exec(keyValues...)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to delete row from table %s, %v", tableName, err)
+		}
+		if affected, err := result.RowsAffected(); err == nil {
+			deleted += affected
+		}
+	}
+
+	//Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction, %v", err)
+	}
+	return deleted, nil
+}
+
+// dropping a table if it exists
+func dropSQLTable(db *sql.DB, tableName string) error {
+	if db == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", sanitizeIdentifier(tableName))); err != nil {
+		return fmt.Errorf("failed to drop table %s, %v", tableName, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPostgreSQLPrimaryKeyColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"column_name"}).AddRow("tenant_id").AddRow("id")
+	mock.ExpectQuery("(?i)SELECT kcu.column_name FROM information_schema.table_constraints").WithArgs("", "users").WillReturnRows(mockRows)
+
+	client := &PostgreSQLClient{DB: db}
+	columns, err := client.PrimaryKeyColumns("users")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(columns) != 2 || columns[0] != "tenant_id" || columns[1] != "id" {
+		t.Errorf("expected [tenant_id id], got %v", columns)
+	}
+}
+
+func TestMySQLDeleteRows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	rows := []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Sathyaraj"},
+	}
+
+	mock.ExpectBegin()
+	stmt := mock.ExpectPrepare("(?i)^DELETE FROM users WHERE id = \\?$")
+	stmt.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	stmt.ExpectExec().WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	client := &MySQLClient{DB: db}
+	deleted, err := client.DeleteRows("users", []string{"id"}, rows)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted row, got %d", deleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestDeleteRowsMissingKeyValue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("(?i)^DELETE FROM users WHERE id = \\$1$")
+	mock.ExpectRollback()
+
+	client := &PostgreSQLClient{DB: db}
+	if _, err := client.DeleteRows("users", []string{"id"}, []map[string]interface{}{{"name": "Susheel"}}); err == nil {
+		t.Errorf("expected error for row without key value, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLDropTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("(?i)^DROP TABLE IF EXISTS users$").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	client := &MySQLClient{DB: db}
+	if err := client.DropTable("users"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestPostgreSQLTableRowCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("(?i)SELECT COUNT\\(\\*\\) FROM information_schema.tables").WithArgs("sales", "orders").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("(?i)^SELECT COUNT\\(\\*\\) FROM sales.orders$").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	mock.ExpectQuery("(?i)SELECT COUNT\\(\\*\\) FROM information_schema.tables").WithArgs("", "users").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("(?i)SELECT COUNT\\(\\*\\) FROM information_schema.tables").WithArgs("", "users").
+		WillReturnError(fmt.Errorf("connection reset by peer"))
+
+	client := &PostgreSQLClient{DB: db}
+	if exists, count, err := client.TableRowCount("sales.orders"); err != nil || !exists || count != 42 {
+		t.Errorf("expected existing table with 42 rows, got %v %d %v", exists, count, err)
+	}
+	if exists, _, err := client.TableRowCount("users"); err != nil || exists {
+		t.Errorf("expected missing table without error, got %v %v", exists, err)
+	}
+	//a failed lookup is an error, not a missing table
+	if _, _, err := client.TableRowCount("users"); err == nil {
+		t.Errorf("expected error for failed catalog lookup, got nil")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
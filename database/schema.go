@@ -0,0 +1,270 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// definition of a single column as read from the source catalog
+type ColumnDefinition struct {
+	Name          string
+	DataType      string  //type name in the dialect of the schema, eg. varchar, character varying, objectId
+	ColumnType    string  //full source type including length and modifiers, eg. int(11) unsigned
+	Length        int64   //maximum character length, 0 when not applicable
+	Precision     int64   //numeric precision, 0 when not applicable
+	Scale         int64   //numeric scale
+	Nullable      bool    //whether the column accepts NULL
+	Default       *string //portable default, a quoted literal, number, TRUE/FALSE or CURRENT_TIMESTAMP
+	AutoIncrement bool    //AUTO_INCREMENT, identity or serial column
+}
+
+// definition of a table, its columns in ordinal order and primary key
+type TableSchema struct {
+	Name       string
+	Dialect    string //database the column types belong to, mysql, postgresql, mongodb or sqlite
+	Columns    []ColumnDefinition
+	PrimaryKey []string
+}
+
+// translating the schema of a source table into the dialect of the type mapper's target
+func TranslateSchema(schema *TableSchema, mapper *TypeMapper) (*TableSchema, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("no schema to translate")
+	}
+	if schema.Dialect != mapper.source {
+		return nil, fmt.Errorf("schema of table %s is in %s, type mapping expects %s", schema.Name, schema.Dialect, mapper.source)
+	}
+
+	translated := &TableSchema{
+		Name:       schema.Name,
+		Dialect:    mapper.target,
+		Columns:    make([]ColumnDefinition, len(schema.Columns)),
+		PrimaryKey: append([]string{}, schema.PrimaryKey...),
+	}
+	for i, column := range schema.Columns {
+		column.DataType = mapper.MapColumn(column)
+		column.ColumnType = column.DataType
+		translated.Columns[i] = column
+	}
+	return translated, nil
+}
+
+// types that cannot carry a literal default or be part of a key in MySQL without a prefix length
+var mysqlLargeObjectType = regexp.MustCompile(`(?i)^(tiny|medium|long)?(text|blob)$|^json$`)
+
+// building the MySQL DDL of a translated schema
+func mysqlCreateTableDDL(schema *TableSchema) string {
+	definitions := make([]string, 0, len(schema.Columns)+1)
+	for _, column := range schema.Columns {
+		dataType := column.DataType
+		isKey := containsColumn(schema.PrimaryKey, column.Name)
+
+		//large object columns cannot be part of a primary key, using a bounded type instead
+		if isKey && mysqlLargeObjectType.MatchString(dataType) {
+			if strings.Contains(strings.ToLower(dataType), "blob") {
+				dataType = "VARBINARY(255)"
+			} else {
+				dataType = "VARCHAR(255)"
+			}
+		}
+
+		definition := fmt.Sprintf("%s %s", column.Name, dataType)
+		if !column.Nullable || isKey {
+			definition += " NOT NULL"
+		}
+		if column.Default != nil && !column.AutoIncrement && !mysqlLargeObjectType.MatchString(dataType) {
+			definition += " DEFAULT " + *column.Default
+		}
+		//AUTO_INCREMENT is only allowed on a key column
+		if column.AutoIncrement && len(schema.PrimaryKey) == 1 && isKey {
+			definition += " AUTO_INCREMENT"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(schema.PrimaryKey) > 0 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(schema.PrimaryKey, ", ")))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", schema.Name, strings.Join(definitions, ", "))
+}
+
+// integer types that can be identity columns in PostgreSQL
+var postgresIntegerType = regexp.MustCompile(`(?i)^(smallint|integer|int|bigint)$`)
+
+// building the PostgreSQL DDL of a translated schema
+func postgresCreateTableDDL(schema *TableSchema) string {
+	definitions := make([]string, 0, len(schema.Columns)+1)
+	for _, column := range schema.Columns {
+		definition := fmt.Sprintf("%s %s", column.Name, column.DataType)
+		if column.AutoIncrement && postgresIntegerType.MatchString(column.DataType) {
+			definition += " GENERATED BY DEFAULT AS IDENTITY"
+		} else if column.Default != nil {
+			definition += " DEFAULT " + *column.Default
+		}
+		if !column.Nullable || containsColumn(schema.PrimaryKey, column.Name) {
+			definition += " NOT NULL"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(schema.PrimaryKey) > 0 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(schema.PrimaryKey, ", ")))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", schema.Name, strings.Join(definitions, ", "))
+}
+
+// building the SQLite DDL of a translated schema. A single auto increment integer key becomes an
+// INTEGER PRIMARY KEY, the alias of the rowid that SQLite assigns to new rows
+func sqliteCreateTableDDL(schema *TableSchema) string {
+	definitions := make([]string, 0, len(schema.Columns)+1)
+	rowidKey := false
+	for _, column := range schema.Columns {
+		definition := fmt.Sprintf("%s %s", column.Name, column.DataType)
+		isKey := containsColumn(schema.PrimaryKey, column.Name)
+		if column.AutoIncrement && isKey && len(schema.PrimaryKey) == 1 && strings.EqualFold(column.DataType, "INTEGER") {
+			definitions = append(definitions, definition+" PRIMARY KEY")
+			rowidKey = true
+			continue
+		}
+		if !column.Nullable || isKey {
+			definition += " NOT NULL"
+		}
+		if column.Default != nil {
+			definition += " DEFAULT " + *column.Default
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(schema.PrimaryKey) > 0 && !rowidKey {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(schema.PrimaryKey, ", ")))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", schema.Name, strings.Join(definitions, ", "))
+}
+
+// splitting a schema qualified table name, the schema is empty for bare names
+func splitQualifiedName(tableName string) (string, string) {
+	if i := strings.Index(tableName, "."); i >= 0 {
+		return tableName[:i], tableName[i+1:]
+	}
+	return "", tableName
+}
+
+// quoting a string as SQL literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// checking if a default is a plain number
+func isNumericLiteral(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+// checking if a default expression means the current time
+func isCurrentTimestamp(value string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	for _, prefix := range []string{"CURRENT_TIMESTAMP", "NOW()", "LOCALTIMESTAMP", "STATEMENT_TIMESTAMP()", "TRANSACTION_TIMESTAMP()"} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizing a MySQL COLUMN_DEFAULT into a portable default
+func normalizeMySQLDefault(value *string, extra string, isNumeric bool, column string) *string {
+	if value == nil || strings.EqualFold(*value, "NULL") {
+		return nil
+	}
+
+	var normalized string
+	switch {
+	case isCurrentTimestamp(*value):
+		normalized = "CURRENT_TIMESTAMP"
+	case strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED"):
+		log.Printf("Warning: default expression %s of column %s cannot be translated, dropping it", *value, column)
+		return nil
+	case isNumeric && isNumericLiteral(*value):
+		normalized = *value
+	case strings.HasPrefix(*value, "'") && strings.HasSuffix(*value, "'") && len(*value) > 1:
+		//MariaDB reports string defaults already quoted
+		normalized = *value
+	default:
+		normalized = quoteLiteral(*value)
+	}
+	return &normalized
+}
+
+// casts appended to PostgreSQL defaults, eg. 'active'::character varying
+var postgresCast = regexp.MustCompile(`::[a-zA-Z_ "]+(\(\d+(,\d+)?\))?(\[\])?$`)
+
+// normalizing a PostgreSQL column_default into a portable default, nextval defaults mark auto increment
+func normalizePostgresDefault(value *string, column string) (*string, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	expression := strings.TrimSpace(*value)
+	if strings.HasPrefix(strings.ToLower(expression), "nextval(") {
+		return nil, true
+	}
+	for postgresCast.MatchString(expression) {
+		expression = strings.TrimSpace(postgresCast.ReplaceAllString(expression, ""))
+	}
+	//negative numbers are wrapped in parentheses
+	if strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+		expression = strings.TrimSuffix(strings.TrimPrefix(expression, "("), ")")
+	}
+
+	var normalized string
+	switch {
+	case strings.EqualFold(expression, "NULL"):
+		return nil, false
+	case isCurrentTimestamp(expression):
+		normalized = "CURRENT_TIMESTAMP"
+	case strings.EqualFold(expression, "true"), strings.EqualFold(expression, "false"):
+		normalized = strings.ToUpper(expression)
+	case isNumericLiteral(expression):
+		normalized = expression
+	case strings.HasPrefix(expression, "'") && strings.HasSuffix(expression, "'") && len(expression) > 1:
+		normalized = expression
+	default:
+		log.Printf("Warning: default expression %s of column %s cannot be translated, dropping it", *value, column)
+		return nil, false
+	}
+	return &normalized, false
+}
+
+// normalizing a SQLite dflt_value, the default expression as written in CREATE TABLE, into a portable default
+func normalizeSQLiteDefault(value *string, column string) *string {
+	if value == nil {
+		return nil
+	}
+
+	expression := strings.TrimSpace(*value)
+	//expressions and negative numbers may be wrapped in parentheses
+	for strings.HasPrefix(expression, "(") && strings.HasSuffix(expression, ")") {
+		expression = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(expression, "("), ")"))
+	}
+
+	var normalized string
+	switch {
+	case strings.EqualFold(expression, "NULL"):
+		return nil
+	case isCurrentTimestamp(expression), strings.EqualFold(expression, "datetime('now')"):
+		normalized = "CURRENT_TIMESTAMP"
+	case strings.EqualFold(expression, "true"), strings.EqualFold(expression, "false"):
+		normalized = strings.ToUpper(expression)
+	case isNumericLiteral(expression):
+		normalized = expression
+	case strings.HasPrefix(expression, "'") && strings.HasSuffix(expression, "'") && len(expression) > 1:
+		normalized = expression
+	case strings.HasPrefix(expression, `"`) && strings.HasSuffix(expression, `"`) && len(expression) > 1:
+		//double quoted strings are accepted as literals by SQLite
+		normalized = quoteLiteral(strings.ReplaceAll(expression[1:len(expression)-1], `""`, `"`))
+	default:
+		log.Printf("Warning: default expression %s of column %s cannot be translated, dropping it", *value, column)
+		return nil
+	}
+	return &normalized
+}
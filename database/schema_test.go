@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMySQLDescribeTableTranslatedToPostgreSQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"COLUMN_NAME", "DATA_TYPE", "COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE", "IS_NULLABLE", "COLUMN_DEFAULT", "EXTRA"}
+	mockRows := sqlmock.NewRows(columns).
+		AddRow("id", "int", "int(11)", nil, 10, 0, "NO", nil, "auto_increment").
+		AddRow("email", "varchar", "varchar(120)", 120, nil, nil, "NO", nil, "").
+		AddRow("balance", "decimal", "decimal(12,4)", nil, 12, 4, "YES", "0.0000", "").
+		AddRow("status", "varchar", "varchar(16)", 16, nil, nil, "YES", "active", "").
+		AddRow("created_at", "datetime", "datetime", nil, nil, nil, "YES", "CURRENT_TIMESTAMP", "DEFAULT_GENERATED").
+		AddRow("avatar", "blob", "blob", 65535, nil, nil, "YES", nil, "")
+	mock.ExpectQuery("(?i)FROM information_schema.COLUMNS").WithArgs("", "users").WillReturnRows(mockRows)
+	mock.ExpectQuery("(?i)FROM information_schema.KEY_COLUMN_USAGE").WithArgs("", "users").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+
+	client := &MySQLClient{DB: db}
+	schema, err := client.DescribeTable("users")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	mapper, err := NewTypeMapper("mysql", "postgresql", nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	translated, err := TranslateSchema(schema, mapper)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := "CREATE TABLE IF NOT EXISTS users (" +
+		"id INTEGER GENERATED BY DEFAULT AS IDENTITY NOT NULL, " +
+		"email VARCHAR(120) NOT NULL, " +
+		"balance NUMERIC(12,4) DEFAULT 0.0000, " +
+		"status VARCHAR(16) DEFAULT 'active', " +
+		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
+		"avatar BYTEA, " +
+		"PRIMARY KEY (id));"
+	if ddl := postgresCreateTableDDL(translated); ddl != expected {
+		t.Errorf("expected DDL\n%s\ngot\n%s", expected, ddl)
+	}
+}
+
+func TestPostgreSQLDescribeTableTranslatedToMySQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"column_name", "data_type", "udt_name", "character_maximum_length", "numeric_precision", "numeric_scale", "is_nullable", "column_default", "is_identity"}
+	mockRows := sqlmock.NewRows(columns).
+		AddRow("id", "integer", "int4", nil, 32, 0, "NO", "nextval('orders_id_seq'::regclass)", "NO").
+		AddRow("code", "text", "text", nil, nil, nil, "NO", nil, "NO").
+		AddRow("amount", "numeric", "numeric", nil, nil, nil, "YES", "(-1)", "NO").
+		AddRow("state", "character varying", "varchar", 20, nil, nil, "YES", "'new'::character varying", "NO").
+		AddRow("shipped", "boolean", "bool", nil, nil, nil, "YES", "false", "NO")
+	mock.ExpectQuery("(?i)FROM information_schema.columns").WithArgs("sales", "orders").WillReturnRows(mockRows)
+	mock.ExpectQuery("(?i)SELECT kcu.column_name").WithArgs("sales", "orders").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id").AddRow("code"))
+
+	client := &PostgreSQLClient{DB: db}
+	schema, err := client.DescribeTable("sales.orders")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !schema.Columns[0].AutoIncrement {
+		t.Errorf("expected nextval default to mark id as auto increment")
+	}
+
+	mapper, _ := NewTypeMapper("postgresql", "mysql", map[string]string{"boolean": "TINYINT(1)"})
+	translated, err := TranslateSchema(schema, mapper)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	//AUTO_INCREMENT is dropped as id is only part of a composite key, the text key column gets a bounded type
+	expected := "CREATE TABLE IF NOT EXISTS sales.orders (" +
+		"id INT NOT NULL, " +
+		"code VARCHAR(255) NOT NULL, " +
+		"amount DECIMAL(65,30) DEFAULT -1, " +
+		"state VARCHAR(20) DEFAULT 'new', " +
+		"shipped TINYINT(1) DEFAULT FALSE, " +
+		"PRIMARY KEY (id, code));"
+	if ddl := mysqlCreateTableDDL(translated); ddl != expected {
+		t.Errorf("expected DDL\n%s\ngot\n%s", expected, ddl)
+	}
+}
+
+func TestCreateTableRequiresTranslatedSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	client := &PostgreSQLClient{DB: db}
+	schema := &TableSchema{Name: "users", Dialect: "mysql", Columns: []ColumnDefinition{{Name: "id", DataType: "int", Nullable: true}}}
+	if err := client.CreateTable(schema); err == nil {
+		t.Errorf("expected error for untranslated schema, got nil")
+	}
+
+	schema.Dialect = "postgresql"
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users \\(id int\\);$").WillReturnResult(driver.ResultNoRows)
+	if err := client.CreateTable(schema); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTypeMapperMapColumn(t *testing.T) {
+	mapper, err := NewTypeMapper("mysql", "postgresql", map[string]string{"TinyInt": "BOOLEAN"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	testCases := []struct {
+		column   ColumnDefinition
+		expected string
+	}{
+		{ColumnDefinition{Name: "flag", DataType: "tinyint"}, "BOOLEAN"},
+		{ColumnDefinition{Name: "name", DataType: "varchar", Length: 50}, "VARCHAR(50)"},
+		{ColumnDefinition{Name: "name", DataType: "varchar"}, "TEXT"},
+		{ColumnDefinition{Name: "price", DataType: "decimal", Precision: 8, Scale: 2}, "NUMERIC(8,2)"},
+		{ColumnDefinition{Name: "shape", DataType: "geometry"}, "TEXT"},
+		{ColumnDefinition{Name: "port", DataType: "smallint", ColumnType: "smallint(5) unsigned"}, "INTEGER"},
+		{ColumnDefinition{Name: "views", DataType: "int", ColumnType: "int(10) unsigned"}, "BIGINT"},
+		{ColumnDefinition{Name: "views", DataType: "int", ColumnType: "int(11)"}, "INTEGER"},
+		{ColumnDefinition{Name: "hash", DataType: "bigint", ColumnType: "bigint(20) unsigned"}, "NUMERIC(20)"},
+		{ColumnDefinition{Name: "level", DataType: "tinyint", ColumnType: "tinyint(3) unsigned"}, "BOOLEAN"},
+	}
+	for _, tc := range testCases {
+		if result := mapper.MapColumn(tc.column); result != tc.expected {
+			t.Errorf("MapColumn(%s %s)=%s, expected %s", tc.column.Name, tc.column.DataType, result, tc.expected)
+		}
+	}
+
+	if _, err := NewTypeMapper("mysql", "mysql", nil); err == nil {
+		t.Errorf("expected error for unsupported pair, got nil")
+	}
+}
+
+func TestMergeBSONTypes(t *testing.T) {
+	testCases := []struct {
+		types    map[string]bool
+		expected string
+	}{
+		{map[string]bool{"string": true, "null": true}, "string"},
+		{map[string]bool{"int": true, "long": true}, "long"},
+		{map[string]bool{"int": true, "double": true}, "double"},
+		{map[string]bool{"int": true, "string": true}, "string"},
+		{map[string]bool{"null": true}, "null"},
+	}
+	for _, tc := range testCases {
+		if result := mergeBSONTypes(tc.types); result != tc.expected {
+			t.Errorf("mergeBSONTypes(%v)=%s, expected %s", tc.types, result, tc.expected)
+		}
+	}
+}
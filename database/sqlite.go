@@ -0,0 +1,787 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
+	_ "modernc.org/sqlite"
+)
+
+// time a statement waits for the lock of the database file held by another connection, the concurrent
+// batch writers of an import take turns on the single writer lock of SQLite
+const sqliteBusyTimeout = 30 * time.Second
+
+type SQLiteClient struct {
+	Path     string //database file, created when it does not exist
+	DB       *sql.DB
+	BulkLoad bool //loading rows with multi-row inserts instead of one INSERT per row
+	Timeouts config.TimeoutConfig
+	Filters  RowFilters //WHERE conditions restricting the rows read from each table
+
+	Progress *monitoring.ProcessTracker //progress of the batches of ImportDataConcurrently, nil when not reported
+}
+
+func NewSQLiteClient(path string) *SQLiteClient {
+	return &SQLiteClient{
+		Path:     path,
+		BulkLoad: true,
+	}
+}
+
+func NewSQLiteClientFromConfig(cfg *config.Config) *SQLiteClient {
+	return &SQLiteClient{
+		Path:     cfg.SQLite.Path,
+		BulkLoad: true,
+		Timeouts: cfg.Timeouts,
+	}
+}
+
+// naming the database by the absolute path of its file
+func (s *SQLiteClient) Location() string {
+	if path, err := filepath.Abs(s.Path); err == nil {
+		return path
+	}
+	return s.Path
+}
+
+// connect to the SQLite database file
+func (s *SQLiteClient) Connect() error {
+	return s.ConnectContext(context.Background())
+}
+
+// connect to the SQLite database file, giving up when ctx is cancelled or the connect timeout expires
+func (s *SQLiteClient) ConnectContext(ctx context.Context) error {
+	if s.Path == "" {
+		return fmt.Errorf("sqlite database path not configured")
+	}
+	//transactions take the writer lock when they begin, so concurrent writers wait for it instead of
+	//failing to upgrade a read lock. The busy timeout is set by a pragma on every new connection
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_txlock=immediate", s.Path, sqliteBusyTimeout.Milliseconds())
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open sqlite database %s, %v", s.Path, err)
+	}
+
+	//testing connection
+	ctx, cancel := operationContext(ctx, s.Timeouts.Connect)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to ping sqlite database %s, %v", s.Path, err)
+	}
+	s.DB = db
+	fmt.Println("successfully connected to the sqlite database!!!")
+	return nil
+}
+
+// reporting the rows of every batch written by ImportDataConcurrently to tracker
+func (s *SQLiteClient) SetProgressTracker(tracker *monitoring.ProcessTracker) {
+	s.Progress = tracker
+}
+
+// loading rows with multi-row inserts, or with one INSERT per row when disabled
+func (s *SQLiteClient) SetBulkLoad(enabled bool) {
+	s.BulkLoad = enabled
+}
+
+// restricting the rows read from each table to those matching its WHERE condition
+func (s *SQLiteClient) SetRowFilters(filters RowFilters) error {
+	if err := filters.validateSQL(); err != nil {
+		return err
+	}
+	s.Filters = filters
+	return nil
+}
+
+// Close the database connection
+func (s *SQLiteClient) Close() error {
+	if s.DB != nil {
+		return s.DB.Close()
+	}
+	return nil
+}
+
+// Executing a query
+func (s *SQLiteClient) ExecuteQuery(query string) (*sql.Rows, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	return s.DB.Query(query)
+}
+
+func (s *SQLiteClient) FetchAllData(tables []string) ([]map[string]interface{}, error) {
+	return s.FetchAllDataContext(context.Background(), tables)
+}
+
+// fetching all rows of the tables, cancelling the running query when ctx is cancelled or the query timeout expires
+func (s *SQLiteClient) FetchAllDataContext(ctx context.Context, tables []string) ([]map[string]interface{}, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	var allResults []map[string]interface{}
+
+	for _, tableName := range tables {
+		sanitizedTableName := sanitizeIdentifier(tableName)
+		query := fmt.Sprintf("SELECT * FROM %s%s;", sanitizedTableName, whereClause(s.Filters.For(tableName)))
+
+		queryCtx, cancel := operationContext(ctx, s.Timeouts.Query)
+		defer cancel()
+		rows, err := s.DB.QueryContext(queryCtx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute query on table %s, %v", tableName, err)
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get column names for table %s, %v", tableName, err)
+		}
+		scan, err := sqliteRowScanner(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get column types for table %s, %v", tableName, err)
+		}
+		for rows.Next() {
+			rowMap, err := scan(rows, columns)
+			if err != nil {
+				return nil, err
+			}
+			rowMap["_source_table"] = tableName
+			allResults = append(allResults, rowMap)
+		}
+		//check for errors after iterating through rows
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error during row iteration %v", err)
+		}
+	}
+	return allResults, nil
+}
+
+// streaming rows of a single table in batches without loading the whole table
+func (s *SQLiteClient) StreamTable(ctx context.Context, tableName string, batchSize int) (RowIterator, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	query := fmt.Sprintf("SELECT * FROM %s%s;", sanitizedTableName, whereClause(s.Filters.For(tableName)))
+
+	stream := newStreamContext(ctx, s.Timeouts.Query)
+	rows, err := stream.query(s.DB, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query on table %s, %w", tableName, err)
+	}
+	return newSQLiteRowIterator(stream, rows, tableName, batchSize)
+}
+
+// creating an iterator over a query executed in the stream, reading the values by the declared types of the columns
+func newSQLiteRowIterator(stream *streamContext, rows *sql.Rows, tableName string, batchSize int) (*sqlRowIterator, error) {
+	scan, err := sqliteRowScanner(rows)
+	if err != nil {
+		rows.Close()
+		stream.close()
+		return nil, fmt.Errorf("failed to get column types for table %s, %w", tableName, err)
+	}
+	iterator, err := newSQLRowIterator(stream, rows, tableName, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	iterator.scan = scan
+	return iterator, nil
+}
+
+// scanning rows like the other sql clients, then converting the values by the declared types of the columns.
+// The driver returns values by how sqlite stored them, so BOOLEAN columns come back as 0 and 1
+func sqliteRowScanner(rows *sql.Rows) (rowScanner, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	declaredTypes := make([]string, len(columnTypes))
+	for i, columnType := range columnTypes {
+		declaredTypes[i] = strings.ToUpper(columnType.DatabaseTypeName())
+	}
+
+	return func(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+		values := make([]interface{}, len(columns))
+		valuesPtr := make([]interface{}, len(columns))
+		for i := range values {
+			valuesPtr[i] = &values[i]
+		}
+		if err := rows.Scan(valuesPtr...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		rowMap := make(map[string]interface{}, len(columns)+1)
+		for i, colName := range columns {
+			rowMap[colName] = sqliteValue(declaredTypes[i], values[i])
+		}
+		return rowMap, nil
+	}, nil
+}
+
+// converting a value read from a column of the declared type, BOOLEAN to bool and BLOB bytes to string like
+// the other sql clients do
+func sqliteValue(declaredType string, value interface{}) interface{} {
+	if i, ok := value.(int64); ok && (declaredType == "BOOLEAN" || declaredType == "BOOL") {
+		return i != 0
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// streaming rows whose watermark column is at or past the given watermark, ordered by that column. Rows at
+// the watermark are read again, as rows committed after the last run may share its value
+func (s *SQLiteClient) StreamTableSince(ctx context.Context, tableName, column string, watermark interface{}, batchSize int) (RowIterator, error) {
+	if watermark == nil {
+		return s.StreamTable(ctx, tableName, batchSize)
+	}
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	sanitizedColumn := sanitizeIdentifier(column)
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizedTableName,
+		whereClause(s.Filters.For(tableName), sanitizedColumn+" >= ?"), sanitizedColumn)
+
+	stream := newStreamContext(ctx, s.Timeouts.Query)
+	rows, err := stream.query(s.DB, query, sqliteWatermark(watermark))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute incremental query on table %s, %w", tableName, err)
+	}
+	return newSQLiteRowIterator(stream, rows, tableName, batchSize)
+}
+
+// returning a time watermark in the text form sqlite keeps datetimes in, sqlite compares them as text so a
+// watermark with a zone suffix would sort after an equal datetime stored without one
+func sqliteWatermark(watermark interface{}) interface{} {
+	if t, ok := watermark.(time.Time); ok {
+		return t.UTC().Format("2006-01-02 15:04:05.999999999")
+	}
+	return watermark
+}
+
+// streaming rows ordered by a key column, only rows after lastKey unless it is nil
+func (s *SQLiteClient) StreamTableAfter(ctx context.Context, tableName, keyColumn string, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	sanitizedTableName := sanitizeIdentifier(tableName)
+	sanitizedColumn := sanitizeIdentifier(keyColumn)
+	var conditions []string
+	var args []interface{}
+	if lastKey != nil {
+		conditions = append(conditions, sanitizedColumn+" > ?")
+		args = append(args, lastKey)
+	}
+	query := fmt.Sprintf("SELECT * FROM %s%s ORDER BY %s;", sanitizedTableName, whereClause(s.Filters.For(tableName), conditions...), sanitizedColumn)
+
+	stream := newStreamContext(ctx, s.Timeouts.Query)
+	rows, err := stream.query(s.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute keyset query on table %s, %w", tableName, err)
+	}
+	return newSQLiteRowIterator(stream, rows, tableName, batchSize)
+}
+
+// splitting a table into ranges of about rowsPerRange rows by its single column integer primary key,
+// nil for tables without one
+func (s *SQLiteClient) SplitTable(tableName string, rowsPerRange int64) ([]KeyRange, error) {
+	schema, err := s.DescribeTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	keyColumn := integerKeyColumn(schema)
+	if keyColumn == "" {
+		return nil, nil
+	}
+
+	return queryKeyRanges(s.DB, tableName, keyColumn, s.Filters.For(tableName), rowsPerRange)
+}
+
+// streaming the rows of a key range ordered by its key, only rows after lastKey unless it is nil
+func (s *SQLiteClient) StreamTableRange(ctx context.Context, tableName string, keyRange KeyRange, lastKey interface{}, batchSize int) (RowIterator, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	placeholder := func(i int) string { return "?" }
+	query, args := rangeQuery(tableName, keyRange, lastKey, s.Filters.For(tableName), placeholder)
+	stream := newStreamContext(ctx, s.Timeouts.Query)
+	rows, err := stream.query(s.DB, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute range query on table %s, %w", tableName, err)
+	}
+	return newSQLiteRowIterator(stream, rows, tableName, batchSize)
+}
+
+// discovering the tables of the main database, or of the filter's attached databases, from pragma_table_list
+func (s *SQLiteClient) DiscoverTables(filter TableFilter) ([]string, error) {
+	placeholder := func(i int) string { return "?" }
+	condition, args := schemaCondition("schema", "'main'", filter.Schemas, placeholder)
+	query := fmt.Sprintf(`SELECT schema, name, schema = 'main' FROM pragma_table_list
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%%' AND %s ORDER BY schema, name`, condition)
+	return discoverSQLTables(s.DB, query, args, filter)
+}
+
+// fecthes data from mulitple tables using workerpool
+func (s *SQLiteClient) FetchAllDataConcurrently(tables []string, numWorkers int) ([]map[string]interface{}, error) {
+	if numWorkers <= 0 {
+		numWorkers = 4 //Default number of workers
+	}
+	return ProcessTablesWithWorkerPool(s, tables, numWorkers)
+}
+
+func (s *SQLiteClient) ImportData(data []map[string]interface{}) error {
+	return s.ImportDataContext(context.Background(), data)
+}
+
+// importing rows with one transaction per table, rolled back when ctx is cancelled or the import timeout expires
+func (s *SQLiteClient) ImportDataContext(ctx context.Context, data []map[string]interface{}) error {
+	if s.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	for tableName, rows := range tableData {
+		//get column names apart from _source_table
+		first_row := rows[0]
+		columns := rowColumns(first_row)
+
+		//Begin migration
+		txCtx, cancel := operationContext(ctx, s.Timeouts.Import)
+		defer cancel()
+		tx, err := s.DB.BeginTx(txCtx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction, %w", err)
+		}
+
+		//Creating table if not present
+		createTableSQL := generateSQLiteCreateTableSQL(tableName, first_row)
+		if _, err := tx.ExecContext(txCtx, createTableSQL); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create table %s, %w", tableName, err)
+		}
+
+		//Inserting rows with multi-row statements unless row by row inserts were selected. Values are bound,
+		//the statement size only bounds the memory a statement takes
+		if s.BulkLoad {
+			err = insertMultiRows(txCtx, tx, tableName, columns, rows, defaultMaxAllowedPacket, sqliteMaxVariables)
+		} else {
+			placeholder := func(i int) string { return "?" }
+			err = insertTableRows(txCtx, tx, tableName, columns, rows, placeholder)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		//Commit transaction
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction, %w", err)
+		}
+		fmt.Printf("Successfully imported %d rows into table %s \n", len(rows), tableName)
+	}
+	return nil
+}
+
+// inserting rows or replacing the existing ones with the same key column values
+func (s *SQLiteClient) UpsertData(data []map[string]interface{}, keyColumns []string) error {
+	if s.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	placeholder := func(i int) string { return "?" }
+	for tableName, rows := range tableData {
+		createTableSQL := generateSQLiteCreateTableSQL(tableName, rows[0])
+		if err := upsertTableRows(s.DB, tableName, rows, keyColumns, createTableSQL, placeholder); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully upserted %d rows into table %s \n", len(rows), tableName)
+	}
+	return nil
+}
+
+// writing rows with the given write mode, conflicts are detected on the key columns
+func (s *SQLiteClient) WriteData(data []map[string]interface{}, mode WriteMode, keyColumns []string) error {
+	switch mode {
+	case WriteModeInsert, "":
+		return s.ImportData(data)
+	case WriteModeReplace:
+		return s.UpsertData(data, keyColumns)
+	case WriteModeUpsert, WriteModeSkipExisting:
+	default:
+		return fmt.Errorf("unsupported write mode %s", mode)
+	}
+
+	if s.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("no data to import")
+	}
+
+	tableData, err := groupRowsByTable(data)
+	if err != nil {
+		return err
+	}
+
+	placeholder := func(i int) string { return "?" }
+	for tableName, rows := range tableData {
+		createTableSQL := generateSQLiteCreateTableSQL(tableName, rows[0], keyColumns...)
+		buildInsertSQL := func(columns []string) string {
+			return sqliteConflictInsertSQL(tableName, columns, keyColumns, mode)
+		}
+		if err := writeTableRows(s.DB, s.DescribeConstraints, tableName, rows, mode, keyColumns, createTableSQL, buildInsertSQL, placeholder); err != nil {
+			return err
+		}
+		fmt.Printf("Successfully wrote %d rows into table %s in %s mode\n", len(rows), tableName, mode)
+	}
+	return nil
+}
+
+// checking the catalog for a table and counting its rows if it exists
+func (s *SQLiteClient) TableRowCount(tableName string) (bool, int64, error) {
+	query := `SELECT COUNT(*) FROM pragma_table_list WHERE schema = COALESCE(NULLIF(?1, ''), 'main') AND name = ?2`
+	return queryTableRowCount(s.DB, query, tableName)
+}
+
+// returning the primary key columns of a table from pragma_table_info
+func (s *SQLiteClient) PrimaryKeyColumns(tableName string) ([]string, error) {
+	query := `SELECT name FROM pragma_table_info(?2, COALESCE(NULLIF(?1, ''), 'main')) WHERE pk > 0 ORDER BY pk`
+	schema, name := splitQualifiedName(tableName)
+	return queryPrimaryKeyColumns(s.DB, query, schema, name)
+}
+
+// declared type of a SQLite column split into its name and optional length or precision and scale, eg. VARCHAR(255)
+var sqliteDeclaredType = regexp.MustCompile(`^\s*([^(]*?)\s*(?:\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\))?\s*$`)
+
+// SQLite type names with a type mapping, other declared types are reduced to their affinity
+var sqliteTypeNames = map[string]bool{
+	"tinyint": true, "smallint": true, "mediumint": true, "int": true, "integer": true, "bigint": true,
+	"numeric": true, "decimal": true, "real": true, "double": true, "float": true, "boolean": true,
+	"char": true, "varchar": true, "text": true, "clob": true, "json": true, "blob": true,
+	"date": true, "datetime": true, "timestamp": true, "time": true,
+}
+
+// returning the affinity SQLite gives a column of the declared type, following the rules of its documentation
+func sqliteAffinity(declaredType string) string {
+	upper := strings.ToUpper(declaredType)
+	switch {
+	case strings.Contains(upper, "INT"):
+		return "integer"
+	case strings.Contains(upper, "CHAR"), strings.Contains(upper, "CLOB"), strings.Contains(upper, "TEXT"):
+		return "text"
+	case strings.Contains(upper, "BLOB"), upper == "":
+		return "blob"
+	case strings.Contains(upper, "REAL"), strings.Contains(upper, "FLOA"), strings.Contains(upper, "DOUB"):
+		return "real"
+	default:
+		return "numeric"
+	}
+}
+
+// reading the column definitions and primary key of a table from pragma_table_info
+func (s *SQLiteClient) DescribeTable(tableName string) (*TableSchema, error) {
+	if s.DB == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	query := `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?2, COALESCE(NULLIF(?1, ''), 'main')) ORDER BY cid`
+	schemaName, name := splitQualifiedName(tableName)
+	rows, err := s.DB.Query(query, schemaName, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of table %s, %v", tableName, err)
+	}
+	defer rows.Close()
+
+	schema := &TableSchema{Name: tableName, Dialect: "sqlite"}
+	var integerKey string
+	for rows.Next() {
+		var column ColumnDefinition
+		var notNull bool
+		var keyPosition int
+		var columnDefault sql.NullString
+		if err := rows.Scan(&column.Name, &column.ColumnType, &notNull, &columnDefault, &keyPosition); err != nil {
+			return nil, fmt.Errorf("failed to scan column definition, %v", err)
+		}
+
+		column.DataType = sqliteAffinity(column.ColumnType)
+		if match := sqliteDeclaredType.FindStringSubmatch(column.ColumnType); match != nil {
+			if typeName := strings.ToLower(match[1]); sqliteTypeNames[typeName] {
+				column.DataType = typeName
+			}
+			first, _ := strconv.ParseInt(match[2], 10, 64)
+			second, _ := strconv.ParseInt(match[3], 10, 64)
+			switch column.DataType {
+			case "numeric", "decimal":
+				column.Precision, column.Scale = first, second
+			case "char", "varchar":
+				column.Length = first
+			}
+		}
+		column.Nullable = !notNull
+		//an INTEGER primary key is the alias of the rowid, assigned to new rows without a value
+		if keyPosition > 0 && strings.EqualFold(column.ColumnType, "INTEGER") {
+			integerKey = column.Name
+		}
+
+		var defaultValue *string
+		if columnDefault.Valid {
+			defaultValue = &columnDefault.String
+		}
+		column.Default = normalizeSQLiteDefault(defaultValue, column.Name)
+		schema.Columns = append(schema.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during the row iteration,%v", err)
+	}
+	if len(schema.Columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+
+	if schema.PrimaryKey, err = s.PrimaryKeyColumns(tableName); err != nil {
+		return nil, err
+	}
+	if integerKey != "" && len(schema.PrimaryKey) == 1 {
+		for i := range schema.Columns {
+			if schema.Columns[i].Name == integerKey {
+				schema.Columns[i].AutoIncrement = true
+			}
+		}
+	}
+	return schema, nil
+}
+
+// creating a table from a schema translated to sqlite
+func (s *SQLiteClient) CreateTable(schema *TableSchema) error {
+	if s.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	if schema.Dialect != "sqlite" {
+		return fmt.Errorf("schema of table %s is in %s, translate it to sqlite first", schema.Name, schema.Dialect)
+	}
+
+	if _, err := s.DB.Exec(sqliteCreateTableDDL(schema)); err != nil {
+		return fmt.Errorf("failed to create table %s, %v", schema.Name, err)
+	}
+	return nil
+}
+
+// reading the primary key, indexes and foreign keys of a table from the pragma functions. SQLite foreign keys
+// have no names, they are named after the table and their position
+func (s *SQLiteClient) DescribeConstraints(tableName string) (*TableConstraints, error) {
+	//indexes of UNIQUE constraints are kept, partial indexes are left out like in PostgreSQL
+	indexQuery := `SELECT il.name, il."unique", ii.name
+		FROM pragma_index_list(?2, COALESCE(NULLIF(?1, ''), 'main')) il
+		JOIN pragma_index_info(il.name, COALESCE(NULLIF(?1, ''), 'main')) ii
+		WHERE il.origin <> 'pk' AND il.partial = 0
+		ORDER BY il.name, ii.seqno`
+	//a foreign key without referenced columns references the primary key of the other table
+	foreignKeyQuery := `SELECT 'fk_' || ?2 || '_' || fk.id, fk."from", fk."table",
+		COALESCE(fk."to", (SELECT p.name FROM pragma_table_info(fk."table", COALESCE(NULLIF(?1, ''), 'main')) p WHERE p.pk = fk.seq + 1)),
+		fk.on_update, fk.on_delete
+		FROM pragma_foreign_key_list(?2, COALESCE(NULLIF(?1, ''), 'main')) fk
+		ORDER BY fk.id, fk.seq`
+	schemaName, name := splitQualifiedName(tableName)
+
+	constraints := &TableConstraints{Table: tableName}
+	var err error
+	if constraints.PrimaryKey, err = s.PrimaryKeyColumns(tableName); err != nil {
+		return nil, err
+	}
+	if constraints.Indexes, err = queryIndexes(s.DB, indexQuery, schemaName, name); err != nil {
+		return nil, err
+	}
+	if constraints.ForeignKeys, err = queryForeignKeys(s.DB, foreignKeyQuery, schemaName, name); err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// adding a primary key to a table, which SQLite only supports by rebuilding it
+func (s *SQLiteClient) AddPrimaryKey(tableName string, columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no primary key columns given for table %s", tableName)
+	}
+	return s.rebuildTable(tableName, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(columns, ", ")))
+}
+
+// creating a secondary or unique index, index names are unique per database in SQLite so they are
+// prefixed with the table name unless they already are
+func (s *SQLiteClient) AddIndex(tableName string, index IndexDefinition) error {
+	if len(index.Columns) == 0 {
+		return fmt.Errorf("index %s of table %s has no columns", index.Name, tableName)
+	}
+	_, name := splitQualifiedName(tableName)
+	indexName := index.Name
+	if !strings.HasPrefix(strings.ToLower(indexName), strings.ToLower(name)+"_") {
+		indexName = name + "_" + indexName
+	}
+	return execConstraintDDL(s.DB, createIndexDDL(tableName, indexName, index, true))
+}
+
+// adding a foreign key to a table, which SQLite only supports by rebuilding it
+func (s *SQLiteClient) AddForeignKey(tableName string, foreignKey ForeignKeyDefinition) error {
+	if err := validateForeignKey(tableName, foreignKey); err != nil {
+		return err
+	}
+	return s.rebuildTable(tableName, foreignKeyClause(foreignKey))
+}
+
+// adding a table constraint by creating a copy of the table with the constraint appended to its definition,
+// copying the rows and replacing the table with the copy. The indexes and triggers of the table are dropped
+// with it and created again. Foreign keys are not enforced by the tool's connections, so dropping a
+// referenced table keeps the rows referencing it
+func (s *SQLiteClient) rebuildTable(tableName, constraint string) error {
+	if s.DB == nil {
+		return fmt.Errorf("database connection not established")
+	}
+	_, name := splitQualifiedName(tableName)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction, %v", err)
+	}
+	defer tx.Rollback()
+
+	var createSQL string
+	if err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&createSQL); err != nil {
+		return fmt.Errorf("failed to read definition of table %s, %v", tableName, err)
+	}
+	//the column definitions end at the last parenthesis, table options like WITHOUT ROWID follow it
+	start, end := strings.Index(createSQL, "("), strings.LastIndex(createSQL, ")")
+	if start < 0 || end < start {
+		return fmt.Errorf("failed to parse definition of table %s, %s", tableName, createSQL)
+	}
+
+	rows, err := tx.Query("SELECT sql FROM sqlite_master WHERE type IN ('index', 'trigger') AND tbl_name = ? AND sql IS NOT NULL", name)
+	if err != nil {
+		return fmt.Errorf("failed to read indexes of table %s, %v", tableName, err)
+	}
+	var dependents []string
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan index definition, %v", err)
+		}
+		dependents = append(dependents, statement)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during the row iteration,%v", err)
+	}
+
+	rebuilt := name + "_rebuild"
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s %s, %s%s", rebuilt, createSQL[start:end], constraint, createSQL[end:]),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", rebuilt, name),
+		fmt.Sprintf("DROP TABLE %s", name),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", rebuilt, name),
+	}
+	for _, statement := range append(statements, dependents...) {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to rebuild table %s, %s, %v", tableName, statement, err)
+		}
+	}
+
+	//Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction, %v", err)
+	}
+	return nil
+}
+
+// deleting the rows matching the key column values, returns the number of rows deleted
+func (s *SQLiteClient) DeleteRows(tableName string, keyColumns []string, rows []map[string]interface{}) (int64, error) {
+	placeholder := func(i int) string { return "?" }
+	return deleteTableRows(s.DB, tableName, keyColumns, rows, placeholder)
+}
+
+// dropping a table
+func (s *SQLiteClient) DropTable(tableName string) error {
+	return dropSQLTable(s.DB, tableName)
+}
+
+// imports data uing batch processing
+func (s *SQLiteClient) ImportDataConcurrently(data []map[string]interface{}, batchsize int) error {
+	if batchsize <= 0 {
+		batchsize = 1000 //default size of the batch
+	}
+	//batches are written by concurrent writers taking turns on the lock of the database file
+	writer := NewBatchWriter(DefaultBatchWriters, batchsize, s.Progress)
+	return writer.Write(data, s.ImportData)
+}
+
+// Helper function
+func generateSQLiteCreateTableSQL(tableName string, sampleRow map[string]interface{}, keyColumns ...string) string {
+	columns := make([]string, 0, len(sampleRow)-1)
+	for col, val := range sampleRow {
+		if col == "_source_table" {
+			continue
+		}
+
+		//Determine the declared type based on Go type, DATETIME and BOOLEAN columns are read back as time.Time and bool
+		var dataType string
+		switch val.(type) {
+		case int, int32, int64:
+			dataType = "INTEGER"
+		case float32, float64:
+			dataType = "REAL"
+		case bool:
+			dataType = "BOOLEAN"
+		case time.Time:
+			dataType = "DATETIME"
+		case []byte:
+			dataType = "BLOB"
+		default:
+			dataType = "TEXT"
+		}
+		columns = append(columns, fmt.Sprintf("%s %s", col, dataType))
+	}
+	if len(keyColumns) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keyColumns, ", ")))
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);",
+		tableName, strings.Join(columns, ", "))
+}
@@ -0,0 +1,357 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
+)
+
+// connecting a client to a new database file in the test's temp dir
+func newTestSQLiteClient(t *testing.T, statements ...string) *SQLiteClient {
+	t.Helper()
+	client := NewSQLiteClient(filepath.Join(t.TempDir(), "test.db"))
+	if err := client.Connect(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	for _, statement := range statements {
+		if _, err := client.DB.Exec(statement); err != nil {
+			t.Fatalf("failed to execute %s, %v", statement, err)
+		}
+	}
+	return client
+}
+
+func TestSQLiteImportAndReadBack(t *testing.T) {
+	created := time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC)
+	rows := []map[string]interface{}{
+		{"_source_table": "users", "id": int64(1), "name": "Susheel", "score": 9.5, "active": true, "created": created, "avatar": []byte{1, 2}},
+		{"_source_table": "users", "id": int64(2), "name": "Alex", "score": 7.25, "active": false, "created": created, "avatar": nil},
+	}
+
+	for _, bulkLoad := range []bool{true, false} {
+		client := newTestSQLiteClient(t)
+		client.SetBulkLoad(bulkLoad)
+		if err := client.ImportData(rows); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		data, err := client.FetchAllData([]string{"users"})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(data) != 2 {
+			t.Fatalf("expected 2 rows, got %d", len(data))
+		}
+		first := data[0]
+		if first["_source_table"] != "users" || first["id"] != int64(1) || first["name"] != "Susheel" || first["score"] != 9.5 {
+			t.Errorf("unexpected row %v", first)
+		}
+		if first["active"] != true || !created.Equal(first["created"].(time.Time)) || first["avatar"] != "\x01\x02" {
+			t.Errorf("expected typed values back, got %v", first)
+		}
+
+		//streamed rows are read by the declared types as well
+		iterator, err := client.StreamTable(context.Background(), "users", 10)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !iterator.Next() || iterator.Batch()[1]["active"] != false || iterator.Batch()[1]["avatar"] != nil {
+			t.Errorf("expected typed values in the stream, got %v, %v", iterator.Batch(), iterator.Err())
+		}
+		iterator.Close()
+	}
+}
+
+func TestSQLiteImportConcurrentlyReportsProgress(t *testing.T) {
+	rows := make([]map[string]interface{}, 25)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"_source_table": "events", "id": int64(i + 1)}
+	}
+
+	client := newTestSQLiteClient(t)
+	tracker := monitoring.NewProgressTracker(int64(len(rows)), 1)
+	client.SetProgressTracker(tracker)
+	if err := client.ImportDataConcurrently(rows, 10); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if processed := tracker.GetMetrics().ProcessedRows; processed != int64(len(rows)) {
+		t.Errorf("Expected the tracker to report %d rows, got %d", len(rows), processed)
+	}
+}
+
+func TestSQLiteDiscoverAndDescribeTable(t *testing.T) {
+	client := newTestSQLiteClient(t,
+		`CREATE TABLE customers (id INTEGER PRIMARY KEY, email VARCHAR(120) NOT NULL, balance DECIMAL(12,4) DEFAULT 0,
+			status TEXT DEFAULT 'active', created_at DATETIME DEFAULT CURRENT_TIMESTAMP, note STRING, payload)`,
+		`CREATE TABLE orders_archive (id INTEGER)`,
+		`CREATE TABLE order_lines (order_id INT NOT NULL, line INT NOT NULL, PRIMARY KEY (order_id, line))`,
+	)
+
+	tables, err := client.DiscoverTables(TableFilter{Exclude: []string{"*_archive"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(tables) != 2 || tables[0] != "customers" || tables[1] != "order_lines" {
+		t.Errorf("unexpected tables %v", tables)
+	}
+
+	schema, err := client.DescribeTable("customers")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(schema.PrimaryKey) != 1 || schema.PrimaryKey[0] != "id" || !schema.Columns[0].AutoIncrement {
+		t.Errorf("expected the rowid alias as auto increment key, got %+v", schema)
+	}
+	email, balance := schema.Columns[1], schema.Columns[2]
+	if email.DataType != "varchar" || email.Length != 120 || email.Nullable {
+		t.Errorf("unexpected email column %+v", email)
+	}
+	if balance.DataType != "decimal" || balance.Precision != 12 || balance.Scale != 4 || *balance.Default != "0" {
+		t.Errorf("unexpected balance column %+v", balance)
+	}
+	if *schema.Columns[3].Default != "'active'" || *schema.Columns[4].Default != "CURRENT_TIMESTAMP" {
+		t.Errorf("expected portable defaults, got %v and %v", *schema.Columns[3].Default, *schema.Columns[4].Default)
+	}
+	//declared types without a mapping are reduced to their affinity
+	if schema.Columns[5].DataType != "numeric" || schema.Columns[6].DataType != "blob" {
+		t.Errorf("expected affinities, got %s and %s", schema.Columns[5].DataType, schema.Columns[6].DataType)
+	}
+
+	mapper, err := NewTypeMapper("sqlite", "postgresql", nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	translated, err := TranslateSchema(schema, mapper)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := "CREATE TABLE IF NOT EXISTS customers (" +
+		"id BIGINT GENERATED BY DEFAULT AS IDENTITY NOT NULL, " +
+		"email TEXT NOT NULL, " +
+		"balance NUMERIC(12,4) DEFAULT 0, " +
+		"status TEXT DEFAULT 'active', " +
+		"created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
+		"note NUMERIC, " +
+		"payload BYTEA, " +
+		"PRIMARY KEY (id));"
+	if ddl := postgresCreateTableDDL(translated); ddl != expected {
+		t.Errorf("expected DDL\n%s\ngot\n%s", expected, ddl)
+	}
+
+	lines, err := client.DescribeTable("order_lines")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(lines.PrimaryKey) != 2 || lines.Columns[0].AutoIncrement {
+		t.Errorf("expected a composite key without auto increment, got %+v", lines)
+	}
+}
+
+func TestSQLiteTableRowCount(t *testing.T) {
+	client := newTestSQLiteClient(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO users (id, name) VALUES (1, 'Susheel'), (2, 'Alex')",
+	)
+
+	if exists, count, err := client.TableRowCount("users"); err != nil || !exists || count != 2 {
+		t.Errorf("expected existing table with 2 rows, got %v %d %v", exists, count, err)
+	}
+	if exists, count, err := client.TableRowCount("main.users"); err != nil || !exists || count != 2 {
+		t.Errorf("expected main.users to be the same table, got %v %d %v", exists, count, err)
+	}
+	if exists, _, err := client.TableRowCount("orders"); err != nil || exists {
+		t.Errorf("expected missing table without error, got %v %v", exists, err)
+	}
+}
+
+func TestSQLiteCreateTableFromMySQLSchema(t *testing.T) {
+	active := "TRUE"
+	schema := &TableSchema{
+		Name:    "users",
+		Dialect: "mysql",
+		Columns: []ColumnDefinition{
+			{Name: "id", DataType: "bigint", AutoIncrement: true},
+			{Name: "email", DataType: "varchar", Length: 120},
+			{Name: "active", DataType: "boolean", Nullable: true, Default: &active},
+			{Name: "profile", DataType: "json", Nullable: true},
+		},
+		PrimaryKey: []string{"id"},
+	}
+	mapper, err := NewTypeMapper("mysql", "sqlite", nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	translated, err := TranslateSchema(schema, mapper)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := "CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY, email VARCHAR(120) NOT NULL, active BOOLEAN DEFAULT TRUE, profile TEXT);"
+	if ddl := sqliteCreateTableDDL(translated); ddl != expected {
+		t.Errorf("expected DDL\n%s\ngot\n%s", expected, ddl)
+	}
+
+	client := newTestSQLiteClient(t)
+	if err := client.CreateTable(translated); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	//the key is assigned like an AUTO_INCREMENT column
+	if _, err := client.DB.Exec("INSERT INTO users (email) VALUES ('a@example.com')"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	described, err := client.DescribeTable("users")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !described.Columns[0].AutoIncrement || *described.Columns[2].Default != "TRUE" {
+		t.Errorf("unexpected schema read back %+v", described)
+	}
+	if err := client.CreateTable(schema); err == nil {
+		t.Error("expected an error for an untranslated schema")
+	}
+}
+
+func TestSQLiteConstraints(t *testing.T) {
+	client := newTestSQLiteClient(t,
+		`CREATE TABLE customers (id INTEGER, email TEXT)`,
+		`CREATE TABLE orders (id INTEGER, customer_id INTEGER, total REAL)`,
+		`CREATE INDEX orders_total ON orders (total)`,
+		`INSERT INTO customers VALUES (1, 'a@example.com'), (2, 'b@example.com')`,
+		`INSERT INTO orders VALUES (10, 1, 9.5), (11, 2, 7.25)`,
+	)
+
+	if err := client.AddPrimaryKey("customers", []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.AddIndex("customers", IndexDefinition{Name: "email_unique", Columns: []string{"email"}, Unique: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.AddPrimaryKey("orders", []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	foreignKey := ForeignKeyDefinition{Name: "fk_customer", Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}, OnDelete: "CASCADE"}
+	if err := client.AddForeignKey("orders", foreignKey); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	constraints, err := client.DescribeConstraints("orders")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(constraints.PrimaryKey) != 1 || constraints.PrimaryKey[0] != "id" {
+		t.Errorf("unexpected primary key %v", constraints.PrimaryKey)
+	}
+	//the index of the table survives both rebuilds
+	if !constraints.HasIndex(IndexDefinition{Columns: []string{"total"}}) || len(constraints.Indexes) != 1 {
+		t.Errorf("unexpected indexes %+v", constraints.Indexes)
+	}
+	if !constraints.HasForeignKey(foreignKey) || constraints.ForeignKeys[0].OnDelete != "CASCADE" || constraints.ForeignKeys[0].Name != "fk_orders_0" {
+		t.Errorf("unexpected foreign keys %+v", constraints.ForeignKeys)
+	}
+
+	customers, err := client.DescribeConstraints("customers")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(customers.Indexes) != 1 || customers.Indexes[0].Name != "customers_email_unique" || !customers.Indexes[0].Unique {
+		t.Errorf("unexpected indexes %+v", customers.Indexes)
+	}
+
+	data, err := client.FetchAllData([]string{"orders"})
+	if err != nil || len(data) != 2 {
+		t.Fatalf("expected the rows to be kept by the rebuild, got %v, %v", data, err)
+	}
+	//duplicate keys leave the table as it was
+	if _, err := client.DB.Exec("INSERT INTO customers (email) VALUES ('c@example.com')"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.AddPrimaryKey("customers", []string{"email"}); err == nil {
+		t.Error("expected an error for a table that already has a primary key")
+	}
+}
+
+func TestSQLiteWriteModesAndRanges(t *testing.T) {
+	client := newTestSQLiteClient(t)
+	rows := func(names ...string) []map[string]interface{} {
+		var data []map[string]interface{}
+		for i, name := range names {
+			data = append(data, map[string]interface{}{"_source_table": "users", "id": int64(i + 1), "name": name})
+		}
+		return data
+	}
+
+	if err := client.WriteData(rows("Susheel", "Alex"), WriteModeUpsert, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.WriteData(rows("Sathyaraj", "Bo", "Dee"), WriteModeSkipExisting, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := client.WriteData(rows("Sathyaraj"), WriteModeUpsert, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	client.Filters = RowFilters{"users": "name <> 'Alex'"}
+	ranges, err := client.SplitTable("users", 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(ranges) != 2 || ranges[0].Column != "id" || ranges[1].Start != 3 || !ranges[1].Last {
+		t.Fatalf("unexpected ranges %+v", ranges)
+	}
+
+	var names []string
+	for _, keyRange := range ranges {
+		iterator, err := client.StreamTableRange(context.Background(), "users", keyRange, nil, 10)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		for iterator.Next() {
+			for _, row := range iterator.Batch() {
+				names = append(names, row["name"].(string))
+			}
+		}
+		if err := iterator.Err(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		iterator.Close()
+	}
+	if len(names) != 2 || names[0] != "Sathyaraj" || names[1] != "Dee" {
+		t.Errorf("expected the updated and skipped rows without the filtered one, got %v", names)
+	}
+
+	deleted, err := client.DeleteRows("users", []string{"id"}, rows("Sathyaraj", "Alex"))
+	if err != nil || deleted != 2 {
+		t.Errorf("expected 2 deleted rows, got %d, %v", deleted, err)
+	}
+}
+
+func TestSQLiteStreamTableStopsWhenCancelled(t *testing.T) {
+	client := newTestSQLiteClient(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO users VALUES (1, 'Susheel'), (2, 'Alex'), (3, 'Bo')`,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iterator, err := client.StreamTable(ctx, "users", 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+	if !iterator.Next() {
+		t.Fatalf("expected a first batch, got %v", iterator.Err())
+	}
+
+	cancel()
+	if iterator.Next() {
+		t.Error("expected the cancelled stream to stop")
+	}
+	if iterator.Err() == nil {
+		t.Error("expected the cancellation to be reported")
+	}
+}
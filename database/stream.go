@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// default number of rows handed out per batch when streaming a table
+const DefaultStreamBatchSize = 1000
+
+// iterating over a single table in batches, so callers never hold the whole table in memory
+type RowIterator interface {
+	//advancing to the next batch, returns false once the table is exhausted or an error occurred
+	Next() bool
+	//returning the current batch, every row carries _source_table
+	Batch() []map[string]interface{}
+	//returning the error that stopped the iteration, if any
+	Err() error
+	//releasing the underlying cursor
+	Close() error
+}
+
+// opening a row iterator for the table, falling back to FetchAllData for clients that cannot stream.
+// Cancelling ctx stops the read of a streaming client
+func OpenRowIterator(ctx context.Context, client DatabaseClient, tableName string, batchSize int) (RowIterator, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	if streamer, ok := client.(StreamingClient); ok {
+		return streamer.StreamTable(ctx, tableName, batchSize)
+	}
+
+	data, err := client.FetchAllData([]string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	return NewSliceRowIterator(data, batchSize), nil
+}
+
+// opening a row iterator over rows whose watermark column is greater than the given watermark,
+// a nil watermark returns the whole table. Clients without pushdown are filtered in memory
+func OpenIncrementalRowIterator(ctx context.Context, client DatabaseClient, tableName, column string, watermark interface{}, batchSize int) (RowIterator, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	if incremental, ok := client.(IncrementalClient); ok {
+		return incremental.StreamTableSince(ctx, tableName, column, watermark, batchSize)
+	}
+
+	iterator, err := OpenRowIterator(ctx, client, tableName, batchSize)
+	if err != nil || watermark == nil {
+		return iterator, err
+	}
+	return &watermarkFilterIterator{inner: iterator, column: column, watermark: watermark}, nil
+}
+
+// scanning the current row of rows into a map
+type rowScanner func(rows *sql.Rows, columns []string) (map[string]interface{}, error)
+
+// row iterator backed by *sql.Rows, used by MySQL, PostgreSQL and SQLite clients
+type sqlRowIterator struct {
+	rows      *sql.Rows
+	stream    *streamContext
+	columns   []string
+	scan      rowScanner
+	tableName string
+	batchSize int
+	batch     []map[string]interface{}
+	err       error
+}
+
+// creating an iterator over a query already executed in the stream, the iteration stops once the stream is
+// done and Close releases it
+func newSQLRowIterator(stream *streamContext, rows *sql.Rows, tableName string, batchSize int) (*sqlRowIterator, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		stream.close()
+		return nil, fmt.Errorf("failed to get column names for table %s, %w", tableName, err)
+	}
+
+	return &sqlRowIterator{
+		rows:      rows,
+		stream:    stream,
+		columns:   columns,
+		scan:      scanRowMap,
+		tableName: tableName,
+		batchSize: batchSize,
+	}, nil
+}
+
+func (it *sqlRowIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	//the query timeout bounds reading a single batch, the stream lives as long as the caller's context
+	done := it.stream.read()
+	defer done()
+
+	it.batch = make([]map[string]interface{}, 0, it.batchSize)
+	for len(it.batch) < it.batchSize {
+		//not every driver aborts rows.Next when the context is done
+		if err := it.stream.Err(); err != nil {
+			it.err = fmt.Errorf("failed to read table %s, %w", it.tableName, it.stream.failed(err))
+			return false
+		}
+		if !it.rows.Next() {
+			break
+		}
+		rowMap, err := it.scan(it.rows, it.columns)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		rowMap["_source_table"] = it.tableName
+		it.batch = append(it.batch, rowMap)
+	}
+
+	//check for errors after iterating through rows
+	if err := it.rows.Err(); err != nil {
+		it.err = fmt.Errorf("error during row iteration on table %s, %w", it.tableName, it.stream.failed(err))
+		return false
+	}
+	return len(it.batch) > 0
+}
+
+func (it *sqlRowIterator) Batch() []map[string]interface{} {
+	return it.batch
+}
+
+func (it *sqlRowIterator) Err() error {
+	return it.err
+}
+
+func (it *sqlRowIterator) Close() error {
+	defer it.stream.close()
+	return it.rows.Close()
+}
+
+// scanning the current row into a map, converting []byte to string
+func scanRowMap(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	valuesPtr := make([]interface{}, len(columns))
+	for i := range values {
+		valuesPtr[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuesPtr...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+
+	rowMap := make(map[string]interface{}, len(columns)+1)
+	for i, colName := range columns {
+		if b, ok := values[i].([]byte); ok {
+			rowMap[colName] = string(b)
+		} else {
+			rowMap[colName] = values[i]
+		}
+	}
+	return rowMap, nil
+}
+
+// row iterator backed by a mongodb cursor
+type mongoRowIterator struct {
+	cursor         *mongo.Cursor
+	stream         *streamContext
+	collectionName string
+	batchSize      int
+	batch          []map[string]interface{}
+	err            error
+}
+
+func (it *mongoRowIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	done := it.stream.read()
+	defer done()
+
+	it.batch = make([]map[string]interface{}, 0, it.batchSize)
+	for len(it.batch) < it.batchSize && it.cursor.Next(it.stream) {
+		var document map[string]interface{}
+		if err := it.cursor.Decode(&document); err != nil {
+			it.err = fmt.Errorf("error decoding document from collection %s, %w", it.collectionName, err)
+			return false
+		}
+		it.batch = append(it.batch, mongoRow(it.collectionName, document))
+	}
+
+	if err := it.cursor.Err(); err != nil {
+		it.err = fmt.Errorf("error during cursor iteration on collection %s, %w", it.collectionName, it.stream.failed(err))
+		return false
+	}
+	return len(it.batch) > 0
+}
+
+func (it *mongoRowIterator) Batch() []map[string]interface{} {
+	return it.batch
+}
+
+func (it *mongoRowIterator) Err() error {
+	return it.err
+}
+
+func (it *mongoRowIterator) Close() error {
+	defer it.stream.close()
+	return it.cursor.Close(it.stream)
+}
+
+// row iterator over data that is already in memory
+type sliceRowIterator struct {
+	data      []map[string]interface{}
+	batchSize int
+	offset    int
+	batch     []map[string]interface{}
+}
+
+// creating an iterator handing out non-overlapping batches of an in-memory slice
+func NewSliceRowIterator(data []map[string]interface{}, batchSize int) RowIterator {
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+	return &sliceRowIterator{data: data, batchSize: batchSize}
+}
+
+func (it *sliceRowIterator) Next() bool {
+	if it.offset >= len(it.data) {
+		it.batch = nil
+		return false
+	}
+
+	end := it.offset + it.batchSize
+	if end > len(it.data) {
+		end = len(it.data)
+	}
+	it.batch = it.data[it.offset:end]
+	it.offset = end
+	return true
+}
+
+func (it *sliceRowIterator) Batch() []map[string]interface{} {
+	return it.batch
+}
+
+func (it *sliceRowIterator) Err() error {
+	return nil
+}
+
+func (it *sliceRowIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMySQLStreamTable_Batches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"id", "name"}).
+		AddRow(1, []byte("Susheel")).
+		AddRow(2, "Sathyaraj").
+		AddRow(3, "Alex").
+		AddRow(4, "Fahad").
+		AddRow(5, "Alice")
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users\\s*;?$").WillReturnRows(mockRows)
+
+	client := &MySQLClient{DB: db}
+	iterator, err := client.StreamTable(context.Background(), "users", 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+
+	var batchSizes []int
+	var total int
+	for iterator.Next() {
+		batch := iterator.Batch()
+		batchSizes = append(batchSizes, len(batch))
+		total += len(batch)
+
+		for _, row := range batch {
+			if row["_source_table"] != "users" {
+				t.Errorf("expected _source_table to be users, got %v", row["_source_table"])
+			}
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		t.Fatalf("unexpected iteration error %v", err)
+	}
+
+	if total != 5 {
+		t.Errorf("expected 5 rows, got %d", total)
+	}
+	if len(batchSizes) != 3 || batchSizes[0] != 2 || batchSizes[1] != 2 || batchSizes[2] != 1 {
+		t.Errorf("expected batches of [2 2 1], got %v", batchSizes)
+	}
+}
+
+func TestMySQLStreamTable_BytesConvertedToString(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"id", "name"}).AddRow(1, []byte("Susheel"))
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users\\s*;?$").WillReturnRows(mockRows)
+
+	client := &MySQLClient{DB: db}
+	iterator, err := client.StreamTable(context.Background(), "users", 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+
+	if !iterator.Next() {
+		t.Fatalf("expected one batch, got none, %v", iterator.Err())
+	}
+	if name, ok := iterator.Batch()[0]["name"].(string); !ok || name != "Susheel" {
+		t.Errorf("expected name to be string Susheel, got %v", iterator.Batch()[0]["name"])
+	}
+}
+
+func TestMySQLStreamTable_QueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users\\s*;?$").WillReturnError(errors.New("query failed!!"))
+
+	client := &MySQLClient{DB: db}
+	if _, err := client.StreamTable(context.Background(), "users", 10); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestMySQLStreamTable_RowError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).RowError(1, errors.New("connection lost"))
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users\\s*;?$").WillReturnRows(mockRows)
+
+	client := &MySQLClient{DB: db}
+	iterator, err := client.StreamTable(context.Background(), "users", 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.Next() {
+	}
+	if iterator.Err() == nil {
+		t.Errorf("expected iteration error, got nil")
+	}
+}
+
+func TestSliceRowIterator(t *testing.T) {
+	data := []map[string]interface{}{
+		{"id": 1}, {"id": 2}, {"id": 3},
+	}
+
+	iterator := NewSliceRowIterator(data, 2)
+	var seen []interface{}
+	for iterator.Next() {
+		for _, row := range iterator.Batch() {
+			seen = append(seen, row["id"])
+		}
+	}
+
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(seen))
+	}
+	for i, id := range seen {
+		if id != i+1 {
+			t.Errorf("expected row %d to have id %d, got %v", i, i+1, id)
+		}
+	}
+}
+
+func TestPostgreSQLStreamTableAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users ORDER BY id;$").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users WHERE id > \\$1 ORDER BY id;$").WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	client := &PostgreSQLClient{DB: db}
+	for _, lastKey := range []interface{}{nil, int64(2)} {
+		iterator, err := client.StreamTableAfter(context.Background(), "users", "id", lastKey, 10)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !iterator.Next() {
+			t.Errorf("expected rows after key %v, got %v", lastKey, iterator.Err())
+		}
+		iterator.Close()
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// deriving the context of a single database operation, limited by timeout unless it is zero
+func operationContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// returning timeout, or fallback when no timeout is configured
+func timeoutOrDefault(timeout, fallback time.Duration) time.Duration {
+	if timeout <= 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// context of a streamed read living as long as the caller's context. The query opening the stream and every
+// batch read from it are bounded by the timeout one at a time, a read taking longer stops the stream
+type streamContext struct {
+	context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+}
+
+func newStreamContext(ctx context.Context, timeout time.Duration) *streamContext {
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	return &streamContext{Context: streamCtx, cancel: cancel, timeout: timeout}
+}
+
+// starting the timeout of a single read, the returned func stops it once the read is done
+func (sc *streamContext) read() func() {
+	if sc.timeout <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(sc.timeout, func() {
+		sc.cancel(fmt.Errorf("read took longer than the query timeout of %s, %w", sc.timeout, context.DeadlineExceeded))
+	})
+	return func() { timer.Stop() }
+}
+
+// running the query opening the stream, closing the stream when it fails
+func (sc *streamContext) query(db *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	done := sc.read()
+	defer done()
+	rows, err := db.QueryContext(sc, query, args...)
+	if err != nil {
+		sc.close()
+		return nil, sc.failed(err)
+	}
+	return rows, nil
+}
+
+// returning the error of a read the timeout stopped instead of the cancellation the driver reports for it
+func (sc *streamContext) failed(err error) error {
+	if cause := context.Cause(sc.Context); cause != nil && cause != sc.Err() {
+		return cause
+	}
+	return err
+}
+
+// releasing the stream
+func (sc *streamContext) close() {
+	sc.cancel(nil)
+}
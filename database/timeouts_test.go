@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+)
+
+func TestImportDataStopsAfterImportTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	//the transaction is rolled back by database/sql once its context expires
+	mock.ExpectExec("^INSERT INTO users").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	client := &MySQLClient{DB: db, BulkLoad: true, Timeouts: config.TimeoutConfig{Import: 20 * time.Millisecond}}
+	start := time.Now()
+	if err := client.ImportData(data); err == nil {
+		t.Fatalf("Expected the import to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the import to be cancelled after its timeout, took %v", elapsed)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestFetchAllDataStopsWhenCancelled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT \\* FROM users").WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	client := &PostgreSQLClient{DB: db}
+	start := time.Now()
+	if _, err := client.FetchAllDataContext(ctx, []string{"users"}); err == nil {
+		t.Fatalf("Expected the cancelled fetch to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the query to be cancelled with its context, took %v", elapsed)
+	}
+}
+
+func TestStreamTableStopsAfterQueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("^SELECT \\* FROM users").WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	client := &PostgreSQLClient{DB: db, Timeouts: config.TimeoutConfig{Query: 20 * time.Millisecond}}
+	start := time.Now()
+	_, err = client.StreamTable(context.Background(), "users", 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the query to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the query to be cancelled after its timeout, took %v", elapsed)
+	}
+}
+
+func TestStreamTableOutlivesQueryTimeout(t *testing.T) {
+	client := newTestSQLiteClient(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO users VALUES (1, 'Susheel'), (2, 'Alex'), (3, 'Bo')`,
+	)
+	client.Timeouts.Query = 50 * time.Millisecond
+
+	//reading the whole stream takes longer than the timeout, every single batch is read in time
+	iterator, err := client.StreamTable(context.Background(), "users", 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+	rows := 0
+	for iterator.Next() {
+		rows += len(iterator.Batch())
+		time.Sleep(30 * time.Millisecond)
+	}
+	if err := iterator.Err(); err != nil || rows != 3 {
+		t.Errorf("Expected every row of the stream, got %d rows, %v", rows, err)
+	}
+}
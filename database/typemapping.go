@@ -0,0 +1,336 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Default type mappings per source_to_target pair, keyed by the lowercase source type name.
+// Target types may use the placeholders {length}, {precision} and {scale}. A second type after "|"
+// is used when the source column has none of the placeholder values, eg. an unbounded numeric.
+// Unsigned source columns are looked up as "<type> unsigned" first, their range needs a wider target type.
+// Entries can be overridden per pair with type_mappings in config.yaml.
+var DefaultTypeMappings = map[string]map[string]string{
+	"mysql_to_postgresql": {
+		"tinyint":    "SMALLINT",
+		"smallint":   "SMALLINT",
+		"mediumint":  "INTEGER",
+		"int":        "INTEGER",
+		"integer":    "INTEGER",
+		"bigint":     "BIGINT",
+		"decimal":    "NUMERIC({precision},{scale})|NUMERIC",
+		"float":      "REAL",
+		"double":     "DOUBLE PRECISION",
+		"bit":        "BIT({precision})|BIT",
+		"boolean":    "BOOLEAN",
+		"char":       "CHAR({length})|CHAR",
+		"varchar":    "VARCHAR({length})|TEXT",
+		"tinytext":   "TEXT",
+		"text":       "TEXT",
+		"mediumtext": "TEXT",
+		"longtext":   "TEXT",
+		"enum":       "TEXT",
+		"set":        "TEXT",
+		"json":       "JSONB",
+		"date":       "DATE",
+		"datetime":   "TIMESTAMP",
+		"timestamp":  "TIMESTAMP",
+		"time":       "TIME",
+		"year":       "SMALLINT",
+		"binary":     "BYTEA",
+		"varbinary":  "BYTEA",
+		"tinyblob":   "BYTEA",
+		"blob":       "BYTEA",
+		"mediumblob": "BYTEA",
+		"longblob":   "BYTEA",
+
+		//postgresql has no unsigned integers, the next wider type holds the upper half of the range
+		"smallint unsigned":  "INTEGER",
+		"mediumint unsigned": "INTEGER",
+		"int unsigned":       "BIGINT",
+		"integer unsigned":   "BIGINT",
+		"bigint unsigned":    "NUMERIC(20)",
+	},
+	"postgresql_to_mysql": {
+		"smallint":                    "SMALLINT",
+		"integer":                     "INT",
+		"bigint":                      "BIGINT",
+		"numeric":                     "DECIMAL({precision},{scale})|DECIMAL(65,30)",
+		"real":                        "FLOAT",
+		"double precision":            "DOUBLE",
+		"boolean":                     "BOOLEAN",
+		"character":                   "CHAR({length})|CHAR(1)",
+		"character varying":           "VARCHAR({length})|LONGTEXT",
+		"text":                        "LONGTEXT",
+		"json":                        "JSON",
+		"jsonb":                       "JSON",
+		"uuid":                        "CHAR(36)",
+		"date":                        "DATE",
+		"timestamp without time zone": "DATETIME(6)",
+		"timestamp with time zone":    "DATETIME(6)",
+		"time without time zone":      "TIME(6)",
+		"time with time zone":         "TIME(6)",
+		"interval":                    "VARCHAR(64)",
+		"bytea":                       "LONGBLOB",
+	},
+	"mongodb_to_mysql": {
+		"objectid":  "CHAR(24)",
+		"string":    "LONGTEXT",
+		"int":       "INT",
+		"long":      "BIGINT",
+		"double":    "DOUBLE",
+		"decimal":   "DECIMAL(65,30)",
+		"bool":      "BOOLEAN",
+		"date":      "DATETIME(3)",
+		"timestamp": "DATETIME",
+		"object":    "JSON",
+		"array":     "JSON",
+		"bindata":   "LONGBLOB",
+		"null":      "LONGTEXT",
+	},
+	"mongodb_to_postgresql": {
+		"objectid":  "CHAR(24)",
+		"string":    "TEXT",
+		"int":       "INTEGER",
+		"long":      "BIGINT",
+		"double":    "DOUBLE PRECISION",
+		"decimal":   "NUMERIC",
+		"bool":      "BOOLEAN",
+		"date":      "TIMESTAMP",
+		"timestamp": "TIMESTAMP",
+		"object":    "JSONB",
+		"array":     "JSONB",
+		"bindata":   "BYTEA",
+		"null":      "TEXT",
+	},
+	//SQLite does not enforce declared lengths, character columns are mapped to unbounded types
+	"sqlite_to_mysql": {
+		"tinyint":   "TINYINT",
+		"smallint":  "SMALLINT",
+		"mediumint": "MEDIUMINT",
+		"int":       "INT",
+		"integer":   "BIGINT",
+		"bigint":    "BIGINT",
+		"numeric":   "DECIMAL({precision},{scale})|DECIMAL(65,30)",
+		"decimal":   "DECIMAL({precision},{scale})|DECIMAL(65,30)",
+		"real":      "DOUBLE",
+		"double":    "DOUBLE",
+		"float":     "DOUBLE",
+		"boolean":   "BOOLEAN",
+		"char":      "LONGTEXT",
+		"varchar":   "LONGTEXT",
+		"text":      "LONGTEXT",
+		"clob":      "LONGTEXT",
+		"json":      "JSON",
+		"date":      "DATE",
+		"datetime":  "DATETIME(6)",
+		"timestamp": "DATETIME(6)",
+		"time":      "TIME(6)",
+		"blob":      "LONGBLOB",
+	},
+	"sqlite_to_postgresql": {
+		"tinyint":   "SMALLINT",
+		"smallint":  "SMALLINT",
+		"mediumint": "INTEGER",
+		"int":       "INTEGER",
+		"integer":   "BIGINT",
+		"bigint":    "BIGINT",
+		"numeric":   "NUMERIC({precision},{scale})|NUMERIC",
+		"decimal":   "NUMERIC({precision},{scale})|NUMERIC",
+		"real":      "DOUBLE PRECISION",
+		"double":    "DOUBLE PRECISION",
+		"float":     "DOUBLE PRECISION",
+		"boolean":   "BOOLEAN",
+		"char":      "TEXT",
+		"varchar":   "TEXT",
+		"text":      "TEXT",
+		"clob":      "TEXT",
+		"json":      "JSONB",
+		"date":      "DATE",
+		"datetime":  "TIMESTAMP",
+		"timestamp": "TIMESTAMP",
+		"time":      "TIME",
+		"blob":      "BYTEA",
+	},
+	//SQLite declared types only set the affinity of a column, they are kept close to the source for readers of the schema.
+	//JSON and similar types without a text affinity would turn numeric looking strings into numbers, they are TEXT
+	"mysql_to_sqlite": {
+		"tinyint":    "INTEGER",
+		"smallint":   "INTEGER",
+		"mediumint":  "INTEGER",
+		"int":        "INTEGER",
+		"integer":    "INTEGER",
+		"bigint":     "INTEGER",
+		"decimal":    "DECIMAL({precision},{scale})|DECIMAL",
+		"float":      "REAL",
+		"double":     "REAL",
+		"bit":        "INTEGER",
+		"boolean":    "BOOLEAN",
+		"char":       "CHAR({length})|TEXT",
+		"varchar":    "VARCHAR({length})|TEXT",
+		"tinytext":   "TEXT",
+		"text":       "TEXT",
+		"mediumtext": "TEXT",
+		"longtext":   "TEXT",
+		"enum":       "TEXT",
+		"set":        "TEXT",
+		"json":       "TEXT",
+		"date":       "DATE",
+		"datetime":   "DATETIME",
+		"timestamp":  "DATETIME",
+		"time":       "TEXT",
+		"year":       "INTEGER",
+		"binary":     "BLOB",
+		"varbinary":  "BLOB",
+		"tinyblob":   "BLOB",
+		"blob":       "BLOB",
+		"mediumblob": "BLOB",
+		"longblob":   "BLOB",
+	},
+	"postgresql_to_sqlite": {
+		"smallint":                    "INTEGER",
+		"integer":                     "INTEGER",
+		"bigint":                      "INTEGER",
+		"numeric":                     "DECIMAL({precision},{scale})|DECIMAL",
+		"real":                        "REAL",
+		"double precision":            "REAL",
+		"boolean":                     "BOOLEAN",
+		"character":                   "CHAR({length})|TEXT",
+		"character varying":           "VARCHAR({length})|TEXT",
+		"text":                        "TEXT",
+		"json":                        "TEXT",
+		"jsonb":                       "TEXT",
+		"uuid":                        "TEXT",
+		"date":                        "DATE",
+		"timestamp without time zone": "DATETIME",
+		"timestamp with time zone":    "DATETIME",
+		"time without time zone":      "TEXT",
+		"time with time zone":         "TEXT",
+		"interval":                    "TEXT",
+		"bytea":                       "BLOB",
+	},
+	"mongodb_to_sqlite": {
+		"objectid":  "TEXT",
+		"string":    "TEXT",
+		"int":       "INTEGER",
+		"long":      "INTEGER",
+		"double":    "REAL",
+		"decimal":   "TEXT",
+		"bool":      "BOOLEAN",
+		"date":      "DATETIME",
+		"timestamp": "DATETIME",
+		"object":    "TEXT",
+		"array":     "TEXT",
+		"bindata":   "BLOB",
+		"null":      "TEXT",
+	},
+	//copying between SQLite files keeps the declared types
+	"sqlite_to_sqlite": {
+		"tinyint":   "TINYINT",
+		"smallint":  "SMALLINT",
+		"mediumint": "MEDIUMINT",
+		"int":       "INT",
+		"integer":   "INTEGER",
+		"bigint":    "BIGINT",
+		"numeric":   "NUMERIC({precision},{scale})|NUMERIC",
+		"decimal":   "DECIMAL({precision},{scale})|DECIMAL",
+		"real":      "REAL",
+		"double":    "DOUBLE",
+		"float":     "FLOAT",
+		"boolean":   "BOOLEAN",
+		"char":      "CHAR({length})|CHAR",
+		"varchar":   "VARCHAR({length})|VARCHAR",
+		"text":      "TEXT",
+		"clob":      "CLOB",
+		"json":      "JSON",
+		"date":      "DATE",
+		"datetime":  "DATETIME",
+		"timestamp": "TIMESTAMP",
+		"time":      "TIME",
+		"blob":      "BLOB",
+	},
+	//MongoDB collections have no column types, documents keep the values as they are
+	"mysql_to_mongodb":      {},
+	"postgresql_to_mongodb": {},
+	"sqlite_to_mongodb":     {},
+}
+
+// target types used for source types without a mapping
+var fallbackTargetTypes = map[string]string{
+	"mysql":      "LONGTEXT",
+	"postgresql": "TEXT",
+	"mongodb":    "",
+	"sqlite":     "TEXT",
+}
+
+// type for mapping source column types to target column types
+type TypeMapper struct {
+	source string
+	target string
+	rules  map[string]string
+}
+
+// creating a type mapper for a source/target pair with optional overrides of the default mappings
+func NewTypeMapper(source, target string, overrides map[string]string) (*TypeMapper, error) {
+	source, target = strings.ToLower(source), strings.ToLower(target)
+	defaults, ok := DefaultTypeMappings[source+"_to_"+target]
+	if !ok {
+		return nil, fmt.Errorf("no type mapping from %s to %s", source, target)
+	}
+
+	rules := make(map[string]string, len(defaults)+len(overrides))
+	for sourceType, targetType := range defaults {
+		rules[sourceType] = targetType
+	}
+	for sourceType, targetType := range overrides {
+		rules[strings.ToLower(strings.TrimSpace(sourceType))] = targetType
+	}
+	return &TypeMapper{source: source, target: target, rules: rules}, nil
+}
+
+// returning the target type of a source column
+func (tm *TypeMapper) MapColumn(column ColumnDefinition) string {
+	//documents are stored schemaless, the source type is only kept for reference
+	if tm.target == "mongodb" {
+		return column.DataType
+	}
+
+	dataType := strings.ToLower(column.DataType)
+	rule, ok := tm.rules[dataType+" unsigned"]
+	if !ok || !strings.Contains(strings.ToLower(column.ColumnType), "unsigned") {
+		rule, ok = tm.rules[dataType]
+	}
+	if !ok {
+		fallback := fallbackTargetTypes[tm.target]
+		log.Printf("Warning: no %s type mapping for %s type %s of column %s, using %s", tm.target, tm.source, column.DataType, column.Name, fallback)
+		return fallback
+	}
+
+	bounded, unbounded, hasUnbounded := strings.Cut(rule, "|")
+	values := map[string]int64{
+		"{length}":    column.Length,
+		"{precision}": column.Precision,
+		"{scale}":     column.Scale,
+	}
+
+	//using the unbounded type when none of the placeholders has a value
+	usesPlaceholder, hasValue := false, false
+	for placeholder, value := range values {
+		if strings.Contains(bounded, placeholder) {
+			usesPlaceholder = true
+			if value > 0 {
+				hasValue = true
+			}
+		}
+	}
+	if usesPlaceholder && !hasValue && hasUnbounded {
+		return unbounded
+	}
+
+	for placeholder, value := range values {
+		bounded = strings.ReplaceAll(bounded, placeholder, strconv.FormatInt(value, 10))
+	}
+	return bounded
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// grouping rows by their _source_table
+func groupRowsByTable(data []map[string]interface{}) (map[string][]map[string]interface{}, error) {
+	tableData := make(map[string][]map[string]interface{})
+	for _, row := range data {
+		tableName, ok := row["_source_table"].(string)
+		if !ok {
+			return nil, fmt.Errorf("row missing source table information")
+		}
+		tableData[tableName] = append(tableData[tableName], row)
+	}
+	return tableData, nil
+}
+
+// returning the sorted column names of a row apart from _source_table
+func rowColumns(row map[string]interface{}) []string {
+	columns := make([]string, 0, len(row))
+	for col := range row {
+		if col != "_source_table" {
+			columns = append(columns, col)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// sanitizing the names of columns written into a statement
+func sanitizeIdentifiers(identifiers []string) []string {
+	sanitized := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		sanitized[i] = sanitizeIdentifier(identifier)
+	}
+	return sanitized
+}
+
+// upserting rows of one table by deleting any row with the same key before inserting it.
+// Works on target tables without a primary key or unique constraint, e.g. ones created by a full migration
+func upsertTableRows(db *sql.DB, tableName string, rows []map[string]interface{}, keyColumns []string, createTableSQL string, placeholder func(i int) string) error {
+	if len(keyColumns) == 0 {
+		return fmt.Errorf("no key columns given for upserting into table %s", tableName)
+	}
+
+	columns := rowColumns(rows[0])
+	for _, key := range keyColumns {
+		if _, ok := rows[0][key]; !ok {
+			return fmt.Errorf("key column %s not present in rows of table %s", key, tableName)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction, %w", err)
+	}
+
+	//Creating table if not present
+	if _, err := tx.Exec(createTableSQL); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create table %s, %w", tableName, err)
+	}
+
+	//Preparing delete statement on the key columns
+	conditions := make([]string, len(keyColumns))
+	for i, key := range keyColumns {
+		conditions[i] = fmt.Sprintf("%s = %s", sanitizeIdentifier(key), placeholder(i+1))
+	}
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s", sanitizeIdentifier(tableName), strings.Join(conditions, " AND "))
+	deleteStmt, err := tx.Prepare(deleteSQL)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare delete statement, %w", err)
+	}
+	defer deleteStmt.Close()
+
+	//Preparing insert statement
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = placeholder(i + 1)
+	}
+	insertSQL := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES(%s)",
+		sanitizeIdentifier(tableName),
+		strings.Join(sanitizeIdentifiers(columns), ", "),
+		strings.Join(placeholders, ", "),
+	)
+	insertStmt, err := tx.Prepare(insertSQL)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert statement, %w", err)
+	}
+	defer insertStmt.Close()
+
+	for _, row := range rows {
+		keyValues := make([]interface{}, len(keyColumns))
+		for i, key := range keyColumns {
+			if row[key] == nil {
+				tx.Rollback()
+				return fmt.Errorf("row in table %s has no value for key column %s", tableName, key)
+			}
+			keyValues[i] = row[key]
+		}
+		if _, err := deleteStmt.Exec(keyValues...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to delete existing row, %w", err)
+		}
+
+		values := make([]interface{}, len(columns))
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		if _, err := insertStmt.Exec(values...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert row, %w", err)
+		}
+	}
+
+	//Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction, %w", err)
+	}
+	return nil
+}
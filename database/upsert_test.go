@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPostgreSQLUpsertData(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+		{"id": 2, "name": "Sathyaraj", "_source_table": "users"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	deleteStmt := mock.ExpectPrepare("(?i)^DELETE FROM users WHERE id = \\$1$")
+	insertStmt := mock.ExpectPrepare("(?i)^INSERT INTO users \\(id, name\\) VALUES\\(\\$1, \\$2\\)$")
+	deleteStmt.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	insertStmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(1, 1))
+	deleteStmt.ExpectExec().WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	insertStmt.ExpectExec().WithArgs(2, "Sathyaraj").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	client := &PostgreSQLClient{DB: db}
+	if err := client.UpsertData(data, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestUpsertDataMissingKeyColumn(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"name": "Susheel", "_source_table": "users"},
+	}
+
+	client := &MySQLClient{DB: db}
+	if err := client.UpsertData(data, []string{"id"}); err == nil {
+		t.Errorf("expected error for missing key column, got nil")
+	}
+}
+
+func TestUpsertDataSanitizesIdentifiers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"i'd": 1, "na'me": "Susheel", "_source_table": "us'ers"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS").WillReturnResult(sqlmock.NewResult(0, 0))
+	deleteStmt := mock.ExpectPrepare("(?i)^DELETE FROM users WHERE id = \\?$")
+	insertStmt := mock.ExpectPrepare("(?i)^INSERT INTO users \\(id, name\\) VALUES\\(\\?, \\?\\)$")
+	deleteStmt.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	insertStmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	client := &MySQLClient{DB: db}
+	if err := client.UpsertData(data, []string{"i'd"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLStreamTableSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	mockRows := sqlmock.NewRows([]string{"id", "updated_at"}).AddRow(4, 50)
+	mock.ExpectQuery("(?i)^SELECT \\* FROM users WHERE updated_at >= \\? ORDER BY updated_at;$").WithArgs(30).WillReturnRows(mockRows)
+
+	client := &MySQLClient{DB: db}
+	iterator, err := client.StreamTableSince(context.Background(), "users", "updated_at", 30, 10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer iterator.Close()
+
+	if !iterator.Next() || len(iterator.Batch()) != 1 {
+		t.Errorf("expected a single changed row, got %v", iterator.Batch())
+	}
+}
+
+func TestCompareWatermarks(t *testing.T) {
+	testCases := []struct {
+		a, b     interface{}
+		expected int
+		hasError bool
+	}{
+		{int32(1), int64(2), -1, false},
+		{int64(5), 4.5, 1, false},
+		{"2025-01-02", "2025-01-01", 1, false},
+		{[]byte("a"), "a", 0, false},
+		{"a", 1, 0, true},
+		{uint64(math.MaxInt64), int64(math.MaxInt64), 0, false},
+		{uint64(math.MaxInt64) + 1, int64(1), 0, true},
+		{primitive.NewObjectIDFromTimestamp(time.Unix(2000, 0)), primitive.NewObjectIDFromTimestamp(time.Unix(1000, 0)), 1, false},
+		{primitive.ObjectID{1}, primitive.ObjectID{1}, 0, false},
+	}
+
+	for i, tc := range testCases {
+		result, err := CompareWatermarks(tc.a, tc.b)
+		if (err != nil) != tc.hasError {
+			t.Errorf("Test case %d: expected error %v, got %v", i+1, tc.hasError, err)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("Test case %d: CompareWatermarks(%v,%v)=%d, expected %d", i+1, tc.a, tc.b, result, tc.expected)
+		}
+	}
+}
+
+func TestWatermarkFilterKeepsRowsAtTheWatermark(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "updated_at": 20},
+		{"id": 2, "updated_at": 30},
+		{"id": 3, "updated_at": 40},
+	}
+	iterator := &watermarkFilterIterator{inner: NewSliceRowIterator(rows, 10), column: "updated_at", watermark: int64(30)}
+	defer iterator.Close()
+
+	//rows committed after the previous run with the watermark value itself must be read again
+	if !iterator.Next() || len(iterator.Batch()) != 2 || iterator.Batch()[0]["id"] != 2 {
+		t.Errorf("expected the rows at and past the watermark, got %v", iterator.Batch())
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// normalising watermark values coming from different drivers so they can be compared and persisted,
+// unsigned values above the int64 range are rejected instead of wrapping around to negative ones
+func NormalizeWatermark(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint:
+		return normalizeUnsignedWatermark(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return normalizeUnsignedWatermark(v)
+	case float32:
+		return float64(v), nil
+	case []byte:
+		return string(v), nil
+	case primitive.DateTime:
+		return v.Time().UTC(), nil
+	case time.Time:
+		return v.UTC(), nil
+	default:
+		return v, nil
+	}
+}
+
+func normalizeUnsignedWatermark(v uint64) (interface{}, error) {
+	if v > math.MaxInt64 {
+		return nil, fmt.Errorf("watermark value %d is out of the int64 range", v)
+	}
+	return int64(v), nil
+}
+
+// comparing two watermark values, returns -1, 0 or 1 like strings.Compare
+func CompareWatermarks(a, b interface{}) (int, error) {
+	a, err := NormalizeWatermark(a)
+	if err != nil {
+		return 0, err
+	}
+	b, err = NormalizeWatermark(b)
+	if err != nil {
+		return 0, err
+	}
+
+	switch av := a.(type) {
+	case int64:
+		switch bv := b.(type) {
+		case int64:
+			return compareOrdered(av, bv), nil
+		case float64:
+			return compareOrdered(float64(av), bv), nil
+		}
+	case float64:
+		switch bv := b.(type) {
+		case float64:
+			return compareOrdered(av, bv), nil
+		case int64:
+			return compareOrdered(av, float64(bv)), nil
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv), nil
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), nil
+		}
+	case primitive.ObjectID:
+		//the bytes start with the creation time, so later documents compare greater
+		if bv, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(av[:], bv[:]), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare watermark values %v (%T) and %v (%T)", a, a, b, b)
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// row iterator keeping only rows whose watermark column is at or past the given value, for clients without pushdown
+type watermarkFilterIterator struct {
+	inner     RowIterator
+	column    string
+	watermark interface{}
+	batch     []map[string]interface{}
+	err       error
+}
+
+func (it *watermarkFilterIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for it.inner.Next() {
+		it.batch = make([]map[string]interface{}, 0, len(it.inner.Batch()))
+		for _, row := range it.inner.Batch() {
+			value, ok := row[it.column]
+			if !ok || value == nil {
+				continue
+			}
+			cmp, err := CompareWatermarks(value, it.watermark)
+			if err != nil {
+				it.err = err
+				return false
+			}
+			if cmp >= 0 {
+				it.batch = append(it.batch, row)
+			}
+		}
+		if len(it.batch) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *watermarkFilterIterator) Batch() []map[string]interface{} {
+	return it.batch
+}
+
+func (it *watermarkFilterIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.inner.Err()
+}
+
+func (it *watermarkFilterIterator) Close() error {
+	return it.inner.Close()
+}
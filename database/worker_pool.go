@@ -2,7 +2,11 @@ package database
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
 )
 
 // manages the concurrent operations
@@ -135,6 +139,30 @@ func ProcessTablesWithWorkerPool(client DatabaseClient, tables []string, numWork
 	return allResults, nil
 }
 
+// default number of goroutines writing batches concurrently
+const DefaultBatchWriters = 4
+
+// bounds of a batch within the imported rows, rows[Start:End]
+type batchRange struct {
+	Number int
+	Start  int
+	End    int
+}
+
+// splitting count rows into consecutive non-overlapping batches of batchSize rows,
+// a batch size of zero or less puts all rows into one batch
+func splitBatches(count, batchSize int) []batchRange {
+	if batchSize <= 0 {
+		batchSize = count
+	}
+	var batches []batchRange
+	for start := 0; start < count; start += batchSize {
+		end := min(start+batchSize, count)
+		batches = append(batches, batchRange{Number: len(batches) + 1, Start: start, End: end})
+	}
+	return batches
+}
+
 // for batch processing of data
 type BatchProcessor struct {
 	batchSize int
@@ -145,23 +173,151 @@ func NewBatchProcessor(batchsize int) *BatchProcessor {
 	return &BatchProcessor{batchSize: batchsize}
 }
 
-// processing data in batches
+// processing data in batches one after another
 func (bp *BatchProcessor) ProcessInBatches(data []map[string]interface{}, processFunc func([]map[string]interface{}) error) error {
-	if len(data) == 0 {
+	for _, batch := range splitBatches(len(data), bp.batchSize) {
+		if err := processFunc(data[batch.Start:batch.End]); err != nil {
+			return fmt.Errorf("failed to process the batch %d-%d, %v", batch.Start, batch.End, err)
+		}
+
+		fmt.Printf("Processed batch %d-%d (%d rows)\n", batch.Start, batch.End, batch.End-batch.Start)
+	}
+	return nil
+}
+
+// failure of a single batch written by a BatchWriter
+type BatchFailure struct {
+	Batch int //number of the batch, starting at 1
+	Start int //index of the first row of the batch
+	End   int //index after the last row of the batch
+	Err   error
+}
+
+// error of a concurrent write, listing every failed batch and the batches not written after the first failure
+type BatchWriteError struct {
+	Failures []BatchFailure
+	Skipped  int
+}
+
+func (e *BatchWriteError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		messages[i] = fmt.Sprintf("batch %d (rows %d-%d): %v", failure.Batch, failure.Start, failure.End, failure.Err)
+	}
+	message := fmt.Sprintf("failed to write %d batches, %s", len(e.Failures), strings.Join(messages, "; "))
+	if e.Skipped > 0 {
+		message += fmt.Sprintf(", %d batches not written", e.Skipped)
+	}
+	return message
+}
+
+// error of a write that is not rolled back, the rows at the indexes of Failed were not written and every
+// other row of the batch was. MongoDB inserts fail this way
+type RowWriteError struct {
+	Failed map[int]error //error of each failed row by its index in the written rows
+}
+
+func (e *RowWriteError) Error() string {
+	indexes := make([]int, 0, len(e.Failed))
+	for index := range e.Failed {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	messages := make([]string, len(indexes))
+	for i, index := range indexes {
+		messages[i] = fmt.Sprintf("row %d: %v", index, e.Failed[index])
+	}
+	return fmt.Sprintf("failed to write %d rows, %s", len(e.Failed), strings.Join(messages, "; "))
+}
+
+// writing non-overlapping batches of rows with a bounded number of goroutines
+type BatchWriter struct {
+	numWorkers int
+	batchSize  int
+	tracker    *monitoring.ProcessTracker
+}
+
+// creating a batch writer, tracker may be nil when progress is not reported
+func NewBatchWriter(numWorkers, batchSize int, tracker *monitoring.ProcessTracker) *BatchWriter {
+	if numWorkers <= 0 {
+		numWorkers = DefaultBatchWriters
+	}
+	return &BatchWriter{numWorkers: numWorkers, batchSize: batchSize, tracker: tracker}
+}
+
+// writing data in batches with writeFunc, which must be safe for concurrent use.
+// The first batch is written alone so that the target tables it creates exist before the other batches run,
+// no further batches are started once one fails
+func (bw *BatchWriter) Write(data []map[string]interface{}, writeFunc func([]map[string]interface{}) error) error {
+	batches := splitBatches(len(data), bw.batchSize)
+	if len(batches) == 0 {
 		return nil
 	}
 
-	for i := 0; i < len(data); i += bp.batchSize {
-		end := i + bp.batchSize
-		if end > len(data) {
-			end = len(data)
+	var mu sync.Mutex
+	var failures []BatchFailure
+	failed := false
+	write := func(batch batchRange) {
+		if err := bw.writeBatch(data, batch, writeFunc); err != nil {
+			mu.Lock()
+			failures = append(failures, BatchFailure{Batch: batch.Number, Start: batch.Start, End: batch.End, Err: err})
+			failed = true
+			mu.Unlock()
 		}
-		batch := data[i:end]
-		if err := processFunc(batch); err != nil {
-			return fmt.Errorf("failed to process the batch %d-%d:%v", i, end, &err)
+	}
+
+	write(batches[0])
+	if failed {
+		return &BatchWriteError{Failures: failures, Skipped: len(batches) - 1}
+	}
+
+	jobs := make(chan batchRange)
+	var wg sync.WaitGroup
+	for i := 0; i < min(bw.numWorkers, len(batches)-1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				write(batch)
+			}
+		}()
+	}
+
+	//dispatching batches until one fails
+	skipped := 0
+	for i, batch := range batches[1:] {
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			skipped = len(batches) - 1 - i
+			break
 		}
+		jobs <- batch
+	}
+	close(jobs)
+	wg.Wait()
 
-		fmt.Printf("Processed batch %d-%d (%d rows)", i, end, len(batch))
+	if len(failures) > 0 {
+		sort.Slice(failures, func(i, j int) bool { return failures[i].Batch < failures[j].Batch })
+		return &BatchWriteError{Failures: failures, Skipped: skipped}
+	}
+	return nil
+}
+
+// writing a single batch and reporting its progress
+func (bw *BatchWriter) writeBatch(data []map[string]interface{}, batch batchRange, writeFunc func([]map[string]interface{}) error) error {
+	rows := data[batch.Start:batch.End]
+	var batchTracker *monitoring.BatchTracker
+	if bw.tracker != nil {
+		batchTracker = bw.tracker.NewBatchTracker(bw.batchSize)
+		batchTracker.StartBatch(batch.Number)
+	}
+	if err := writeFunc(rows); err != nil {
+		return err
+	}
+	if batchTracker != nil {
+		batchTracker.CompleteBatch(int64(len(rows)))
 	}
 	return nil
 }
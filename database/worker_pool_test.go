@@ -0,0 +1,125 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
+)
+
+// recording the batches written and the highest number of concurrent writes
+type recordingWriter struct {
+	mu          sync.Mutex
+	batches     [][]map[string]interface{}
+	inFlight    int
+	maxInFlight int
+	failOn      int //id of the first row of a batch that fails, 0 for none
+	delay       time.Duration
+}
+
+func (r *recordingWriter) write(batch []map[string]interface{}) error {
+	r.mu.Lock()
+	r.inFlight++
+	r.maxInFlight = max(r.maxInFlight, r.inFlight)
+	r.mu.Unlock()
+
+	time.Sleep(r.delay)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inFlight--
+	if batch[0]["id"] == r.failOn {
+		return fmt.Errorf("duplicate key %d", r.failOn)
+	}
+	r.batches = append(r.batches, batch)
+	return nil
+}
+
+func batchWriterRows(count int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, count)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": i + 1, "_source_table": "users"}
+	}
+	return rows
+}
+
+func TestSplitBatches(t *testing.T) {
+	batches := splitBatches(10, 4)
+	expected := []batchRange{{1, 0, 4}, {2, 4, 8}, {3, 8, 10}}
+	if fmt.Sprint(batches) != fmt.Sprint(expected) {
+		t.Errorf("Expected batches %v, got %v", expected, batches)
+	}
+
+	if batches := splitBatches(10, 0); len(batches) != 1 || batches[0].End != 10 {
+		t.Errorf("Expected a single batch without a batch size, got %v", batches)
+	}
+	if batches := splitBatches(0, 4); len(batches) != 0 {
+		t.Errorf("Expected no batches without rows, got %v", batches)
+	}
+}
+
+func TestBatchWriterWritesEveryRowOnceConcurrently(t *testing.T) {
+	rows := batchWriterRows(95)
+	recorder := &recordingWriter{delay: 10 * time.Millisecond}
+	tracker := monitoring.NewProgressTracker(int64(len(rows)), 1)
+
+	writer := NewBatchWriter(4, 10, tracker)
+	if err := writer.Write(rows, recorder.write); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	seen := make(map[int]int)
+	for _, batch := range recorder.batches {
+		for _, row := range batch {
+			seen[row["id"].(int)]++
+		}
+	}
+	for id := 1; id <= len(rows); id++ {
+		if seen[id] != 1 {
+			t.Errorf("Expected row %d to be written once, written %d times", id, seen[id])
+		}
+	}
+	if len(recorder.batches) != 10 {
+		t.Errorf("Expected 10 batches, got %d", len(recorder.batches))
+	}
+	if recorder.maxInFlight < 2 || recorder.maxInFlight > 4 {
+		t.Errorf("Expected between 2 and 4 concurrent writes, got %d", recorder.maxInFlight)
+	}
+	if processed := tracker.GetMetrics().ProcessedRows; processed != int64(len(rows)) {
+		t.Errorf("Expected the tracker to report %d rows, got %d", len(rows), processed)
+	}
+}
+
+func TestBatchWriterReportsFailedBatches(t *testing.T) {
+	rows := batchWriterRows(50)
+	recorder := &recordingWriter{failOn: 21}
+
+	err := NewBatchWriter(1, 10, nil).Write(rows, recorder.write)
+	var writeErr *BatchWriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("Expected a batch write error, got %v", err)
+	}
+	if len(writeErr.Failures) != 1 || writeErr.Failures[0].Batch != 3 || writeErr.Failures[0].Start != 20 || writeErr.Failures[0].End != 30 {
+		t.Errorf("Expected batch 3 with rows 20-30 to fail, got %+v", writeErr.Failures)
+	}
+	if len(recorder.batches)+len(writeErr.Failures)+writeErr.Skipped != 5 {
+		t.Errorf("Expected every batch to be written, failed or skipped, got %d written, %+v", len(recorder.batches), writeErr)
+	}
+}
+
+func TestBatchWriterStopsWhenFirstBatchFails(t *testing.T) {
+	rows := batchWriterRows(30)
+	recorder := &recordingWriter{failOn: 1}
+
+	err := NewBatchWriter(4, 10, nil).Write(rows, recorder.write)
+	var writeErr *BatchWriteError
+	if !errors.As(err, &writeErr) || writeErr.Skipped != 2 {
+		t.Fatalf("Expected the other 2 batches to be skipped, got %v", err)
+	}
+	if len(recorder.batches) != 0 {
+		t.Errorf("Expected no batch to be written, got %d", len(recorder.batches))
+	}
+}
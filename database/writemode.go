@@ -0,0 +1,200 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// type for how rows are written when a row with the same key already exists in the target
+type WriteMode string
+
+const (
+	WriteModeInsert       WriteMode = "insert"        //plain insert, fails or duplicates on existing keys
+	WriteModeUpsert       WriteMode = "upsert"        //inserting new rows, updating the columns of existing ones
+	WriteModeReplace      WriteMode = "replace"       //inserting new rows, replacing existing ones as a whole
+	WriteModeSkipExisting WriteMode = "skip-existing" //inserting new rows, leaving existing ones untouched
+)
+
+// parsing a write mode given on the command line, empty means insert
+func ParseWriteMode(mode string) (WriteMode, error) {
+	switch WriteMode(strings.ToLower(mode)) {
+	case "", WriteModeInsert:
+		return WriteModeInsert, nil
+	case WriteModeUpsert:
+		return WriteModeUpsert, nil
+	case WriteModeReplace:
+		return WriteModeReplace, nil
+	case WriteModeSkipExisting:
+		return WriteModeSkipExisting, nil
+	default:
+		return "", fmt.Errorf("invalid write mode %s, use insert, upsert, replace or skip-existing", mode)
+	}
+}
+
+// checking if a column is one of the given columns
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// returning the columns that are not key columns
+func nonKeyColumns(columns, keyColumns []string) []string {
+	result := make([]string, 0, len(columns))
+	for _, col := range columns {
+		if !containsColumn(keyColumns, col) {
+			result = append(result, col)
+		}
+	}
+	return result
+}
+
+// building a MySQL insert resolving duplicate keys with ON DUPLICATE KEY UPDATE
+func mysqlConflictInsertSQL(tableName string, columns, keyColumns []string, mode WriteMode) string {
+	tableName, columns, keyColumns = sanitizeIdentifier(tableName), sanitizeIdentifiers(columns), sanitizeIdentifiers(keyColumns)
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = "?"
+	}
+
+	//skipping existing rows is a no-op update of the first key column, unlike INSERT IGNORE it keeps other errors
+	updates := []string{fmt.Sprintf("%s = %s", keyColumns[0], keyColumns[0])}
+	if mode == WriteModeUpsert {
+		if updateColumns := nonKeyColumns(columns, keyColumns); len(updateColumns) > 0 {
+			updates = make([]string, len(updateColumns))
+			for i, col := range updateColumns {
+				updates[i] = fmt.Sprintf("%s = VALUES(%s)", col, col)
+			}
+		}
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES(%s) ON DUPLICATE KEY UPDATE %s",
+		tableName,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(updates, ", "),
+	)
+}
+
+// building a PostgreSQL insert resolving conflicts on the key columns with ON CONFLICT
+func postgresConflictInsertSQL(tableName string, columns, keyColumns []string, mode WriteMode) string {
+	placeholder := func(i int) string { return fmt.Sprintf("$%d", i) }
+	return onConflictInsertSQL(tableName, columns, keyColumns, mode, placeholder)
+}
+
+// building a SQLite insert resolving conflicts on the key columns with ON CONFLICT, which SQLite took over from PostgreSQL
+func sqliteConflictInsertSQL(tableName string, columns, keyColumns []string, mode WriteMode) string {
+	placeholder := func(i int) string { return "?" }
+	return onConflictInsertSQL(tableName, columns, keyColumns, mode, placeholder)
+}
+
+// building an insert with an ON CONFLICT clause on the key columns
+func onConflictInsertSQL(tableName string, columns, keyColumns []string, mode WriteMode, placeholder func(i int) string) string {
+	tableName, columns, keyColumns = sanitizeIdentifier(tableName), sanitizeIdentifiers(columns), sanitizeIdentifiers(keyColumns)
+	placeholders := make([]string, len(columns))
+	for i := range placeholders {
+		placeholders[i] = placeholder(i + 1)
+	}
+
+	action := "DO NOTHING"
+	if mode == WriteModeUpsert {
+		if updateColumns := nonKeyColumns(columns, keyColumns); len(updateColumns) > 0 {
+			updates := make([]string, len(updateColumns))
+			for i, col := range updateColumns {
+				updates[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+			}
+			action = "DO UPDATE SET " + strings.Join(updates, ", ")
+		}
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES(%s) ON CONFLICT (%s) %s",
+		tableName,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(keyColumns, ", "),
+		action,
+	)
+}
+
+// writing rows of one table in the upsert or skip-existing mode. The conflict clauses only find existing rows
+// through a primary key or unique index on the key columns, without one every row would be inserted again. The
+// table is created first, with the key columns as its primary key, then its keys are checked. Upserts into a table
+// without such a key delete and insert the rows instead, skip-existing fails as it cannot find the existing rows
+func writeTableRows(db *sql.DB, describe func(tableName string) (*TableConstraints, error), tableName string, rows []map[string]interface{}, mode WriteMode, keyColumns []string, createTableSQL string, buildInsertSQL func(columns []string) string, placeholder func(i int) string) error {
+	if len(keyColumns) == 0 {
+		return fmt.Errorf("no key columns given for writing into table %s", tableName)
+	}
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create table %s, %w", tableName, err)
+	}
+
+	constraints, err := describe(tableName)
+	if err != nil {
+		return fmt.Errorf("failed to read the keys of table %s, %w", tableName, err)
+	}
+	if !constraints.HasUniqueKey(keyColumns) {
+		if mode == WriteModeSkipExisting {
+			return fmt.Errorf("table %s has no primary key or unique index on %s to find existing rows by, add one or use the upsert or replace write mode",
+				tableName, strings.Join(keyColumns, ", "))
+		}
+		log.Printf("Warning: table %s has no primary key or unique index on %s, upserting by deleting and inserting the rows", tableName, strings.Join(keyColumns, ", "))
+		return upsertTableRows(db, tableName, rows, keyColumns, createTableSQL, placeholder)
+	}
+	return conflictInsertTableRows(db, tableName, rows, keyColumns, createTableSQL, buildInsertSQL)
+}
+
+// inserting rows of one table with a conflict resolving insert statement in a single transaction
+func conflictInsertTableRows(db *sql.DB, tableName string, rows []map[string]interface{}, keyColumns []string, createTableSQL string, buildInsertSQL func(columns []string) string) error {
+	if len(keyColumns) == 0 {
+		return fmt.Errorf("no key columns given for writing into table %s", tableName)
+	}
+
+	columns := rowColumns(rows[0])
+	for _, key := range keyColumns {
+		if _, ok := rows[0][key]; !ok {
+			return fmt.Errorf("key column %s not present in rows of table %s", key, tableName)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction, %w", err)
+	}
+
+	//Creating table if not present
+	if _, err := tx.Exec(createTableSQL); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create table %s, %w", tableName, err)
+	}
+
+	stmt, err := tx.Prepare(buildInsertSQL(columns))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement, %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		values := make([]interface{}, len(columns))
+		for i, col := range columns {
+			values[i] = row[col]
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to write row, %w", err)
+		}
+	}
+
+	//Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction, %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseWriteMode(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected WriteMode
+		hasError bool
+	}{
+		{"", WriteModeInsert, false},
+		{"insert", WriteModeInsert, false},
+		{"UPSERT", WriteModeUpsert, false},
+		{"replace", WriteModeReplace, false},
+		{"skip-existing", WriteModeSkipExisting, false},
+		{"merge", "", true},
+	}
+
+	for _, tc := range testCases {
+		mode, err := ParseWriteMode(tc.input)
+		if (err != nil) != tc.hasError {
+			t.Errorf("ParseWriteMode(%q): expected error %v, got %v", tc.input, tc.hasError, err)
+			continue
+		}
+		if mode != tc.expected {
+			t.Errorf("ParseWriteMode(%q)=%s, expected %s", tc.input, mode, tc.expected)
+		}
+	}
+}
+
+// expecting the catalog queries of DescribeConstraints, returning a primary key on the given columns
+func expectTableKeys(mock sqlmock.Sqlmock, primaryKey ...string) {
+	primaryKeyRows := sqlmock.NewRows([]string{"column_name"})
+	for _, column := range primaryKey {
+		primaryKeyRows.AddRow(column)
+	}
+	mock.ExpectQuery("(?i)PRIMARY").WillReturnRows(primaryKeyRows)
+	mock.ExpectQuery(".").WillReturnRows(sqlmock.NewRows([]string{"name", "unique", "column"}))
+	mock.ExpectQuery(".").WillReturnRows(sqlmock.NewRows([]string{"name", "column", "referenced_table", "referenced_column", "on_update", "on_delete"}))
+}
+
+func TestPostgreSQLWriteDataUpsert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+	}
+
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users \\(.*PRIMARY KEY \\(id\\)\\);$").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTableKeys(mock, "id")
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users \\(.*PRIMARY KEY \\(id\\)\\);$").WillReturnResult(sqlmock.NewResult(0, 0))
+	stmt := mock.ExpectPrepare("(?i)^INSERT INTO users \\(id, name\\) VALUES\\(\\$1, \\$2\\) ON CONFLICT \\(id\\) DO UPDATE SET name = EXCLUDED.name$")
+	stmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	client := &PostgreSQLClient{DB: db}
+	if err := client.WriteData(data, WriteModeUpsert, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLWriteDataSkipExisting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+	}
+
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTableKeys(mock, "id")
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	stmt := mock.ExpectPrepare("(?i)^INSERT INTO users \\(id, name\\) VALUES\\(\\?, \\?\\) ON DUPLICATE KEY UPDATE id = id$")
+	stmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	client := &MySQLClient{DB: db}
+	if err := client.WriteData(data, WriteModeSkipExisting, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLWriteDataUpsertWithoutUniqueKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+	}
+
+	//the existing table has no key, ON DUPLICATE KEY UPDATE would insert the row again
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTableKeys(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	deleteStmt := mock.ExpectPrepare("(?i)^DELETE FROM users WHERE id = \\?$")
+	insertStmt := mock.ExpectPrepare("(?i)^INSERT INTO users \\(id, name\\) VALUES\\(\\?, \\?\\)$")
+	deleteStmt.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	insertStmt.ExpectExec().WithArgs(1, "Susheel").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	client := &MySQLClient{DB: db}
+	if err := client.WriteData(data, WriteModeUpsert, []string{"id"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLWriteDataSkipExistingWithoutUniqueKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating mock database %v", err)
+	}
+	defer db.Close()
+
+	data := []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "_source_table": "users"},
+	}
+
+	mock.ExpectExec("(?i)^CREATE TABLE IF NOT EXISTS users").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTableKeys(mock, "id", "name")
+
+	client := &MySQLClient{DB: db}
+	err = client.WriteData(data, WriteModeSkipExisting, []string{"id"})
+	if err == nil || !strings.Contains(err.Error(), "no primary key or unique index on id") {
+		t.Fatalf("Expected skip-existing to fail without a unique key on id, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations %v", err)
+	}
+}
+
+func TestMySQLConflictInsertSQLUpsert(t *testing.T) {
+	query := mysqlConflictInsertSQL("users", []string{"email", "id", "name"}, []string{"id"}, WriteModeUpsert)
+	expected := "INSERT INTO users (email, id, name) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE email = VALUES(email), name = VALUES(name)"
+	if query != expected {
+		t.Errorf("expected %s, got %s", expected, query)
+	}
+}
+
+func TestGenerateMySQLCreateTableSQLWithStringKey(t *testing.T) {
+	query := generateMySQLCreateTableSQL("users", map[string]interface{}{"email": "a@b.c"}, "email")
+	if !strings.Contains(query, "email VARCHAR(255)") || !strings.HasSuffix(query, "PRIMARY KEY (email));") {
+		t.Errorf("expected keyed VARCHAR column and primary key, got %s", query)
+	}
+}
@@ -1,22 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/config"
 	"github.com/SusheelSathyaraj/DataMigrationTool/migration"
+	"github.com/SusheelSathyaraj/DataMigrationTool/transform"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/database"
 )
 
 // supported database formats
-var supportedDatabases = []string{"mysql", "postgresql", "mongodb"}
+var supportedDatabases = []string{"mysql", "postgresql", "mongodb", "sqlite"}
 
 //validate inputs, source, target, filetype and mode
 
@@ -40,7 +45,7 @@ func validateInput(source, target, mode string) error {
 	}
 
 	//validating migration modes
-	validmodes := []string{"full", "incremental", "scheduled"}
+	validmodes := []string{"full", "incremental", "scheduled", "cdc", "online"}
 	for _, v := range validmodes {
 		if strings.EqualFold(v, mode) {
 			return nil
@@ -63,12 +68,35 @@ func printUsage() {
 	fmt.Println("Usage Example:")
 	fmt.Println(" ./binary --source=mysql --target=postgresql --mode=full")
 	fmt.Println(" ./binary --source=mongodb --target=mysql --mode=full --workers=8 --backup")
+	fmt.Println(" ./binary --source=mysql --target=postgresql --mode=incremental --incremental-column=updated_at")
+	fmt.Println(" ./binary --source=mysql --target=postgresql --mode=full --write-mode=upsert --key-columns=id")
+	fmt.Println(" ./binary --source=postgresql --target=mysql --mode=full --schemas=public,sales --exclude-tables='*_archive'")
+	fmt.Println(" ./binary --source=mysql --target=postgresql --mode=scheduled --schedule=\"*/30 * * * *\" --schedule-mode=full")
+	fmt.Println(" ./binary --source=mysql --target=postgresql --mode=cdc --include-tables=orders,customers")
+	fmt.Println(" ./binary --source=mysql --target=postgresql --mode=online --max-lag=2s --cutover-read-only")
+	fmt.Println(" ./binary --source=sqlite --target=postgresql --mode=full")
 	fmt.Println(" make run ARGS=\"--source=mysql --target=postgresql --mode=full\"")
 	fmt.Println()
 	fmt.Println("Available Options:")
 	flag.PrintDefaults()
 }
 
+// waiting for the operator to press Enter once the applications stopped writing to the source
+func confirmCutover(ctx context.Context) error {
+	fmt.Printf("\nThe target caught up with the source. Stop the writes to the source, then press Enter to cut over\n")
+	confirmed := make(chan error, 1)
+	go func() {
+		_, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		confirmed <- err
+	}()
+	select {
+	case err := <-confirmed:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // creating appropriate database client based on type
 func createDatabaseClient(dbType string, cfg *config.Config) database.DatabaseClient {
 	switch strings.ToLower(dbType) {
@@ -78,6 +106,8 @@ func createDatabaseClient(dbType string, cfg *config.Config) database.DatabaseCl
 		return database.NewPostgreSQLClientFromConfig(cfg)
 	case "mongodb":
 		return database.NewMongoDBClientFromConfig(cfg)
+	case "sqlite":
+		return database.NewSQLiteClientFromConfig(cfg)
 	default:
 		log.Fatalf("Unsupported database type, %s", dbType)
 		return nil
@@ -87,9 +117,9 @@ func createDatabaseClient(dbType string, cfg *config.Config) database.DatabaseCl
 func main() {
 
 	//defining CLI for user input
-	sourceDB := flag.String("source", "", "Source Database type(mysql,postgresql,mongodb)")
-	targetDB := flag.String("target", "", "Target Database type (mysql,postgresql,mongodb)")
-	mode := flag.String("mode", "full", "Migration mode(full,incremental,scheduled)")
+	sourceDB := flag.String("source", "", "Source Database type(mysql,postgresql,mongodb,sqlite)")
+	targetDB := flag.String("target", "", "Target Database type (mysql,postgresql,mongodb,sqlite)")
+	mode := flag.String("mode", "full", "Migration mode(full,incremental,scheduled,cdc,online)")
 	//filetype := flag.String("filetype", "", "Format (csv,json,xml)")
 	//filetype to be added later
 	configPath := flag.String("config", "config.yaml", "Path to config file")
@@ -98,6 +128,26 @@ func main() {
 	concurrent := flag.Bool("concurrent", true, "Enable concurrent processing")
 	validate := flag.Bool("validate", true, "Enable data validation")
 	backup := flag.Bool("backup", false, "Create Backup before migration")
+	incrementalColumn := flag.String("incremental-column", "", "Watermark column for incremental mode (eg. updated_at, id)")
+	schedule := flag.String("schedule", "", "Cron expression for scheduled mode, overrides schedule.cron in config (eg. '*/30 * * * *', '@hourly')")
+	scheduleMode := flag.String("schedule-mode", "", "Migration mode run on every scheduled tick (full,incremental), overrides schedule.mode in config")
+	keyColumns := flag.String("key-columns", "", "Comma separated key columns identifying rows for upserts and write modes (default id, _id for mongodb)")
+	schemas := flag.String("schemas", "", "Comma separated source schemas to discover tables in, overrides discovery.schemas in config")
+	includeTables := flag.String("include-tables", "", "Comma separated glob patterns of tables to migrate (eg. 'orders*,customers'), overrides discovery.include in config")
+	excludeTables := flag.String("exclude-tables", "", "Comma separated glob patterns of tables to skip (eg. '*_archive'), overrides discovery.exclude in config")
+	writeMode := flag.String("write-mode", "insert", "How rows are written when the key already exists in the target (insert,upsert,replace,skip-existing)")
+	resume := flag.String("resume", "", "Run id of a failed full migration to continue from its last checkpoint")
+	chunkRows := flag.Int64("chunk-rows", 0, "Split tables holding more rows into ranges of about this many rows read concurrently, 0 disables splitting")
+	bulkLoad := flag.Bool("bulk-load", true, "Load rows with COPY into postgresql and multi-row inserts into mysql and sqlite, false inserts one row per statement")
+	deadLetterFile := flag.String("dead-letter", "", "JSONL file receiving rows that fail to import, the rest of their batch is still written")
+	deadLetterTable := flag.String("dead-letter-table", "", "Target table receiving rows that fail to import, the rest of their batch is still written")
+	errorBudget := flag.Int64("error-budget", 100, "Rows per table that may fail to import into the dead-letter queue before the migration fails")
+	retryAttempts := flag.Int("retry-attempts", 5, "Attempts of a batch read or write failing with a transient error like a deadlock or dropped connection, 1 disables retries")
+	retryBackoff := flag.Duration("retry-backoff", 200*time.Millisecond, "Wait before the first retry of a transient error, doubled for every further retry")
+	changePosition := flag.String("cdc-position", "", "Source position cdc mode starts from, overrides the saved position (eg. binlog.000042:1337 or gtid:<set> for mysql, an LSN like 16/B374D848 for postgresql, a resume token like {\"_data\":\"8263...\"} for mongodb)")
+	maxLag := flag.Duration("max-lag", 5*time.Second, "Lag of the target behind the source below which online mode starts the cutover")
+	cutoverReadOnly := flag.Bool("cutover-read-only", false, "Set the source read-only for the cutover of online mode instead of waiting for writes to be stopped by hand")
+	skipConstraints := flag.Bool("skip-constraints", false, "Skip recreating primary keys, indexes and foreign keys on the target after a full migration")
 
 	//Advanced Options
 	showVersion := flag.Bool("version", false, "Show version information")
@@ -105,6 +155,7 @@ func main() {
 	listSnapshots := flag.Bool("list-snapshots", false, "List all available rollback snapshots")
 	rollbackSnapshot := flag.String("rollback", "", "ROllback using specific snapshot ID")
 	cleanupSnapshots := flag.String("cleanup-snapshots", "", "Cleanup snapshots older than duration(eg. '30d', '1h')")
+	stateDir := flag.String("state-dir", "migration_snapshots", "Directory of the rollback snapshots, checkpoints, watermarks, change positions and schedule state")
 	dryRun := flag.Bool("dry-run", false, "Performs validation and planning without actual migration")
 
 	//custom usage function
@@ -119,7 +170,7 @@ func main() {
 	if *showVersion {
 		fmt.Println("DataMigration Tool v1.0")
 		fmt.Println("Built with Go", runtime.Version())
-		fmt.Println("Support: MySQL, PostgreSQL, MongoDB")
+		fmt.Println("Support: MySQL, PostgreSQL, MongoDB, SQLite")
 		os.Exit(0)
 	}
 
@@ -136,22 +187,47 @@ func main() {
 
 	fmt.Printf("Configuration loaded from %s \n", *configPath)
 
+	transforms, err := transform.Compile(cfg.Transforms)
+	if err != nil {
+		log.Fatalf("Invalid transforms in config, %v", err)
+	}
+	mapping, err := database.NewNameMapping(cfg.Mappings)
+	if err != nil {
+		log.Fatalf("Invalid mappings in config, %v", err)
+	}
+
 	//Handling rollback command
 	if *rollbackSnapshot != "" {
 		fmt.Printf("Initiating Rollback for Snapshot %s\n", *rollbackSnapshot)
 
+		//the snapshot knows its target, --target is only needed to override it
+		target := *targetDB
+		if target == "" {
+			snapshotEngine := migration.NewMigrationEngine(migration.MigrationConfig{}, nil, nil)
+			snapshotEngine.SetStateDir(*stateDir)
+			snapshot, err := snapshotEngine.RollBackManager.LoadSnapshot(*rollbackSnapshot)
+			snapshotEngine.Close()
+			if err != nil {
+				log.Fatalf("Rollback Failed %v", err)
+			}
+			target = snapshot.TargetDB
+		}
+
 		//creating a dummy engine for rollback
-		targetClient := createDatabaseClient(*targetDB, cfg)
+		targetClient := createDatabaseClient(target, cfg)
 		if err := targetClient.Connect(); err != nil {
 			log.Fatalf("Failed to connect to target database, %v", err)
 		}
-		defer targetClient.Close()
 
-		dummyConfig := migration.MigrationConfig{TargetDb: *targetDB}
+		dummyConfig := migration.MigrationConfig{TargetDb: target}
 		engine := migration.NewMigrationEngine(dummyConfig, nil, targetClient)
+		engine.SetStateDir(*stateDir)
 
-		if err := engine.RollBackManager.RollBackMigration(*rollbackSnapshot); err != nil {
-			log.Fatalf("Rollback Failed %v", err)
+		rollbackErr := engine.RollBackManager.RollBackMigration(*rollbackSnapshot)
+		engine.Close()
+		targetClient.Close()
+		if rollbackErr != nil {
+			log.Fatalf("Rollback Failed %v", rollbackErr)
 		}
 		fmt.Printf("Rollback completed successful for snapshot %s\n", *rollbackSnapshot)
 		os.Exit(0)
@@ -162,6 +238,7 @@ func main() {
 		//creating a dummy engine to access rollback manager
 		dummyConfig := migration.MigrationConfig{}
 		engine := migration.NewMigrationEngine(dummyConfig, nil, nil)
+		engine.SetStateDir(*stateDir)
 
 		snapshots, err := engine.RollBackManager.ListSnapshots()
 		if err != nil {
@@ -193,6 +270,7 @@ func main() {
 
 		dummyConfig := migration.MigrationConfig{}
 		engime := migration.NewMigrationEngine(dummyConfig, nil, nil)
+		engime.SetStateDir(*stateDir)
 
 		if err := engime.RollBackManager.CleanupOldSnapshots(maxAge); err != nil {
 			log.Fatalf("Cleanup failed %v", err)
@@ -208,6 +286,34 @@ func main() {
 		os.Exit(1)
 	}
 
+	parsedWriteMode, err := database.ParseWriteMode(*writeMode)
+	if err != nil {
+		fmt.Printf(" Validation Error: %v", err)
+		printUsage()
+		os.Exit(1)
+	}
+
+	if *deadLetterFile != "" && *deadLetterTable != "" {
+		fmt.Printf(" Validation Error: choose either a dead-letter file or a dead-letter table")
+		printUsage()
+		os.Exit(1)
+	}
+
+	if *retryAttempts < 1 {
+		fmt.Printf(" Validation Error: retry attempts must be at least 1")
+		printUsage()
+		os.Exit(1)
+	}
+	retryPolicy := database.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = *retryAttempts
+	retryPolicy.InitialBackoff = *retryBackoff
+
+	if *resume != "" && !strings.EqualFold(*mode, "full") {
+		fmt.Printf(" Validation Error: only full migrations can be resumed")
+		printUsage()
+		os.Exit(1)
+	}
+
 	fmt.Println("Input validated successfully")
 	fmt.Printf("Starting Migration from %s to %s in %s mode", *sourceDB, *targetDB, *mode)
 
@@ -224,11 +330,28 @@ func main() {
 	}
 	fmt.Println()
 
+	//cancelling connections, queries and the migration on Ctrl-C or SIGTERM, a second signal exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	//creating and connectinf source database client
 	fmt.Printf("Connecting to Source database %s...\n", *sourceDB)
 	sourceClient := createDatabaseClient(*sourceDB, cfg)
+	if len(cfg.Filters) > 0 {
+		filterClient, ok := sourceClient.(database.FilterClient)
+		if !ok {
+			log.Fatalf("source database %s does not support row filters", *sourceDB)
+		}
+		if err := filterClient.SetRowFilters(database.RowFilters(cfg.Filters)); err != nil {
+			log.Fatalf("Invalid filters in config, %v", err)
+		}
+	}
 
-	if err := sourceClient.Connect(); err != nil {
+	if err := connectDatabaseClient(ctx, sourceClient); err != nil {
 		log.Fatalf("Failed to connect to the source database, %v", err)
 	}
 	defer sourceClient.Close()
@@ -237,8 +360,11 @@ func main() {
 	//creating and connecting to the target database client
 	fmt.Printf("COnnecting to the  Target database %s...\n", *targetDB)
 	targetClient := createDatabaseClient(*targetDB, cfg)
+	if bulkClient, ok := targetClient.(database.BulkLoadClient); ok {
+		bulkClient.SetBulkLoad(*bulkLoad)
+	}
 
-	if err := targetClient.Connect(); err != nil {
+	if err := connectDatabaseClient(ctx, targetClient); err != nil {
 		log.Fatalf("Failed to connect to the target database, %v", err)
 	}
 	defer targetClient.Close()
@@ -246,7 +372,22 @@ func main() {
 
 	//Parsing SQL file or discovering collections for mongodb
 	fmt.Println("Discovering tables and collections...")
-	tables, err := getTablesOrCollections(*sourceDB, cfg, sourceClient)
+	tableFilter := database.TableFilter{
+		Schemas: cfg.Discovery.Schemas,
+		Include: cfg.Discovery.Include,
+		Exclude: cfg.Discovery.Exclude,
+	}
+	if *schemas != "" {
+		tableFilter.Schemas = splitList(*schemas)
+	}
+	if *includeTables != "" {
+		tableFilter.Include = splitList(*includeTables)
+	}
+	if *excludeTables != "" {
+		tableFilter.Exclude = splitList(*excludeTables)
+	}
+
+	tables, err := getTablesOrCollections(*sourceDB, cfg, sourceClient, tableFilter)
 	if err != nil {
 		log.Fatalf("could not discover tables or collections, %v", err)
 	}
@@ -260,9 +401,16 @@ func main() {
 		entityType = "collections"
 	}
 	fmt.Printf("Found %d %s, %v\n", len(tables), entityType, tables)
+	if unknown := mapping.UnknownTables(tables); len(unknown) > 0 {
+		fmt.Printf("Warning: mappings configured for %s which are not migrated\n", strings.Join(unknown, ", "))
+	}
+	if unknown := database.RowFilters(cfg.Filters).UnknownTables(tables); len(unknown) > 0 {
+		fmt.Printf("Warning: filters configured for %s which are not migrated\n", strings.Join(unknown, ", "))
+	}
 
-	//exiting early when it is dry run after discovery
+	//exiting early when it is dry run after discovery and planning
 	if *dryRun {
+		migration.BuildMigrationPlan(sourceClient, tables).Print()
 		fmt.Printf("\n Dry Run Complete \n")
 		fmt.Printf("Migrating %d %s from %s to %s \n", len(tables), entityType, *sourceDB, *targetDB)
 		fmt.Printf("Run without --dry-run to perform actual migration \n")
@@ -271,15 +419,39 @@ func main() {
 
 	//creating migration configuration
 	migrationConfig := migration.MigrationConfig{
-		Mode:         migration.MigrationMode(*mode),
-		SourceDb:     *sourceDB,
-		TargetDb:     *targetDB,
-		Tables:       tables,
-		Workers:      *workers,
-		BatchSize:    *batchsize,
-		Concurrent:   *concurrent,
-		ValidateData: *validate,
-		CreateBackup: *backup,
+		Mode:              migration.MigrationMode(strings.ToLower(*mode)),
+		SourceDb:          *sourceDB,
+		TargetDb:          *targetDB,
+		Tables:            tables,
+		Workers:           *workers,
+		BatchSize:         *batchsize,
+		Concurrent:        *concurrent,
+		ValidateData:      *validate,
+		CreateBackup:      *backup,
+		IncrementalColumn: *incrementalColumn,
+		KeyColumns:        splitList(*keyColumns),
+		WriteMode:         parsedWriteMode,
+		TypeMappings:      cfg.TypeMappings[strings.ToLower(*sourceDB)+"_to_"+strings.ToLower(*targetDB)],
+		Mapping:           mapping,
+		Transforms:        transforms,
+		SkipConstraints:   *skipConstraints,
+		ResumeRunID:       *resume,
+		ChunkRows:         *chunkRows,
+		DeadLetterFile:    *deadLetterFile,
+		DeadLetterTable:   *deadLetterTable,
+		ErrorBudget:       *errorBudget,
+		Retry:             retryPolicy,
+		ChangePosition:    *changePosition,
+		MaxLag:            *maxLag,
+		CutoverReadOnly:   *cutoverReadOnly,
+		Schedule:          cfg.Schedule.Cron,
+		ScheduledMode:     migration.MigrationMode(strings.ToLower(cfg.Schedule.Mode)),
+	}
+	if *schedule != "" {
+		migrationConfig.Schedule = *schedule
+	}
+	if *scheduleMode != "" {
+		migrationConfig.ScheduledMode = migration.MigrationMode(strings.ToLower(*scheduleMode))
 	}
 
 	//creating and executing migration
@@ -288,24 +460,75 @@ func main() {
 	fmt.Printf(strings.Repeat("=", 60) + "\n")
 
 	migrationEngine := migration.NewMigrationEngine(migrationConfig, sourceClient, targetClient)
+	migrationEngine.SetStateDir(*stateDir)
+	defer migrationEngine.Close()
+
+	//scheduled mode keeps running migrations until SIGINT or SIGTERM
+	if migrationConfig.Mode == migration.ScheduledMigration {
+		scheduler, err := migration.NewMigrationScheduler(migrationEngine, migrationConfig.Schedule)
+		if err != nil {
+			log.Fatalf("Failed to create scheduler, %v", err)
+		}
+
+		fmt.Printf("Scheduler started with schedule %q, press Ctrl-C to stop\n", migrationConfig.Schedule)
+		if err := scheduler.Run(ctx); err != nil {
+			log.Fatalf("Scheduler failed, %v", err)
+		}
+		fmt.Printf("Scheduler stopped\n")
+		return
+	}
+
+	//cdc mode keeps replicating changes until SIGINT or SIGTERM
+	if migrationConfig.Mode == migration.ChangeDataCaptureMigration {
+		fmt.Printf("Change data capture started, press Ctrl-C to stop\n")
+	}
+
+	//online mode waits at the cutover until the operator stopped the writes to the source
+	if migrationConfig.Mode == migration.OnlineMigration && !migrationConfig.CutoverReadOnly {
+		migrationEngine.ConfirmCutover = confirmCutover
+	}
 
 	startTime := time.Now()
 
-	result, err := migrationEngine.ExecuteMigration()
+	result, err := migrationEngine.ExecuteMigrationContext(ctx)
+	if err != nil && ctx.Err() != nil {
+		//an interrupted run keeps its migrated rows, it can be resumed or rolled back later
+		log.Printf("Migration interrupted, %v", err)
+		if snapshot := migrationEngine.CurrentSnapshot; snapshot != nil {
+			fmt.Printf("Rollback the migrated rows with: ./binary --rollback=%s\n", snapshot.ID)
+		}
+		if checkpoint := migrationEngine.CurrentCheckpoint; checkpoint != nil && checkpoint.Status != "completed" {
+			fmt.Printf("Continue the migration with: ./binary --source=%s --target=%s --resume=%s\n", *sourceDB, *targetDB, checkpoint.RunID)
+		}
+		migrationEngine.Close()
+		sourceClient.Close()
+		targetClient.Close()
+		os.Exit(130)
+	}
 	if err != nil {
 		log.Printf("Migration Failed, %v", err)
 		if result != nil {
 			result.Print()
 		}
 
-		//attempting rollback when failure occurs
-		fmt.Printf("Attempting to rollback migration...")
-		if rollbackErr := migrationEngine.RollBackManager; rollbackErr != nil {
-			log.Printf("Rollback failed, %v", rollbackErr)
-			fmt.Printf("Try Manual Rollback: ./binary --rollback=<snapshot_id>\n")
-		} else {
-			fmt.Printf("Rollback completed successfully\n")
+		//attempting rollback when failure occurs, unless the engine already rolled back
+		if snapshot := migrationEngine.CurrentSnapshot; snapshot != nil {
+			if current, loadErr := migrationEngine.RollBackManager.LoadSnapshot(snapshot.ID); loadErr == nil && current.Status != "rolled_back" {
+				fmt.Printf("Attempting to rollback migration...\n")
+				if rollbackErr := migrationEngine.RollBackManager.RollBackMigration(snapshot.ID); rollbackErr != nil {
+					log.Printf("Rollback failed, %v", rollbackErr)
+					fmt.Printf("Try Manual Rollback: ./binary --rollback=%s\n", snapshot.ID)
+					migrationEngine.Close()
+					os.Exit(2)
+				}
+				fmt.Printf("Rollback completed successfully\n")
+				migrationEngine.DiscardCheckpoint()
+			}
+		}
+		if checkpoint := migrationEngine.CurrentCheckpoint; checkpoint != nil && checkpoint.Status != "completed" {
+			fmt.Printf("Continue the migration with: ./binary --source=%s --target=%s --resume=%s\n", *sourceDB, *targetDB, checkpoint.RunID)
 		}
+		migrationEngine.Close()
 		os.Exit(1)
 	}
 
@@ -340,8 +563,31 @@ func main() {
 	fmt.Printf(" Ready for production use!\n")
 }
 
+// connecting a client, cancelled with ctx when the client supports it
+func connectDatabaseClient(ctx context.Context, client database.DatabaseClient) error {
+	if contextClient, ok := client.(database.ContextClient); ok {
+		return contextClient.ConnectContext(ctx)
+	}
+	return client.Connect()
+}
+
+// splitting a comma separated flag value, ignoring empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // helper function for handling mongodb parsing logic and SQL table discovery
-func getTablesOrCollections(sourceDB string, cfg *config.Config, sourceClient database.DatabaseClient) ([]string, error) {
+func getTablesOrCollections(sourceDB string, cfg *config.Config, sourceClient database.DatabaseClient, filter database.TableFilter) ([]string, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
 	switch strings.ToLower(sourceDB) {
 	case "mongodb":
 		//for mongodb, discover collections from database
@@ -350,26 +596,39 @@ func getTablesOrCollections(sourceDB string, cfg *config.Config, sourceClient da
 			if err != nil {
 				return nil, fmt.Errorf("failed to get MongoDB collections, %v", err)
 			}
+			collections = filter.Apply(collections)
 			if len(collections) == 0 {
 				return nil, fmt.Errorf("no collections found in mongodb database")
 			}
 			return collections, nil
 		}
 		return nil, fmt.Errorf("failed to cast to MongoDB client")
-	case "mysql", "postgresql":
-		//for sql databases, parse SQL files
-		if cfg.SQLFilePath == "" {
-			return nil, fmt.Errorf("SQL file path not specified in the configuration")
+	case "mysql", "postgresql", "sqlite":
+		//a configured SQL file overrides discovery from the live catalog, a sqlite file always has its own catalog
+		if cfg.SQLFilePath != "" && !strings.EqualFold(sourceDB, "sqlite") {
+			parser := &database.SQLParser{}
+			tables, err := parser.ParseSQLFiles(cfg.SQLFilePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse SQL file %s, %v", cfg.SQLFilePath, err)
+			}
+			tables = filter.Apply(tables)
+			if len(tables) == 0 {
+				return nil, fmt.Errorf("no tables found in SQL file %s,%v", cfg.SQLFilePath, err)
+			}
+			return tables, nil
+		}
+
+		discoverer, ok := sourceClient.(database.DiscoveryClient)
+		if !ok {
+			return nil, fmt.Errorf("source %s does not support table discovery, set sqlfile_path in the configuration", sourceDB)
 		}
-		parser := &database.SQLParser{}
-		tables, err := parser.ParseSQLFiles(cfg.SQLFilePath)
+		tables, err := discoverer.DiscoverTables(filter)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse SQL file %s, %v", cfg.SQLFilePath, err)
+			return nil, fmt.Errorf("failed to discover tables of %s, %v", sourceDB, err)
 		}
 		if len(tables) == 0 {
-			return nil, fmt.Errorf("no tables found in SQL file %s,%v", cfg.SQLFilePath, err)
+			return nil, fmt.Errorf("no tables found in %s matching the discovery filters", sourceDB)
 		}
-
 		return tables, nil
 	default:
 		return nil, fmt.Errorf("unsupported database type %s", sourceDB)
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
 )
 
 // tests validateInput function
@@ -23,6 +29,8 @@ func TestValidateInput(t *testing.T) {
 		{"mysql", "", "full", false},
 		{"", "MONGODB", "", false},
 		{"MySQL", "MongoDb", "Full", true},
+		{"sqlite", "postgresql", "full", true},
+		{"MYSQL", "SQLite", "incremental", true},
 	}
 
 	for i, tc := range tests {
@@ -56,3 +64,28 @@ func TestIsValidDatabase(t *testing.T) {
 		}
 	}
 }
+
+// tests that a configured SQL file overrides discovery and is filtered
+func TestGetTablesOrCollectionsFromSQLFile(t *testing.T) {
+	sqlFile := filepath.Join(t.TempDir(), "schema.sql")
+	content := "CREATE TABLE customers (id INT);\nCREATE TABLE orders (id INT);\nCREATE TABLE orders_archive (id INT);\n"
+	if err := os.WriteFile(sqlFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write SQL file, %v", err)
+	}
+
+	cfg := &config.Config{SQLFilePath: sqlFile}
+	filter := database.TableFilter{Exclude: []string{"*_archive"}}
+
+	tables, err := getTablesOrCollections("mysql", cfg, &database.MySQLClient{}, filter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if expected := []string{"customers", "orders"}; !reflect.DeepEqual(tables, expected) {
+		t.Errorf("expected %v, got %v", expected, tables)
+	}
+
+	//without a SQL file the tables are discovered from the catalog, failing here as there is no connection
+	if _, err := getTablesOrCollections("mysql", &config.Config{}, &database.MySQLClient{}, filter); err == nil {
+		t.Errorf("expected discovery error without connection, got nil")
+	}
+}
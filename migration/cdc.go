@@ -0,0 +1,375 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// source position after the last transaction applied to the target by change data capture
+type ChangePosition struct {
+	Position  string    `json:"position"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// type for persisting change data capture positions between runs
+type ChangePositionStore struct {
+	stateDir string
+}
+
+// creating a new change position store writing into stateDir
+func NewChangePositionStore(stateDir string) *ChangePositionStore {
+	return &ChangePositionStore{stateDir: stateDir}
+}
+
+// returning the state file for a source/target pair
+func (cs *ChangePositionStore) fileName(sourceDB, targetDB string) string {
+	return filepath.Join(cs.stateDir, fmt.Sprintf("cdc_position_%s_to_%s.json", sourceDB, targetDB))
+}
+
+// loading the persisted position, nil when changes were never captured
+func (cs *ChangePositionStore) Load(sourceDB, targetDB string) (*ChangePosition, error) {
+	data, err := os.ReadFile(cs.fileName(sourceDB, targetDB))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read change position file, %v", err)
+	}
+
+	var position ChangePosition
+	if err := json.Unmarshal(data, &position); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal change position, %v", err)
+	}
+	return &position, nil
+}
+
+// saving the position atomically, so a crash never leaves a half written file behind
+func (cs *ChangePositionStore) Save(sourceDB, targetDB, position string) error {
+	if err := os.MkdirAll(cs.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create change position directory, %v", err)
+	}
+
+	data, err := json.MarshalIndent(ChangePosition{Position: position, UpdatedAt: time.Now()}, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal change position, %v", err)
+	}
+
+	fileName := cs.fileName(sourceDB, targetDB)
+	tmpFile := fileName + ".tmp"
+	if err := writeFileSynced(tmpFile, data); err != nil {
+		return fmt.Errorf("failed to write change position file, %v", err)
+	}
+	if err := os.Rename(tmpFile, fileName); err != nil {
+		return fmt.Errorf("failed to replace change position file, %v", err)
+	}
+	return nil
+}
+
+// replicating the changes committed to the source tables until ctx is cancelled. Every transaction is applied
+// to the target in commit order before its position is saved, a restarted run applies the transaction it was
+// interrupted in again, which upserts and deletes by key make harmless
+func (me *MigrationEngine) executeChangeDataCapture(ctx context.Context, result *MigrationResult) error {
+	me.Logger.Info("Executing Change Data Capture")
+	log.Println("Executing change data capture...")
+
+	capture, upserter, deleter, err := me.changeCaptureClients()
+	if err != nil {
+		return err
+	}
+
+	position, err := me.changeStartPosition(ctx, capture)
+	if err != nil {
+		return err
+	}
+
+	reader, err := me.readChanges(ctx, capture, position)
+	if err != nil {
+		return err
+	}
+	defer reader.stop()
+	me.Logger.Info(fmt.Sprintf("Capturing changes of %d tables from position %s", len(me.Config.Tables), position))
+
+	var transactions int64
+	for change := range reader.changes {
+		if change.err != nil {
+			return fmt.Errorf("failed to read changes after position %s, %v", position, change.err)
+		}
+		if err := me.applyChanges(ctx, reader, change.transaction, result, upserter, deleter); err != nil {
+			return err
+		}
+		position = change.transaction.Position
+		transactions++
+	}
+
+	me.Logger.Info(fmt.Sprintf("Change data capture stopped at position %s after %d transactions", position, transactions))
+	log.Printf("Change data capture stopped at position %s, the next run continues from there", position)
+	return nil
+}
+
+// returning the source capturing changes and the target applying them, failing when either lacks support
+func (me *MigrationEngine) changeCaptureClients() (database.ChangeCaptureClient, database.UpsertClient, database.RollbackClient, error) {
+	capture, ok := me.SourceClient.(database.ChangeCaptureClient)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("source database %s does not support change data capture", me.Config.SourceDb)
+	}
+	upserter, ok := me.TargetClient.(database.UpsertClient)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("target database %s does not support upserts needed for change data capture", me.Config.TargetDb)
+	}
+	deleter, ok := me.TargetClient.(database.RollbackClient)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("target database %s does not support deletes needed for change data capture", me.Config.TargetDb)
+	}
+	return capture, upserter, deleter, nil
+}
+
+// transaction read from the change stream, or the error that ended the stream
+type streamedChange struct {
+	transaction *database.ChangeTransaction
+	err         error
+}
+
+// change stream read in its own goroutine, so waiting for changes never interrupts a read in progress
+type changeReader struct {
+	changes <-chan streamedChange //closed once the reader stopped
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	stream  database.ChangeStream //replaced when the stream is reopened
+	applied string                //position of the last applied transaction, the start position before the first
+}
+
+// reading the change stream from position in the background until ctx is cancelled or the stream fails with an
+// error that is not transient, dropped streams are reopened after the last transaction read
+func (me *MigrationEngine) readChanges(ctx context.Context, capture database.ChangeCaptureClient, position string) (*changeReader, error) {
+	stream, err := me.openChangeStream(ctx, capture, position)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	changes := make(chan streamedChange)
+	reader := &changeReader{changes: changes, cancel: cancel, stream: stream, applied: position}
+	go func() {
+		defer close(changes)
+		defer func() {
+			reader.current().Close()
+		}()
+
+		reconnects := 0
+		for {
+			transaction, err := reader.current().Next(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && database.IsTransientError(err) && reconnects < me.retryPolicy().MaxAttempts {
+				//reconnecting after a dropped connection, continuing after the last transaction read
+				reconnects++
+				me.ProgressTracker.AddRetry()
+				me.Logger.Info(fmt.Sprintf("Warning: change stream failed with a transient error, reopening it at position %s, %v", position, err))
+				reader.current().Close()
+				reopened, openErr := me.openChangeStream(ctx, capture, position)
+				if openErr == nil {
+					reader.mu.Lock()
+					reader.stream = reopened
+					reader.mu.Unlock()
+					continue
+				}
+				err = openErr
+			}
+			if err == nil {
+				reconnects = 0
+				position = transaction.Position
+			}
+
+			select {
+			case changes <- streamedChange{transaction: transaction, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return reader, nil
+}
+
+// returning the stream currently read
+func (r *changeReader) current() database.ChangeStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stream
+}
+
+// acknowledging position on the stream currently read
+func (r *changeReader) ack(position string) error {
+	return r.current().Ack(position)
+}
+
+// stopping the reader and waiting until its stream is closed
+func (r *changeReader) stop() {
+	r.cancel()
+	for range r.changes {
+	}
+}
+
+// applying a transaction read from the change stream, then saving its position and letting the source release it
+func (me *MigrationEngine) applyChanges(ctx context.Context, reader *changeReader, transaction *database.ChangeTransaction, result *MigrationResult, upserter database.UpsertClient, deleter database.RollbackClient) error {
+	applied, err := me.applyChangeTransaction(ctx, transaction, upserter, deleter)
+	if err != nil {
+		me.ProgressTracker.AddError(err.Error())
+		return fmt.Errorf("failed to apply the transaction committed at %s, %v", transaction.CommitTime.Format(time.RFC3339), err)
+	}
+	result.TotalRowsMigrated += applied
+
+	//saving the position only once the whole transaction is applied
+	sourceKey, targetKey := me.stateKeys()
+	if err := me.ChangePositionStore.Save(sourceKey, targetKey, transaction.Position); err != nil {
+		return fmt.Errorf("failed to save change position %s, %v", transaction.Position, err)
+	}
+	reader.applied = transaction.Position
+	if err := reader.ack(transaction.Position); err != nil {
+		me.Logger.Error("Failed to acknowledge change position", err.Error())
+	}
+
+	if applied > 0 {
+		me.Logger.Info(fmt.Sprintf("Applied %d changes committed at %s, position %s", applied, transaction.CommitTime.Format(time.RFC3339), transaction.Position))
+	}
+	return nil
+}
+
+// returning the position capture starts from, the configured one, the saved one of the previous run or,
+// when capturing for the first time, the current position of the source
+func (me *MigrationEngine) changeStartPosition(ctx context.Context, capture database.ChangeCaptureClient) (string, error) {
+	if me.Config.ChangePosition != "" {
+		return me.Config.ChangePosition, nil
+	}
+
+	sourceKey, targetKey := me.stateKeys()
+	saved, err := me.ChangePositionStore.Load(sourceKey, targetKey)
+	if err != nil {
+		return "", err
+	}
+	if saved != nil {
+		me.Logger.Info(fmt.Sprintf("Resuming change data capture from position %s saved at %s", saved.Position, saved.UpdatedAt.Format(time.RFC3339)))
+		return saved.Position, nil
+	}
+
+	position, err := capture.CurrentChangePosition(ctx, me.Config.Tables)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the current change position of %s, %v", me.Config.SourceDb, err)
+	}
+	me.Logger.Info(fmt.Sprintf("Warning: no saved change position, capturing changes committed after %s, rows written before are not copied by change data capture", position))
+	//saving right away, so a restart before the first change does not skip the changes committed meanwhile
+	if err := me.ChangePositionStore.Save(sourceKey, targetKey, position); err != nil {
+		return "", fmt.Errorf("failed to save change position %s, %v", position, err)
+	}
+	return position, nil
+}
+
+// opening the change stream of the migrated tables at position, retrying transient errors
+func (me *MigrationEngine) openChangeStream(ctx context.Context, capture database.ChangeCaptureClient, position string) (database.ChangeStream, error) {
+	var stream database.ChangeStream
+	err := me.retry(ctx, fmt.Sprintf("opening the change stream at position %s", position), func() error {
+		var err error
+		stream, err = capture.OpenChangeStream(ctx, me.Config.Tables, position)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the change stream of %s at position %s, %v", me.Config.SourceDb, position, err)
+	}
+	return stream, nil
+}
+
+// single row write derived from a captured change
+type changeWrite struct {
+	table  string
+	delete bool
+	row    map[string]interface{}
+}
+
+// applying the changes of a transaction in commit order, returns the number of rows written. Consecutive
+// changes of a table that are all upserts or all deletes are written together
+func (me *MigrationEngine) applyChangeTransaction(ctx context.Context, transaction *database.ChangeTransaction, upserter database.UpsertClient, deleter database.RollbackClient) (int64, error) {
+	writes := make([]changeWrite, 0, len(transaction.Changes))
+	for _, change := range transaction.Changes {
+		switch change.Operation {
+		case database.ChangeInsert:
+			writes = append(writes, changeWrite{table: change.Table, row: change.Row})
+		case database.ChangeUpdate:
+			//an update of the key moves the row, the row under the old key is deleted first
+			if change.Before != nil && me.keyChanged(change.Table, change.Before, change.Row, me.keyColumns(change.Table)) {
+				writes = append(writes, changeWrite{table: change.Table, delete: true, row: change.Before})
+			}
+			writes = append(writes, changeWrite{table: change.Table, row: change.Row})
+		case database.ChangeDelete:
+			writes = append(writes, changeWrite{table: change.Table, delete: true, row: change.Row})
+		default:
+			return 0, fmt.Errorf("unknown change operation %s on table %s", change.Operation, change.Table)
+		}
+	}
+
+	var applied int64
+	for start := 0; start < len(writes); {
+		table, isDelete := writes[start].table, writes[start].delete
+		end := start
+		rows := make([]map[string]interface{}, 0)
+		for ; end < len(writes) && writes[end].table == table && writes[end].delete == isDelete; end++ {
+			rows = append(rows, writes[end].row)
+		}
+		start = end
+		keyColumns := me.keyColumns(table)
+
+		transformed, err := me.transformRows(table, rows)
+		if err != nil {
+			return applied, fmt.Errorf("failed to transform changes of table %s, %v", table, err)
+		}
+
+		write := func(ctx context.Context, rows []map[string]interface{}) error {
+			return me.retry(ctx, fmt.Sprintf("upserting %d changed rows of table %s", len(rows), table), func() error {
+				return upserter.UpsertData(rows, keyColumns)
+			})
+		}
+		if isDelete {
+			targetTable := me.Config.Mapping.TargetTable(table)
+			write = func(ctx context.Context, rows []map[string]interface{}) error {
+				return me.retry(ctx, fmt.Sprintf("deleting %d rows of table %s", len(rows), table), func() error {
+					_, err := deleter.DeleteRows(targetTable, keyColumns, rows)
+					return err
+				})
+			}
+		}
+		written, err := me.writeRows(ctx, table, transformed, write)
+		if err != nil {
+			return applied, fmt.Errorf("failed to write changes of table %s, %v", table, err)
+		}
+		applied += int64(len(written))
+	}
+	return applied, nil
+}
+
+// reporting whether an update changed the target key of a row, comparing the rows as they are written
+func (me *MigrationEngine) keyChanged(table string, before, after map[string]interface{}, keyColumns []string) bool {
+	rows, failed := me.Config.Transforms.For(table).Apply([]map[string]interface{}{before, after})
+	if len(failed) > 0 {
+		//the failing row is dead-lettered when it is written
+		return false
+	}
+	rows = me.Config.Mapping.MapRows(rows)
+	for _, column := range keyColumns {
+		//rows without the key, like partial row images, keep it
+		value, ok := rows[1][column]
+		if ok && fmt.Sprint(rows[0][column]) != fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+)
+
+// mock source replaying a fixed list of transactions as its change stream
+type changeCaptureMockClient struct {
+	*test.CompleteMockDatabaseClient
+	transactions []*database.ChangeTransaction
+	dropAfter    int //number of transactions after which the first stream fails with a transient error
+	opened       []string
+	acked        []string
+	delays       map[string]time.Duration //wait before the transaction at a position arrives
+	positionRead bool
+}
+
+// returning "0" first, later the position of the last transaction, as every transaction is committed meanwhile
+func (c *changeCaptureMockClient) CurrentChangePosition(ctx context.Context, tables []string) (string, error) {
+	if !c.positionRead || len(c.transactions) == 0 {
+		c.positionRead = true
+		return "0", nil
+	}
+	return c.transactions[len(c.transactions)-1].Position, nil
+}
+
+func (c *changeCaptureMockClient) ChangePositionReached(position, target string) (bool, error) {
+	applied, err := strconv.Atoi(position)
+	if err != nil {
+		return false, err
+	}
+	wanted, err := strconv.Atoi(target)
+	if err != nil {
+		return false, err
+	}
+	return applied >= wanted, nil
+}
+
+func (c *changeCaptureMockClient) OpenChangeStream(ctx context.Context, tables []string, position string) (database.ChangeStream, error) {
+	c.opened = append(c.opened, position)
+	stream := &mockChangeStream{client: c, dropAfter: -1}
+	if len(c.opened) == 1 {
+		stream.dropAfter = c.dropAfter
+	}
+	//continuing after the transaction committed at position
+	for i, transaction := range c.transactions {
+		if transaction.Position == position {
+			stream.next = i + 1
+		}
+	}
+	return stream, nil
+}
+
+type mockChangeStream struct {
+	client    *changeCaptureMockClient
+	next      int
+	sent      int
+	dropAfter int
+}
+
+func (s *mockChangeStream) Next(ctx context.Context) (*database.ChangeTransaction, error) {
+	if s.sent == s.dropAfter {
+		return nil, fmt.Errorf("failed to read packet header, %w", io.ErrUnexpectedEOF)
+	}
+	if s.next >= len(s.client.transactions) {
+		//waiting for changes like an idle source until the capture is stopped
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	transaction := s.client.transactions[s.next]
+	select {
+	case <-time.After(s.client.delays[transaction.Position]):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.next++
+	s.sent++
+	return transaction, nil
+}
+
+func (s *mockChangeStream) Ack(position string) error {
+	s.client.acked = append(s.client.acked, position)
+	return nil
+}
+
+func (s *mockChangeStream) Close() error {
+	return nil
+}
+
+func userChange(operation database.ChangeOperation, id int, name string) database.RowChange {
+	return database.RowChange{Operation: operation, Table: "users", Row: map[string]interface{}{"id": id, "name": name, "_source_table": "users"}}
+}
+
+func newChangeCaptureTestEngine(t *testing.T, source, target database.DatabaseClient) *MigrationEngine {
+	engine := NewMigrationEngine(MigrationConfig{
+		Mode:      ChangeDataCaptureMigration,
+		SourceDb:  "mysql",
+		TargetDb:  "postgresql",
+		Tables:    []string{"users"},
+		BatchSize: 10,
+		Retry:     database.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}, source, target)
+	engine.RollBackManager.snapshotsDir = t.TempDir()
+	engine.CheckpointStore = NewCheckpointStore(t.TempDir())
+	engine.ChangePositionStore = NewChangePositionStore(t.TempDir())
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+// running change data capture until every transaction of the source is applied
+func runChangeCapture(t *testing.T, engine *MigrationEngine, source *changeCaptureMockClient) *MigrationResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	last := source.transactions[len(source.transactions)-1].Position
+	go func() {
+		for ctx.Err() == nil {
+			if saved, _ := engine.ChangePositionStore.Load("mysql", "postgresql"); saved != nil && saved.Position == last {
+				cancel()
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	result, err := engine.ExecuteMigrationContext(ctx)
+	if err != nil {
+		t.Fatalf("Change data capture failed, %v", err)
+	}
+	return result
+}
+
+func TestChangeDataCaptureAppliesTransactions(t *testing.T) {
+	sourceClient := &changeCaptureMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql")}
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	rename := userChange(database.ChangeUpdate, 1, "Sathyaraj")
+	rename.Before = map[string]interface{}{"id": 1, "name": "Susheel", "_source_table": "users"}
+	rekey := userChange(database.ChangeUpdate, 20, "Alex")
+	rekey.Before = map[string]interface{}{"id": 2, "name": "Alex", "_source_table": "users"}
+	sourceClient.transactions = []*database.ChangeTransaction{
+		{Position: "1", Changes: []database.RowChange{userChange(database.ChangeInsert, 1, "Susheel"), userChange(database.ChangeInsert, 2, "Alex"), userChange(database.ChangeInsert, 3, "Bo")}},
+		{Position: "2", Changes: []database.RowChange{rename, rekey}},
+		{Position: "3", Changes: []database.RowChange{userChange(database.ChangeDelete, 3, "Bo")}},
+	}
+
+	engine := newChangeCaptureTestEngine(t, sourceClient, targetClient)
+	result := runChangeCapture(t, engine, sourceClient)
+
+	users := targetClient.GetImportedData("users")
+	if len(users) != 2 {
+		t.Fatalf("Expected 2 users after the delete, got %v", users)
+	}
+	names := map[string]string{}
+	for _, user := range users {
+		names[fmt.Sprint(user["id"])] = fmt.Sprint(user["name"])
+	}
+	if names["1"] != "Sathyaraj" || names["20"] != "Alex" {
+		t.Errorf("Expected the update and the moved key to be applied, got %v", users)
+	}
+	if result.TotalRowsMigrated != 7 {
+		t.Errorf("Expected 7 written rows, got %d", result.TotalRowsMigrated)
+	}
+	if len(sourceClient.acked) != 3 || sourceClient.acked[2] != "3" {
+		t.Errorf("Expected every transaction to be acknowledged, got %v", sourceClient.acked)
+	}
+
+	//a restarted capture continues after the saved position
+	restarted := newChangeCaptureTestEngine(t, sourceClient, targetClient)
+	restarted.ChangePositionStore = engine.ChangePositionStore
+	sourceClient.transactions = append(sourceClient.transactions, &database.ChangeTransaction{Position: "4", Changes: []database.RowChange{userChange(database.ChangeInsert, 4, "Dee")}})
+	result = runChangeCapture(t, restarted, sourceClient)
+	if sourceClient.opened[len(sourceClient.opened)-1] != "3" || result.TotalRowsMigrated != 1 {
+		t.Errorf("Expected the restarted capture to continue at position 3, opened %v and wrote %d rows", sourceClient.opened, result.TotalRowsMigrated)
+	}
+}
+
+func TestChangeDataCaptureReopensDroppedStream(t *testing.T) {
+	sourceClient := &changeCaptureMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql"), dropAfter: 1}
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	sourceClient.Connect()
+	targetClient.Connect()
+	sourceClient.transactions = []*database.ChangeTransaction{
+		{Position: "1", Changes: []database.RowChange{userChange(database.ChangeInsert, 1, "Susheel")}},
+		{Position: "2", Changes: []database.RowChange{userChange(database.ChangeInsert, 2, "Alex")}},
+	}
+
+	engine := newChangeCaptureTestEngine(t, sourceClient, targetClient)
+	result := runChangeCapture(t, engine, sourceClient)
+
+	if len(sourceClient.opened) != 2 || sourceClient.opened[1] != "1" {
+		t.Errorf("Expected the stream to be reopened after the applied transaction, opened %v", sourceClient.opened)
+	}
+	if result.Retries != 1 || targetClient.GetImportedTableRowCount("users") != 2 {
+		t.Errorf("Expected 2 users after one reconnect, got %d users and %d retries", targetClient.GetImportedTableRowCount("users"), result.Retries)
+	}
+}
+
+func TestChangeDataCaptureRequiresCapableSource(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newChangeCaptureTestEngine(t, sourceClient, targetClient)
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Error("Expected an error for a source without change data capture")
+	}
+}
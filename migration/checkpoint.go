@@ -0,0 +1,135 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// progress of a full migration run, persisted after every committed batch so a failed run can be resumed
+type MigrationCheckpoint struct {
+	RunID     string                      `json:"run_id"`
+	SourceDB  string                      `json:"source_db"`
+	TargetDB  string                      `json:"target_db"`
+	Tables    []string                    `json:"tables"`
+	Status    string                      `json:"status"` //"in_progress", "completed", "failed", "interrupted"
+	StartedAt time.Time                   `json:"started_at"`
+	UpdatedAt time.Time                   `json:"updated_at"`
+	Progress  map[string]*TableCheckpoint `json:"progress"`
+}
+
+// progress of a single table or key range, the last committed key when it is read in key order,
+// otherwise only the number of committed rows
+type TableCheckpoint struct {
+	Status        string             `json:"status"` //"pending", "in_progress", "completed"
+	KeyColumn     string             `json:"key_column,omitempty"`
+	LastKey       *TableWatermark    `json:"last_key,omitempty"`
+	RowsCommitted int64              `json:"rows_committed"`
+	Batches       int                `json:"batches"`
+	UpdatedAt     time.Time          `json:"updated_at,omitempty"`
+	Range         *database.KeyRange `json:"range,omitempty"`  //key range read by this checkpoint, nil for a whole table
+	Chunks        []*TableCheckpoint `json:"chunks,omitempty"` //progress of the key ranges of a table split for concurrent reads
+}
+
+// returning the rows committed for a table, including those of its key ranges
+func (tc *TableCheckpoint) CommittedRows() int64 {
+	rows := tc.RowsCommitted
+	for _, chunk := range tc.Chunks {
+		rows += chunk.RowsCommitted
+	}
+	return rows
+}
+
+// creating the checkpoint of a new run with every table pending
+func NewMigrationCheckpoint(config MigrationConfig) *MigrationCheckpoint {
+	now := time.Now()
+	checkpoint := &MigrationCheckpoint{
+		RunID:     fmt.Sprintf("run_%s_to_%s_%d", config.SourceDb, config.TargetDb, now.Unix()),
+		SourceDB:  config.SourceDb,
+		TargetDB:  config.TargetDb,
+		Tables:    append([]string{}, config.Tables...),
+		Status:    "in_progress",
+		StartedAt: now,
+		UpdatedAt: now,
+		Progress:  make(map[string]*TableCheckpoint, len(config.Tables)),
+	}
+	for _, table := range config.Tables {
+		checkpoint.Progress[table] = &TableCheckpoint{Status: "pending"}
+	}
+	return checkpoint
+}
+
+// type for persisting run checkpoints next to the rollback snapshots
+type CheckpointStore struct {
+	stateDir string
+}
+
+// creating a new checkpoint store writing into stateDir
+func NewCheckpointStore(stateDir string) *CheckpointStore {
+	return &CheckpointStore{stateDir: stateDir}
+}
+
+// returning the checkpoint file of a run
+func (cs *CheckpointStore) fileName(runID string) string {
+	return filepath.Join(cs.stateDir, fmt.Sprintf("checkpoint_%s.json", runID))
+}
+
+// loading the checkpoint of a run
+func (cs *CheckpointStore) Load(runID string) (*MigrationCheckpoint, error) {
+	data, err := os.ReadFile(cs.fileName(runID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no checkpoint found for run %s", runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file, %v", err)
+	}
+
+	var checkpoint MigrationCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint, %v", err)
+	}
+	for _, table := range checkpoint.Tables {
+		if checkpoint.Progress[table] == nil {
+			if checkpoint.Progress == nil {
+				checkpoint.Progress = make(map[string]*TableCheckpoint)
+			}
+			checkpoint.Progress[table] = &TableCheckpoint{Status: "pending"}
+		}
+	}
+	return &checkpoint, nil
+}
+
+// saving a checkpoint atomically, so a crash never leaves a half written file behind
+func (cs *CheckpointStore) Save(checkpoint *MigrationCheckpoint) error {
+	if err := os.MkdirAll(cs.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory, %v", err)
+	}
+
+	checkpoint.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(checkpoint, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint, %v", err)
+	}
+
+	fileName := cs.fileName(checkpoint.RunID)
+	tmpFile := fileName + ".tmp"
+	if err := writeFileSynced(tmpFile, data); err != nil {
+		return fmt.Errorf("failed to write checkpoint file, %v", err)
+	}
+	if err := os.Rename(tmpFile, fileName); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file, %v", err)
+	}
+	return nil
+}
+
+// removing the checkpoint of a run that completed or was rolled back
+func (cs *CheckpointStore) Remove(runID string) error {
+	if err := os.Remove(cs.fileName(runID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checkpoint file, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+)
+
+// mock target failing every import after the first failAfter ones
+type failingTargetClient struct {
+	*test.CompleteMockDatabaseClient
+	failAfter int
+	imports   int
+}
+
+func (f *failingTargetClient) ImportData(data []map[string]interface{}) error {
+	f.imports++
+	if f.failAfter > 0 && f.imports > f.failAfter {
+		return fmt.Errorf("connection lost")
+	}
+	return f.CompleteMockDatabaseClient.ImportData(data)
+}
+
+// mock source streaming tables ordered by their single column primary key id
+type keysetMockClient struct {
+	*constraintMockClient
+	resumedAfter []interface{}
+}
+
+func (k *keysetMockClient) StreamTableAfter(ctx context.Context, tableName, keyColumn string, lastKey interface{}, batchSize int) (database.RowIterator, error) {
+	rows, err := k.FetchAllData([]string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][keyColumn].(int) < rows[j][keyColumn].(int) })
+
+	if lastKey != nil {
+		k.resumedAfter = append(k.resumedAfter, lastKey)
+		filtered := rows[:0]
+		for _, row := range rows {
+			if int64(row[keyColumn].(int)) > lastKey.(int64) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+	return database.NewSliceRowIterator(rows, batchSize), nil
+}
+
+func newCheckpointTestEngine(t *testing.T, source database.DatabaseClient, target database.DatabaseClient, stateDir, resumeRunID string) *MigrationEngine {
+	config := MigrationConfig{
+		Mode:        FullMigration,
+		SourceDb:    "mysql",
+		TargetDb:    "postgresql",
+		Tables:      []string{"users", "orders"},
+		BatchSize:   2,
+		ResumeRunID: resumeRunID,
+	}
+
+	engine := NewMigrationEngine(config, source, target)
+	engine.RollBackManager.snapshotsDir = stateDir
+	engine.CheckpointStore = NewCheckpointStore(stateDir)
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+func addCheckpointTestData(client *test.CompleteMockDatabaseClient) {
+	client.AddTestData("users", []map[string]interface{}{
+		{"id": 5, "name": "e"}, {"id": 1, "name": "a"}, {"id": 4, "name": "d"}, {"id": 2, "name": "b"}, {"id": 3, "name": "c"},
+	})
+	client.AddTestData("orders", []map[string]interface{}{
+		{"id": 1, "user_id": 1}, {"id": 2, "user_id": 2}, {"id": 3, "user_id": 5},
+	})
+}
+
+func TestResumeSkipsCommittedRows(t *testing.T) {
+	stateDir := t.TempDir()
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	addCheckpointTestData(sourceClient)
+	targetClient := &failingTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failAfter: 2}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newCheckpointTestEngine(t, sourceClient, targetClient, stateDir, "")
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Fatalf("Expected the first run to fail")
+	}
+
+	runID := engine.CurrentCheckpoint.RunID
+	checkpoint, err := engine.CheckpointStore.Load(runID)
+	if err != nil {
+		t.Fatalf("Expected checkpoint of the failed run, %v", err)
+	}
+	if users := checkpoint.Progress["users"]; users.Status != "in_progress" || users.RowsCommitted != 4 || checkpoint.Status != "failed" {
+		t.Errorf("Expected 4 committed users rows in a failed run, got %+v in %s run", users, checkpoint.Status)
+	}
+
+	targetClient.failAfter = 0
+	resumed := newCheckpointTestEngine(t, sourceClient, targetClient, stateDir, runID)
+	result, err := resumed.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Resumed run failed, %v", err)
+	}
+
+	if result.TotalRowsMigrated != 4 {
+		t.Errorf("Expected the resumed run to migrate the remaining 4 rows, got %d", result.TotalRowsMigrated)
+	}
+	if users, orders := targetClient.GetImportedTableRowCount("users"), targetClient.GetImportedTableRowCount("orders"); users != 5 || orders != 3 {
+		t.Errorf("Expected 5 users and 3 orders without duplicates, got %d and %d", users, orders)
+	}
+	if _, err := resumed.CheckpointStore.Load(runID); err == nil {
+		t.Errorf("Expected the checkpoint to be removed once the run completed")
+	}
+}
+
+func TestResumeContinuesAfterLastKey(t *testing.T) {
+	stateDir := t.TempDir()
+	sourceClient := &keysetMockClient{constraintMockClient: newConstraintMockClient("mysql")}
+	sourceClient.constraints["users"] = &database.TableConstraints{Table: "users", PrimaryKey: []string{"id"}}
+	sourceClient.constraints["orders"] = &database.TableConstraints{Table: "orders", PrimaryKey: []string{"id"}}
+	addCheckpointTestData(sourceClient.CompleteMockDatabaseClient)
+	targetClient := &failingTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failAfter: 4}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newCheckpointTestEngine(t, sourceClient, targetClient, stateDir, "")
+	engine.Config.SkipConstraints = true
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Fatalf("Expected the first run to fail")
+	}
+
+	runID := engine.CurrentCheckpoint.RunID
+	checkpoint, err := engine.CheckpointStore.Load(runID)
+	if err != nil {
+		t.Fatalf("Expected checkpoint of the failed run, %v", err)
+	}
+	if users := checkpoint.Progress["users"]; users.Status != "completed" || users.KeyColumn != "id" {
+		t.Errorf("Expected users to be completed and keyed by id, got %+v", users)
+	}
+	if orders := checkpoint.Progress["orders"]; orders.LastKey == nil || orders.LastKey.Value != "2" {
+		t.Errorf("Expected orders to be committed up to id 2, got %+v", orders)
+	}
+
+	targetClient.failAfter = 0
+	resumed := newCheckpointTestEngine(t, sourceClient, targetClient, stateDir, runID)
+	resumed.Config.SkipConstraints = true
+	if _, err := resumed.ExecuteMigration(); err != nil {
+		t.Fatalf("Resumed run failed, %v", err)
+	}
+
+	if len(sourceClient.resumedAfter) != 1 || sourceClient.resumedAfter[0] != int64(2) {
+		t.Errorf("Expected orders to be read after key 2 only, got %v", sourceClient.resumedAfter)
+	}
+	if users, orders := targetClient.GetImportedTableRowCount("users"), targetClient.GetImportedTableRowCount("orders"); users != 5 || orders != 3 {
+		t.Errorf("Expected 5 users and 3 orders without duplicates, got %d and %d", users, orders)
+	}
+}
+
+func TestResumeRejectsOtherMigrations(t *testing.T) {
+	stateDir := t.TempDir()
+	store := NewCheckpointStore(stateDir)
+	checkpoint := NewMigrationCheckpoint(MigrationConfig{SourceDb: "mongodb", TargetDb: "postgresql", Tables: []string{"users"}})
+	if err := store.Save(checkpoint); err != nil {
+		t.Fatalf("Failed to save checkpoint, %v", err)
+	}
+
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	engine := newCheckpointTestEngine(t, sourceClient, targetClient, stateDir, checkpoint.RunID)
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Errorf("Expected resuming a mongodb run into a mysql migration to fail")
+	}
+
+	engine = newCheckpointTestEngine(t, sourceClient, targetClient, stateDir, "run_missing")
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Errorf("Expected resuming an unknown run to fail")
+	}
+}
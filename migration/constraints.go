@@ -0,0 +1,117 @@
+package migration
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// recreating primary keys, indexes and foreign keys of the migrated tables once all rows are loaded,
+// keys and indexes of every table come first so foreign keys find the referenced keys in place. The rows are
+// already loaded, so constraints that cannot be created are reported in the result instead of failing the run
+func (me *MigrationEngine) migrateConstraints(result *MigrationResult, plan *MigrationPlan) {
+	if me.Config.SkipConstraints {
+		me.Logger.Info("Skipping migration of indexes and constraints")
+		return
+	}
+
+	_, sourceOk := me.SourceClient.(database.ConstraintClient)
+	writer, targetOk := me.TargetClient.(database.ConstraintClient)
+	if !sourceOk || !targetOk {
+		me.Logger.Info(fmt.Sprintf("Warning: indexes and constraints cannot be migrated from %s to %s", me.Config.SourceDb, me.Config.TargetDb))
+		return
+	}
+
+	me.Logger.Info("Migrating indexes and constraints")
+	log.Printf("Migrating indexes and constraints...")
+
+	var failures []string
+	sourceConstraints := make(map[string]*database.TableConstraints)
+	mappedConstraints := make(map[string]*database.TableConstraints) //source constraints with target names
+	targetConstraints := make(map[string]*database.TableConstraints)
+	created := 0
+
+	for _, table := range plan.Order {
+		source, ok := plan.Constraints[table]
+		if !ok {
+			me.Logger.Info(fmt.Sprintf("Warning: indexes and constraints of table %s could not be read, skipping them", table))
+			continue
+		}
+		//columns renamed or dropped by the transformations of the table
+		source = me.transformConstraints(table, source)
+		sourceConstraints[table] = source
+		mapped := me.Config.Mapping.MapConstraints(source)
+		mappedConstraints[table] = mapped
+		targetTable := me.Config.Mapping.TargetTable(table)
+
+		target, err := writer.DescribeConstraints(targetTable)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("table %s: %v", table, err))
+			continue
+		}
+		targetConstraints[table] = target
+
+		if len(mapped.PrimaryKey) > 0 && len(target.PrimaryKey) == 0 {
+			if err := writer.AddPrimaryKey(targetTable, mapped.PrimaryKey); err != nil {
+				failures = append(failures, fmt.Sprintf("primary key of table %s: %v", table, err))
+			} else {
+				created++
+			}
+		}
+
+		for _, index := range mapped.Indexes {
+			if target.HasIndex(index) {
+				continue
+			}
+			if err := writer.AddIndex(targetTable, index); err != nil {
+				failures = append(failures, fmt.Sprintf("index %s of table %s: %v", index.Name, table, err))
+				continue
+			}
+			created++
+		}
+	}
+
+	migrated := make(map[string]bool, len(plan.Order))
+	for _, table := range plan.Order {
+		migrated[strings.ToLower(table)] = true
+	}
+
+	//foreign keys in load order, the deferred ones within cycles last
+	for _, deferred := range []bool{false, true} {
+		for _, table := range plan.Order {
+			target, ok := targetConstraints[table]
+			if !ok {
+				continue
+			}
+			for i, foreignKey := range sourceConstraints[table].ForeignKeys {
+				if plan.IsDeferred(table, foreignKey) != deferred {
+					continue
+				}
+				if !migrated[strings.ToLower(foreignKey.ReferencedTable)] {
+					me.Logger.Info(fmt.Sprintf("Warning: foreign key %s of table %s references table %s which is not migrated, skipping it", foreignKey.Name, table, foreignKey.ReferencedTable))
+					continue
+				}
+				mappedKey := mappedConstraints[table].ForeignKeys[i]
+				if target.HasForeignKey(mappedKey) {
+					continue
+				}
+				if err := writer.AddForeignKey(me.Config.Mapping.TargetTable(table), mappedKey); err != nil {
+					failures = append(failures, fmt.Sprintf("foreign key %s of table %s: %v", foreignKey.Name, table, err))
+					continue
+				}
+				created++
+			}
+		}
+	}
+
+	me.Logger.Info(fmt.Sprintf("Created %d indexes and constraints on %s", created, me.Config.TargetDb))
+	for _, failure := range failures {
+		me.Logger.Error("Constraint Migration Failed", failure)
+	}
+	if len(failures) > 0 {
+		log.Printf("Warning: failed to recreate %d indexes or constraints, rows were migrated, create them by hand", len(failures))
+	}
+	result.ConstraintFailures = append(result.ConstraintFailures, failures...)
+}
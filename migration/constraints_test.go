@@ -0,0 +1,152 @@
+package migration
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+)
+
+// mock client exposing table constraints and recording the ones created
+type constraintMockClient struct {
+	*test.CompleteMockDatabaseClient
+	constraints map[string]*database.TableConstraints
+	operations  []string
+	failOn      string
+	loaded      []string
+}
+
+func newConstraintMockClient(dbType string) *constraintMockClient {
+	return &constraintMockClient{
+		CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient(dbType),
+		constraints:                make(map[string]*database.TableConstraints),
+	}
+}
+
+func (c *constraintMockClient) DescribeConstraints(tableName string) (*database.TableConstraints, error) {
+	if constraints, ok := c.constraints[tableName]; ok {
+		return constraints, nil
+	}
+	return &database.TableConstraints{Table: tableName}, nil
+}
+
+func (c *constraintMockClient) ImportData(data []map[string]interface{}) error {
+	if len(data) > 0 {
+		if table, ok := data[0]["_source_table"].(string); ok {
+			c.loaded = append(c.loaded, table)
+		}
+	}
+	return c.CompleteMockDatabaseClient.ImportData(data)
+}
+
+func (c *constraintMockClient) record(operation string) error {
+	if operation == c.failOn {
+		return fmt.Errorf("cannot create %s", operation)
+	}
+	c.operations = append(c.operations, operation)
+	return nil
+}
+
+func (c *constraintMockClient) AddPrimaryKey(tableName string, columns []string) error {
+	return c.record("pk " + tableName)
+}
+
+func (c *constraintMockClient) AddIndex(tableName string, index database.IndexDefinition) error {
+	return c.record("index " + index.Name)
+}
+
+func (c *constraintMockClient) AddForeignKey(tableName string, foreignKey database.ForeignKeyDefinition) error {
+	return c.record("fk " + foreignKey.Name)
+}
+
+func newConstraintTestEngine(t *testing.T, tables []string) (*MigrationEngine, *constraintMockClient, *constraintMockClient) {
+	sourceClient := newConstraintMockClient("mysql")
+	targetClient := newConstraintMockClient("postgresql")
+
+	sourceClient.constraints["customers"] = &database.TableConstraints{
+		Table:      "customers",
+		PrimaryKey: []string{"id"},
+		Indexes:    []database.IndexDefinition{{Name: "uq_email", Columns: []string{"email"}, Unique: true}},
+	}
+	sourceClient.constraints["orders"] = &database.TableConstraints{
+		Table:      "orders",
+		PrimaryKey: []string{"id"},
+		Indexes:    []database.IndexDefinition{{Name: "idx_customer", Columns: []string{"customer_id"}}},
+		ForeignKeys: []database.ForeignKeyDefinition{
+			{Name: "fk_customer", Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}},
+			{Name: "fk_warehouse", Columns: []string{"warehouse_id"}, ReferencedTable: "warehouses", ReferencedColumns: []string{"id"}},
+		},
+	}
+	sourceClient.AddTestData("customers", []map[string]interface{}{{"id": 1, "email": "susheel@example.com"}})
+	sourceClient.AddTestData("orders", []map[string]interface{}{{"id": 10, "customer_id": 1, "warehouse_id": 3}})
+
+	config := MigrationConfig{
+		Mode:     FullMigration,
+		SourceDb: "mysql",
+		TargetDb: "postgresql",
+		Tables:   tables,
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	t.Cleanup(func() {
+		sourceClient.Close()
+		targetClient.Close()
+	})
+
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+	engine.RollBackManager.snapshotsDir = t.TempDir()
+	t.Cleanup(engine.Close)
+	return engine, sourceClient, targetClient
+}
+
+func TestMigrationEngineRecreatesConstraints(t *testing.T) {
+	engine, _, targetClient := newConstraintTestEngine(t, []string{"orders", "customers"})
+	//the target already has the key of customers
+	targetClient.constraints["customers"] = &database.TableConstraints{Table: "customers", PrimaryKey: []string{"id"}}
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	//keys and indexes in load order first, the foreign key to the unmigrated warehouses table is skipped
+	expected := []string{"index uq_email", "pk orders", "index idx_customer", "fk fk_customer"}
+	if !reflect.DeepEqual(targetClient.operations, expected) {
+		t.Errorf("Expected operations %v, got %v", expected, targetClient.operations)
+	}
+}
+
+func TestMigrationEngineConstraintFailure(t *testing.T) {
+	engine, _, targetClient := newConstraintTestEngine(t, []string{"customers", "orders"})
+	targetClient.failOn = "fk fk_customer"
+
+	//the rows are loaded, a failing foreign key is reported without failing the migration
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Expected failing foreign key to be reported only, got %v", err)
+	}
+	if !result.Success || len(result.ConstraintFailures) != 1 || !strings.Contains(result.ConstraintFailures[0], "fk_customer") {
+		t.Errorf("Expected the failing foreign key in the result, got %v", result.ConstraintFailures)
+	}
+	if result.TotalRowsMigrated != 2 {
+		t.Errorf("Expected rows to be loaded before constraints, got %d rows", result.TotalRowsMigrated)
+	}
+	if len(targetClient.operations) != 4 {
+		t.Errorf("Expected remaining constraints to be created, got %v", targetClient.operations)
+	}
+}
+
+func TestMigrationEngineSkipConstraints(t *testing.T) {
+	engine, _, targetClient := newConstraintTestEngine(t, []string{"customers", "orders"})
+	engine.Config.SkipConstraints = true
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+	if len(targetClient.operations) != 0 {
+		t.Errorf("Expected no constraints to be created, got %v", targetClient.operations)
+	}
+}
@@ -0,0 +1,223 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// table the dead-letter rows are written into when no name is configured
+const DefaultDeadLetterTable = "migration_dead_letters"
+
+// row that failed to import, kept with the error it failed with
+type DeadLetterRow struct {
+	RunID    string                 `json:"run_id,omitempty"`
+	Table    string                 `json:"table"`
+	Error    string                 `json:"error"`
+	Row      map[string]interface{} `json:"row"`
+	FailedAt time.Time              `json:"failed_at"`
+}
+
+// destination of the rows that failed to import
+type DeadLetterQueue interface {
+	Write(row DeadLetterRow) error
+	Close() error
+}
+
+// dead-letter queue appending one JSON document per row to a file
+type FileDeadLetterQueue struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// opening the dead-letter file at path, rows are appended to an existing file
+func NewFileDeadLetterQueue(path string) (*FileDeadLetterQueue, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create dead-letter directory %s, %v", dir, err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file %s, %v", path, err)
+	}
+	return &FileDeadLetterQueue{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// appending a row to the file (threadsafe)
+func (q *FileDeadLetterQueue) Write(row DeadLetterRow) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.encoder.Encode(row)
+}
+
+// closing the file
+func (q *FileDeadLetterQueue) Close() error {
+	return q.file.Close()
+}
+
+// dead-letter queue writing rows into a table of a database, the failed row is kept as a JSON document
+type TableDeadLetterQueue struct {
+	mu     sync.Mutex
+	client database.DatabaseClient
+	table  string
+}
+
+// creating a dead-letter queue writing into table of client
+func NewTableDeadLetterQueue(client database.DatabaseClient, table string) *TableDeadLetterQueue {
+	if table == "" {
+		table = DefaultDeadLetterTable
+	}
+	return &TableDeadLetterQueue{client: client, table: table}
+}
+
+// writing a row into the dead-letter table (threadsafe)
+func (q *TableDeadLetterQueue) Write(row DeadLetterRow) error {
+	encoded, err := json.Marshal(row.Row)
+	if err != nil {
+		return fmt.Errorf("failed to encode row, %v", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.client.ImportData([]map[string]interface{}{{
+		"_source_table": q.table,
+		"run_id":        row.RunID,
+		"source_table":  row.Table,
+		"error_message": row.Error,
+		"row_data":      string(encoded),
+		"failed_at":     row.FailedAt.Format(time.RFC3339),
+	}})
+}
+
+// the table belongs to the target client, which is closed with the migration
+func (q *TableDeadLetterQueue) Close() error {
+	return nil
+}
+
+// opening the configured dead-letter queue, without one a failing row fails the run
+func (me *MigrationEngine) openDeadLetterQueue() error {
+	me.deadLetterMu.Lock()
+	defer me.deadLetterMu.Unlock()
+	me.deadLettered = make(map[string]int64)
+
+	switch {
+	case me.Config.DeadLetterFile != "":
+		queue, err := NewFileDeadLetterQueue(me.Config.DeadLetterFile)
+		if err != nil {
+			return err
+		}
+		me.DeadLetters = queue
+		me.Logger.Info(fmt.Sprintf("Writing rows failing to import to %s, up to %d per table", me.Config.DeadLetterFile, me.Config.ErrorBudget))
+	case me.Config.DeadLetterTable != "":
+		me.DeadLetters = NewTableDeadLetterQueue(me.TargetClient, me.Config.DeadLetterTable)
+		me.Logger.Info(fmt.Sprintf("Writing rows failing to import to table %s, up to %d per table", me.Config.DeadLetterTable, me.Config.ErrorBudget))
+	}
+	return nil
+}
+
+// closing the dead-letter queue of the run
+func (me *MigrationEngine) closeDeadLetterQueue() {
+	if me.DeadLetters == nil {
+		return
+	}
+	if err := me.DeadLetters.Close(); err != nil {
+		me.Logger.Error("Failed to close dead-letter queue", err.Error())
+	}
+	me.DeadLetters = nil
+}
+
+// writing the rows of a table with write. With a dead-letter queue a batch failing with an error that is not
+// transient is bisected until the failing rows are isolated, those are dead-lettered and the rest is written.
+// A write that is not rolled back names its failed rows instead, only those are dead-lettered.
+// Returning the rows written
+func (me *MigrationEngine) writeRows(ctx context.Context, table string, rows []map[string]interface{}, write func(ctx context.Context, rows []map[string]interface{}) error) ([]map[string]interface{}, error) {
+	//every row of the batch may have been dead-lettered already
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	err := write(ctx, rows)
+	if err == nil {
+		return rows, nil
+	}
+	if me.DeadLetters == nil || database.IsTransientError(err) || ctx.Err() != nil {
+		return nil, err
+	}
+	var rowErr *database.RowWriteError
+	if errors.As(err, &rowErr) {
+		//the other rows are written already, writing them again would duplicate them
+		written := make([]map[string]interface{}, 0, len(rows)-len(rowErr.Failed))
+		for i, row := range rows {
+			cause, failed := rowErr.Failed[i]
+			if !failed {
+				written = append(written, row)
+				continue
+			}
+			if err := me.deadLetter(table, row, cause); err != nil {
+				return nil, err
+			}
+		}
+		return written, nil
+	}
+	if len(rows) == 1 {
+		return nil, me.deadLetter(table, rows[0], err)
+	}
+
+	half := len(rows) / 2
+	left, err := me.writeRows(ctx, table, rows[:half], write)
+	if err != nil {
+		return left, err
+	}
+	right, err := me.writeRows(ctx, table, rows[half:], write)
+
+	//copying, appending to left would overwrite the rows of the batch
+	written := make([]map[string]interface{}, 0, len(left)+len(right))
+	written = append(written, left...)
+	return append(written, right...), err
+}
+
+// writing a row that failed to import to the dead-letter queue, failing once the table exceeds its error budget
+func (me *MigrationEngine) deadLetter(table string, row map[string]interface{}, cause error) error {
+	me.deadLetterMu.Lock()
+	if me.deadLettered[table] >= me.Config.ErrorBudget {
+		me.deadLetterMu.Unlock()
+		return fmt.Errorf("table %s exceeded its error budget of %d failed rows, %v", table, me.Config.ErrorBudget, cause)
+	}
+	me.deadLettered[table]++
+	me.deadLetterMu.Unlock()
+
+	values := make(map[string]interface{}, len(row))
+	for col, value := range row {
+		if col != "_source_table" {
+			values[col] = value
+		}
+	}
+	deadLetter := DeadLetterRow{Table: table, Error: cause.Error(), Row: values, FailedAt: time.Now()}
+	if me.CurrentCheckpoint != nil {
+		deadLetter.RunID = me.CurrentCheckpoint.RunID
+	}
+	if err := me.DeadLetters.Write(deadLetter); err != nil {
+		return fmt.Errorf("failed to dead-letter row of table %s, %v", table, err)
+	}
+	me.ProgressTracker.AddError(fmt.Sprintf("dead-lettered row of table %s, %v", table, cause))
+	return nil
+}
+
+// returning the rows dead-lettered by the run per table
+func (me *MigrationEngine) deadLetteredRows() map[string]int64 {
+	me.deadLetterMu.Lock()
+	defer me.deadLetterMu.Unlock()
+	counts := make(map[string]int64, len(me.deadLettered))
+	for table, rows := range me.deadLettered {
+		counts[table] = rows
+	}
+	return counts
+}
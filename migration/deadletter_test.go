@@ -0,0 +1,142 @@
+package migration
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+)
+
+func TestDeadLetterQueueIsolatesFailingRows(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failOnID: 7}
+	addPipelineTestTable(sourceClient, "users", 20)
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	deadLetterFile := filepath.Join(t.TempDir(), "failed_rows.jsonl")
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"users"}, 2)
+	engine.Config.DeadLetterFile = deadLetterFile
+	engine.Config.ErrorBudget = 5
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Expected the failing row to be dead-lettered, got %v", err)
+	}
+
+	if rows := targetClient.GetImportedTableRowCount("users"); rows != 19 || result.TotalRowsMigrated != 19 {
+		t.Errorf("Expected the other 19 users to be migrated, got %d imported and %d reported", rows, result.TotalRowsMigrated)
+	}
+	if result.DeadLetteredRows != 1 {
+		t.Errorf("Expected 1 dead-lettered row, got %d", result.DeadLetteredRows)
+	}
+	if users := engine.CurrentCheckpoint.Progress["users"]; users.Status != "completed" || users.RowsCommitted != 20 {
+		t.Errorf("Expected all 20 users to be committed, got %+v", users)
+	}
+
+	file, err := os.Open(deadLetterFile)
+	if err != nil {
+		t.Fatalf("Expected the dead-letter file, %v", err)
+	}
+	defer file.Close()
+	var deadLetters []DeadLetterRow
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var row DeadLetterRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("Invalid dead-letter line %s, %v", scanner.Text(), err)
+		}
+		deadLetters = append(deadLetters, row)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("Expected 1 dead-letter line, got %d", len(deadLetters))
+	}
+	if row := deadLetters[0]; row.Table != "users" || row.Row["id"] != float64(7) || !strings.Contains(row.Error, "duplicate key 7") || row.RunID != engine.CurrentCheckpoint.RunID {
+		t.Errorf("Expected user 7 with its error, got %+v", row)
+	}
+}
+
+// target writing every row but the failing ones without rolling back, like an unordered MongoDB insert
+type unorderedTargetClient struct {
+	*test.CompleteMockDatabaseClient
+	failOnID int
+}
+
+func (c *unorderedTargetClient) ImportData(data []map[string]interface{}) error {
+	failed := make(map[int]error)
+	var written []map[string]interface{}
+	for i, row := range data {
+		if row["id"] == c.failOnID {
+			failed[i] = fmt.Errorf("duplicate key %d", c.failOnID)
+			continue
+		}
+		written = append(written, row)
+	}
+	if len(written) > 0 {
+		if err := c.CompleteMockDatabaseClient.ImportData(written); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return &database.RowWriteError{Failed: failed}
+	}
+	return nil
+}
+
+func TestDeadLetterQueueKeepsRowsOfWritesNotRolledBack(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := &unorderedTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failOnID: 7}
+	addPipelineTestTable(sourceClient, "users", 20)
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"users"}, 2)
+	engine.Config.DeadLetterFile = filepath.Join(t.TempDir(), "failed_rows.jsonl")
+	engine.Config.ErrorBudget = 5
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Expected the failing row to be dead-lettered, got %v", err)
+	}
+
+	//the batch is not bisected and written again, which would duplicate the rows written with the failing one
+	if rows := targetClient.GetImportedTableRowCount("users"); rows != 19 || result.TotalRowsMigrated != 19 || result.DeadLetteredRows != 1 {
+		t.Errorf("Expected 19 users written once and 1 dead-lettered, got %d imported, %d reported and %d dead-lettered", rows, result.TotalRowsMigrated, result.DeadLetteredRows)
+	}
+}
+
+func TestDeadLetterQueueFailsOverErrorBudget(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failOnID: 7}
+	addPipelineTestTable(sourceClient, "users", 20)
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"users"}, 2)
+	engine.Config.DeadLetterFile = filepath.Join(t.TempDir(), "failed_rows.jsonl")
+	engine.Config.ErrorBudget = 0
+	_, err := engine.ExecuteMigration()
+	if err == nil || !strings.Contains(err.Error(), "error budget") {
+		t.Fatalf("Expected the run to fail over its error budget, got %v", err)
+	}
+}
+
+func TestTableDeadLetterQueue(t *testing.T) {
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	targetClient.Connect()
+
+	queue := NewTableDeadLetterQueue(targetClient, "")
+	row := DeadLetterRow{Table: "users", Error: "value too long", Row: map[string]interface{}{"id": 7}, FailedAt: time.Now()}
+	if err := queue.Write(row); err != nil {
+		t.Fatalf("Failed to write dead-letter row, %v", err)
+	}
+
+	if rows := targetClient.GetImportedTableRowCount(DefaultDeadLetterTable); rows != 1 {
+		t.Errorf("Expected 1 row in %s, got %d", DefaultDeadLetterTable, rows)
+	}
+}
@@ -1,12 +1,20 @@
 package migration
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/database"
 	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
+	"github.com/SusheelSathyaraj/DataMigrationTool/transform"
 	"github.com/SusheelSathyaraj/DataMigrationTool/validation"
 )
 
@@ -17,6 +25,10 @@ const (
 	FullMigration        MigrationMode = "full"
 	IncrementalMigration MigrationMode = "incremental"
 	ScheduledMigration   MigrationMode = "scheduled"
+	//replicating the changes committed to the source until the run is stopped
+	ChangeDataCaptureMigration MigrationMode = "cdc"
+	//full load followed by replaying the changes committed meanwhile and a cutover with a short downtime
+	OnlineMigration MigrationMode = "online"
 )
 
 // config for migration
@@ -30,7 +42,24 @@ type MigrationConfig struct {
 	Concurrent        bool
 	ValidateData      bool
 	CreateBackup      bool
-	IncrementalColumn string //column used for incremental migration like updated_at
+	IncrementalColumn string                //column used for incremental migration like updated_at
+	KeyColumns        []string              //source columns identifying a row, used for upserts under their target names
+	WriteMode         database.WriteMode    //insert, upsert, replace or skip-existing, empty means insert
+	TypeMappings      map[string]string     //overrides of the default source to target type mappings
+	SkipConstraints   bool                  //skips recreating primary keys, indexes and foreign keys after the load
+	Schedule          string                //cron expression for scheduled migration
+	ScheduledMode     MigrationMode         //mode run on every scheduled tick, full or incremental
+	ResumeRunID       string                //run id of a failed full migration to continue from its checkpoint
+	ChunkRows         int64                 //tables holding more rows are split into key ranges read concurrently, 0 disables splitting
+	DeadLetterFile    string                //JSONL file receiving rows that fail to import instead of failing the run
+	DeadLetterTable   string                //target table receiving rows that fail to import instead of failing the run
+	ErrorBudget       int64                 //rows per table that may be dead-lettered before the run fails
+	Mapping           *database.NameMapping //target names of the source tables and columns, nil keeps the source names
+	Transforms        transform.Tables      //transformations applied to the rows of each table before they are written
+	Retry             database.RetryPolicy  //retries of batch reads and writes failing with a transient error, zero attempts means the default policy
+	ChangePosition    string                //source position change data capture starts from, overrides the saved position
+	MaxLag            time.Duration         //online migrations cut over once the target lags less behind the source, 0 means the default
+	CutoverReadOnly   bool                  //online migrations set the source read-only for the cutover instead of waiting for confirmation
 }
 
 // Migration process keeper
@@ -42,7 +71,24 @@ type MigrationEngine struct {
 	ProgressTracker *monitoring.ProcessTracker
 	Logger          *monitoring.MigrationLogger
 	RollBackManager *RollBackManager
-	CurrentSnapshot *MigrationSnapshot
+	WatermarkStore  *WatermarkStore
+	CheckpointStore *CheckpointStore
+	//position of the last change applied by change data capture
+	ChangePositionStore *ChangePositionStore
+	CurrentSnapshot     *MigrationSnapshot
+	//checkpoint of the running full migration, kept after a failure for resuming
+	CurrentCheckpoint *MigrationCheckpoint
+	checkpointMu      sync.Mutex
+	//queue of the rows failing to import, nil unless a dead-letter file or table is configured
+	DeadLetters  DeadLetterQueue
+	deadLetterMu sync.Mutex
+	deadLettered map[string]int64 //rows dead-lettered per table by the running migration
+	//blocking until the writes to the source are stopped during the cutover of an online migration, unless the
+	//source is set read-only. Nil cuts over right away, assuming the writes already stopped
+	ConfirmCutover func(ctx context.Context) error
+	//directory of the snapshots and state files, a temp dir removed by Close unless set with SetStateDir
+	stateDir     string
+	tempStateDir bool
 }
 
 // Results of the migration
@@ -56,28 +102,85 @@ type MigrationResult struct {
 	Errors               []string
 	StartTime            time.Time
 	EndTime              time.Time
+	Retries              int64         //batch reads and writes retried after a transient error
+	DeadLetteredRows     int64         //rows that failed to import and were written to the dead-letter queue
+	DowntimeStart        time.Time     //writes to the source stopped for the cutover of an online migration
+	DowntimeEnd          time.Time     //the target was caught up and validated, applications may switch to it
+	Downtime             time.Duration //window between DowntimeStart and DowntimeEnd
+	ConstraintFailures   []string      //indexes and constraints that could not be recreated, the rows are migrated nonetheless
 }
 
 // creating a new migration engine
 func NewMigrationEngine(config MigrationConfig, source, target database.DatabaseClient) *MigrationEngine {
-	//initialising with estimated row count(will be updated during validation)
-	progressTracker := monitoring.NewProgressTracker(0, len(config.Tables))
 	logger := monitoring.NewMigrationLogger()
 	rollbackManager := NewRollBackManager(target, logger)
 
-	return &MigrationEngine{
+	engine := &MigrationEngine{
 		Config:          config,
 		SourceClient:    source,
 		TargetClient:    target,
 		Validator:       validation.NewMigrationValidator(source, target),
-		ProgressTracker: progressTracker,
 		Logger:          logger,
 		RollBackManager: rollbackManager,
 	}
+	//initialising with estimated row count(will be updated during validation)
+	engine.setProgressTracker(monitoring.NewProgressTracker(0, len(config.Tables)))
+	engine.SetStateDir(defaultStateDir())
+	engine.tempStateDir = true
+	return engine
+}
+
+// replacing the progress tracker, clients reporting the batches they write are given the new one
+func (me *MigrationEngine) setProgressTracker(tracker *monitoring.ProcessTracker) {
+	me.ProgressTracker = tracker
+	for _, client := range []database.DatabaseClient{me.SourceClient, me.TargetClient} {
+		if progressClient, ok := client.(database.ProgressClient); ok {
+			progressClient.SetProgressTracker(tracker)
+		}
+	}
+}
+
+var stateDirCount atomic.Int64
+
+// naming a temp dir of its own for the state of an engine, it is only created once a state file is written
+func defaultStateDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("migration_state_%d_%d_%d", os.Getpid(), time.Now().UnixNano(), stateDirCount.Add(1)))
+}
+
+// keeping the snapshots and the checkpoint, watermark and change position files of the engine in dir
+func (me *MigrationEngine) SetStateDir(dir string) {
+	me.stateDir, me.tempStateDir = dir, false
+	me.RollBackManager.snapshotsDir = dir
+	me.WatermarkStore = NewWatermarkStore(dir)
+	me.CheckpointStore = NewCheckpointStore(dir)
+	me.ChangePositionStore = NewChangePositionStore(dir)
+}
+
+var stateKeyUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// naming the source and the target in the state files by their type and, when the client tells, the database
+// they connect to, so runs between other databases of the same types keep their own watermarks and positions
+func (me *MigrationEngine) stateKeys() (string, string) {
+	return stateKey(me.Config.SourceDb, me.SourceClient), stateKey(me.Config.TargetDb, me.TargetClient)
+}
+
+// naming a database by its type and location, eg. mysql_db.example.com-3306-shop
+func stateKey(dbType string, client database.DatabaseClient) string {
+	located, ok := client.(database.LocationClient)
+	if !ok || located.Location() == "" {
+		return dbType
+	}
+	return dbType + "_" + strings.Trim(stateKeyUnsafe.ReplaceAllString(located.Location(), "-"), "-")
 }
 
 // running the complete migration logic
 func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
+	return me.ExecuteMigrationContext(context.Background())
+}
+
+// executing the migration until it is done or ctx is cancelled, a cancelled run stops its in-flight
+// reads and writes and marks its snapshot and checkpoint as interrupted
+func (me *MigrationEngine) ExecuteMigrationContext(ctx context.Context) (*MigrationResult, error) {
 	startTime := time.Now()
 
 	result := &MigrationResult{
@@ -85,6 +188,35 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 		Errors:    make([]string, 0),
 	}
 
+	//resetting per run state, the engine is reused by scheduled migrations
+	me.CurrentSnapshot = nil
+	me.CurrentCheckpoint = nil
+
+	//a resumed run continues with the tables of the failed run
+	if me.Config.ResumeRunID != "" {
+		checkpoint, err := me.loadResumeCheckpoint()
+		if err != nil {
+			return result, err
+		}
+		me.Config.Tables = checkpoint.Tables
+		me.CurrentCheckpoint = checkpoint
+	}
+	me.setProgressTracker(monitoring.NewProgressTracker(0, len(me.Config.Tables)))
+	me.Validator.Mapping = me.Config.Mapping
+	me.Validator.Transforms = me.Config.Transforms
+	defer func() {
+		result.Retries = me.ProgressTracker.GetMetrics().Retries
+		for _, rows := range me.deadLetteredRows() {
+			result.DeadLetteredRows += rows
+		}
+	}()
+
+	//rows failing to import are dead-lettered instead of failing the run when a queue is configured
+	if err := me.openDeadLetterQueue(); err != nil {
+		return result, err
+	}
+	defer me.closeDeadLetterQueue()
+
 	me.Logger.Info(fmt.Sprintf("Starting %s migration from %s to %s", me.Config.Mode, me.Config.SourceDb, me.Config.TargetDb))
 	log.Printf("Starting %s migation from %s to %s", me.Config.Mode, me.Config.SourceDb, me.Config.TargetDb)
 
@@ -101,7 +233,7 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 	}
 
 	//Step1: Premigration  validation
-	if me.Config.ValidateData {
+	if me.validatesData() {
 		me.Logger.Info("Starting Pre-Migration Validation")
 		preValidation, err := me.Validator.PreMigrationValidation(me.Config.Tables)
 		if err != nil {
@@ -118,7 +250,7 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 		for _, validation := range preValidation {
 			totalRows += validation.RowCount
 		}
-		me.ProgressTracker = monitoring.NewProgressTracker(totalRows, len(me.Config.Tables))
+		me.setProgressTracker(monitoring.NewProgressTracker(totalRows, len(me.Config.Tables)))
 
 		preValidationSummary := validation.GenerateValidationSummary(preValidation, startTime)
 		preValidationSummary.Print("Pre-Migration")
@@ -139,18 +271,21 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 	defer func() {
 		stopProgress <- struct{}{}
 		me.ProgressTracker.PrintFinalSummary()
-		me.Logger.Close()
 	}()
 
 	//Step2: Execute Migration depending on mode
 	var migrationErr error
 	switch me.Config.Mode {
 	case FullMigration:
-		migrationErr = me.executeFullMigration(result)
+		migrationErr = me.executeFullMigration(ctx, result)
 	case IncrementalMigration:
-		migrationErr = me.executeIncrementalMigration(result)
+		migrationErr = me.executeIncrementalMigration(ctx, result)
 	case ScheduledMigration:
-		migrationErr = me.executeScheduledMigration(result)
+		migrationErr = me.executeScheduledMigration(ctx, result)
+	case ChangeDataCaptureMigration:
+		migrationErr = me.executeChangeDataCapture(ctx, result)
+	case OnlineMigration:
+		migrationErr = me.executeOnlineMigration(ctx, result)
 	default:
 		return result, fmt.Errorf("unsupported migration mode %s", me.Config.Mode)
 	}
@@ -159,17 +294,21 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 		me.Logger.Error("Migration Failed", migrationErr.Error())
 		result.Errors = append(result.Errors, migrationErr.Error())
 
-		//marking snapshot as failed
+		//marking snapshot as failed, or as interrupted when the run was cancelled
 		if me.CurrentSnapshot != nil {
-			me.RollBackManager.MarkSnapshotFailed(me.CurrentSnapshot.ID)
+			if ctx.Err() != nil {
+				me.RollBackManager.MarkSnapshotInterrupted(me.CurrentSnapshot.ID)
+			} else {
+				me.RollBackManager.MarkSnapshotFailed(me.CurrentSnapshot.ID)
+			}
 		}
 		return result, migrationErr
 	}
 
-	//Step3: Post-Migration Validation
-	if me.Config.ValidateData {
+	//Step3: Post-Migration Validation, online migrations validate during the cutover instead
+	if me.validatesData() && me.Config.Mode != OnlineMigration {
 		me.Logger.Info("Starting Post-Migration Validation")
-		postValidation, err := me.Validator.PostMigationValidation(me.Config.Tables, result.PreValidation)
+		postValidation, err := me.Validator.PostMigationValidation(me.Config.Tables, me.expectedRowCounts(result.PreValidation))
 		if err != nil {
 			me.Logger.Error("Post-Migration VAlidation error", err.Error())
 			result.Errors = append(result.Errors, fmt.Sprintf("post migration validation error , %v", err))
@@ -180,6 +319,7 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 				me.Logger.Info("Attempting automatic rollback due to vaildation failure")
 				if rollbackErr := me.RollBackManager.RollBackMigration(me.CurrentSnapshot.ID); rollbackErr != nil {
 					me.Logger.Error("Automatic rollback failed", rollbackErr.Error())
+					result.Errors = append(result.Errors, fmt.Sprintf("automatic rollback failed, %v", rollbackErr))
 				}
 			}
 			return result, fmt.Errorf("post Migration Validation Failed, %v", err)
@@ -200,6 +340,7 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 				me.Logger.Info("Attempting automatic rollbackdue to validation failure")
 				if rollbackErr := me.RollBackManager.RollBackMigration(me.CurrentSnapshot.ID); rollbackErr != nil {
 					me.Logger.Error("Automatic rollback failed", rollbackErr.Error())
+					result.Errors = append(result.Errors, fmt.Sprintf("automatic rollback failed, %v", rollbackErr))
 				}
 			}
 			return result, fmt.Errorf("migration validation failed for %d tables", postValidationSummary.InvalidTables)
@@ -226,106 +367,519 @@ func (me *MigrationEngine) ExecuteMigration() (*MigrationResult, error) {
 }
 
 // performs a complete full data migration
-func (me *MigrationEngine) executeFullMigration(result *MigrationResult) error {
+func (me *MigrationEngine) executeFullMigration(ctx context.Context, result *MigrationResult) error {
 	me.Logger.Info("Executing Full Migration")
 	log.Printf("Executing Full Migration...")
 
-	//processing tables individually for better tracking
-	for i, table := range me.Config.Tables {
-		me.ProgressTracker.SetCurrentTable(table)
-		me.Logger.TableProgress(table, 0, "Starting table Migration")
+	plan := me.planMigration()
 
-		//fetching data from current table
-		var tableData []map[string]interface{}
-		var err error
+	checkpoint := me.CurrentCheckpoint
+	if checkpoint == nil {
+		checkpoint = NewMigrationCheckpoint(me.Config)
+		me.CurrentCheckpoint = checkpoint
+	} else {
+		me.Logger.Info(fmt.Sprintf("Resuming run %s", checkpoint.RunID))
+		checkpoint.Status = "in_progress"
+	}
+	me.Logger.Info(fmt.Sprintf("Checkpointing run %s, a failed run can be continued with --resume=%s", checkpoint.RunID, checkpoint.RunID))
+
+	for _, table := range plan.Order {
+		if progress := checkpoint.Progress[table]; progress.Status == "completed" {
+			me.ProgressTracker.CompletedTable()
+			me.Logger.TableProgress(table, progress.CommittedRows(), "Table already migrated by the resumed run, skipping it")
+		} else if progress.Status == "pending" && len(progress.Chunks) == 0 {
+			progress.KeyColumn = me.checkpointKeyColumn(table, plan)
+			me.splitTable(table, progress)
+		}
+	}
 
-		if me.Config.Concurrent && len(me.Config.Tables) > 1 {
-			tableData, err = me.SourceClient.FetchAllDataConcurrently([]string{table}, 1)
-		} else {
-			tableData, err = me.SourceClient.FetchAllData([]string{table})
+	//loading several tables at once, a table starts once the tables it references are loaded
+	rows, err := newLoadPipeline(ctx, me, plan, checkpoint).run()
+	result.TotalRowsMigrated += rows
+	if err != nil {
+		me.ProgressTracker.AddError(err.Error())
+		checkpoint.Status = "failed"
+		if ctx.Err() != nil {
+			checkpoint.Status = "interrupted"
 		}
+		me.saveCheckpoint()
+		return err
+	}
 
-		if err != nil {
-			errorMsg := fmt.Sprintf("failed to fetch data from table %s, %v", table, err)
-			me.Logger.Error("Table Fetching Failed", errorMsg)
-			me.ProgressTracker.AddError(errorMsg)
-			return fmt.Errorf(errorMsg)
-		}
-
-		tableRowCount := int64(len(tableData))
-		me.Logger.TableProgress(table, tableRowCount, fmt.Sprintf("Fetched %d rows ", tableRowCount))
-
-		//validating data types before migration
-		if me.Config.ValidateData && len(tableData) > 0 {
-			if err := me.Validator.ValidateDataTypes(tableData); err != nil {
-				errorMsg := fmt.Sprintf("data type validation failed for table %s, %v", table, err)
-				me.Logger.Error("Data Type Validation Failed", errorMsg)
-				me.ProgressTracker.AddError(errorMsg)
-				return fmt.Errorf(errorMsg)
+	me.migrateConstraints(result, plan)
+
+	//a completed run has nothing left to resume
+	checkpoint.Status = "completed"
+	if err := me.CheckpointStore.Remove(checkpoint.RunID); err != nil {
+		me.Logger.Error("Failed to remove checkpoint", err.Error())
+	}
+
+	me.Logger.Info(fmt.Sprintf("Full Migration Completed -%d rows migrated", result.TotalRowsMigrated))
+	log.Printf("Successfully Migrated %d rows across %d tables", result.TotalRowsMigrated, len(me.Config.Tables))
+
+	return nil
+}
+
+// splitting a table into key ranges read concurrently when it holds more than ChunkRows rows,
+// each range keeps its own checkpoint
+func (me *MigrationEngine) splitTable(table string, progress *TableCheckpoint) {
+	ranges, ok := me.SourceClient.(database.RangeClient)
+	if !ok || me.Config.ChunkRows <= 0 || !me.Config.Concurrent || me.Config.Workers <= 1 {
+		return
+	}
+
+	keyRanges, err := ranges.SplitTable(table, me.Config.ChunkRows)
+	if err != nil {
+		me.Logger.Info(fmt.Sprintf("Warning: could not split table %s into key ranges, reading it as a whole, %v", table, err))
+		return
+	}
+	if len(keyRanges) < 2 {
+		return
+	}
+
+	for i := range keyRanges {
+		chunk := &TableCheckpoint{Status: "pending", Range: &keyRanges[i]}
+		if keyRanges[i].IsKeyed() {
+			chunk.KeyColumn = keyRanges[i].Column
+		}
+		progress.Chunks = append(progress.Chunks, chunk)
+	}
+	me.Logger.Info(fmt.Sprintf("Split table %s into %d ranges of %s", table, len(keyRanges), keyRanges[0].Column))
+}
+
+// opening the source rows of a table or key range, a resumed table continues after its last committed key or
+// skips the rows it already committed
+func (me *MigrationEngine) openTableIterator(ctx context.Context, table string, progress *TableCheckpoint, batchSize int) (database.RowIterator, int64, error) {
+	if progress.Range != nil {
+		ranges, ok := me.SourceClient.(database.RangeClient)
+		if !ok {
+			return nil, 0, fmt.Errorf("source database %s cannot read key ranges", me.Config.SourceDb)
+		}
+		var lastKey interface{}
+		skip := progress.RowsCommitted
+		if progress.LastKey != nil {
+			decoded, err := progress.LastKey.Decode()
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid checkpoint key for table %s, %v", table, err)
+			}
+			lastKey, skip = decoded, 0
+		}
+		iterator, err := ranges.StreamTableRange(ctx, table, *progress.Range, lastKey, batchSize)
+		return iterator, skip, err
+	}
+
+	if keyset, ok := me.SourceClient.(database.KeysetClient); ok && progress.KeyColumn != "" {
+		var lastKey interface{}
+		skip := progress.RowsCommitted
+		if progress.LastKey != nil {
+			decoded, err := progress.LastKey.Decode()
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid checkpoint key for table %s, %v", table, err)
 			}
+			lastKey, skip = decoded, 0
 		}
+		iterator, err := keyset.StreamTableAfter(ctx, table, progress.KeyColumn, lastKey, batchSize)
+		return iterator, skip, err
+	}
+
+	if progress.RowsCommitted > 0 {
+		me.Logger.Info(fmt.Sprintf("Warning: table %s has no single column key, skipping its first %d rows assumes the source returns them in the same order", table, progress.RowsCommitted))
+	}
+	iterator, err := database.OpenRowIterator(ctx, me.SourceClient, table, batchSize)
+	return iterator, progress.RowsCommitted, err
+}
+
+// returning the column a table is read in order of, so its checkpoint can hold the last committed key
+func (me *MigrationEngine) checkpointKeyColumn(table string, plan *MigrationPlan) string {
+	if _, ok := me.SourceClient.(database.KeysetClient); !ok {
+		return ""
+	}
+	constraints := plan.Constraints[table]
+	if constraints == nil || len(constraints.PrimaryKey) != 1 {
+		return ""
+	}
+	return constraints.PrimaryKey[0]
+}
 
-		//importing data to target database with batch tracking
-		if me.Config.Concurrent && len(tableData) > me.Config.BatchSize {
-			me.Logger.TableProgress(table, tableRowCount, fmt.Sprintf("Starting Concurrent import with batchsize %d", me.Config.BatchSize))
+// recording a committed batch in the table's checkpoint
+func (me *MigrationEngine) commitBatch(progress *TableCheckpoint, batch []map[string]interface{}) {
+	me.checkpointMu.Lock()
+	defer me.checkpointMu.Unlock()
 
-			//creating batch tracker for this table
-			batchTracker := me.ProgressTracker.NewBatchTracker(me.Config.BatchSize)
+	progress.Status = "in_progress"
+	progress.RowsCommitted += int64(len(batch))
+	progress.Batches++
+	progress.UpdatedAt = time.Now()
 
-			//overriding the import to track batched
-			err = me.importDataWithBatchTracking(tableData, batchTracker)
+	if progress.KeyColumn != "" {
+		lastKey, err := EncodeWatermark(progress.KeyColumn, batch[len(batch)-1][progress.KeyColumn])
+		if err != nil {
+			//the committed row count still allows resuming from the start of the ordered table
+			progress.LastKey = nil
 		} else {
-			me.Logger.TableProgress(table, tableRowCount, "Starting Sequential Import")
-			err = me.TargetClient.ImportData(tableData)
-			me.ProgressTracker.UpdateProgress(tableRowCount)
+			progress.LastKey = &lastKey
+		}
+	}
+	me.saveCheckpoint()
+}
+
+// marking a table or key range whose rows are all committed as completed in the checkpoint
+func (me *MigrationEngine) completeCheckpoint(progress *TableCheckpoint) {
+	me.checkpointMu.Lock()
+	defer me.checkpointMu.Unlock()
+
+	progress.Status = "completed"
+	me.saveCheckpoint()
+}
+
+// saving the checkpoint of the current run, a failed save only costs the ability to resume
+func (me *MigrationEngine) saveCheckpoint() {
+	if me.CurrentCheckpoint == nil {
+		return
+	}
+	if err := me.CheckpointStore.Save(me.CurrentCheckpoint); err != nil {
+		me.Logger.Error("Failed to save checkpoint", err.Error())
+	}
+}
+
+// loading the checkpoint of the run to resume and checking it belongs to this migration
+func (me *MigrationEngine) loadResumeCheckpoint() (*MigrationCheckpoint, error) {
+	if me.Config.Mode != FullMigration {
+		return nil, fmt.Errorf("only full migrations can be resumed, got %s mode", me.Config.Mode)
+	}
+	checkpoint, err := me.CheckpointStore.Load(me.Config.ResumeRunID)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.SourceDB != me.Config.SourceDb || checkpoint.TargetDB != me.Config.TargetDb {
+		return nil, fmt.Errorf("run %s migrated from %s to %s, not from %s to %s", checkpoint.RunID, checkpoint.SourceDB, checkpoint.TargetDB, me.Config.SourceDb, me.Config.TargetDb)
+	}
+	return checkpoint, nil
+}
+
+// removing the checkpoint of the current run, used once its rows were rolled back
+func (me *MigrationEngine) DiscardCheckpoint() {
+	if me.CurrentCheckpoint == nil {
+		return
+	}
+	if err := me.CheckpointStore.Remove(me.CurrentCheckpoint.RunID); err != nil {
+		me.Logger.Error("Failed to remove checkpoint", err.Error())
+	}
+	me.CurrentCheckpoint = nil
+}
+
+// building the load order of the tables from the foreign keys of the source
+func (me *MigrationEngine) planMigration() *MigrationPlan {
+	plan := BuildMigrationPlan(me.SourceClient, me.Config.Tables)
+	me.Logger.Info(fmt.Sprintf("Load order: %s", strings.Join(plan.Order, ", ")))
+	for _, cycle := range plan.Cycles {
+		me.Logger.Info(fmt.Sprintf("Warning: tables %s reference each other, their foreign keys are created after all of them are loaded", strings.Join(cycle, ", ")))
+	}
+	return plan
+}
+
+// creating the target table from the translated source schema when both databases support it,
+// otherwise the target infers the columns from the first rows it imports
+func (me *MigrationEngine) prepareTargetTable(table string) error {
+	reader, sourceOk := me.SourceClient.(database.SchemaClient)
+	writer, targetOk := me.TargetClient.(database.SchemaClient)
+	if !sourceOk || !targetOk {
+		return nil
+	}
+
+	mapper, err := database.NewTypeMapper(me.Config.SourceDb, me.Config.TargetDb, me.Config.TypeMappings)
+	if err != nil {
+		me.Logger.Info(fmt.Sprintf("Warning: %v, inferring column types of table %s from its rows", err, table))
+		return nil
+	}
+
+	schema, err := reader.DescribeTable(table)
+	if err != nil {
+		me.Logger.Info(fmt.Sprintf("Warning: could not read schema of table %s, inferring column types from its rows, %v", table, err))
+		return nil
+	}
+
+	translated, err := database.TranslateSchema(schema, mapper)
+	if err != nil {
+		return fmt.Errorf("failed to translate schema of table %s, %v", table, err)
+	}
+	//creating the columns the rows have once they are transformed, under their target names
+	translated = me.Config.Mapping.MapSchema(me.Config.Transforms.For(table).TransformSchema(translated))
+	if err := writer.CreateTable(translated); err != nil {
+		errorMsg := fmt.Sprintf("failed to create target table %s, %v", table, err)
+		me.Logger.Error("Table Creation Failed", errorMsg)
+		return fmt.Errorf(errorMsg)
+	}
+	me.Logger.Info(fmt.Sprintf("Created table %s from the %s schema with %d columns", translated.Name, me.Config.SourceDb, len(translated.Columns)))
+	return nil
+}
+
+// writing a batch into the target with the configured write mode, inserts are cancelled with ctx
+// when the target supports it
+func (me *MigrationEngine) writeBatch(ctx context.Context, table string, batch []map[string]interface{}) error {
+	//SQL imports and writes run in their own transaction rolled back on failure, so they can be run again.
+	//MongoDB inserts are not transactional, documents keep the _id given on the first attempt and the ones
+	//already written are skipped as duplicates
+	return me.retry(ctx, fmt.Sprintf("writing a batch of %d rows", len(batch)), func() error {
+		return me.writeBatchOnce(ctx, table, batch)
+	})
+}
+
+// writing a batch of a source table once without retrying
+func (me *MigrationEngine) writeBatchOnce(ctx context.Context, table string, batch []map[string]interface{}) error {
+	if me.Config.WriteMode == "" || me.Config.WriteMode == database.WriteModeInsert {
+		if importer, ok := me.TargetClient.(database.ContextClient); ok {
+			return importer.ImportDataContext(ctx, batch)
 		}
+		return me.TargetClient.ImportData(batch)
+	}
 
+	writer, ok := me.TargetClient.(database.WriteModeClient)
+	if !ok {
+		return fmt.Errorf("target database %s does not support write mode %s", me.Config.TargetDb, me.Config.WriteMode)
+	}
+	return writer.WriteData(batch, me.Config.WriteMode, me.keyColumns(table))
+}
+
+// returning the configured retry policy or the default one
+func (me *MigrationEngine) retryPolicy() database.RetryPolicy {
+	if me.Config.Retry.MaxAttempts <= 0 {
+		return database.DefaultRetryPolicy()
+	}
+	return me.Config.Retry
+}
+
+// running operation with the configured retry policy, counting and logging every retry after a transient error
+func (me *MigrationEngine) retry(ctx context.Context, description string, operation func() error) error {
+	policy := me.retryPolicy()
+	return policy.Do(ctx, operation, func(retry int, err error, wait time.Duration) {
+		me.ProgressTracker.AddRetry()
+		me.Logger.Info(fmt.Sprintf("Warning: %s failed with a transient error, retry %d of %d in %v, %v", description, retry, policy.MaxAttempts-1, wait, err))
+	})
+}
+
+// returning the source row counts expected in the target, without the rows dead-lettered by the run
+func (me *MigrationEngine) expectedRowCounts(preValidation []validation.ValidationResult) []validation.ValidationResult {
+	deadLettered := me.deadLetteredRows()
+	expected := make([]validation.ValidationResult, len(preValidation))
+	for i, result := range preValidation {
+		result.RowCount -= deadLettered[result.TableName]
+		expected[i] = result
+	}
+	return expected
+}
+
+// reporting whether the run validates row counts before and after, change data capture copies no fixed set of rows
+func (me *MigrationEngine) validatesData() bool {
+	return me.Config.ValidateData && me.Config.Mode != ChangeDataCaptureMigration
+}
+
+// returning the configured batch size or the default one
+func (me *MigrationEngine) batchSize() int {
+	if me.Config.BatchSize <= 0 {
+		return database.DefaultStreamBatchSize
+	}
+	return me.Config.BatchSize
+}
+
+// performing incremental migration of rows whose watermark column reached the last persisted high-water mark
+func (me *MigrationEngine) executeIncrementalMigration(ctx context.Context, result *MigrationResult) error {
+	me.Logger.Info("Executing Incremental Migration")
+	log.Println("Executing incremental migration...")
+
+	column := me.Config.IncrementalColumn
+	if column == "" {
+		return fmt.Errorf("incremental migration requires an incremental column, eg. updated_at")
+	}
+
+	upserter, ok := me.TargetClient.(database.UpsertClient)
+	if !ok {
+		return fmt.Errorf("target database %s does not support upserts needed for incremental migration", me.Config.TargetDb)
+	}
+
+	sourceKey, targetKey := me.stateKeys()
+	watermarks, err := me.WatermarkStore.Load(sourceKey, targetKey)
+	if err != nil {
+		return fmt.Errorf("failed to load watermarks, %v", err)
+	}
+
+	plan := me.planMigration()
+
+	for i, table := range plan.Order {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("incremental migration interrupted before table %s, %v", table, err)
+		}
+		me.ProgressTracker.SetCurrentTable(table)
+
+		//decoding the last high-water mark, a changed column means starting over for this table
+		var lastMark interface{}
+		if stored, exists := watermarks[table]; exists && stored.Column == column {
+			lastMark, err = stored.Decode()
+			if err != nil {
+				return fmt.Errorf("invalid watermark for table %s, %v", table, err)
+			}
+		}
+		me.Logger.TableProgress(table, 0, fmt.Sprintf("Starting incremental migration from %s >= %v", column, lastMark))
+
+		tableRowCount, newMark, err := me.migrateTableIncrementally(ctx, table, column, lastMark, upserter)
 		if err != nil {
-			errorMsg := fmt.Sprintf("failed to import data for table %s, %v", table, err)
-			me.Logger.Error("Table Import Failed", errorMsg)
-			me.ProgressTracker.AddError(errorMsg)
-			return fmt.Errorf(errorMsg)
+			me.ProgressTracker.AddError(err.Error())
+			return err
 		}
 
-		//updating rollback snapshot with migrated data
-		if me.CurrentSnapshot != nil {
-			if err := me.RollBackManager.UpdateSnapshotWithMigratedData(me.CurrentSnapshot.ID, tableData); err != nil {
-				me.Logger.Error("Failed to update rollback snapshot", err.Error())
-				//continue migration but log the error
+		//persisting the new watermark only once the whole table is upserted
+		if newMark != nil {
+			encoded, err := EncodeWatermark(column, newMark)
+			if err != nil {
+				return fmt.Errorf("failed to encode watermark for table %s, %v", table, err)
+			}
+			watermarks[table] = encoded
+			if err := me.WatermarkStore.Save(sourceKey, targetKey, watermarks); err != nil {
+				return fmt.Errorf("failed to save watermark for table %s, %v", table, err)
 			}
 		}
 
 		me.ProgressTracker.CompletedTable()
-		me.Logger.TableProgress(table, tableRowCount, "Table Migration Completed Successfully")
+		me.Logger.TableProgress(table, tableRowCount, fmt.Sprintf("Incremental Migration Completed, watermark %s = %v", column, newMark))
 		result.TotalRowsMigrated += tableRowCount
 
-		log.Printf("Successfully migrated table %s (%d/%d) with %d rows", table, i+1, len(me.Config.Tables), tableRowCount)
+		log.Printf("Successfully migrated changes of table %s (%d/%d) with %d rows", table, i+1, len(me.Config.Tables), tableRowCount)
 	}
 
-	me.Logger.Info(fmt.Sprintf("Full Migration Completed -%d rows migrated", result.TotalRowsMigrated))
-	log.Printf("Successfully Migrated %d rows across %d tables", result.TotalRowsMigrated, len(me.Config.Tables))
-
+	me.Logger.Info(fmt.Sprintf("Incremental Migration Completed -%d rows migrated", result.TotalRowsMigrated))
 	return nil
 }
 
-// performing incremental data migration(placeholder)
-func (me *MigrationEngine) executeIncrementalMigration(result *MigrationResult) error {
-	log.Println("Executing 	incremental migration...")
-	//TODO: implement incremental migration logic
-	//1.identify changed records since last migration
-	//2.fetching only the delta data
-	//3.performing upsert operations on target
-	return fmt.Errorf("incremental migration not implemented")
+// upserting the rows of a table changed since lastMark, returning the new high-water mark
+func (me *MigrationEngine) migrateTableIncrementally(ctx context.Context, table, column string, lastMark interface{}, upserter database.UpsertClient) (int64, interface{}, error) {
+	if err := me.prepareTargetTable(table); err != nil {
+		return 0, nil, err
+	}
+
+	batchSize := me.batchSize()
+
+	var iterator database.RowIterator
+	err := me.retry(ctx, fmt.Sprintf("reading changed rows of table %s", table), func() error {
+		var err error
+		iterator, err = database.OpenIncrementalRowIterator(ctx, me.SourceClient, table, column, lastMark, batchSize)
+		return err
+	})
+	if err != nil {
+		errorMsg := fmt.Sprintf("failed to fetch changed rows from table %s, %v", table, err)
+		me.Logger.Error("Table Fetching Failed", errorMsg)
+		return 0, nil, fmt.Errorf(errorMsg)
+	}
+	defer iterator.Close()
+
+	batchTracker := me.ProgressTracker.NewBatchTracker(batchSize)
+	keyColumns := me.keyColumns(table)
+
+	var tableRowCount int64
+	var newMark interface{}
+	batchNumber := 0
+	for iterator.Next() {
+		//the watermark is only saved once the whole table is upserted, so an interrupted table is read again next run
+		if err := ctx.Err(); err != nil {
+			return tableRowCount, nil, fmt.Errorf("incremental migration of table %s interrupted after %d rows, %v", table, tableRowCount, err)
+		}
+		batch := iterator.Batch()
+		batchNumber++
+
+		//tracking the highest watermark seen so far
+		for _, row := range batch {
+			value := row[column]
+			if value == nil {
+				continue
+			}
+			if newMark == nil {
+				newMark = value
+				continue
+			}
+			cmp, err := database.CompareWatermarks(value, newMark)
+			if err != nil {
+				return tableRowCount, nil, fmt.Errorf("failed to compare watermark in table %s, %v", table, err)
+			}
+			if cmp > 0 {
+				newMark = value
+			}
+		}
+
+		batchTracker.StartBatch(batchNumber)
+		upsert := func(ctx context.Context, rows []map[string]interface{}) error {
+			return me.retry(ctx, fmt.Sprintf("upserting batch %d of table %s", batchNumber, table), func() error {
+				return upserter.UpsertData(rows, keyColumns)
+			})
+		}
+		transformed, err := me.transformRows(table, batch)
+		if err != nil {
+			errorMsg := fmt.Sprintf("failed to transform data for table %s, batch %d, %v", table, batchNumber, err)
+			me.Logger.Error("Table Transform Failed", errorMsg)
+			return tableRowCount, nil, fmt.Errorf(errorMsg)
+		}
+		written, err := me.writeRows(ctx, table, transformed, upsert)
+		if err != nil {
+			errorMsg := fmt.Sprintf("failed to upsert data for table %s, batch %d, %v", table, batchNumber, err)
+			me.Logger.Error("Table Upsert Failed", errorMsg)
+			return tableRowCount, nil, fmt.Errorf(errorMsg)
+		}
+		batchTracker.CompleteBatch(int64(len(written)))
+		tableRowCount += int64(len(written))
+	}
+
+	if err := iterator.Err(); err != nil {
+		errorMsg := fmt.Sprintf("failed to fetch changed rows from table %s after %d rows, %v", table, tableRowCount, err)
+		me.Logger.Error("Table Fetching Failed", errorMsg)
+		return tableRowCount, nil, fmt.Errorf(errorMsg)
+	}
+
+	//keeping the previous watermark when nothing changed
+	if newMark == nil {
+		newMark = lastMark
+	}
+	return tableRowCount, newMark, nil
+}
+
+// returning the key columns of a source table under the names they have in the written rows. The configured
+// columns, or the usual primary key of the source, are renamed by the transformations and the mapping
+func (me *MigrationEngine) keyColumns(table string) []string {
+	keyColumns := me.Config.KeyColumns
+	if len(keyColumns) == 0 {
+		keyColumns = []string{"id"}
+		if strings.EqualFold(me.Config.SourceDb, "mongodb") {
+			keyColumns = []string{"_id"}
+		}
+	}
+	//a dropped key column keeps its name, writing by it fails with the missing column
+	if renamed, ok := me.Config.Transforms.For(table).RenameColumns(keyColumns); ok {
+		keyColumns = renamed
+	}
+	return me.Config.Mapping.TargetColumns(table, keyColumns)
 }
 
-// performing scheduled data migration(placeholder)
-func (me *MigrationEngine) executeScheduledMigration(result *MigrationResult) error {
+// performing a single tick of a scheduled migration, the schedule itself is driven by MigrationScheduler
+func (me *MigrationEngine) executeScheduledMigration(ctx context.Context, result *MigrationResult) error {
+	me.Logger.Info(fmt.Sprintf("Executing Scheduled Migration tick in %s mode", me.Config.ScheduledMode))
 	log.Println("Executing Scheduled Migration...")
-	//TODO: implent scheduled migration logic
-	//1.setting up cron jobs
-	//2.managing job state
-	//3.handling concurrent job execution
-	return fmt.Errorf("scheduled migration not implemented")
+
+	switch me.Config.ScheduledMode {
+	case FullMigration, "":
+		return me.executeFullMigration(ctx, result)
+	case IncrementalMigration:
+		return me.executeIncrementalMigration(ctx, result)
+	default:
+		return fmt.Errorf("unsupported mode %s for scheduled migration, use full or incremental", me.Config.ScheduledMode)
+	}
+}
+
+// releasing resources held by the engine, it cannot be used afterwards
+func (me *MigrationEngine) Close() {
+	me.Logger.Close()
+	//the default state dir lives only as long as the engine
+	if me.tempStateDir {
+		if err := os.RemoveAll(me.stateDir); err != nil {
+			log.Printf("Warning: Could not remove state directory %s, %v", me.stateDir, err)
+		}
+	}
 }
 
 // printing the formatted result of migration
@@ -335,9 +889,24 @@ func (mr *MigrationResult) Print() {
 	fmt.Printf("Duration %v\n", mr.Duration)
 	fmt.Printf("Tables Processed %v\n", mr.TotalTablesProcessed)
 	fmt.Printf("Rows Migrated %v\n", mr.TotalRowsMigrated)
+	if mr.Retries > 0 {
+		fmt.Printf("Retries %v\n", mr.Retries)
+	}
+	if mr.DeadLetteredRows > 0 {
+		fmt.Printf("Dead-Lettered Rows %v\n", mr.DeadLetteredRows)
+	}
+	if mr.Downtime > 0 {
+		fmt.Printf("Downtime %v (%s to %s)\n", mr.Downtime.Round(time.Millisecond), mr.DowntimeStart.Format(time.RFC3339), mr.DowntimeEnd.Format(time.RFC3339))
+	}
 	fmt.Printf("Start Time %s\n", mr.StartTime.Format("2025-08-24 20:09:45"))
 	fmt.Printf("End Time %s\n", mr.EndTime.Format("2025-08-24 20:09:45"))
 
+	if len(mr.ConstraintFailures) > 0 {
+		fmt.Println("\n Indexes and constraints not recreated:")
+		for _, failure := range mr.ConstraintFailures {
+			fmt.Printf("-%s\n", failure)
+		}
+	}
 	if len(mr.Errors) > 0 {
 		fmt.Println("\n Errors:")
 		for _, err := range mr.Errors {
@@ -346,27 +915,3 @@ func (mr *MigrationResult) Print() {
 	}
 	fmt.Println("===============")
 }
-
-// importing data with detail batch progress trackking
-func (me *MigrationEngine) importDataWithBatchTracking(data []map[string]interface{}, batchTracker *monitoring.BatchTracker) error {
-	batchSize := me.Config.BatchSize
-	totalBatches := (len(data) + batchSize - 1) / batchSize //ceiling division
-
-	for i := 0; i < len(data); i++ {
-		end := i + batchSize
-		if end > len(data) {
-			end = len(data)
-		}
-		batch := data[i:end]
-		batchNumber := (i / batchSize) + 1
-
-		batchTracker.StartBatch(batchNumber)
-
-		//importing the batch
-		if err := me.TargetClient.ImportData(batch); err != nil {
-			return fmt.Errorf("failed to import batch %d / %d, %v", batchNumber, totalBatches, err)
-		}
-		batchTracker.CompleteBatch(int64(len(batch)))
-	}
-	return nil
-}
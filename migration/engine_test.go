@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
 	"github.com/SusheelSathyaraj/DataMigrationTool/test"
 )
 
@@ -361,6 +362,172 @@ func TestMigrationEngineWithConcurrentProcessing(t *testing.T) {
 	t.Logf(" Tables %d", result.TotalTablesProcessed)
 }
 
+func TestMigrationEngineStreamsInBatches(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+
+	var testData []map[string]interface{}
+	for i := 0; i < 25; i++ {
+		testData = append(testData, map[string]interface{}{"id": i, "name": fmt.Sprintf("User%d", i)})
+	}
+	sourceClient.AddTestData("users", testData)
+
+	config := MigrationConfig{
+		Mode:         FullMigration,
+		SourceDb:     "mysql",
+		TargetDb:     "postgresql",
+		Tables:       []string{"users"},
+		BatchSize:    10,
+		ValidateData: false,
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	defer sourceClient.Close()
+	defer targetClient.Close()
+
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if result.TotalRowsMigrated != 25 {
+		t.Errorf("Expected 25 rows to be migrated, got %d", result.TotalRowsMigrated)
+	}
+
+	//25 rows with a batch size of 10 must be written as 3 non-overlapping batches
+	if targetClient.GetImportCallCount() != 3 {
+		t.Errorf("Expected 3 import calls, got %d", targetClient.GetImportCallCount())
+	}
+
+	if targetClient.GetImportedTableRowCount("users") != 25 {
+		t.Errorf("Expected 25 imported rows, got %d", targetClient.GetImportedTableRowCount("users"))
+	}
+}
+
+func TestMigrationEngineWriteModeUpsert(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Sathyaraj"},
+	})
+
+	config := MigrationConfig{
+		Mode:      FullMigration,
+		SourceDb:  "mysql",
+		TargetDb:  "postgresql",
+		Tables:    []string{"users"},
+		WriteMode: database.WriteModeUpsert,
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	defer sourceClient.Close()
+	defer targetClient.Close()
+
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+
+	//re-running the migration must not duplicate rows
+	for run := 1; run <= 2; run++ {
+		if _, err := engine.ExecuteMigration(); err != nil {
+			t.Fatalf("Run %d failed, %v", run, err)
+		}
+	}
+	if targetClient.GetImportedTableRowCount("users") != 2 {
+		t.Errorf("Expected 2 rows after re-running in upsert mode, got %d", targetClient.GetImportedTableRowCount("users"))
+	}
+
+	//skip-existing keeps the rows already present
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Changed"},
+		{"id": 3, "name": "New"},
+	})
+	engine.Config.WriteMode = database.WriteModeSkipExisting
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Skip-existing run failed, %v", err)
+	}
+	for _, row := range targetClient.GetImportedData("users") {
+		if row["id"] == 1 && row["name"] != "Susheel" {
+			t.Errorf("Expected existing row to be skipped, got %v", row)
+		}
+	}
+	if targetClient.GetImportedTableRowCount("users") != 3 {
+		t.Errorf("Expected 3 rows after skip-existing run, got %d", targetClient.GetImportedTableRowCount("users"))
+	}
+}
+
+// mock client exposing a table schema, for testing schema translation
+type schemaMockClient struct {
+	*test.CompleteMockDatabaseClient
+	schema  *database.TableSchema
+	created []*database.TableSchema
+}
+
+func (s *schemaMockClient) DescribeTable(tableName string) (*database.TableSchema, error) {
+	if s.schema == nil || s.schema.Name != tableName {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+	return s.schema, nil
+}
+
+func (s *schemaMockClient) CreateTable(schema *database.TableSchema) error {
+	s.created = append(s.created, schema)
+	return nil
+}
+
+func TestMigrationEngineTranslatesSchema(t *testing.T) {
+	sourceClient := &schemaMockClient{
+		CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql"),
+		schema: &database.TableSchema{
+			Name:    "users",
+			Dialect: "mysql",
+			Columns: []database.ColumnDefinition{
+				{Name: "id", DataType: "int", AutoIncrement: true},
+				{Name: "name", DataType: "varchar", Length: 40, Nullable: true},
+				{Name: "active", DataType: "tinyint", Nullable: true},
+			},
+			PrimaryKey: []string{"id"},
+		},
+	}
+	targetClient := &schemaMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql")}
+
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "active": 1},
+	})
+
+	config := MigrationConfig{
+		Mode:         FullMigration,
+		SourceDb:     "mysql",
+		TargetDb:     "postgresql",
+		Tables:       []string{"users"},
+		TypeMappings: map[string]string{"tinyint": "BOOLEAN"},
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	defer sourceClient.Close()
+	defer targetClient.Close()
+
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	if len(targetClient.created) != 1 {
+		t.Fatalf("Expected target table to be created from the source schema, got %d tables", len(targetClient.created))
+	}
+	created := targetClient.created[0]
+	if created.Dialect != "postgresql" || created.Columns[1].DataType != "VARCHAR(40)" || created.Columns[2].DataType != "BOOLEAN" {
+		t.Errorf("Expected translated postgresql schema, got %+v", created)
+	}
+	if targetClient.GetImportedTableRowCount("users") != 1 {
+		t.Errorf("Expected 1 row imported, got %d", targetClient.GetImportedTableRowCount("users"))
+	}
+}
+
 func TestMigrationEngineWithBackupAndRollBack(t *testing.T) {
 	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
 	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
@@ -406,8 +573,11 @@ func TestMigrationEngineWithBackupAndRollBack(t *testing.T) {
 	//verfying rollback functionality
 	rollbackErr := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID)
 	if rollbackErr != nil {
-		t.Logf("Rollback failed(expected for mock implementation), %v", rollbackErr)
-		//this is for now expected since complete rollbak is not iplemented yet
+		t.Fatalf("Rollback failed, %v", rollbackErr)
+	}
+
+	if targetClient.GetImportedTableRowCount("users") != 0 {
+		t.Errorf("Expected migrated rows to be removed by rollback, got %d rows", targetClient.GetImportedTableRowCount("users"))
 	}
 }
 
@@ -430,14 +600,137 @@ func TestMigrationEngineIncrementalMode(t *testing.T) {
 	defer targetCleint.Close()
 
 	engine := NewMigrationEngine(config, sourceClient, targetCleint)
+	engine.WatermarkStore = NewWatermarkStore(t.TempDir())
 	result, err := engine.ExecuteMigration()
 
 	if err == nil {
-		t.Errorf("Expected error as incremental migration is not implemented, got nil")
+		t.Errorf("Expected error as no incremental column is configured, got nil")
 	}
 
 	if result != nil && result.Success {
-		t.Errorf("Expected migration failure due to unimplemented feature, got success")
+		t.Errorf("Expected migration failure due to missing incremental column, got success")
+	}
+}
+
+func TestMigrationEngineIncrementalWatermark(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "updated_at": 10},
+		{"id": 2, "name": "Sathyaraj", "updated_at": 20},
+		{"id": 3, "name": "Alex", "updated_at": 30},
+	})
+
+	config := MigrationConfig{
+		Mode:              IncrementalMigration,
+		SourceDb:          "mysql",
+		TargetDb:          "postgresql",
+		Tables:            []string{"users"},
+		BatchSize:         2,
+		IncrementalColumn: "updated_at",
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	defer sourceClient.Close()
+	defer targetClient.Close()
+
+	stateDir := t.TempDir()
+
+	//first run migrates every row and stores the high-water mark
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+	engine.WatermarkStore = NewWatermarkStore(stateDir)
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("First incremental run failed, %v", err)
+	}
+	if result.TotalRowsMigrated != 3 {
+		t.Errorf("Expected 3 rows in first run, got %d", result.TotalRowsMigrated)
+	}
+
+	watermarks, err := engine.WatermarkStore.Load("mysql", "postgresql")
+	if err != nil {
+		t.Fatalf("Failed to load watermarks, %v", err)
+	}
+	if watermarks["users"].Value != "30" {
+		t.Errorf("Expected watermark 30 after first run, got %s", watermarks["users"].Value)
+	}
+
+	//second run picks up the updated and the new row, and the row at the watermark again
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "updated_at": 10},
+		{"id": 2, "name": "Sathyaraj Updated", "updated_at": 40},
+		{"id": 3, "name": "Alex", "updated_at": 30},
+		{"id": 4, "name": "Fahad", "updated_at": 50},
+	})
+
+	engine = NewMigrationEngine(config, sourceClient, targetClient)
+	engine.WatermarkStore = NewWatermarkStore(stateDir)
+	result, err = engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Second incremental run failed, %v", err)
+	}
+	if result.TotalRowsMigrated != 3 {
+		t.Errorf("Expected 3 changed rows in second run, got %d", result.TotalRowsMigrated)
+	}
+
+	importedUsers := targetClient.GetImportedData("users")
+	if len(importedUsers) != 4 {
+		t.Fatalf("Expected 4 users on target after upsert, got %d", len(importedUsers))
+	}
+	for _, user := range importedUsers {
+		if user["id"] == 2 && user["name"] != "Sathyaraj Updated" {
+			t.Errorf("Expected user 2 to be updated, got %v", user["name"])
+		}
+	}
+
+	watermarks, _ = engine.WatermarkStore.Load("mysql", "postgresql")
+	if watermarks["users"].Value != "50" {
+		t.Errorf("Expected watermark 50 after second run, got %s", watermarks["users"].Value)
+	}
+
+	//third run without changes keeps the watermark and only reads the row at it again
+	engine = NewMigrationEngine(config, sourceClient, targetClient)
+	engine.WatermarkStore = NewWatermarkStore(stateDir)
+	result, err = engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Third incremental run failed, %v", err)
+	}
+	if result.TotalRowsMigrated != 1 {
+		t.Errorf("Expected only the row at the watermark in third run, got %d", result.TotalRowsMigrated)
+	}
+}
+
+func TestWatermarkEncodeDecode(t *testing.T) {
+	updatedAt := time.Date(2025, 8, 24, 20, 9, 45, 123000000, time.UTC)
+
+	testCases := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{int32(42), int64(42)},
+		{12.5, 12.5},
+		{updatedAt, updatedAt},
+		{"2025-08-24", "2025-08-24"},
+	}
+
+	for _, tc := range testCases {
+		encoded, err := EncodeWatermark("updated_at", tc.value)
+		if err != nil {
+			t.Fatalf("Failed to encode %v, %v", tc.value, err)
+		}
+		decoded, err := encoded.Decode()
+		if err != nil {
+			t.Fatalf("Failed to decode %v, %v", encoded, err)
+		}
+		if decoded != tc.expected {
+			t.Errorf("Expected %v (%T) after round trip, got %v (%T)", tc.expected, tc.expected, decoded, decoded)
+		}
+	}
+
+	if _, err := EncodeWatermark("updated_at", []int{1}); err == nil {
+		t.Errorf("Expected error for unsupported watermark type, got nil")
 	}
 }
 
@@ -445,13 +738,18 @@ func TestMigrationEngineScheduledMode(t *testing.T) {
 	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
 	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
 
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+	})
+
 	config := MigrationConfig{
-		Mode:         ScheduledMigration,
-		SourceDb:     "mysql",
-		TargetDb:     "postgresql",
-		Tables:       []string{"users"},
-		ValidateData: false,
-		CreateBackup: false,
+		Mode:          ScheduledMigration,
+		ScheduledMode: FullMigration,
+		SourceDb:      "mysql",
+		TargetDb:      "postgresql",
+		Tables:        []string{"users"},
+		ValidateData:  false,
+		CreateBackup:  false,
 	}
 
 	sourceClient.Connect()
@@ -459,15 +757,23 @@ func TestMigrationEngineScheduledMode(t *testing.T) {
 	defer sourceClient.Close()
 	defer targetClient.Close()
 
+	//executing a scheduled migration directly runs a single tick
 	engine := NewMigrationEngine(config, sourceClient, targetClient)
 	result, err := engine.ExecuteMigration()
 
-	if err == nil {
-		t.Errorf("Expected error as scheduled migration is not implemented, got nil")
+	if err != nil {
+		t.Errorf("Expected scheduled tick to succeed, got %v", err)
 	}
 
-	if result != nil && result.Success {
-		t.Errorf("Expected migration failure due to unimplemented feature, got success")
+	if result == nil || result.TotalRowsMigrated != 1 {
+		t.Errorf("Expected 1 row migrated by the scheduled tick, got %v", result)
+	}
+
+	//an unsupported mode for the ticks fails the run
+	config.ScheduledMode = ScheduledMigration
+	engine = NewMigrationEngine(config, sourceClient, targetClient)
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Errorf("Expected error for scheduled mode nested in scheduled mode, got nil")
 	}
 }
 
@@ -0,0 +1,231 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/validation"
+)
+
+const (
+	defaultMaxLag     = 5 * time.Second  //lag of the target below which the cutover starts, when none is configured
+	lagReportInterval = 10 * time.Second //interval of the lag reports while catching up
+)
+
+// migrating the tables while the source stays in use. The change position is recorded before the full load,
+// the changes committed during the load are replayed until the target lags less than MaxLag behind, then the
+// cutover stops the writes to the source, applies the last changes and validates the target. The downtime
+// window, from stopping the writes until the target is validated, is reported in the result
+func (me *MigrationEngine) executeOnlineMigration(ctx context.Context, result *MigrationResult) error {
+	me.Logger.Info("Executing Online Migration")
+	log.Println("Executing online migration...")
+
+	capture, upserter, deleter, err := me.changeCaptureClients()
+	if err != nil {
+		return err
+	}
+	var readOnly database.ReadOnlyClient
+	if me.Config.CutoverReadOnly {
+		client, ok := me.SourceClient.(database.ReadOnlyClient)
+		if !ok {
+			return fmt.Errorf("source database %s cannot be set read-only for the cutover", me.Config.SourceDb)
+		}
+		readOnly = client
+	}
+
+	//Phase1: recording the position first, the changes committed while the tables are copied are replayed from it
+	position, err := capture.CurrentChangePosition(ctx, me.Config.Tables)
+	if err != nil {
+		return fmt.Errorf("failed to read the current change position of %s, %v", me.Config.SourceDb, err)
+	}
+	sourceKey, targetKey := me.stateKeys()
+	if err := me.ChangePositionStore.Save(sourceKey, targetKey, position); err != nil {
+		return fmt.Errorf("failed to save change position %s, %v", position, err)
+	}
+	me.Logger.Info(fmt.Sprintf("Recorded change position %s before the initial load", position))
+
+	//Phase2: copying the tables, rows changed meanwhile are written again by the replayed changes
+	if err := me.executeFullMigration(ctx, result); err != nil {
+		return err
+	}
+
+	//Phase3: catching up with the changes committed during the load
+	reader, err := me.readChanges(ctx, capture, position)
+	if err != nil {
+		return err
+	}
+	defer reader.stop()
+	if err := me.catchUp(ctx, reader, result, upserter, deleter); err != nil {
+		return err
+	}
+
+	//Phase4: cutting over
+	return me.cutover(ctx, capture, reader, readOnly, result, upserter, deleter)
+}
+
+// returning the configured maximum lag or the default one
+func (me *MigrationEngine) maxLag() time.Duration {
+	if me.Config.MaxLag <= 0 {
+		return defaultMaxLag
+	}
+	return me.Config.MaxLag
+}
+
+// applying changes until the last one applied was committed less than MaxLag ago, or no change arrived for
+// MaxLag. The lag is measured against the commit times of the source, so the clocks of both hosts should agree
+func (me *MigrationEngine) catchUp(ctx context.Context, reader *changeReader, result *MigrationResult, upserter database.UpsertClient, deleter database.RollbackClient) error {
+	maxLag := me.maxLag()
+	lastReport := time.Now()
+	for {
+		select {
+		case change, ok := <-reader.changes:
+			if !ok {
+				return fmt.Errorf("catching up with the source changes interrupted, %v", ctx.Err())
+			}
+			if change.err != nil {
+				return fmt.Errorf("failed to read changes during catch-up, %v", change.err)
+			}
+			if err := me.applyChanges(ctx, reader, change.transaction, result, upserter, deleter); err != nil {
+				return err
+			}
+
+			lag := time.Since(change.transaction.CommitTime)
+			if lag <= maxLag {
+				me.Logger.Info(fmt.Sprintf("Caught up with the source, lag %v at position %s", lag.Round(time.Millisecond), change.transaction.Position))
+				return nil
+			}
+			if time.Since(lastReport) >= lagReportInterval {
+				me.Logger.Info(fmt.Sprintf("Catching up with the source, lag %v at position %s", lag.Round(time.Second), change.transaction.Position))
+				log.Printf("Catching up with the source, lag %v", lag.Round(time.Second))
+				lastReport = time.Now()
+			}
+		case <-time.After(maxLag):
+			me.Logger.Info(fmt.Sprintf("Caught up with the source, no changes for %v", maxLag))
+			return nil
+		}
+	}
+}
+
+// stopping the writes to the source, by setting it read-only or by waiting for the operator to confirm, then
+// applying the changes up to the position of the now static source and validating the target against it
+func (me *MigrationEngine) cutover(ctx context.Context, capture database.ChangeCaptureClient, reader *changeReader, readOnly database.ReadOnlyClient, result *MigrationResult, upserter database.UpsertClient, deleter database.RollbackClient) (err error) {
+	me.Logger.Info("Starting cutover")
+
+	if readOnly == nil && me.ConfirmCutover != nil {
+		//keeping up with the source while the operator stops the applications writing to it
+		confirmed := make(chan error, 1)
+		go func() {
+			confirmed <- me.ConfirmCutover(ctx)
+		}()
+	waiting:
+		for {
+			select {
+			case err := <-confirmed:
+				if err != nil {
+					return fmt.Errorf("cutover was not confirmed, %v", err)
+				}
+				break waiting
+			case change, ok := <-reader.changes:
+				if err := me.applyStreamedChange(ctx, reader, change, ok, result, upserter, deleter); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	result.DowntimeStart = time.Now()
+	if readOnly != nil {
+		me.Logger.Info(fmt.Sprintf("Setting source database %s read-only", me.Config.SourceDb))
+		if err := readOnly.SetReadOnly(ctx, true); err != nil {
+			return fmt.Errorf("failed to stop writes to the source, %v", err)
+		}
+		//letting the applications write to the source again when the cutover fails
+		defer func() {
+			if err == nil {
+				return
+			}
+			if restoreErr := readOnly.SetReadOnly(context.Background(), false); restoreErr != nil {
+				me.Logger.Error("Failed to make the source writable again", restoreErr.Error())
+				result.Errors = append(result.Errors, fmt.Sprintf("failed to make the source writable again, %v", restoreErr))
+				return
+			}
+			me.Logger.Info("Source database is writable again after the failed cutover")
+		}()
+	}
+
+	//draining, the last changes committed before the writes stopped are still arriving
+	last, err := capture.CurrentChangePosition(ctx, me.Config.Tables)
+	if err != nil {
+		return fmt.Errorf("failed to read the last change position of %s, %v", me.Config.SourceDb, err)
+	}
+	me.Logger.Info(fmt.Sprintf("Applying the changes up to position %s", last))
+	for {
+		reached, err := capture.ChangePositionReached(reader.applied, last)
+		if err != nil {
+			return fmt.Errorf("failed to compare change position %s with %s, %v", reader.applied, last, err)
+		}
+		if reached {
+			break
+		}
+		change, ok := <-reader.changes
+		if err := me.applyStreamedChange(ctx, reader, change, ok, result, upserter, deleter); err != nil {
+			return err
+		}
+	}
+	me.Logger.Info(fmt.Sprintf("Applied the last changes of the source, position %s", reader.applied))
+
+	if me.Config.ValidateData {
+		if err := me.validateCutover(result); err != nil {
+			return err
+		}
+	}
+
+	result.DowntimeEnd = time.Now()
+	result.Downtime = result.DowntimeEnd.Sub(result.DowntimeStart)
+	me.Logger.Info(fmt.Sprintf("Cutover completed, downtime %v from %s to %s", result.Downtime.Round(time.Millisecond),
+		result.DowntimeStart.Format(time.RFC3339), result.DowntimeEnd.Format(time.RFC3339)))
+	log.Printf("Cutover completed with %v downtime, switch the applications to the target", result.Downtime.Round(time.Millisecond))
+	return nil
+}
+
+// applying a change received from the reader, failing when the stream ended
+func (me *MigrationEngine) applyStreamedChange(ctx context.Context, reader *changeReader, change streamedChange, ok bool, result *MigrationResult, upserter database.UpsertClient, deleter database.RollbackClient) error {
+	if !ok {
+		return fmt.Errorf("cutover interrupted, %v", ctx.Err())
+	}
+	if change.err != nil {
+		return fmt.Errorf("failed to read changes during cutover, %v", change.err)
+	}
+	return me.applyChanges(ctx, reader, change.transaction, result, upserter, deleter)
+}
+
+// comparing the target with the source once no more writes reach it, the row counts of the source are read again
+// as the load started with earlier ones
+func (me *MigrationEngine) validateCutover(result *MigrationResult) error {
+	me.Logger.Info("Starting Cutover Validation")
+	startTime := time.Now()
+
+	preValidation, err := me.Validator.PreMigrationValidation(me.Config.Tables)
+	if err != nil {
+		return fmt.Errorf("cutover validation of the source failed, %v", err)
+	}
+	result.PreValidation = preValidation
+
+	postValidation, err := me.Validator.PostMigationValidation(me.Config.Tables, me.expectedRowCounts(preValidation))
+	if err != nil {
+		return fmt.Errorf("cutover validation of the target failed, %v", err)
+	}
+	result.PostValidation = postValidation
+
+	summary := validation.GenerateValidationSummary(postValidation, startTime)
+	summary.Print("Cutover")
+	if summary.InvalidTables > 0 {
+		me.Logger.Error("Cutover Validation failed", fmt.Sprintf("%d tables differ from the source", summary.InvalidTables))
+		return fmt.Errorf("cutover validation failed for %d tables", summary.InvalidTables)
+	}
+	me.Logger.Info("Cutover Validation completed Successfully")
+	return nil
+}
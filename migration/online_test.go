@@ -0,0 +1,155 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+)
+
+// mock source recording whether it was set read-only
+type readOnlyMockClient struct {
+	*changeCaptureMockClient
+	readOnly []bool
+}
+
+func (c *readOnlyMockClient) SetReadOnly(ctx context.Context, readOnly bool) error {
+	c.readOnly = append(c.readOnly, readOnly)
+	return nil
+}
+
+func newOnlineTestEngine(t *testing.T, source, target database.DatabaseClient) *MigrationEngine {
+	engine := newChangeCaptureTestEngine(t, source, target)
+	engine.Config.Mode = OnlineMigration
+	engine.Config.MaxLag = time.Second
+	return engine
+}
+
+func newOnlineSource() *changeCaptureMockClient {
+	source := &changeCaptureMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql"), dropAfter: -1}
+	source.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Alex"},
+	})
+	source.Connect()
+	return source
+}
+
+func TestOnlineMigrationCatchesUpBeforeCutover(t *testing.T) {
+	sourceClient := newOnlineSource()
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	targetClient.Connect()
+
+	//committed during the load, long before the catch-up reads it
+	old := time.Now().Add(-time.Hour)
+	sourceClient.transactions = []*database.ChangeTransaction{
+		{Position: "1", CommitTime: old, Changes: []database.RowChange{userChange(database.ChangeUpdate, 2, "Sathyaraj")}},
+		{Position: "2", CommitTime: time.Now(), Changes: []database.RowChange{userChange(database.ChangeInsert, 3, "Bo")}},
+		//committed while the operator stops the applications
+		{Position: "3", CommitTime: time.Now(), Changes: []database.RowChange{userChange(database.ChangeInsert, 4, "Dee")}},
+	}
+
+	engine := newOnlineTestEngine(t, sourceClient, targetClient)
+	engine.ConfirmCutover = func(ctx context.Context) error {
+		for targetClient.GetImportedTableRowCount("users") < 4 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Millisecond):
+			}
+		}
+		return nil
+	}
+
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Online migration failed, %v", err)
+	}
+
+	users := targetClient.GetImportedData("users")
+	names := map[string]string{}
+	for _, user := range users {
+		names[fmt.Sprint(user["id"])] = fmt.Sprint(user["name"])
+	}
+	if len(users) != 4 || names["2"] != "Sathyaraj" || names["4"] != "Dee" {
+		t.Errorf("Expected the loaded rows with the replayed changes, got %v", users)
+	}
+	if len(sourceClient.opened) != 1 || sourceClient.opened[0] != "0" {
+		t.Errorf("Expected the changes to be replayed from the position recorded before the load, opened %v", sourceClient.opened)
+	}
+	if saved, _ := engine.ChangePositionStore.Load("mysql", "postgresql"); saved == nil || saved.Position != "3" {
+		t.Errorf("Expected the position of the last change to be saved, got %+v", saved)
+	}
+	if result.Downtime <= 0 || !result.DowntimeEnd.After(result.DowntimeStart) {
+		t.Errorf("Expected the downtime window to be reported, got %v from %v to %v", result.Downtime, result.DowntimeStart, result.DowntimeEnd)
+	}
+}
+
+func TestOnlineMigrationDrainsUpToTheLastPosition(t *testing.T) {
+	sourceClient := &readOnlyMockClient{changeCaptureMockClient: newOnlineSource()}
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	targetClient.Connect()
+	sourceClient.transactions = []*database.ChangeTransaction{
+		{Position: "1", CommitTime: time.Now(), Changes: []database.RowChange{userChange(database.ChangeInsert, 3, "Bo")}},
+		//committed right before the source was set read-only, arriving long after
+		{Position: "2", CommitTime: time.Now(), Changes: []database.RowChange{userChange(database.ChangeInsert, 4, "Dee")}},
+	}
+	sourceClient.delays = map[string]time.Duration{"2": 200 * time.Millisecond}
+
+	engine := newOnlineTestEngine(t, sourceClient, targetClient)
+	engine.Config.CutoverReadOnly = true
+	engine.Config.MaxLag = 10 * time.Millisecond
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Online migration failed, %v", err)
+	}
+	if targetClient.GetImportedTableRowCount("users") != 4 {
+		t.Errorf("Expected the late change to be applied before the cutover completed, got %v", targetClient.GetImportedData("users"))
+	}
+	if saved, _ := engine.ChangePositionStore.Load("mysql", "postgresql"); saved == nil || saved.Position != "2" {
+		t.Errorf("Expected the position of the last change to be saved, got %+v", saved)
+	}
+}
+
+func TestOnlineMigrationRestoresWritableSourceAfterFailedCutover(t *testing.T) {
+	sourceClient := &readOnlyMockClient{changeCaptureMockClient: newOnlineSource()}
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	targetClient.Connect()
+	sourceClient.transactions = []*database.ChangeTransaction{
+		{Position: "1", CommitTime: time.Now(), Changes: []database.RowChange{userChange(database.ChangeInsert, 3, "Bo")}},
+		//drained after the source was set read-only, cannot be applied
+		{Position: "2", CommitTime: time.Now(), Changes: []database.RowChange{{Operation: "truncate", Table: "users"}}},
+	}
+
+	engine := newOnlineTestEngine(t, sourceClient, targetClient)
+	engine.Config.CutoverReadOnly = true
+
+	result, err := engine.ExecuteMigration()
+	if err == nil {
+		t.Fatal("Expected the cutover to fail")
+	}
+	if len(sourceClient.readOnly) != 2 || !sourceClient.readOnly[0] || sourceClient.readOnly[1] {
+		t.Errorf("Expected the source to be set read-only and writable again, got %v", sourceClient.readOnly)
+	}
+	if result.Downtime != 0 {
+		t.Errorf("Expected no downtime window for a failed cutover, got %v", result.Downtime)
+	}
+}
+
+func TestOnlineMigrationRequiresReadOnlySource(t *testing.T) {
+	sourceClient := newOnlineSource()
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	targetClient.Connect()
+
+	engine := newOnlineTestEngine(t, sourceClient, targetClient)
+	engine.Config.CutoverReadOnly = true
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Error("Expected an error for a source that cannot be set read-only")
+	}
+	if targetClient.GetImportedTableRowCount("users") != 0 {
+		t.Error("Expected the check to fail before the load")
+	}
+}
@@ -0,0 +1,505 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// full load of the planned tables, a pool of readers streams batches of several tables at once into a pool
+// of writers over a bounded channel, so a slow target blocks the readers instead of buffering rows
+type loadPipeline struct {
+	engine     *MigrationEngine
+	plan       *MigrationPlan
+	checkpoint *MigrationCheckpoint
+	batchSize  int
+	readers    int
+	writers    int
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	batches chan *pipelineBatch
+	ready   chan *partRun
+
+	mu         sync.Mutex
+	runs       map[string]*tableRun
+	pending    map[string]int      //dependencies each table still waits for
+	dependents map[string][]string //tables waiting for each table
+	remaining  int
+	completed  int
+	rows       int64
+	err        error
+}
+
+// a batch of rows on its way from a reader to the writers
+type pipelineBatch struct {
+	part    *partRun
+	number  int
+	first   bool //first batch sent for the table, written before any other
+	rows    []map[string]interface{}
+	written []map[string]interface{} //rows written into the target, all rows unless some were dead-lettered
+}
+
+// state of a table being loaded, read as a whole or as key ranges read concurrently
+type tableRun struct {
+	table        string
+	progress     *TableCheckpoint
+	parts        []*partRun
+	prepare      sync.Once
+	prepareErr   error
+	firstWritten chan struct{}
+
+	mu        sync.Mutex
+	firstSent bool
+	remaining int
+	rows      int64
+}
+
+// state of a stream of batches of a table, the whole table or one of its key ranges, its batches may be
+// written out of order but are committed to the checkpoint in the order they were read
+type partRun struct {
+	run      *tableRun
+	name     string
+	progress *TableCheckpoint
+
+	mu         sync.Mutex
+	read       int
+	readDone   bool
+	written    map[int]*pipelineBatch
+	nextCommit int
+	rows       int64
+}
+
+// creating the pipeline for the tables of the plan not completed by a resumed run
+func newLoadPipeline(parent context.Context, me *MigrationEngine, plan *MigrationPlan, checkpoint *MigrationCheckpoint) *loadPipeline {
+	readers, writers := 1, 1
+	if me.Config.Concurrent && me.Config.Workers > 1 {
+		readers, writers = me.Config.Workers, me.Config.Workers
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	p := &loadPipeline{
+		engine:     me,
+		plan:       plan,
+		checkpoint: checkpoint,
+		batchSize:  me.batchSize(),
+		writers:    writers,
+		ctx:        ctx,
+		cancel:     cancel,
+		batches:    make(chan *pipelineBatch, writers),
+		runs:       make(map[string]*tableRun),
+		pending:    make(map[string]int),
+		dependents: make(map[string][]string),
+	}
+
+	parts := 0
+	for _, table := range plan.Order {
+		progress := checkpoint.Progress[table]
+		if progress.Status == "completed" {
+			continue
+		}
+		run := &tableRun{table: table, progress: progress, firstWritten: make(chan struct{})}
+
+		if len(progress.Chunks) == 0 {
+			run.parts = append(run.parts, newPartRun(run, table, progress))
+		} else {
+			me.ProgressTracker.AddChunks(len(progress.Chunks))
+			for i, chunk := range progress.Chunks {
+				if chunk.Status == "completed" {
+					me.ProgressTracker.CompletedChunk()
+					continue
+				}
+				run.parts = append(run.parts, newPartRun(run, fmt.Sprintf("%s[%d/%d]", table, i+1, len(progress.Chunks)), chunk))
+			}
+		}
+
+		//a resumed run may have committed every range without marking the table
+		if len(run.parts) == 0 {
+			me.completeCheckpoint(progress)
+			me.ProgressTracker.CompletedTable()
+			me.Logger.TableProgress(table, progress.CommittedRows(), "All ranges already migrated by the resumed run, skipping table")
+			continue
+		}
+		run.remaining = len(run.parts)
+		parts += len(run.parts)
+		p.runs[table] = run
+	}
+	p.remaining = len(p.runs)
+	p.ready = make(chan *partRun, parts)
+	p.readers = max(1, min(readers, parts))
+
+	//a table waits for the tables it references unless they are loaded already or belong to its own cycle
+	for table := range p.runs {
+		for _, parent := range plan.Dependencies[table] {
+			if _, ok := p.runs[parent]; !ok || plan.inSameCycle(table, parent) {
+				continue
+			}
+			p.pending[table]++
+			p.dependents[parent] = append(p.dependents[parent], table)
+		}
+	}
+	return p
+}
+
+// creating the state of a stream of batches of a table
+func newPartRun(run *tableRun, name string, progress *TableCheckpoint) *partRun {
+	return &partRun{
+		run:        run,
+		name:       name,
+		progress:   progress,
+		written:    make(map[int]*pipelineBatch),
+		nextCommit: 1,
+	}
+}
+
+// loading all tables, returning the rows of the completed tables and the first error that stopped the load
+func (p *loadPipeline) run() (int64, error) {
+	defer p.cancel()
+
+	p.engine.Logger.Info(fmt.Sprintf("Loading %d tables with %d readers and %d writers", len(p.runs), p.readers, p.writers))
+
+	p.mu.Lock()
+	for _, table := range p.plan.Order {
+		if run, ok := p.runs[table]; ok && p.pending[table] == 0 {
+			p.dispatch(run)
+		}
+	}
+	if p.remaining == 0 {
+		close(p.ready)
+	}
+	p.mu.Unlock()
+
+	var readers, writers sync.WaitGroup
+	for i := 0; i < p.readers; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			p.reader()
+		}()
+	}
+	for i := 0; i < p.writers; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			p.writer()
+		}()
+	}
+
+	readers.Wait()
+	close(p.batches)
+	writers.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	//readers and writers stop without an error when the parent context is cancelled
+	if p.err == nil && p.ctx.Err() != nil {
+		p.err = fmt.Errorf("migration interrupted after %d rows, %v", p.rows, context.Cause(p.ctx))
+	}
+	return p.rows, p.err
+}
+
+// handing all parts of a table whose dependencies are loaded to the readers
+func (p *loadPipeline) dispatch(run *tableRun) {
+	for _, part := range run.parts {
+		p.ready <- part
+	}
+}
+
+// recording the first error and stopping all readers and writers
+func (p *loadPipeline) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.cancel()
+}
+
+// reading parts of tables whose dependencies are loaded until none are left
+func (p *loadPipeline) reader() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case part, ok := <-p.ready:
+			if !ok {
+				return
+			}
+			if err := p.readPart(part); err != nil {
+				p.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// streaming the batches of a table or key range to the writers, the first batch of a table is written
+// before any other is sent so targets creating the table from its first rows never race
+func (p *loadPipeline) readPart(part *partRun) error {
+	me := p.engine
+	run := part.run
+	me.ProgressTracker.SetCurrentTable(part.name)
+	me.Logger.TableProgress(part.name, part.progress.RowsCommitted, "Starting table Migration")
+
+	//the ranges of a table share its target table, created once
+	run.prepare.Do(func() {
+		run.prepareErr = me.prepareTargetTable(run.table)
+	})
+	if run.prepareErr != nil {
+		return run.prepareErr
+	}
+
+	//reads failing with a transient error are reopened after the last row handed to the writers
+	me.checkpointMu.Lock()
+	start := *part.progress
+	me.checkpointMu.Unlock()
+	position := &readPosition{}
+	err := me.retry(p.ctx, fmt.Sprintf("reading table %s", part.name), func() error {
+		return p.streamPart(part, &start, position)
+	})
+	if err != nil {
+		me.Logger.Error("Table Fetching Failed", err.Error())
+		return err
+	}
+	if position.stopped {
+		return nil
+	}
+
+	me.Logger.Info(fmt.Sprintf("Read all %d batches of table %s", position.batches, part.name))
+
+	part.mu.Lock()
+	part.readDone = true
+	done := part.nextCommit > part.read
+	part.mu.Unlock()
+	if done {
+		p.completePart(part)
+	}
+	return nil
+}
+
+// position reached by the reads of a part, the batches and rows handed to the writers and the last of those rows
+type readPosition struct {
+	batches int
+	rows    int64
+	lastRow map[string]interface{}
+	stopped bool //the load was stopped before the part was read
+}
+
+// returning the checkpoint a read is reopened from, the one it started from advanced past the rows already read
+func (rp *readPosition) resumeFrom(start *TableCheckpoint) *TableCheckpoint {
+	if rp.batches == 0 {
+		return start
+	}
+	progress := *start
+	progress.RowsCommitted += rp.rows
+	if progress.KeyColumn != "" {
+		if lastKey, err := EncodeWatermark(progress.KeyColumn, rp.lastRow[progress.KeyColumn]); err == nil {
+			progress.LastKey = &lastKey
+		} else {
+			//skipping the rows read from the start of the ordered table instead
+			progress.LastKey = nil
+		}
+	}
+	return &progress
+}
+
+// streaming the batches of a part from position into the writers
+func (p *loadPipeline) streamPart(part *partRun, start *TableCheckpoint, position *readPosition) error {
+	me := p.engine
+	run := part.run
+
+	iterator, skip, err := me.openTableIterator(p.ctx, run.table, position.resumeFrom(start), p.batchSize)
+	if err != nil {
+		return fmt.Errorf("failed to fetch data from table %s, %w", part.name, err)
+	}
+	defer iterator.Close()
+
+	for iterator.Next() {
+		batch := iterator.Batch()
+
+		//dropping rows a resumed run already committed
+		if skip > 0 {
+			if int64(len(batch)) <= skip {
+				skip -= int64(len(batch))
+				continue
+			}
+			batch, skip = batch[skip:], 0
+		}
+		batchNumber := position.batches + 1
+
+		//validating data types on the first batch before anything is written
+		if me.Config.ValidateData && batchNumber == 1 {
+			if err := me.Validator.ValidateDataTypes(batch); err != nil {
+				errorMsg := fmt.Sprintf("data type validation failed for table %s, %v", part.name, err)
+				me.Logger.Error("Data Type Validation Failed", errorMsg)
+				return fmt.Errorf(errorMsg)
+			}
+		}
+
+		part.mu.Lock()
+		part.read = batchNumber
+		part.mu.Unlock()
+
+		run.mu.Lock()
+		first := !run.firstSent
+		run.firstSent = true
+		run.mu.Unlock()
+		if !first && !p.waitFirstWritten(run) {
+			position.stopped = true
+			return nil
+		}
+
+		select {
+		case p.batches <- &pipelineBatch{part: part, number: batchNumber, first: first, rows: batch}:
+		case <-p.ctx.Done():
+			position.stopped = true
+			return nil
+		}
+		position.batches = batchNumber
+		position.rows += int64(len(batch))
+		position.lastRow = batch[len(batch)-1]
+
+		if first && !p.waitFirstWritten(run) {
+			position.stopped = true
+			return nil
+		}
+	}
+
+	if err := iterator.Err(); err != nil {
+		return fmt.Errorf("failed to fetch data from table %s after %d batches, %w", part.name, position.batches, err)
+	}
+	return nil
+}
+
+// waiting until the first batch of a table is written, false when the load was stopped meanwhile
+func (p *loadPipeline) waitFirstWritten(run *tableRun) bool {
+	select {
+	case <-run.firstWritten:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// writing batches of any table until the readers are done
+func (p *loadPipeline) writer() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case batch, ok := <-p.batches:
+			if !ok {
+				return
+			}
+			if err := p.writeBatch(batch); err != nil {
+				p.fail(err)
+				return
+			}
+		}
+	}
+}
+
+// writing a batch into the target and committing the batches of its part written without gaps
+func (p *loadPipeline) writeBatch(batch *pipelineBatch) error {
+	me := p.engine
+	part := batch.part
+
+	batchTracker := me.ProgressTracker.NewBatchTracker(p.batchSize)
+	batchTracker.StartBatch(batch.number)
+	transformed, err := me.transformRows(part.run.table, batch.rows)
+	if err != nil {
+		errorMsg := fmt.Sprintf("failed to transform data for table %s, batch %d, %v", part.name, batch.number, err)
+		me.Logger.Error("Table Transform Failed", errorMsg)
+		return fmt.Errorf(errorMsg)
+	}
+	write := func(ctx context.Context, rows []map[string]interface{}) error {
+		return me.writeBatch(ctx, part.run.table, rows)
+	}
+	written, err := me.writeRows(p.ctx, part.run.table, transformed, write)
+	if err != nil {
+		errorMsg := fmt.Sprintf("failed to import data for table %s, batch %d, %v", part.name, batch.number, err)
+		me.Logger.Error("Table Import Failed", errorMsg)
+		return fmt.Errorf(errorMsg)
+	}
+	batch.written = written
+	batchTracker.CompleteBatch(int64(len(written)))
+	if batch.first {
+		close(part.run.firstWritten)
+	}
+
+	part.mu.Lock()
+	part.written[batch.number] = batch
+	for {
+		next, ok := part.written[part.nextCommit]
+		if !ok {
+			break
+		}
+		delete(part.written, part.nextCommit)
+		part.nextCommit++
+		part.rows += int64(len(next.written))
+		//dead-lettered rows are committed as well, a resumed run continues after them
+		me.commitBatch(part.progress, next.rows)
+
+		//recording the keys of the migrated rows for rollback
+		if me.CurrentSnapshot != nil && len(next.written) > 0 {
+			if err := me.RollBackManager.UpdateSnapshotWithMigratedData(me.CurrentSnapshot, next.written); err != nil {
+				me.Logger.Error("Failed to update rollback snapshot", err.Error())
+				//continue migration but log the error
+			}
+		}
+	}
+	done := part.readDone && part.nextCommit > part.read
+	part.mu.Unlock()
+
+	if done {
+		p.completePart(part)
+	}
+	return nil
+}
+
+// marking a part whose batches are all committed as completed, its table completes with the last part
+func (p *loadPipeline) completePart(part *partRun) {
+	me := p.engine
+	run := part.run
+	if part.progress != run.progress {
+		me.completeCheckpoint(part.progress)
+		me.ProgressTracker.CompletedChunk()
+		me.Logger.TableProgress(part.name, part.rows, "Range Migration Completed Successfully")
+	}
+
+	run.mu.Lock()
+	run.rows += part.rows
+	run.remaining--
+	done := run.remaining == 0
+	run.mu.Unlock()
+
+	if done {
+		p.completeTable(run)
+	}
+}
+
+// marking a table whose parts are all committed as completed and releasing the tables waiting for it
+func (p *loadPipeline) completeTable(run *tableRun) {
+	me := p.engine
+	me.completeCheckpoint(run.progress)
+	me.ProgressTracker.CompletedTable()
+	me.Logger.TableProgress(run.table, run.rows, "Table Migration Completed Successfully")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed++
+	p.rows += run.rows
+	log.Printf("Successfully migrated table %s (%d/%d) with %d rows", run.table, p.completed, len(p.runs), run.rows)
+
+	for _, dependent := range p.dependents[run.table] {
+		p.pending[dependent]--
+		if p.pending[dependent] == 0 {
+			p.dispatch(p.runs[dependent])
+		}
+	}
+	p.remaining--
+	if p.remaining == 0 {
+		close(p.ready)
+	}
+}
@@ -0,0 +1,483 @@
+package migration
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+	"github.com/SusheelSathyaraj/DataMigrationTool/transform"
+	"github.com/go-sql-driver/mysql"
+)
+
+// mock target recording the order of imported tables and how many imports ran at once
+type concurrentTargetClient struct {
+	*test.CompleteMockDatabaseClient
+	mu          sync.Mutex
+	delay       time.Duration
+	failOn      string
+	failOnID    int
+	deadlockID  int //id of a row whose first import fails with a deadlock
+	inFlight    int
+	maxInFlight int
+	loaded      []string
+}
+
+func (c *concurrentTargetClient) ImportData(data []map[string]interface{}) error {
+	table, _ := data[0]["_source_table"].(string)
+
+	c.mu.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.loaded = append(c.loaded, table)
+	deadlock := c.deadlockID != 0 && containsID(data, c.deadlockID)
+	if deadlock {
+		c.deadlockID = 0
+	}
+	c.mu.Unlock()
+
+	if deadlock {
+		return &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	}
+
+	if table == c.failOn {
+		return fmt.Errorf("disk full")
+	}
+	for _, row := range data {
+		if c.failOnID != 0 && row["id"] == c.failOnID {
+			return fmt.Errorf("duplicate key %d", c.failOnID)
+		}
+	}
+	return c.CompleteMockDatabaseClient.ImportData(data)
+}
+
+func containsID(data []map[string]interface{}, id int) bool {
+	for _, row := range data {
+		if row["id"] == id {
+			return true
+		}
+	}
+	return false
+}
+
+func newPipelineTestEngine(t *testing.T, source database.DatabaseClient, target database.DatabaseClient, tables []string, workers int) *MigrationEngine {
+	config := MigrationConfig{
+		Mode:       FullMigration,
+		SourceDb:   "mysql",
+		TargetDb:   "postgresql",
+		Tables:     tables,
+		BatchSize:  3,
+		Workers:    workers,
+		Concurrent: true,
+	}
+
+	engine := NewMigrationEngine(config, source, target)
+	engine.RollBackManager.snapshotsDir = t.TempDir()
+	engine.CheckpointStore = NewCheckpointStore(t.TempDir())
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+func addPipelineTestTable(client *test.CompleteMockDatabaseClient, table string, rows int) {
+	data := make([]map[string]interface{}, rows)
+	for i := range data {
+		data[i] = map[string]interface{}{"id": i + 1, "name": fmt.Sprintf("%s_%d", table, i+1)}
+	}
+	client.AddTestData(table, data)
+}
+
+func TestPipelineLoadsTablesConcurrently(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), delay: 5 * time.Millisecond}
+	tables := []string{"users", "orders", "products", "invoices"}
+	for i, table := range tables {
+		addPipelineTestTable(sourceClient, table, 10*(i+1))
+	}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, tables, 4)
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	if result.TotalRowsMigrated != 100 {
+		t.Errorf("Expected 100 rows to be migrated, got %d", result.TotalRowsMigrated)
+	}
+	for i, table := range tables {
+		if rows := targetClient.GetImportedTableRowCount(table); rows != 10*(i+1) {
+			t.Errorf("Expected %d rows in table %s, got %d", 10*(i+1), table, rows)
+		}
+	}
+	if targetClient.maxInFlight < 2 {
+		t.Errorf("Expected batches to be written concurrently, at most %d were in flight", targetClient.maxInFlight)
+	}
+	if targetClient.maxInFlight > 4 {
+		t.Errorf("Expected at most 4 writers, %d batches were in flight", targetClient.maxInFlight)
+	}
+}
+
+func TestPipelineWaitsForReferencedTables(t *testing.T) {
+	sourceClient := newConstraintMockClient("mysql")
+	sourceClient.constraints["orders"] = &database.TableConstraints{
+		Table:       "orders",
+		ForeignKeys: []database.ForeignKeyDefinition{{Name: "fk_customer", Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}}},
+	}
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "orders", 9)
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "customers", 9)
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "products", 9)
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), delay: 2 * time.Millisecond}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"orders", "customers", "products"}, 3)
+	engine.Config.SkipConstraints = true
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	//every batch of customers is written before the first batch of orders
+	lastCustomers, firstOrders := -1, -1
+	for i, table := range targetClient.loaded {
+		if table == "customers" {
+			lastCustomers = i
+		}
+		if table == "orders" && firstOrders == -1 {
+			firstOrders = i
+		}
+	}
+	if firstOrders < lastCustomers {
+		t.Errorf("Expected orders to be loaded after customers, got %v", targetClient.loaded)
+	}
+}
+
+func TestPipelineStopsOnFirstError(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failOn: "orders"}
+	tables := []string{"users", "orders", "products"}
+	for _, table := range tables {
+		addPipelineTestTable(sourceClient, table, 30)
+	}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, tables, 3)
+	_, err := engine.ExecuteMigration()
+	if err == nil || !strings.Contains(err.Error(), "orders") {
+		t.Fatalf("Expected the failing orders table to fail the migration, got %v", err)
+	}
+
+	checkpoint, loadErr := engine.CheckpointStore.Load(engine.CurrentCheckpoint.RunID)
+	if loadErr != nil {
+		t.Fatalf("Expected checkpoint of the failed run, %v", loadErr)
+	}
+	if checkpoint.Status != "failed" || checkpoint.Progress["orders"].Status == "completed" {
+		t.Errorf("Expected a failed run with orders not completed, got %s run with orders %+v", checkpoint.Status, checkpoint.Progress["orders"])
+	}
+	if rows := targetClient.GetImportedTableRowCount("orders"); rows != 0 {
+		t.Errorf("Expected no orders rows to be written, got %d", rows)
+	}
+}
+
+// mock source splitting tables into ranges of their integer id and recording the ranges it streamed
+type rangeMockClient struct {
+	*test.CompleteMockDatabaseClient
+	mu        sync.Mutex
+	streamed  []int64
+	dropRange int64 //start of a range whose first stream loses its connection after one batch
+}
+
+// iterator losing its connection after the first batch
+type droppingIterator struct {
+	database.RowIterator
+	batches int
+}
+
+func (d *droppingIterator) Next() bool {
+	if d.batches == 1 {
+		return false
+	}
+	d.batches++
+	return d.RowIterator.Next()
+}
+
+func (d *droppingIterator) Err() error {
+	if d.batches == 1 {
+		return driver.ErrBadConn
+	}
+	return d.RowIterator.Err()
+}
+
+func (r *rangeMockClient) SplitTable(tableName string, rowsPerRange int64) ([]database.KeyRange, error) {
+	rows, err := r.FetchAllData([]string{tableName})
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	last := int64(0)
+	for _, row := range rows {
+		last = max(last, int64(row["id"].(int)))
+	}
+
+	var ranges []database.KeyRange
+	for start := int64(1); start <= last; start += rowsPerRange {
+		ranges = append(ranges, database.KeyRange{Column: "id", Start: start, End: start + rowsPerRange, Last: start+rowsPerRange > last})
+	}
+	return ranges, nil
+}
+
+func (r *rangeMockClient) StreamTableRange(ctx context.Context, tableName string, keyRange database.KeyRange, lastKey interface{}, batchSize int) (database.RowIterator, error) {
+	r.mu.Lock()
+	r.streamed = append(r.streamed, keyRange.Start)
+	drop := r.dropRange != 0 && keyRange.Start == r.dropRange
+	if drop {
+		r.dropRange = 0
+	}
+	r.mu.Unlock()
+
+	rows, err := r.FetchAllData([]string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	var filtered []map[string]interface{}
+	for _, row := range rows {
+		id := int64(row["id"].(int))
+		if id >= keyRange.Start && (keyRange.Last || id < keyRange.End) && (lastKey == nil || id > lastKey.(int64)) {
+			filtered = append(filtered, row)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i]["id"].(int) < filtered[j]["id"].(int) })
+	iterator := database.NewSliceRowIterator(filtered, batchSize)
+	if drop {
+		return &droppingIterator{RowIterator: iterator}, nil
+	}
+	return iterator, nil
+}
+
+func TestPipelineSplitsLargeTables(t *testing.T) {
+	sourceClient := &rangeMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql")}
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "events", 20)
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "users", 4)
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), delay: time.Millisecond}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"events", "users"}, 3)
+	engine.Config.ChunkRows = 5
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	if result.TotalRowsMigrated != 24 || targetClient.GetImportedTableRowCount("events") != 20 {
+		t.Errorf("Expected 24 rows with all 20 events, got %d rows with %d events", result.TotalRowsMigrated, targetClient.GetImportedTableRowCount("events"))
+	}
+	if metrics := engine.ProgressTracker.GetMetrics(); metrics.TotalChunks != 4 || metrics.ProcessedChunks != 4 {
+		t.Errorf("Expected 4 of 4 chunks to be tracked, got %d of %d", metrics.ProcessedChunks, metrics.TotalChunks)
+	}
+	if progress := engine.CurrentCheckpoint.Progress["users"]; len(progress.Chunks) != 0 {
+		t.Errorf("Expected the small users table to be read as a whole, got %d chunks", len(progress.Chunks))
+	}
+}
+
+func TestResumeContinuesKeyRanges(t *testing.T) {
+	stateDir := t.TempDir()
+	sourceClient := &rangeMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql")}
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "events", 20)
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), failOnID: 13}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	//every range is a single batch, so a failed range never leaves rows behind
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"events"}, 2)
+	engine.CheckpointStore = NewCheckpointStore(stateDir)
+	engine.Config.ChunkRows, engine.Config.BatchSize = 5, 5
+	if _, err := engine.ExecuteMigration(); err == nil {
+		t.Fatalf("Expected the first run to fail")
+	}
+
+	checkpoint, err := engine.CheckpointStore.Load(engine.CurrentCheckpoint.RunID)
+	if err != nil {
+		t.Fatalf("Expected checkpoint of the failed run, %v", err)
+	}
+	var pending []int64
+	for _, chunk := range checkpoint.Progress["events"].Chunks {
+		if chunk.Status != "completed" {
+			pending = append(pending, chunk.Range.Start)
+		}
+	}
+	//the first range is written before any other, the failed one stays pending
+	if len(checkpoint.Progress["events"].Chunks) != 4 || len(pending) == 0 || pending[0] == 1 || !strings.Contains(fmt.Sprint(pending), "11") {
+		t.Fatalf("Expected the range starting at 11 to be pending, got pending ranges %v", pending)
+	}
+
+	targetClient.failOnID = 0
+	sourceClient.streamed = nil
+	resumed := newPipelineTestEngine(t, sourceClient, targetClient, nil, 2)
+	resumed.CheckpointStore = NewCheckpointStore(stateDir)
+	resumed.Config.ResumeRunID = checkpoint.RunID
+	resumed.Config.ChunkRows, resumed.Config.BatchSize = 5, 5
+	if _, err := resumed.ExecuteMigration(); err != nil {
+		t.Fatalf("Resumed run failed, %v", err)
+	}
+
+	sort.Slice(sourceClient.streamed, func(i, j int) bool { return sourceClient.streamed[i] < sourceClient.streamed[j] })
+	if fmt.Sprint(sourceClient.streamed) != fmt.Sprint(pending) {
+		t.Errorf("Expected only the pending ranges %v to be read again, got %v", pending, sourceClient.streamed)
+	}
+	if rows := targetClient.GetImportedTableRowCount("events"); rows != 20 {
+		t.Errorf("Expected 20 events without duplicates, got %d", rows)
+	}
+}
+
+func TestPipelineStopsWhenInterrupted(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), delay: 20 * time.Millisecond}
+	addPipelineTestTable(sourceClient, "users", 300)
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"users"}, 2)
+	engine.Config.CreateBackup = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := engine.ExecuteMigrationContext(ctx); err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Fatalf("Expected the cancelled migration to be interrupted, got %v", err)
+	}
+
+	checkpoint, err := engine.CheckpointStore.Load(engine.CurrentCheckpoint.RunID)
+	if err != nil {
+		t.Fatalf("Expected checkpoint of the interrupted run, %v", err)
+	}
+	if users := checkpoint.Progress["users"]; checkpoint.Status != "interrupted" || users.RowsCommitted >= 300 {
+		t.Errorf("Expected an interrupted run with part of the users committed, got %+v in %s run", users, checkpoint.Status)
+	}
+	if imported := targetClient.GetImportedTableRowCount("users"); int64(imported) != checkpoint.Progress["users"].RowsCommitted {
+		t.Errorf("Expected the %d imported rows to be committed in the checkpoint, got %d", imported, checkpoint.Progress["users"].RowsCommitted)
+	}
+
+	snapshot, err := engine.RollBackManager.LoadSnapshot(engine.CurrentSnapshot.ID)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot, %v", err)
+	}
+	if snapshot.Status != "interrupted" {
+		t.Errorf("Expected the snapshot to be marked interrupted, got %s", snapshot.Status)
+	}
+}
+
+func TestPipelineRetriesTransientErrors(t *testing.T) {
+	sourceClient := &rangeMockClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("mysql"), dropRange: 11}
+	addPipelineTestTable(sourceClient.CompleteMockDatabaseClient, "events", 20)
+	targetClient := &concurrentTargetClient{CompleteMockDatabaseClient: test.NewCompleteMockDatabaseClient("postgresql"), deadlockID: 5}
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"events"}, 2)
+	engine.Config.ChunkRows = 10
+	engine.Config.Retry = database.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Expected the transient errors to be retried, got %v", err)
+	}
+
+	if result.Retries != 2 {
+		t.Errorf("Expected a retried write and a retried read, got %d retries", result.Retries)
+	}
+	if rows := targetClient.GetImportedTableRowCount("events"); rows != 20 {
+		t.Errorf("Expected 20 events without duplicates, got %d", rows)
+	}
+	//the dropped range is read again after the batch it already handed to the writers
+	if len(sourceClient.streamed) != 3 {
+		t.Errorf("Expected the dropped range to be streamed again, got streams of %v", sourceClient.streamed)
+	}
+}
+
+func TestPipelineTransformsRowsBeforeWriting(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel", "age": "34"},
+		{"id": 2, "name": "Ana", "age": "unknown"},
+		{"id": 3, "name": "Bo", "age": "27"},
+	})
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	transforms, err := transform.Compile(map[string][]config.TransformStep{
+		"users": {{Rename: map[string]string{"name": "given_name"}}, {Cast: map[string]string{"age": "int"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile transforms, %v", err)
+	}
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"users"}, 2)
+	engine.Config.Transforms = transforms
+	engine.Config.DeadLetterFile = filepath.Join(t.TempDir(), "failed_rows.jsonl")
+	engine.Config.ErrorBudget = 1
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	imported := targetClient.GetImportedData("users")
+	if len(imported) != 2 || result.DeadLetteredRows != 1 {
+		t.Fatalf("Expected 2 transformed users and the uncastable one dead-lettered, got %v and %d dead-lettered", imported, result.DeadLetteredRows)
+	}
+	for _, row := range imported {
+		if _, ok := row["name"]; ok || row["given_name"] == nil {
+			t.Errorf("Expected name to be renamed to given_name, got %v", row)
+		}
+		if _, ok := row["age"].(int64); !ok {
+			t.Errorf("Expected age to be cast to an int, got %T", row["age"])
+		}
+	}
+}
+
+func TestPipelineWritesMappedTablesAndColumns(t *testing.T) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+	sourceClient.AddTestData("userAccounts", []map[string]interface{}{
+		{"id": 1, "userName": "Susheel"},
+		{"id": 2, "userName": "Ana"},
+	})
+	sourceClient.Connect()
+	targetClient.Connect()
+
+	mapping, err := database.NewNameMapping(config.MappingConfig{
+		ColumnCase: "snake_case",
+		Tables:     map[string]config.TableMapping{"userAccounts": {Target: "user_accounts"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create mapping, %v", err)
+	}
+
+	engine := newPipelineTestEngine(t, sourceClient, targetClient, []string{"userAccounts"}, 1)
+	engine.Config.Mapping = mapping
+	engine.Config.ValidateData = true
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Expected the mapped table to be migrated and validated, got %v", err)
+	}
+
+	if targetClient.GetImportedTableRowCount("userAccounts") != 0 {
+		t.Errorf("Expected no rows under the source table name")
+	}
+	imported := targetClient.GetImportedData("user_accounts")
+	if len(imported) != 2 || imported[0]["user_name"] == nil {
+		t.Errorf("Expected 2 rows with mapped columns in user_accounts, got %v", imported)
+	}
+}
@@ -0,0 +1,221 @@
+package migration
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// order in which the tables of a migration are loaded, derived from the foreign keys of the source
+type MigrationPlan struct {
+	Order        []string                              //tables in load order, referenced tables first
+	Dependencies map[string][]string                   //migrated tables each table references
+	Cycles       [][]string                            //groups of tables referencing each other, including self references
+	Constraints  map[string]*database.TableConstraints //source constraints of the tables, missing when they could not be read
+	cycleOf      map[string]int
+}
+
+// building the load plan of the tables from the foreign keys in the source catalog, tables keep their
+// configured order when the source cannot describe its constraints
+func BuildMigrationPlan(source database.DatabaseClient, tables []string) *MigrationPlan {
+	plan := &MigrationPlan{
+		Dependencies: make(map[string][]string),
+		Constraints:  make(map[string]*database.TableConstraints),
+		cycleOf:      make(map[string]int),
+	}
+
+	reader, ok := source.(database.ConstraintClient)
+	if !ok {
+		plan.Order = append([]string{}, tables...)
+		return plan
+	}
+
+	for _, table := range tables {
+		constraints, err := reader.DescribeConstraints(table)
+		if err != nil {
+			log.Printf("Warning: could not read foreign keys of table %s, it is loaded in configured order, %v", table, err)
+			continue
+		}
+		plan.Constraints[table] = constraints
+	}
+
+	plan.order(tables)
+	return plan
+}
+
+// sorting the tables topologically, tables referencing each other are collapsed into a cycle that is
+// loaded as a whole and whose foreign keys are deferred until all its tables are loaded
+func (mp *MigrationPlan) order(tables []string) {
+	position := make(map[string]int, len(tables))
+	for i, table := range tables {
+		position[strings.ToLower(table)] = i
+	}
+
+	edges := make([][]int, len(tables))
+	selfReferencing := make([]bool, len(tables))
+	for i, table := range tables {
+		constraints := mp.Constraints[table]
+		if constraints == nil {
+			continue
+		}
+		seen := make(map[int]bool)
+		for _, foreignKey := range constraints.ForeignKeys {
+			parent, ok := position[strings.ToLower(foreignKey.ReferencedTable)]
+			if !ok || seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			if parent == i {
+				selfReferencing[i] = true
+				continue
+			}
+			edges[i] = append(edges[i], parent)
+			mp.Dependencies[table] = append(mp.Dependencies[table], tables[parent])
+		}
+	}
+
+	components := stronglyConnectedComponents(edges)
+
+	//loading a component once all components it references are loaded, the earliest configured first
+	componentOf := make([]int, len(tables))
+	for c, members := range components {
+		for _, member := range members {
+			componentOf[member] = c
+		}
+	}
+	pending := make([]int, len(components))
+	dependents := make([][]int, len(components))
+	for c, members := range components {
+		seen := make(map[int]bool)
+		for _, member := range members {
+			for _, parent := range edges[member] {
+				p := componentOf[parent]
+				if p == c || seen[p] {
+					continue
+				}
+				seen[p] = true
+				pending[c]++
+				dependents[p] = append(dependents[p], c)
+			}
+		}
+	}
+
+	var ready []int
+	for c := range components {
+		if pending[c] == 0 {
+			ready = append(ready, c)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return components[ready[i]][0] < components[ready[j]][0] })
+		c := ready[0]
+		ready = ready[1:]
+
+		members := components[c]
+		if len(members) > 1 || selfReferencing[members[0]] {
+			cycle := make([]string, len(members))
+			for i, member := range members {
+				cycle[i] = tables[member]
+				mp.cycleOf[strings.ToLower(tables[member])] = len(mp.Cycles) + 1
+			}
+			mp.Cycles = append(mp.Cycles, cycle)
+		}
+		for _, member := range members {
+			mp.Order = append(mp.Order, tables[member])
+		}
+		for _, dependent := range dependents[c] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+}
+
+// finding the strongly connected components of the graph with Tarjan's algorithm, members of each
+// component are sorted by their position
+func stronglyConnectedComponents(edges [][]int) [][]int {
+	index := make([]int, len(edges))
+	lowLink := make([]int, len(edges))
+	onStack := make([]bool, len(edges))
+	for i := range index {
+		index[i] = -1
+	}
+
+	var stack []int
+	var components [][]int
+	counter := 0
+
+	var visit func(node int)
+	visit = func(node int) {
+		index[node], lowLink[node] = counter, counter
+		counter++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range edges[node] {
+			if index[next] == -1 {
+				visit(next)
+				lowLink[node] = min(lowLink[node], lowLink[next])
+			} else if onStack[next] {
+				lowLink[node] = min(lowLink[node], index[next])
+			}
+		}
+
+		if lowLink[node] == index[node] {
+			var component []int
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				component = append(component, member)
+				if member == node {
+					break
+				}
+			}
+			sort.Ints(component)
+			components = append(components, component)
+		}
+	}
+
+	for node := range edges {
+		if index[node] == -1 {
+			visit(node)
+		}
+	}
+	return components
+}
+
+// checking if a foreign key lies within a cycle, such keys can only be created once the whole cycle is loaded
+func (mp *MigrationPlan) IsDeferred(table string, foreignKey database.ForeignKeyDefinition) bool {
+	return mp.inSameCycle(table, foreignKey.ReferencedTable)
+}
+
+// checking if two tables belong to the same cycle, such tables are loaded without waiting for each other
+func (mp *MigrationPlan) inSameCycle(table, other string) bool {
+	cycle, ok := mp.cycleOf[strings.ToLower(table)]
+	return ok && mp.cycleOf[strings.ToLower(other)] == cycle
+}
+
+// printing the load order, dependencies and cycles of the plan
+func (mp *MigrationPlan) Print() {
+	fmt.Println("\n=== Migration Plan===")
+	for i, table := range mp.Order {
+		if dependencies := mp.Dependencies[table]; len(dependencies) > 0 {
+			fmt.Printf("%d. %s (after %s)\n", i+1, table, strings.Join(dependencies, ", "))
+		} else {
+			fmt.Printf("%d. %s\n", i+1, table)
+		}
+	}
+
+	if len(mp.Cycles) > 0 {
+		fmt.Println("\n Foreign key cycles, their constraints are deferred until all tables of the cycle are loaded:")
+		for _, cycle := range mp.Cycles {
+			fmt.Printf("-%s\n", strings.Join(cycle, " <-> "))
+		}
+	}
+	fmt.Println("===============")
+}
@@ -0,0 +1,110 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+func TestBuildMigrationPlan(t *testing.T) {
+	references := func(tables ...string) *database.TableConstraints {
+		constraints := &database.TableConstraints{}
+		for _, table := range tables {
+			constraints.ForeignKeys = append(constraints.ForeignKeys, database.ForeignKeyDefinition{ReferencedTable: table})
+		}
+		return constraints
+	}
+
+	testCases := []struct {
+		name        string
+		tables      []string
+		constraints map[string]*database.TableConstraints
+		order       []string
+		cycles      [][]string
+	}{
+		{
+			name:        "referenced tables first",
+			tables:      []string{"order_items", "orders", "customers"},
+			constraints: map[string]*database.TableConstraints{"order_items": references("orders"), "orders": references("customers")},
+			order:       []string{"customers", "orders", "order_items"},
+		},
+		{
+			name:        "independent tables keep configured order",
+			tables:      []string{"logs", "orders", "customers", "audit"},
+			constraints: map[string]*database.TableConstraints{"orders": references("customers", "warehouses")},
+			order:       []string{"logs", "customers", "orders", "audit"},
+		},
+		{
+			name:        "self reference",
+			tables:      []string{"employees", "departments"},
+			constraints: map[string]*database.TableConstraints{"employees": references("employees", "departments")},
+			order:       []string{"departments", "employees"},
+			cycles:      [][]string{{"employees"}},
+		},
+		{
+			name:   "cycle loaded as a whole",
+			tables: []string{"invoices", "payments", "customers", "accounts"},
+			constraints: map[string]*database.TableConstraints{
+				"invoices": references("payments", "customers"),
+				"payments": references("invoices"),
+				"accounts": references("payments"),
+			},
+			order:  []string{"customers", "invoices", "payments", "accounts"},
+			cycles: [][]string{{"invoices", "payments"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := newConstraintMockClient("mysql")
+			source.constraints = tc.constraints
+
+			plan := BuildMigrationPlan(source, tc.tables)
+			if !reflect.DeepEqual(plan.Order, tc.order) {
+				t.Errorf("Expected order %v, got %v", tc.order, plan.Order)
+			}
+			if !reflect.DeepEqual(plan.Cycles, tc.cycles) {
+				t.Errorf("Expected cycles %v, got %v", tc.cycles, plan.Cycles)
+			}
+		})
+	}
+}
+
+func TestMigrationPlanDeferredForeignKeys(t *testing.T) {
+	source := newConstraintMockClient("postgresql")
+	source.constraints["invoices"] = &database.TableConstraints{ForeignKeys: []database.ForeignKeyDefinition{
+		{Name: "fk_payment", ReferencedTable: "payments"},
+		{Name: "fk_customer", ReferencedTable: "customers"},
+	}}
+	source.constraints["payments"] = &database.TableConstraints{ForeignKeys: []database.ForeignKeyDefinition{
+		{Name: "fk_invoice", ReferencedTable: "invoices"},
+	}}
+
+	plan := BuildMigrationPlan(source, []string{"invoices", "payments", "customers"})
+	for _, foreignKey := range source.constraints["invoices"].ForeignKeys {
+		if deferred := plan.IsDeferred("invoices", foreignKey); deferred != (foreignKey.Name == "fk_payment") {
+			t.Errorf("Foreign key %s deferred %v", foreignKey.Name, deferred)
+		}
+	}
+}
+
+func TestBuildMigrationPlanWithoutConstraintClient(t *testing.T) {
+	source := newConstraintMockClient("mysql").CompleteMockDatabaseClient
+
+	plan := BuildMigrationPlan(source, []string{"orders", "customers"})
+	if !reflect.DeepEqual(plan.Order, []string{"orders", "customers"}) {
+		t.Errorf("Expected configured order, got %v", plan.Order)
+	}
+}
+
+func TestMigrationEngineLoadsInDependencyOrder(t *testing.T) {
+	engine, _, targetClient := newConstraintTestEngine(t, []string{"orders", "customers"})
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+	if !reflect.DeepEqual(targetClient.loaded, []string{"customers", "orders"}) {
+		t.Errorf("Expected customers to be loaded before orders, got %v", targetClient.loaded)
+	}
+}
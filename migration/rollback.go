@@ -1,35 +1,43 @@
 package migration
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/database"
 	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
 )
 
+// directory holding snapshots and other migration state files
+const snapshotsDirectory = "migration_snapshots"
+
 // type to represent a snapshot of the migration state for rollback
 type MigrationSnapshot struct {
-	ID                string                              `json:"id"`
-	Timestamp         time.Time                           `json:"timestamp"`
-	SourceDB          string                              `json:"source_db"`
-	TargetDB          string                              `json:"target_db"`
-	Tables            []string                            `json:"tables"`
-	PreMigrationState map[string]TableSnapshot            `json:"pre_migration_state"`
-	MigratedData      map[string][]map[string]interface{} `json:"migrated_data"`
-	Status            string                              `json:"status"` //"in_progress", "completed", "failed", "rolled_back"
+	ID                string                   `json:"id"`
+	Timestamp         time.Time                `json:"timestamp"`
+	SourceDB          string                   `json:"source_db"`
+	TargetDB          string                   `json:"target_db"`
+	Tables            []string                 `json:"tables"`
+	PreMigrationState map[string]TableSnapshot `json:"pre_migration_state"`
+	Status            string                   `json:"status"` //"in_progress", "completed", "failed", "interrupted", "rolled_back"
 }
 
 // type to represent a snapshot of the state of the table befoer migration
 type TableSnapshot struct {
-	TableName     string `json:"table_name"`
-	RowCount      int64  `json:"row_count"`
-	ExistedBefore bool   `json:"existed_before"`
-	SchemaHash    string `json:"schema_hash,omitempty"` //for schema tracking
+	TableName     string   `json:"table_name"`
+	RowCount      int64    `json:"row_count"`
+	ExistedBefore bool     `json:"existed_before"`
+	SchemaHash    string   `json:"schema_hash,omitempty"` //for schema tracking
+	KeyColumns    []string `json:"key_columns,omitempty"` //primary key recorded for the migrated rows
 }
 
 // type for handling migration rollbacks
@@ -37,20 +45,14 @@ type RollBackManager struct {
 	targetClient database.DatabaseClient
 	snapshotsDir string
 	logger       *monitoring.MigrationLogger
+	mu           sync.Mutex //serializing snapshot updates of concurrent writers
 }
 
 // creating a new rollback manager
 func NewRollBackManager(targetClient database.DatabaseClient, logger *monitoring.MigrationLogger) *RollBackManager {
-	snapshotsDir := "migration_snapshots"
-
-	//creating snapshots directory if not present
-	if err := os.MkdirAll(snapshotsDir, 0755); err != nil {
-		log.Printf("Warning: Could not create snaphot directory, %v", err)
-	}
-
 	return &RollBackManager{
 		targetClient: targetClient,
-		snapshotsDir: snapshotsDir,
+		snapshotsDir: snapshotsDirectory,
 		logger:       logger,
 	}
 }
@@ -68,21 +70,17 @@ func (rm *RollBackManager) CreateSnapshot(config MigrationConfig) (*MigrationSna
 		TargetDB:          config.TargetDb,
 		Tables:            config.Tables,
 		PreMigrationState: make(map[string]TableSnapshot),
-		MigratedData:      make(map[string][]map[string]interface{}),
 		Status:            "in_progress",
 	}
 
-	//capturing pre-migration state for each tble
-	for _, table := range config.Tables {
+	//capturing pre-migration state for each tble, under the name it has in the target
+	for _, sourceTable := range config.Tables {
+		table := config.Mapping.TargetTable(sourceTable)
 		tableSnapshot, err := rm.captureTableState(table)
 		if err != nil {
+			//a table whose state is unknown could be dropped on rollback although it existed
 			rm.logger.Error("Failed to capture table state", fmt.Sprintf("Table: %s, Error: %v", table, err))
-			//continue with othe tables  instead of failing completely
-			tableSnapshot = TableSnapshot{
-				TableName:     table,
-				RowCount:      0,
-				ExistedBefore: false,
-			}
+			return nil, fmt.Errorf("failed to capture the state of table %s, %v", table, err)
 		}
 		snapshot.PreMigrationState[table] = tableSnapshot
 	}
@@ -94,29 +92,42 @@ func (rm *RollBackManager) CreateSnapshot(config MigrationConfig) (*MigrationSna
 	return snapshot, nil
 }
 
-// capturing the current state of the table
+// capturing the current state of the table, its existence is checked in the catalog of the target
 func (rm *RollBackManager) captureTableState(tableName string) (TableSnapshot, error) {
-	//fetching existing data to check if table exists and get row count
-	existingData, err := rm.targetClient.FetchAllData([]string{tableName})
-
+	client, err := rm.rollbackClient()
 	if err != nil {
-		//table might not exist, which is fine for fresh migration
-		return TableSnapshot{
-			TableName:     tableName,
-			RowCount:      0,
-			ExistedBefore: false,
-		}, nil
+		return TableSnapshot{}, err
 	}
 
-	return TableSnapshot{
+	existed, rowCount, err := client.TableRowCount(tableName)
+	if err != nil {
+		return TableSnapshot{}, err
+	}
+	tableSnapshot := TableSnapshot{
 		TableName:     tableName,
-		RowCount:      int64(len(existingData)),
-		ExistedBefore: true,
-	}, nil
+		RowCount:      rowCount,
+		ExistedBefore: existed,
+	}
+	if !existed {
+		//table is created by the migration, it is dropped on rollback
+		return tableSnapshot, nil
+	}
+
+	//only the keys of rows migrated into existing tables are recorded
+	keyColumns, err := client.PrimaryKeyColumns(tableName)
+	if err != nil {
+		return TableSnapshot{}, err
+	}
+	tableSnapshot.KeyColumns = keyColumns
+	return tableSnapshot, nil
 }
 
 // saving a snapshot to the disc
 func (rm *RollBackManager) saveSnapshot(snapshot *MigrationSnapshot) error {
+	//creating snapshots directory if not present
+	if err := os.MkdirAll(rm.snapshotsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory, %v", err)
+	}
 	fileName := filepath.Join(rm.snapshotsDir, snapshot.ID+".json")
 
 	data, err := json.MarshalIndent(snapshot, "", " ")
@@ -129,31 +140,98 @@ func (rm *RollBackManager) saveSnapshot(snapshot *MigrationSnapshot) error {
 	return nil
 }
 
-// updating the snapshot with migrated data for rollback
-func (rm *RollBackManager) UpdateSnapshotWithMigratedData(snapshotID string, data []map[string]interface{}) error {
-	snapshot, err := rm.LoadSnapshot(snapshotID)
-	if err != nil {
-		return fmt.Errorf("failed to load snapshot, %v", err)
-	}
+// file listing the keys of the rows migrated into a table, one JSON object per line
+func (rm *RollBackManager) migratedKeysFile(snapshotID, tableName string) string {
+	return filepath.Join(rm.snapshotsDir, fmt.Sprintf("%s_%s.keys.jsonl", snapshotID, tableName))
+}
+
+// recording the keys of migrated rows for rollback, appended to a key file per table so each batch costs only its own rows
+func (rm *RollBackManager) UpdateSnapshotWithMigratedData(snapshot *MigrationSnapshot, data []map[string]interface{}) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
 
-	//group data by table
+	//group keys by table
+	lines := make(map[string][][]byte)
 	for _, row := range data {
-		if tableName, ok := row["_source_table"].(string); ok {
-			if snapshot.MigratedData[tableName] == nil {
-				snapshot.MigratedData[tableName] = make([]map[string]interface{}, 0)
-			}
+		tableName, ok := row["_source_table"].(string)
+		if !ok {
+			continue
+		}
+		preState, ok := snapshot.PreMigrationState[tableName]
+		if ok && !preState.ExistedBefore {
+			//table is dropped on rollback, its rows need not be identified
+			continue
+		}
 
-			//Storing the migrated row to potential rollbac
-			cleanRow := make(map[string]interface{})
+		key := make(map[string]interface{})
+		if len(preState.KeyColumns) > 0 {
+			for _, column := range preState.KeyColumns {
+				key[column] = row[column]
+			}
+		} else {
+			//no primary key known, keeping the whole row so it can still be matched once the table has one
 			for k, v := range row {
 				if k != "_source_table" {
-					cleanRow[k] = v
+					key[k] = v
 				}
 			}
-			snapshot.MigratedData[tableName] = append(snapshot.MigratedData[tableName], cleanRow)
 		}
+		line, err := json.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("failed to marshal migrated key, %v", err)
+		}
+		lines[tableName] = append(lines[tableName], append(line, '\n'))
 	}
-	return rm.saveSnapshot(snapshot)
+
+	for tableName, tableLines := range lines {
+		file, err := os.OpenFile(rm.migratedKeysFile(snapshot.ID, tableName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open migrated keys file, %v", err)
+		}
+		_, err = file.Write(bytes.Join(tableLines, nil))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write migrated keys, %v", err)
+		}
+	}
+	return nil
+}
+
+// loading the keys of the rows migrated into a table, none if no rows were recorded
+func (rm *RollBackManager) loadMigratedKeys(snapshotID, tableName string) ([]map[string]interface{}, error) {
+	file, err := os.Open(rm.migratedKeysFile(snapshotID, tableName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrated keys file, %v", err)
+	}
+	defer file.Close()
+
+	var keys []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		//decoding numbers as json.Number so large integer keys keep their precision for rollback
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		var key map[string]interface{}
+		if err := decoder.Decode(&key); err != nil {
+			//a line cut short by a crash while appending holds no complete key
+			log.Printf("Warning: Skipping unreadable migrated key of table %s, %v", tableName, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read migrated keys file, %v", err)
+	}
+	return keys, nil
 }
 
 // loading the snapshot to the disc
@@ -193,7 +271,17 @@ func (rm *RollBackManager) MarkSnapshotFailed(snapshotID string) error {
 	return rm.saveSnapshot(snapshot)
 }
 
-// performing rollback using snapshot
+// marking the snapshot as interrupted by a cancelled run, its rows can still be rolled back or the run resumed
+func (rm *RollBackManager) MarkSnapshotInterrupted(snapshotID string) error {
+	snapshot, err := rm.LoadSnapshot(snapshotID)
+	if err != nil {
+		return err
+	}
+	snapshot.Status = "interrupted"
+	return rm.saveSnapshot(snapshot)
+}
+
+// performing rollback using snapshot, fails if any table could not be rolled back
 func (rm *RollBackManager) RollBackMigration(snapshotID string) error {
 	rm.logger.Info(fmt.Sprintf("Starting rollback for migration %s", snapshotID))
 
@@ -206,17 +294,30 @@ func (rm *RollBackManager) RollBackMigration(snapshotID string) error {
 		return fmt.Errorf("migration %s has already been rolled back", snapshotID)
 	}
 
-	//rollback each table
+	//rollback each table, collecting the failures instead of stopping at the first one
+	var failedTables []string
 	for tableName, tableSnapshot := range snapshot.PreMigrationState {
 		rm.logger.Info(fmt.Sprintf("Rolling back table, %s", tableName))
 
-		if err := rm.rollbackTable(tableName, tableSnapshot, snapshot.MigratedData[tableName]); err != nil {
+		if err := rm.rollbackTable(snapshotID, tableName, tableSnapshot); err != nil {
 			rm.logger.Error("Table rollbcak failed", fmt.Sprintf("Table %s, Error: %v", tableName, err))
+			failedTables = append(failedTables, fmt.Sprintf("%s: %v", tableName, err))
+			continue
 		}
 
 		rm.logger.Info(fmt.Sprintf("Successfully rolled back table %s", tableName))
 	}
 
+	if len(failedTables) > 0 {
+		//keeping the snapshot so the rollback can be retried
+		snapshot.Status = "rollback_failed"
+		if err := rm.saveSnapshot(snapshot); err != nil {
+			rm.logger.Error("Failed to update snapshot status", err.Error())
+		}
+		sort.Strings(failedTables)
+		return fmt.Errorf("rollback failed for %d of %d tables, %s", len(failedTables), len(snapshot.PreMigrationState), strings.Join(failedTables, "; "))
+	}
+
 	//marking snapshots as rolled back to avoid recalling
 	snapshot.Status = "rolled_back"
 	if err := rm.saveSnapshot(snapshot); err != nil {
@@ -228,62 +329,81 @@ func (rm *RollBackManager) RollBackMigration(snapshotID string) error {
 }
 
 // rolling back a specific table
-func (rm *RollBackManager) rollbackTable(tableName string, preState TableSnapshot, migratedData []map[string]interface{}) error {
+func (rm *RollBackManager) rollbackTable(snapshotID, tableName string, preState TableSnapshot) error {
 	if !preState.ExistedBefore {
 		//table did not exist before migration, so we need to drop it
 		return rm.dropTable(tableName)
 	} else {
 		//table existed before, so we need to remove only the migrated data
+		migratedData, err := rm.loadMigratedKeys(snapshotID, tableName)
+		if err != nil {
+			return err
+		}
 		return rm.removeMigratedData(tableName, migratedData)
 	}
 }
 
+// returning the target client as a client able to undo migrations
+func (rm *RollBackManager) rollbackClient() (database.RollbackClient, error) {
+	client, ok := rm.targetClient.(database.RollbackClient)
+	if !ok {
+		return nil, fmt.Errorf("target database does not support rollback")
+	}
+	return client, nil
+}
+
 // dropping a table that did not exist before migration
 func (rm *RollBackManager) dropTable(tableName string) error {
-	//TODO:we will need database specific DROP Table command
+	client, err := rm.rollbackClient()
+	if err != nil {
+		return err
+	}
 
 	rm.logger.Info(fmt.Sprintf("Dropping table %s that did not exist before migration", tableName))
 
-	// we are clearing the table instaed of dropping it,
-	//TODO: proper DROP table logic
-
-	return rm.clearTable(tableName)
+	if err := client.DropTable(tableName); err != nil {
+		return err
+	}
+	return nil
 }
 
-// removing migrated data from a table
+// removing migrated data from a table using its primary key
 func (rm *RollBackManager) removeMigratedData(tableName string, migratedData []map[string]interface{}) error {
 	if len(migratedData) == 0 {
 		return nil
 	}
 
-	rm.logger.Info(fmt.Sprintf("Removing %d migrated rows froom table %s", len(migratedData), tableName))
-
-	//TODO: delete operation using promary keys
-	//performing logging to what is to be deleted
-
-	for i, row := range migratedData {
-		if i < 5 { //logging fist 5 rows for verification
-			rm.logger.Info(fmt.Sprintf("Would delete row %v", row))
-		}
+	client, err := rm.rollbackClient()
+	if err != nil {
+		return err
 	}
-	rm.logger.Info(fmt.Sprintf("Successfully removed %d rows from %s", len(migratedData), tableName))
 
-	return nil
-}
+	keyColumns, err := client.PrimaryKeyColumns(tableName)
+	if err != nil {
+		return err
+	}
+	if len(keyColumns) == 0 {
+		return fmt.Errorf("table %s has no primary key, migrated rows cannot be identified", tableName)
+	}
 
-// clearin all data from a table
-func (rm *RollBackManager) clearTable(tableName string) error {
-	rm.logger.Info(fmt.Sprintf("CLearing all data fro table %s", tableName))
+	rm.logger.Info(fmt.Sprintf("Removing %d migrated rows froom table %s by key %s", len(migratedData), tableName, strings.Join(keyColumns, ", ")))
 
-	//TODO: DELETE from Tablename
-	//we are just logginf the action
+	deleted, err := client.DeleteRows(tableName, keyColumns, migratedData)
+	if err != nil {
+		return err
+	}
+	if deleted < int64(len(migratedData)) {
+		rm.logger.Info(fmt.Sprintf("Warning: only %d of %d migrated rows were found in table %s", deleted, len(migratedData), tableName))
+	}
+	rm.logger.Info(fmt.Sprintf("Successfully removed %d rows from %s", deleted, tableName))
 
 	return nil
 }
 
 // returns a list of all snapshots available
 func (rm *RollBackManager) ListSnapshots() ([]MigrationSnapshot, error) {
-	files, err := filepath.Glob(filepath.Join(rm.snapshotsDir, "*.json"))
+	//state files like watermarks and checkpoints share the directory, snapshot ids start with migration_
+	files, err := filepath.Glob(filepath.Join(rm.snapshotsDir, "migration_*.json"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list the snapshots, %v", err)
 	}
@@ -316,8 +436,14 @@ func (rm *RollBackManager) CleanupOldSnapshots(maxDuration time.Duration) error
 	cleaned := 0
 
 	for _, snapshot := range snapshots {
-		if snapshot.Timestamp.Before(cutoffTime) && (snapshot.Status == "completed" || snapshot.Status == "rolled-back") {
+		if snapshot.Timestamp.Before(cutoffTime) && (snapshot.Status == "completed" || snapshot.Status == "rolled_back") {
 			filename := filepath.Join(rm.snapshotsDir, snapshot.ID+".json")
+			keyFiles, _ := filepath.Glob(filepath.Join(rm.snapshotsDir, snapshot.ID+"_*.keys.jsonl"))
+			for _, keyFile := range keyFiles {
+				if err := os.Remove(keyFile); err != nil {
+					log.Printf("Warning: Could not remove migrated keys file %s, %v", keyFile, err)
+				}
+			}
 			if err := os.Remove(filename); err != nil {
 				log.Printf("Warning: Could not remove all snapshots %s, %v", filename, err)
 			} else {
@@ -0,0 +1,276 @@
+package migration
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/monitoring"
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newRollbackTestEngine(t *testing.T, tables []string) (*MigrationEngine, *test.CompleteMockDatabaseClient, *test.CompleteMockDatabaseClient) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+
+	config := MigrationConfig{
+		Mode:         FullMigration,
+		SourceDb:     "mysql",
+		TargetDb:     "postgresql",
+		Tables:       tables,
+		CreateBackup: true,
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	t.Cleanup(func() {
+		sourceClient.Close()
+		targetClient.Close()
+	})
+
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+	engine.RollBackManager.snapshotsDir = t.TempDir()
+	t.Cleanup(engine.Close)
+	return engine, sourceClient, targetClient
+}
+
+func TestRollBackRemovesOnlyMigratedRows(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"users"})
+
+	//row already present in the target before the migration
+	targetClient.ImportData([]map[string]interface{}{
+		{"id": 100, "name": "Existing", "_source_table": "users"},
+	})
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Sathyaraj"},
+	})
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+	if targetClient.GetImportedTableRowCount("users") != 3 {
+		t.Fatalf("Expected 3 rows in target after migration, got %d", targetClient.GetImportedTableRowCount("users"))
+	}
+
+	if err := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID); err != nil {
+		t.Fatalf("Rollback failed, %v", err)
+	}
+
+	remaining := targetClient.GetImportedData("users")
+	if len(remaining) != 1 || remaining[0]["id"] != 100 {
+		t.Errorf("Expected only the pre-existing row to remain, got %v", remaining)
+	}
+
+	snapshot, err := engine.RollBackManager.LoadSnapshot(engine.CurrentSnapshot.ID)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot, %v", err)
+	}
+	if snapshot.Status != "rolled_back" {
+		t.Errorf("Expected snapshot status rolled_back, got %s", snapshot.Status)
+	}
+
+	if err := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID); err == nil {
+		t.Errorf("Expected error rolling back the same migration twice, got nil")
+	}
+}
+
+func TestRollBackRecordsOnlyMigratedKeys(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"users", "orders"})
+
+	targetClient.ImportData([]map[string]interface{}{
+		{"id": 100, "name": "Existing", "_source_table": "users"},
+	})
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Sathyaraj"},
+	})
+	sourceClient.AddTestData("orders", []map[string]interface{}{
+		{"id": 1, "amount": 10.5},
+	})
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	data, err := os.ReadFile(engine.RollBackManager.migratedKeysFile(engine.CurrentSnapshot.ID, "users"))
+	if err != nil {
+		t.Fatalf("Failed to read migrated keys, %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || strings.Contains(string(data), "Susheel") {
+		t.Errorf("Expected one line per migrated row holding only its key, got %q", data)
+	}
+
+	//rows of a table created by the migration are not recorded, the table is dropped on rollback
+	if _, err := os.Stat(engine.RollBackManager.migratedKeysFile(engine.CurrentSnapshot.ID, "orders")); !os.IsNotExist(err) {
+		t.Errorf("Expected no migrated keys for the new table, got %v", err)
+	}
+}
+
+func TestRollBackDropsNewTables(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"orders"})
+
+	sourceClient.AddTestData("orders", []map[string]interface{}{
+		{"id": 1, "amount": 10.5},
+	})
+
+	//the table is missing in the target when the snapshot is taken
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+	if engine.CurrentSnapshot.PreMigrationState["orders"].ExistedBefore {
+		t.Fatalf("Expected orders to be recorded as new table")
+	}
+
+	if err := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID); err != nil {
+		t.Fatalf("Rollback failed, %v", err)
+	}
+	if targetClient.GetImportedTableRowCount("orders") != 0 {
+		t.Errorf("Expected orders table to be dropped, got %d rows", targetClient.GetImportedTableRowCount("orders"))
+	}
+}
+
+func TestRollBackSnapshotFailsWhenTableStateUnknown(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"users"})
+
+	targetClient.ImportData([]map[string]interface{}{
+		{"id": 100, "name": "Existing", "_source_table": "users"},
+	})
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+	})
+
+	//an error reading the target must not record the existing table as new, it would be dropped on rollback
+	targetClient.SetFailOnFetch("users")
+	if _, err := engine.ExecuteMigration(); err == nil || !strings.Contains(err.Error(), "users") {
+		t.Fatalf("Expected snapshot error naming table users, got %v", err)
+	}
+	if engine.CurrentSnapshot != nil {
+		t.Errorf("Expected no snapshot, got %s", engine.CurrentSnapshot.ID)
+	}
+	if targetClient.GetImportedTableRowCount("users") != 1 {
+		t.Errorf("Expected no rows migrated without a snapshot, got %d rows", targetClient.GetImportedTableRowCount("users"))
+	}
+}
+
+func TestRollBackSnapshotCountsExistingRows(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"users", "orders"})
+
+	targetClient.ImportData([]map[string]interface{}{
+		{"id": 100, "name": "Existing", "_source_table": "users"},
+		{"id": 101, "name": "Existing", "_source_table": "users"},
+	})
+	sourceClient.AddTestData("users", []map[string]interface{}{{"id": 1, "name": "Susheel"}})
+	sourceClient.AddTestData("orders", []map[string]interface{}{{"id": 1, "amount": 10.5}})
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+	users, orders := engine.CurrentSnapshot.PreMigrationState["users"], engine.CurrentSnapshot.PreMigrationState["orders"]
+	if !users.ExistedBefore || users.RowCount != 2 || len(users.KeyColumns) != 1 {
+		t.Errorf("Expected users to exist with 2 rows and key id, got %+v", users)
+	}
+	if orders.ExistedBefore || orders.RowCount != 0 {
+		t.Errorf("Expected orders to be recorded as new table, got %+v", orders)
+	}
+}
+
+func TestRollBackReportsTableFailures(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"users"})
+
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+	})
+	targetClient.ImportData([]map[string]interface{}{
+		{"id": 100, "name": "Existing", "_source_table": "users"},
+	})
+	targetClient.SetPrimaryKey(nil)
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	err := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID)
+	if err == nil || !strings.Contains(err.Error(), "users") {
+		t.Fatalf("Expected rollback error naming table users, got %v", err)
+	}
+
+	snapshot, _ := engine.RollBackManager.LoadSnapshot(engine.CurrentSnapshot.ID)
+	if snapshot.Status != "rollback_failed" {
+		t.Errorf("Expected snapshot status rollback_failed, got %s", snapshot.Status)
+	}
+
+	//the failed rollback can be retried once the table has a primary key
+	targetClient.SetPrimaryKey([]string{"id"})
+	if err := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID); err != nil {
+		t.Errorf("Expected retried rollback to succeed, got %v", err)
+	}
+}
+
+func TestRollBackUnsupportedTarget(t *testing.T) {
+	engine, sourceClient, targetClient := newRollbackTestEngine(t, []string{"users"})
+
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+	})
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	//hiding the rollback methods of the mock
+	engine.RollBackManager.targetClient = struct{ database.DatabaseClient }{targetClient}
+	if err := engine.RollBackManager.RollBackMigration(engine.CurrentSnapshot.ID); err == nil {
+		t.Errorf("Expected error for target without rollback support, got nil")
+	}
+}
+
+func TestRollBackRowsMigratedFromSQLIntoMongoDB(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("rollback", func(mt *mtest.T) {
+		targetClient := database.NewMongoDBClient("", "test")
+		targetClient.Client, targetClient.Database = mt.Client, mt.DB
+		rm := &RollBackManager{targetClient: targetClient, snapshotsDir: t.TempDir(), logger: monitoring.NewMigrationLogger()}
+
+		snapshot := &MigrationSnapshot{
+			ID:                "migration_mysql_to_mongodb_1",
+			PreMigrationState: map[string]TableSnapshot{"users": {TableName: "users", ExistedBefore: true, KeyColumns: []string{"_id"}}},
+			Status:            "completed",
+		}
+		if err := rm.saveSnapshot(snapshot); err != nil {
+			t.Fatalf("Failed to save snapshot, %v", err)
+		}
+
+		//rows read from a SQL table carry no _id
+		rows := []map[string]interface{}{
+			{"_source_table": "users", "id": 1, "name": "Susheel"},
+			{"_source_table": "users", "id": 2, "name": "Sathyaraj"},
+		}
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		if err := targetClient.ImportData(rows); err != nil {
+			t.Fatalf("Import failed, %v", err)
+		}
+		if err := rm.UpdateSnapshotWithMigratedData(snapshot, rows); err != nil {
+			t.Fatalf("Failed to record migrated keys, %v", err)
+		}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+		if err := rm.RollBackMigration(snapshot.ID); err != nil {
+			t.Fatalf("Rollback failed, %v", err)
+		}
+
+		//the documents deleted are the ones inserted, identified by the _id given before the insert
+		inserted := mt.GetStartedEvent().Command.Lookup("documents").Array()
+		deleted := mt.GetStartedEvent().Command.Lookup("deletes").Array()
+		for i := range rows {
+			insertedID := inserted.Index(uint(i)).Value().Document().Lookup("_id")
+			deletedID := deleted.Index(uint(i)).Value().Document().Lookup("q", "_id")
+			if insertedID.Type != bson.TypeObjectID || !insertedID.Equal(deletedID) {
+				t.Errorf("Expected document %d to be deleted by its _id %v, got %v", i, insertedID, deletedID)
+			}
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// persisted state of a scheduled job, survives restarts of the scheduler
+type ScheduleState struct {
+	JobID          string    `json:"job_id"`
+	CronExpression string    `json:"cron_expression"`
+	LastRunStart   time.Time `json:"last_run_start,omitempty"`
+	LastRunEnd     time.Time `json:"last_run_end,omitempty"`
+	LastRunStatus  string    `json:"last_run_status,omitempty"` //"running", "completed", "failed", "interrupted"
+	LastError      string    `json:"last_error,omitempty"`
+	NextRun        time.Time `json:"next_run"`
+	RunCount       int       `json:"run_count"`
+	SkippedRuns    int       `json:"skipped_runs"`
+}
+
+// type for running migrations on a cron schedule
+type MigrationScheduler struct {
+	engine     *MigrationEngine
+	expression string
+	schedule   cron.Schedule
+	jobID      string
+	stateDir   string
+
+	mu      sync.Mutex //guards state
+	state   ScheduleState
+	running sync.Mutex //held while a run is in progress, prevents overlapping runs
+	wg      sync.WaitGroup
+}
+
+// creating a scheduler for the engine, expression is a standard cron expression or descriptor like @hourly
+func NewMigrationScheduler(engine *MigrationEngine, expression string) (*MigrationScheduler, error) {
+	if expression == "" {
+		return nil, fmt.Errorf("scheduled migration requires a cron expression")
+	}
+
+	switch engine.Config.ScheduledMode {
+	case FullMigration, IncrementalMigration, "":
+	default:
+		return nil, fmt.Errorf("unsupported mode %s for scheduled migration, use full or incremental", engine.Config.ScheduledMode)
+	}
+
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q, %v", expression, err)
+	}
+
+	return &MigrationScheduler{
+		engine:     engine,
+		expression: expression,
+		schedule:   schedule,
+		jobID:      fmt.Sprintf("%s_to_%s", engine.Config.SourceDb, engine.Config.TargetDb),
+		stateDir:   engine.stateDir,
+	}, nil
+}
+
+// changing the directory the job state is persisted in
+func (ms *MigrationScheduler) SetStateDir(stateDir string) {
+	ms.stateDir = stateDir
+}
+
+// returning a copy of the current job state
+func (ms *MigrationScheduler) State() ScheduleState {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.state
+}
+
+// running migrations on the schedule until the context is cancelled, which interrupts an in-flight run and waits for it to stop
+func (ms *MigrationScheduler) Run(ctx context.Context) error {
+	if err := ms.loadState(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	now := time.Now()
+	next := ms.schedule.Next(now)
+	//a tick missed while the scheduler was down is run straight away
+	if !ms.state.NextRun.IsZero() && ms.state.NextRun.Before(now) && ms.state.CronExpression == ms.expression {
+		log.Printf("Scheduled run at %s was missed, running now", ms.state.NextRun.Format(time.RFC3339))
+		next = now
+	}
+	if !ms.state.LastRunStart.IsZero() {
+		log.Printf("Last scheduled run started %s with status %s", ms.state.LastRunStart.Format(time.RFC3339), ms.state.LastRunStatus)
+	}
+	ms.state.CronExpression = ms.expression
+	ms.mu.Unlock()
+
+	defer ms.wg.Wait()
+
+	for {
+		ms.mu.Lock()
+		ms.state.NextRun = next
+		err := ms.saveState()
+		ms.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Next scheduled migration %s at %s", ms.jobID, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Printf("Scheduler for %s stopping, waiting for the in-flight run to be interrupted", ms.jobID)
+			return nil
+		case <-timer.C:
+		}
+
+		ms.trigger(ctx)
+		next = ms.schedule.Next(time.Now())
+	}
+}
+
+// starting a run in the background unless the previous one is still in progress, the run is cancelled with ctx
+func (ms *MigrationScheduler) trigger(ctx context.Context) {
+	if !ms.running.TryLock() {
+		ms.mu.Lock()
+		ms.state.SkippedRuns++
+		ms.mu.Unlock()
+		log.Printf("Previous run of %s still in progress, skipping this tick", ms.jobID)
+		return
+	}
+
+	ms.wg.Add(1)
+	go func() {
+		defer ms.wg.Done()
+		defer ms.running.Unlock()
+		ms.runOnce(ctx)
+	}()
+}
+
+// executing a single migration and recording its outcome
+func (ms *MigrationScheduler) runOnce(ctx context.Context) {
+	ms.mu.Lock()
+	ms.state.LastRunStart = time.Now()
+	ms.state.LastRunEnd = time.Time{}
+	ms.state.LastRunStatus = "running"
+	ms.state.LastError = ""
+	ms.state.RunCount++
+	if err := ms.saveState(); err != nil {
+		log.Printf("Warning: could not save schedule state, %v", err)
+	}
+	ms.mu.Unlock()
+
+	result, err := ms.engine.ExecuteMigrationContext(ctx)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.state.LastRunEnd = time.Now()
+	if err != nil && ctx.Err() != nil {
+		ms.state.LastRunStatus = "interrupted"
+		ms.state.LastError = err.Error()
+		log.Printf("Scheduled migration %s interrupted, %v", ms.jobID, err)
+	} else if err != nil {
+		ms.state.LastRunStatus = "failed"
+		ms.state.LastError = err.Error()
+		log.Printf("Scheduled migration %s failed, %v", ms.jobID, err)
+	} else {
+		ms.state.LastRunStatus = "completed"
+		log.Printf("Scheduled migration %s completed, %d rows migrated", ms.jobID, result.TotalRowsMigrated)
+	}
+	if err := ms.saveState(); err != nil {
+		log.Printf("Warning: could not save schedule state, %v", err)
+	}
+}
+
+// returning the state file of the job
+func (ms *MigrationScheduler) stateFile() string {
+	return filepath.Join(ms.stateDir, fmt.Sprintf("schedule_%s.json", ms.jobID))
+}
+
+// loading the persisted state of the job, if any
+func (ms *MigrationScheduler) loadState() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.state = ScheduleState{JobID: ms.jobID}
+
+	data, err := os.ReadFile(ms.stateFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schedule state, %v", err)
+	}
+	if err := json.Unmarshal(data, &ms.state); err != nil {
+		return fmt.Errorf("failed to unmarshal schedule state, %v", err)
+	}
+
+	//a run that was still in progress when the process died did not complete
+	if ms.state.LastRunStatus == "running" {
+		ms.state.LastRunStatus = "failed"
+		ms.state.LastError = "scheduler stopped before the run finished"
+	}
+	return nil
+}
+
+// saving the job state atomically, callers hold ms.mu
+func (ms *MigrationScheduler) saveState() error {
+	if err := os.MkdirAll(ms.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create schedule state directory, %v", err)
+	}
+
+	data, err := json.MarshalIndent(ms.state, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedule state, %v", err)
+	}
+
+	fileName := ms.stateFile()
+	tmpFile := fileName + ".tmp"
+	if err := writeFileSynced(tmpFile, data); err != nil {
+		return fmt.Errorf("failed to write schedule state, %v", err)
+	}
+	if err := os.Rename(tmpFile, fileName); err != nil {
+		return fmt.Errorf("failed to replace schedule state, %v", err)
+	}
+	return nil
+}
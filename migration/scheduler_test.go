@@ -0,0 +1,142 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/test"
+)
+
+func newSchedulerTestEngine(t *testing.T) (*MigrationEngine, *test.CompleteMockDatabaseClient) {
+	sourceClient := test.NewCompleteMockDatabaseClient("mysql")
+	targetClient := test.NewCompleteMockDatabaseClient("postgresql")
+
+	sourceClient.AddTestData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Sathyaraj"},
+	})
+
+	config := MigrationConfig{
+		Mode:          ScheduledMigration,
+		ScheduledMode: FullMigration,
+		SourceDb:      "mysql",
+		TargetDb:      "postgresql",
+		Tables:        []string{"users"},
+	}
+
+	sourceClient.Connect()
+	targetClient.Connect()
+	t.Cleanup(func() {
+		sourceClient.Close()
+		targetClient.Close()
+	})
+
+	engine := NewMigrationEngine(config, sourceClient, targetClient)
+	t.Cleanup(engine.Close)
+	return engine, targetClient
+}
+
+func TestNewMigrationSchedulerValidation(t *testing.T) {
+	engine, _ := newSchedulerTestEngine(t)
+
+	if _, err := NewMigrationScheduler(engine, ""); err == nil {
+		t.Errorf("Expected error for empty cron expression, got nil")
+	}
+
+	if _, err := NewMigrationScheduler(engine, "not a cron"); err == nil {
+		t.Errorf("Expected error for invalid cron expression, got nil")
+	}
+
+	if _, err := NewMigrationScheduler(engine, "*/5 * * * *"); err != nil {
+		t.Errorf("Expected valid cron expression to be accepted, got %v", err)
+	}
+
+	engine.Config.ScheduledMode = "online"
+	if _, err := NewMigrationScheduler(engine, "@hourly"); err == nil {
+		t.Errorf("Expected error for unsupported scheduled mode, got nil")
+	}
+}
+
+func TestMigrationSchedulerRunsAndPersistsState(t *testing.T) {
+	engine, targetClient := newSchedulerTestEngine(t)
+	stateDir := t.TempDir()
+
+	scheduler, err := NewMigrationScheduler(engine, "@every 1s")
+	if err != nil {
+		t.Fatalf("Failed to create scheduler, %v", err)
+	}
+	scheduler.SetStateDir(stateDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+
+	if err := scheduler.Run(ctx); err != nil {
+		t.Fatalf("Scheduler failed, %v", err)
+	}
+
+	state := scheduler.State()
+	if state.RunCount < 2 {
+		t.Errorf("Expected at least 2 runs, got %d", state.RunCount)
+	}
+	if state.LastRunStatus != "completed" {
+		t.Errorf("Expected last run to be completed, got %s (%s)", state.LastRunStatus, state.LastError)
+	}
+	if targetClient.GetImportedTableRowCount("users") != 2*state.RunCount {
+		t.Errorf("Expected %d imported rows, got %d", 2*state.RunCount, targetClient.GetImportedTableRowCount("users"))
+	}
+
+	//a restarted scheduler picks up the persisted state
+	restarted, _ := NewMigrationScheduler(engine, "@every 1s")
+	restarted.SetStateDir(stateDir)
+	if err := restarted.loadState(); err != nil {
+		t.Fatalf("Failed to load state, %v", err)
+	}
+	if restarted.State().RunCount != state.RunCount || restarted.State().NextRun.IsZero() {
+		t.Errorf("Expected persisted state %+v, got %+v", state, restarted.State())
+	}
+}
+
+func TestMigrationSchedulerRunsMissedTick(t *testing.T) {
+	engine, _ := newSchedulerTestEngine(t)
+
+	scheduler, _ := NewMigrationScheduler(engine, "@hourly")
+	scheduler.SetStateDir(t.TempDir())
+
+	//pretending the scheduler was down when the previous tick was due
+	scheduler.state = ScheduleState{JobID: scheduler.jobID, CronExpression: "@hourly", NextRun: time.Now().Add(-time.Minute)}
+	if err := scheduler.saveState(); err != nil {
+		t.Fatalf("Failed to save state, %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := scheduler.Run(ctx); err != nil {
+		t.Fatalf("Scheduler failed, %v", err)
+	}
+
+	if scheduler.State().RunCount != 1 {
+		t.Errorf("Expected the missed tick to run once, got %d runs", scheduler.State().RunCount)
+	}
+}
+
+func TestMigrationSchedulerPreventsOverlappingRuns(t *testing.T) {
+	engine, _ := newSchedulerTestEngine(t)
+	engine.SourceClient.(*test.CompleteMockDatabaseClient).SetFetchDelay(300 * time.Millisecond)
+
+	scheduler, _ := NewMigrationScheduler(engine, "@hourly")
+	scheduler.SetStateDir(t.TempDir())
+	scheduler.state = ScheduleState{JobID: scheduler.jobID}
+
+	scheduler.trigger(context.Background())
+	scheduler.trigger(context.Background())
+	scheduler.wg.Wait()
+
+	state := scheduler.State()
+	if state.RunCount != 1 {
+		t.Errorf("Expected 1 run, got %d", state.RunCount)
+	}
+	if state.SkippedRuns != 1 {
+		t.Errorf("Expected 1 skipped run, got %d", state.SkippedRuns)
+	}
+}
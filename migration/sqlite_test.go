@@ -0,0 +1,263 @@
+package migration
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/transform"
+)
+
+// connecting a client to a new database file in the test's temp dir
+func newSQLiteTestClient(t *testing.T, statements ...string) *database.SQLiteClient {
+	t.Helper()
+	client := database.NewSQLiteClient(filepath.Join(t.TempDir(), "test.db"))
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Failed to open sqlite database, %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	for _, statement := range statements {
+		if _, err := client.DB.Exec(statement); err != nil {
+			t.Fatalf("Failed to execute %s, %v", statement, err)
+		}
+	}
+	return client
+}
+
+func newSQLiteTestEngine(t *testing.T, config MigrationConfig, source, target database.DatabaseClient) *MigrationEngine {
+	config.SourceDb, config.TargetDb = "sqlite", "sqlite"
+	config.Retry = database.RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond}
+	engine := NewMigrationEngine(config, source, target)
+	engine.RollBackManager.snapshotsDir = t.TempDir()
+	engine.CheckpointStore = NewCheckpointStore(t.TempDir())
+	engine.WatermarkStore = NewWatermarkStore(t.TempDir())
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+// counting the rows of a table of a sqlite database
+func countSQLiteRows(t *testing.T, client *database.SQLiteClient, table string) int {
+	t.Helper()
+	var count int
+	if err := client.DB.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows of table %s, %v", table, err)
+	}
+	return count
+}
+
+func TestSQLiteFullMigrationEndToEnd(t *testing.T) {
+	sourceClient := newSQLiteTestClient(t,
+		`CREATE TABLE customers (id INTEGER PRIMARY KEY, email TEXT NOT NULL, joined DATETIME)`,
+		`CREATE UNIQUE INDEX customers_email ON customers (email)`,
+		`CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER NOT NULL REFERENCES customers (id) ON DELETE CASCADE, total REAL)`,
+		`CREATE INDEX orders_customer ON orders (customer_id)`,
+		`INSERT INTO customers VALUES (1, 'susheel@example.com', '2024-03-05 10:20:30'), (2, 'alex@example.com', NULL), (3, 'bo@example.com', NULL)`,
+		`INSERT INTO orders VALUES (10, 1, 9.5), (11, 1, 7.25), (12, 2, 12), (13, 3, 1.5), (14, 3, 3)`,
+	)
+	targetClient := newSQLiteTestClient(t)
+
+	//listed in the wrong order, the plan loads customers first
+	engine := newSQLiteTestEngine(t, MigrationConfig{
+		Mode:         FullMigration,
+		Tables:       []string{"orders", "customers"},
+		Workers:      2,
+		BatchSize:    2,
+		Concurrent:   true,
+		ChunkRows:    2,
+		ValidateData: true,
+	}, sourceClient, targetClient)
+
+	result, err := engine.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+	if !result.Success || result.TotalRowsMigrated != 8 {
+		t.Errorf("Expected 8 migrated rows, got %+v", result)
+	}
+	if countSQLiteRows(t, targetClient, "customers") != 3 || countSQLiteRows(t, targetClient, "orders") != 5 {
+		t.Error("Expected every row in the target")
+	}
+
+	constraints, err := targetClient.DescribeConstraints("orders")
+	if err != nil {
+		t.Fatalf("Failed to read target constraints, %v", err)
+	}
+	foreignKey := database.ForeignKeyDefinition{Columns: []string{"customer_id"}, ReferencedTable: "customers", ReferencedColumns: []string{"id"}}
+	if len(constraints.PrimaryKey) != 1 || !constraints.HasForeignKey(foreignKey) || constraints.ForeignKeys[0].OnDelete != "CASCADE" {
+		t.Errorf("Expected the primary and foreign key of orders to be recreated, got %+v", constraints)
+	}
+	if !constraints.HasIndex(database.IndexDefinition{Columns: []string{"customer_id"}}) {
+		t.Errorf("Expected the index of orders to be recreated, got %+v", constraints.Indexes)
+	}
+	customers, err := targetClient.DescribeConstraints("customers")
+	if err != nil {
+		t.Fatalf("Failed to read target constraints, %v", err)
+	}
+	if !customers.HasIndex(database.IndexDefinition{Columns: []string{"email"}, Unique: true}) {
+		t.Errorf("Expected the unique index of customers to be recreated, got %+v", customers.Indexes)
+	}
+
+	var joined time.Time
+	if err := targetClient.DB.QueryRow("SELECT joined FROM customers WHERE id = 1").Scan(&joined); err != nil {
+		t.Fatalf("Failed to read migrated row, %v", err)
+	}
+	if !joined.Equal(time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC)) {
+		t.Errorf("Expected the timestamp to survive the migration, got %v", joined)
+	}
+}
+
+func TestSQLiteClientsReportBatchesToEngineTracker(t *testing.T) {
+	sourceClient := newSQLiteTestClient(t,
+		`CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO events VALUES (1, 'signup'), (2, 'login')`,
+	)
+	targetClient := newSQLiteTestClient(t)
+
+	engine := newSQLiteTestEngine(t, MigrationConfig{
+		Mode:         FullMigration,
+		Tables:       []string{"events"},
+		BatchSize:    10,
+		ValidateData: true,
+	}, sourceClient, targetClient)
+	if targetClient.Progress != engine.ProgressTracker || sourceClient.Progress != engine.ProgressTracker {
+		t.Fatal("Expected the clients to report to the tracker of the new engine")
+	}
+
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("Migration failed, %v", err)
+	}
+
+	//the tracker is replaced by every run, the clients follow it
+	processed := engine.ProgressTracker.GetMetrics().ProcessedRows
+	rows := make([]map[string]interface{}, 25)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"_source_table": "audit", "id": int64(i + 1)}
+	}
+	if err := targetClient.ImportDataConcurrently(rows, 10); err != nil {
+		t.Fatalf("Concurrent import failed, %v", err)
+	}
+	if reported := engine.ProgressTracker.GetMetrics().ProcessedRows - processed; reported != int64(len(rows)) {
+		t.Errorf("Expected the batches of %d rows to be reported to the engine tracker, got %d", len(rows), reported)
+	}
+}
+
+func TestSQLiteIncrementalMigrationEndToEnd(t *testing.T) {
+	sourceClient := newSQLiteTestClient(t,
+		`CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, updated_at DATETIME)`,
+		`INSERT INTO events VALUES (1, 'signup', '2024-03-05 10:00:00'), (2, 'login', '2024-03-05 11:00:00')`,
+	)
+	targetClient := newSQLiteTestClient(t)
+
+	config := MigrationConfig{
+		Mode:              IncrementalMigration,
+		Tables:            []string{"events"},
+		BatchSize:         10,
+		IncrementalColumn: "updated_at",
+		KeyColumns:        []string{"id"},
+		WriteMode:         database.WriteModeUpsert,
+	}
+	engine := newSQLiteTestEngine(t, config, sourceClient, targetClient)
+	if _, err := engine.ExecuteMigration(); err != nil {
+		t.Fatalf("First incremental run failed, %v", err)
+	}
+
+	//one row changed and two added since the first run, one of them committed with the watermark of the first run
+	if _, err := sourceClient.DB.Exec(`UPDATE events SET name = 'login again', updated_at = '2024-03-05 12:00:00' WHERE id = 2;
+		INSERT INTO events VALUES (3, 'logout', '2024-03-05 13:00:00'), (4, 'late login', '2024-03-05 11:00:00')`); err != nil {
+		t.Fatalf("Failed to change source rows, %v", err)
+	}
+	second := newSQLiteTestEngine(t, config, sourceClient, targetClient)
+	second.WatermarkStore = engine.WatermarkStore
+	result, err := second.ExecuteMigration()
+	if err != nil {
+		t.Fatalf("Second incremental run failed, %v", err)
+	}
+	if result.TotalRowsMigrated != 3 {
+		t.Errorf("Expected only the changed rows to be migrated, got %d", result.TotalRowsMigrated)
+	}
+
+	var name string
+	if err := targetClient.DB.QueryRow("SELECT name FROM events WHERE id = 2").Scan(&name); err != nil {
+		t.Fatalf("Failed to read migrated row, %v", err)
+	}
+	if name != "login again" || countSQLiteRows(t, targetClient, "events") != 4 {
+		t.Errorf("Expected the changed row to be updated and the new ones added, got %s", name)
+	}
+}
+
+func TestSQLiteWatermarksAreKeptPerDatabase(t *testing.T) {
+	stateDir := t.TempDir()
+	config := MigrationConfig{
+		Mode:              IncrementalMigration,
+		Tables:            []string{"events"},
+		BatchSize:         10,
+		IncrementalColumn: "updated_at",
+		KeyColumns:        []string{"id"},
+		WriteMode:         database.WriteModeUpsert,
+	}
+
+	//two databases of the same type, the second one holding only rows older than the watermark of the first
+	for _, updatedAt := range []string{"2024-06-01 10:00:00", "2024-01-01 10:00:00"} {
+		sourceClient := newSQLiteTestClient(t,
+			`CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT, updated_at DATETIME)`,
+			`INSERT INTO events VALUES (1, 'signup', '`+updatedAt+`'), (2, 'login', '`+updatedAt+`')`,
+		)
+		engine := newSQLiteTestEngine(t, config, sourceClient, newSQLiteTestClient(t))
+		engine.SetStateDir(stateDir)
+		result, err := engine.ExecuteMigration()
+		if err != nil {
+			t.Fatalf("Incremental run failed, %v", err)
+		}
+		if result.TotalRowsMigrated != 2 {
+			t.Errorf("Expected every row of the database updated at %s to be migrated, got %d", updatedAt, result.TotalRowsMigrated)
+		}
+	}
+}
+
+func TestSQLiteUpsertByRenamedKey(t *testing.T) {
+	sourceClient := newSQLiteTestClient(t,
+		`CREATE TABLE accounts (accountId INTEGER PRIMARY KEY, fullName TEXT)`,
+		`INSERT INTO accounts VALUES (1, 'Susheel'), (2, 'Alex')`,
+	)
+	targetClient := newSQLiteTestClient(t)
+
+	//the key is renamed by a transformation and then by the mapping, accountId is written as account_key
+	pipeline, err := transform.NewPipeline("accounts", []config.TransformStep{{Rename: map[string]string{"accountId": "accountKey"}}})
+	if err != nil {
+		t.Fatalf("Failed to compile transforms, %v", err)
+	}
+	mapping, err := database.NewNameMapping(config.MappingConfig{ColumnCase: "snake_case"})
+	if err != nil {
+		t.Fatalf("Failed to create mapping, %v", err)
+	}
+	migrationConfig := MigrationConfig{
+		Mode:       FullMigration,
+		Tables:     []string{"accounts"},
+		BatchSize:  10,
+		KeyColumns: []string{"accountId"},
+		WriteMode:  database.WriteModeUpsert,
+		Transforms: transform.Tables{"accounts": pipeline},
+		Mapping:    mapping,
+	}
+	if _, err := newSQLiteTestEngine(t, migrationConfig, sourceClient, targetClient).ExecuteMigration(); err != nil {
+		t.Fatalf("First migration failed, %v", err)
+	}
+
+	if _, err := sourceClient.DB.Exec(`UPDATE accounts SET fullName = 'Alex B' WHERE accountId = 2`); err != nil {
+		t.Fatalf("Failed to change source row, %v", err)
+	}
+	if _, err := newSQLiteTestEngine(t, migrationConfig, sourceClient, targetClient).ExecuteMigration(); err != nil {
+		t.Fatalf("Second migration failed, %v", err)
+	}
+
+	var name string
+	if err := targetClient.DB.QueryRow("SELECT full_name FROM accounts WHERE account_key = 2").Scan(&name); err != nil {
+		t.Fatalf("Failed to read migrated row, %v", err)
+	}
+	if name != "Alex B" || countSQLiteRows(t, targetClient, "accounts") != 2 {
+		t.Errorf("Expected the rows to be upserted by the renamed key, got %s and %d rows", name, countSQLiteRows(t, targetClient, "accounts"))
+	}
+}
@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// transforming a batch of a table before it is written and mapping it to the target names. Rows that cannot
+// be transformed are dead-lettered when a queue is configured, otherwise they fail the batch
+func (me *MigrationEngine) transformRows(table string, rows []map[string]interface{}) ([]map[string]interface{}, error) {
+	transformed, failed := me.Config.Transforms.For(table).Apply(rows)
+	if len(failed) == 0 {
+		return me.Config.Mapping.MapRows(transformed), nil
+	}
+
+	indexes := make([]int, 0, len(failed))
+	for i := range failed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		if me.DeadLetters == nil {
+			return nil, failed[i]
+		}
+		if err := me.deadLetter(table, rows[i], failed[i]); err != nil {
+			return nil, err
+		}
+	}
+	return me.Config.Mapping.MapRows(transformed), nil
+}
+
+// returning the constraints of a source table with the column names of the transformed target table,
+// indexes and foreign keys on dropped columns are left out
+func (me *MigrationEngine) transformConstraints(table string, constraints *database.TableConstraints) *database.TableConstraints {
+	if len(me.Config.Transforms) == 0 {
+		return constraints
+	}
+	pipeline := me.Config.Transforms.For(table)
+
+	transformed := &database.TableConstraints{Table: constraints.Table}
+	if primaryKey, ok := pipeline.RenameColumns(constraints.PrimaryKey); ok {
+		transformed.PrimaryKey = primaryKey
+	} else {
+		me.Logger.Info(fmt.Sprintf("Warning: the primary key of table %s has a dropped column, skipping it", table))
+	}
+
+	for _, index := range constraints.Indexes {
+		columns, ok := pipeline.RenameColumns(index.Columns)
+		if !ok {
+			me.Logger.Info(fmt.Sprintf("Warning: index %s of table %s has a dropped column, skipping it", index.Name, table))
+			continue
+		}
+		index.Columns = columns
+		transformed.Indexes = append(transformed.Indexes, index)
+	}
+
+	for _, foreignKey := range constraints.ForeignKeys {
+		columns, ok := pipeline.RenameColumns(foreignKey.Columns)
+		referenced, referencedOk := me.Config.Transforms.For(foreignKey.ReferencedTable).RenameColumns(foreignKey.ReferencedColumns)
+		if !ok || !referencedOk {
+			me.Logger.Info(fmt.Sprintf("Warning: foreign key %s of table %s has a dropped column, skipping it", foreignKey.Name, table))
+			continue
+		}
+		foreignKey.Columns, foreignKey.ReferencedColumns = columns, referenced
+		transformed.ForeignKeys = append(transformed.ForeignKeys, foreignKey)
+	}
+	return transformed
+}
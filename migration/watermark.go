@@ -0,0 +1,141 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// high-water mark of a table after the last successful incremental run
+type TableWatermark struct {
+	Column    string    `json:"column"`
+	Kind      string    `json:"kind"` //"int", "float", "time", "string", "objectid"
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// type for persisting incremental watermarks between runs
+type WatermarkStore struct {
+	stateDir string
+}
+
+// creating a new watermark store writing into stateDir
+func NewWatermarkStore(stateDir string) *WatermarkStore {
+	return &WatermarkStore{stateDir: stateDir}
+}
+
+// encoding a watermark value so that its type survives the JSON round trip
+func EncodeWatermark(column string, value interface{}) (TableWatermark, error) {
+	watermark := TableWatermark{Column: column, UpdatedAt: time.Now()}
+
+	normalized, err := database.NormalizeWatermark(value)
+	if err != nil {
+		return watermark, fmt.Errorf("unsupported watermark for column %s, %v", column, err)
+	}
+	switch v := normalized.(type) {
+	case int64:
+		watermark.Kind = "int"
+		watermark.Value = strconv.FormatInt(v, 10)
+	case float64:
+		watermark.Kind = "float"
+		watermark.Value = strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		watermark.Kind = "time"
+		watermark.Value = v.Format(time.RFC3339Nano)
+	case string:
+		watermark.Kind = "string"
+		watermark.Value = v
+	case primitive.ObjectID:
+		watermark.Kind = "objectid"
+		watermark.Value = v.Hex()
+	default:
+		return watermark, fmt.Errorf("unsupported watermark type %T for column %s", value, column)
+	}
+	return watermark, nil
+}
+
+// decoding the persisted watermark back into a typed value usable as a query argument
+func (tw TableWatermark) Decode() (interface{}, error) {
+	switch tw.Kind {
+	case "int":
+		return strconv.ParseInt(tw.Value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(tw.Value, 64)
+	case "time":
+		return time.Parse(time.RFC3339Nano, tw.Value)
+	case "string":
+		return tw.Value, nil
+	case "objectid":
+		return primitive.ObjectIDFromHex(tw.Value)
+	default:
+		return nil, fmt.Errorf("unknown watermark kind %s", tw.Kind)
+	}
+}
+
+// returning the state file for a source/target pair
+func (ws *WatermarkStore) fileName(sourceDB, targetDB string) string {
+	return filepath.Join(ws.stateDir, fmt.Sprintf("watermarks_%s_to_%s.json", sourceDB, targetDB))
+}
+
+// loading the persisted watermarks per table, an empty map when no run happened yet
+func (ws *WatermarkStore) Load(sourceDB, targetDB string) (map[string]TableWatermark, error) {
+	watermarks := make(map[string]TableWatermark)
+
+	data, err := os.ReadFile(ws.fileName(sourceDB, targetDB))
+	if os.IsNotExist(err) {
+		return watermarks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark file, %v", err)
+	}
+
+	if err := json.Unmarshal(data, &watermarks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal watermarks, %v", err)
+	}
+	return watermarks, nil
+}
+
+// saving watermarks atomically, so a crash never leaves a half written file behind
+func (ws *WatermarkStore) Save(sourceDB, targetDB string, watermarks map[string]TableWatermark) error {
+	if err := os.MkdirAll(ws.stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create watermark directory, %v", err)
+	}
+
+	data, err := json.MarshalIndent(watermarks, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal watermarks, %v", err)
+	}
+
+	fileName := ws.fileName(sourceDB, targetDB)
+	tmpFile := fileName + ".tmp"
+	if err := writeFileSynced(tmpFile, data); err != nil {
+		return fmt.Errorf("failed to write watermark file, %v", err)
+	}
+	if err := os.Rename(tmpFile, fileName); err != nil {
+		return fmt.Errorf("failed to replace watermark file, %v", err)
+	}
+	return nil
+}
+
+// writing a file and flushing it to disk before returning
+func writeFileSynced(fileName string, data []byte) error {
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
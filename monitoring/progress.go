@@ -14,6 +14,9 @@ type ProcessTracker struct {
 	processedRows   int64
 	totalTables     int
 	processedTables int
+	totalChunks     int
+	processedChunks int
+	retries         int64
 	startTime       time.Time
 	currentTable    string
 	errors          []string
@@ -26,6 +29,9 @@ type MigrationMetrics struct {
 	ProcessedRows     int64         `json:"processed_rows"`
 	TotalTables       int           `json:"total_tables"`
 	ProcessedTables   int           `json:"processed_tables"`
+	TotalChunks       int           `json:"total_chunks"`
+	ProcessedChunks   int           `json:"processed_chunks"`
+	Retries           int64         `json:"retries"`
 	RowsPerSecond     float64       `json:"rows_per_second"`
 	TablesPerMinute   float64       `json:"tables_per_minute"`
 	EstimatedTimeLeft time.Duration `json:"estimated_time_left"`
@@ -68,6 +74,25 @@ func (pt *ProcessTracker) CompletedTable() {
 	pt.processedTables++
 }
 
+// adding key ranges of tables that are read in chunks
+func (pt *ProcessTracker) AddChunks(chunks int) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.totalChunks += chunks
+}
+
+// marks a chunk of a table as completed
+func (pt *ProcessTracker) CompletedChunk() {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.processedChunks++
+}
+
+// counting an operation retried after a transient error (threadsafe)
+func (pt *ProcessTracker) AddRetry() {
+	atomic.AddInt64(&pt.retries, 1)
+}
+
 // adding an error to the error list
 func (pt *ProcessTracker) AddError(err string) {
 	pt.mu.Lock()
@@ -109,6 +134,9 @@ func (pt *ProcessTracker) GetMetrics() MigrationMetrics {
 		ProcessedRows:     pt.processedRows,
 		TotalTables:       pt.totalTables,
 		ProcessedTables:   pt.processedTables,
+		TotalChunks:       pt.totalChunks,
+		ProcessedChunks:   pt.processedChunks,
+		Retries:           atomic.LoadInt64(&pt.retries),
 		RowsPerSecond:     rowsPerSecond,
 		TablesPerMinute:   tablesPerMinute,
 		EstimatedTimeLeft: estimatedTimeLeft,
@@ -144,6 +172,12 @@ func (pt *ProcessTracker) PrintProgress() {
 		formatDuration(metrics.EstimatedTimeLeft),
 	)
 
+	if metrics.TotalChunks > 0 {
+		fmt.Printf("| Chunks: %d/%d", metrics.ProcessedChunks, metrics.TotalChunks)
+	}
+	if metrics.Retries > 0 {
+		fmt.Printf("| Retries: %d", metrics.Retries)
+	}
 	if metrics.CurrentTable != "" {
 		fmt.Printf("| Current: %s", metrics.CurrentTable)
 	}
@@ -197,8 +231,14 @@ func (pt *ProcessTracker) PrintFinalSummary() {
 	fmt.Printf("Total Duration %v\n", formatDuration(metrics.ElapsedTime))
 	fmt.Printf("Rows Processed: %d / %d (%.1f%%)\n", metrics.ProcessedRows, metrics.TotalRows, metrics.ProgressPercent)
 	fmt.Printf("Tables Processed: %d / %d\n", metrics.ProcessedTables, metrics.TotalTables)
+	if metrics.TotalChunks > 0 {
+		fmt.Printf("Chunks Processed: %d / %d\n", metrics.ProcessedChunks, metrics.TotalChunks)
+	}
 	fmt.Printf("Average Speed: %.0f rows/sec (%.0f rows/min)\n", metrics.RowsPerSecond, metrics.RowsPerSecond*60)
 	fmt.Printf("Tables per Minute: %.1f\n", metrics.TablesPerMinute)
+	if metrics.Retries > 0 {
+		fmt.Printf("Retries after Transient Errors: %d\n", metrics.Retries)
+	}
 
 	if metrics.ErrorCount > 0 {
 		fmt.Printf("Errrors Encountered: %d\n", metrics.ErrorCount)
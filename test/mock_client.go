@@ -3,11 +3,15 @@ package test
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
 )
 
 // struct for testing migration engine
 type CompleteMockDatabaseClient struct {
+	mu           sync.Mutex //guarding the data against concurrent readers and writers of the engine
 	name         string
 	connected    bool
 	data         map[string][]map[string]interface{}
@@ -18,6 +22,7 @@ type CompleteMockDatabaseClient struct {
 	failOnImport  bool
 	fetchDelay    time.Duration
 	importDelay   time.Duration
+	primaryKey    []string
 
 	connectCalled int
 	closeCalled   int
@@ -33,6 +38,7 @@ func NewCompleteMockDatabaseClient(name string) *CompleteMockDatabaseClient {
 		importedData: make(map[string][]map[string]interface{}, 0),
 		fetchDelay:   0,
 		importDelay:  0,
+		primaryKey:   []string{"id"},
 	}
 }
 
@@ -66,6 +72,9 @@ func (m *CompleteMockDatabaseClient) ExecuteQuery(query string) (*sql.Rows, erro
 }
 
 func (m *CompleteMockDatabaseClient) FetchAllData(tables []string) ([]map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.fetchCalled++
 
 	if m.failOnFetch != "" {
@@ -83,7 +92,9 @@ func (m *CompleteMockDatabaseClient) FetchAllData(tables []string) ([]map[string
 
 	var allData []map[string]interface{}
 	for _, table := range tables {
-		if data, exists := m.data[table]; exists {
+		//a target returns what was imported into it alongside its own test data
+		data := append(append([]map[string]interface{}{}, m.data[table]...), m.importedData[table]...)
+		if len(data) > 0 {
 			for _, row := range data {
 				//creating a copy of to avoid modifying original data
 				rowCopy := make(map[string]interface{})
@@ -111,6 +122,9 @@ func (m *CompleteMockDatabaseClient) FetchAllDataConcurrently(tables []string, n
 }
 
 func (m *CompleteMockDatabaseClient) ImportData(data []map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.importCalled++
 
 	if !m.connected {
@@ -148,6 +162,184 @@ func (m *CompleteMockDatabaseClient) ImportData(data []map[string]interface{}) e
 	return nil
 }
 
+func (m *CompleteMockDatabaseClient) UpsertData(data []map[string]interface{}, keyColumns []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.importCalled++
+
+	if !m.connected {
+		return fmt.Errorf("database %s not connected", m.name)
+	}
+
+	if m.failOnImport {
+		return fmt.Errorf("mock upsert err failed for %s", m.name)
+	}
+
+	for _, row := range data {
+		tableName, exists := row["_source_table"].(string)
+		if !exists {
+			return fmt.Errorf("row missing source table information")
+		}
+
+		cleanRow := make(map[string]interface{})
+		for k, v := range row {
+			if k != "_source_table" {
+				cleanRow[k] = v
+			}
+		}
+
+		//replacing the row with matching key values, appending otherwise
+		replaced := false
+		for i, existing := range m.importedData[tableName] {
+			matches := true
+			for _, key := range keyColumns {
+				if fmt.Sprintf("%v", existing[key]) != fmt.Sprintf("%v", cleanRow[key]) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				m.importedData[tableName][i] = cleanRow
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			m.importedData[tableName] = append(m.importedData[tableName], cleanRow)
+		}
+	}
+	return nil
+}
+
+func (m *CompleteMockDatabaseClient) WriteData(data []map[string]interface{}, mode database.WriteMode, keyColumns []string) error {
+	switch mode {
+	case database.WriteModeInsert, "":
+		return m.ImportData(data)
+	case database.WriteModeReplace:
+		return m.UpsertData(data, keyColumns)
+	case database.WriteModeUpsert, database.WriteModeSkipExisting:
+	default:
+		return fmt.Errorf("unsupported write mode %s", mode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.importCalled++
+	if !m.connected {
+		return fmt.Errorf("database %s not connected", m.name)
+	}
+
+	for _, row := range data {
+		tableName, exists := row["_source_table"].(string)
+		if !exists {
+			return fmt.Errorf("row missing source table information")
+		}
+
+		//finding the row with matching key values
+		var existing map[string]interface{}
+		for _, candidate := range m.importedData[tableName] {
+			matches := true
+			for _, key := range keyColumns {
+				if fmt.Sprintf("%v", candidate[key]) != fmt.Sprintf("%v", row[key]) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				existing = candidate
+				break
+			}
+		}
+
+		if existing == nil {
+			cleanRow := make(map[string]interface{})
+			for k, v := range row {
+				if k != "_source_table" {
+					cleanRow[k] = v
+				}
+			}
+			m.importedData[tableName] = append(m.importedData[tableName], cleanRow)
+			continue
+		}
+
+		//upserting updates the columns of the existing row, skipping leaves it untouched
+		if mode == database.WriteModeUpsert {
+			for k, v := range row {
+				if k != "_source_table" {
+					existing[k] = v
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *CompleteMockDatabaseClient) TableRowCount(tableName string) (bool, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.connected {
+		return false, 0, fmt.Errorf("database %s not connected", m.name)
+	}
+	if tableName == m.failOnFetch {
+		return false, 0, fmt.Errorf("mock fetch error for tables %s", tableName)
+	}
+	data, hasData := m.data[tableName]
+	imported, hasImported := m.importedData[tableName]
+	return hasData || hasImported, int64(len(data) + len(imported)), nil
+}
+
+func (m *CompleteMockDatabaseClient) PrimaryKeyColumns(tableName string) ([]string, error) {
+	if !m.connected {
+		return nil, fmt.Errorf("database %s not connected", m.name)
+	}
+	return m.primaryKey, nil
+}
+
+func (m *CompleteMockDatabaseClient) DeleteRows(tableName string, keyColumns []string, rows []map[string]interface{}) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.connected {
+		return 0, fmt.Errorf("database %s not connected", m.name)
+	}
+
+	var deleted int64
+	for _, row := range rows {
+		remaining := m.importedData[tableName][:0]
+		for _, existing := range m.importedData[tableName] {
+			matches := true
+			for _, key := range keyColumns {
+				if fmt.Sprintf("%v", existing[key]) != fmt.Sprintf("%v", row[key]) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				deleted++
+				continue
+			}
+			remaining = append(remaining, existing)
+		}
+		m.importedData[tableName] = remaining
+	}
+	return deleted, nil
+}
+
+func (m *CompleteMockDatabaseClient) DropTable(tableName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.connected {
+		return fmt.Errorf("database %s not connected", m.name)
+	}
+	delete(m.importedData, tableName)
+	delete(m.data, tableName)
+	return nil
+}
+
 func (m *CompleteMockDatabaseClient) ImportDataConcurrently(data []map[string]interface{}, batchSize int) error {
 	if batchSize <= 0 {
 		return m.ImportData(data)
@@ -177,11 +369,17 @@ func (m *CompleteMockDatabaseClient) ImportDataConcurrently(data []map[string]in
 //adding test data to the mock database
 
 func (m *CompleteMockDatabaseClient) AddTestData(table string, data []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.data[table] = make([]map[string]interface{}, len(data))
 	copy(m.data[table], data)
 }
 
 func (m *CompleteMockDatabaseClient) GetImportedData(table string) []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if data, exists := m.importedData[table]; exists {
 		return data
 	}
@@ -193,6 +391,9 @@ func (m *CompleteMockDatabaseClient) GetAllImporetedData() map[string][]map[stri
 }
 
 func (m *CompleteMockDatabaseClient) GetTotalImportedRows() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	total := 0
 	for _, tableData := range m.importedData {
 		total += len(tableData)
@@ -208,10 +409,14 @@ func (m *CompleteMockDatabaseClient) SetFailOnFetch(table string) {
 	m.failOnFetch = table
 }
 
-func (m CompleteMockDatabaseClient) SetFailOnImport(fail bool) {
+func (m *CompleteMockDatabaseClient) SetFailOnImport(fail bool) {
 	m.failOnImport = fail
 }
 
+func (m *CompleteMockDatabaseClient) SetPrimaryKey(columns []string) {
+	m.primaryKey = columns
+}
+
 func (m *CompleteMockDatabaseClient) SetFetchDelay(delay time.Duration) {
 	m.fetchDelay = delay
 }
@@ -263,6 +468,9 @@ func (m *CompleteMockDatabaseClient) GetTableRowCount(table string) int {
 }
 
 func (m *CompleteMockDatabaseClient) GetImportedTableRowCount(table string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if data, exists := m.importedData[table]; exists {
 		return len(data)
 	}
@@ -278,6 +486,7 @@ func (m *CompleteMockDatabaseClient) Reset() {
 	m.failOnFetch = ""
 	m.fetchDelay = 0
 	m.importDelay = 0
+	m.primaryKey = []string{"id"}
 	m.connectCalled = 0
 	m.closeCalled = 0
 	m.fetchCalled = 0
@@ -0,0 +1,574 @@
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+)
+
+// target column types of the values produced by casts and new columns, per target database
+var kindTypes = map[string]map[string]string{
+	"mysql": {
+		"string": "LONGTEXT",
+		"int":    "BIGINT",
+		"float":  "DOUBLE",
+		"bool":   "BOOLEAN",
+	},
+	"postgresql": {
+		"string": "TEXT",
+		"int":    "BIGINT",
+		"float":  "DOUBLE PRECISION",
+		"bool":   "BOOLEAN",
+	},
+}
+
+// single operation of a pipeline
+type step interface {
+	//transforming a row in place
+	apply(row map[string]interface{}) error
+	//changing the columns of the target table the same way
+	schema(schema *database.TableSchema)
+	//returning the name a column has after the step, false when the step drops it
+	rename(column string) (string, bool)
+}
+
+// transformations of one table, applied in order to every row before it is written
+type Pipeline struct {
+	table string
+	steps []step
+}
+
+// transformation pipelines keyed by table
+type Tables map[string]*Pipeline
+
+// compiling the configured transformations of every table
+func Compile(tables map[string][]config.TransformStep) (Tables, error) {
+	compiled := make(Tables, len(tables))
+	for table, steps := range tables {
+		pipeline, err := NewPipeline(table, steps)
+		if err != nil {
+			return nil, err
+		}
+		compiled[table] = pipeline
+	}
+	return compiled, nil
+}
+
+// returning the pipeline of a table, nil when it has no transformations
+func (t Tables) For(table string) *Pipeline {
+	if pipeline, ok := t[table]; ok {
+		return pipeline
+	}
+	for name, pipeline := range t {
+		if strings.EqualFold(name, table) {
+			return pipeline
+		}
+	}
+	return nil
+}
+
+// compiling the transformation steps of a table
+func NewPipeline(table string, steps []config.TransformStep) (*Pipeline, error) {
+	pipeline := &Pipeline{table: table}
+	for i, configured := range steps {
+		compiled, err := compileStep(configured)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform %d of table %s, %v", i+1, table, err)
+		}
+		pipeline.steps = append(pipeline.steps, compiled...)
+	}
+	return pipeline, nil
+}
+
+// compiling the operation of a configured step
+func compileStep(configured config.TransformStep) ([]step, error) {
+	var compiled []step
+	operations := 0
+
+	if len(configured.Rename) > 0 {
+		operations++
+		for _, from := range sortedKeys(configured.Rename) {
+			if configured.Rename[from] == "" {
+				return nil, fmt.Errorf("rename of column %s has no new name", from)
+			}
+			compiled = append(compiled, &renameStep{from: from, to: configured.Rename[from]})
+		}
+	}
+	if len(configured.Drop) > 0 {
+		operations++
+		compiled = append(compiled, &dropStep{columns: configured.Drop})
+	}
+	if len(configured.Cast) > 0 {
+		operations++
+		for _, column := range sortedKeys(configured.Cast) {
+			kind := strings.ToLower(configured.Cast[column])
+			if _, ok := kindTypes["postgresql"][kind]; !ok {
+				return nil, fmt.Errorf("unsupported cast of column %s to %s, use string, int, float or bool", column, configured.Cast[column])
+			}
+			compiled = append(compiled, &castStep{column: column, kind: kind})
+		}
+	}
+	if len(configured.Default) > 0 {
+		operations++
+		for _, column := range sortedKeys(configured.Default) {
+			compiled = append(compiled, &defaultStep{column: column, value: configured.Default[column]})
+		}
+	}
+	if concat := configured.Concat; concat != nil {
+		operations++
+		if concat.Column == "" || len(concat.From) == 0 {
+			return nil, fmt.Errorf("concat needs a column and the columns it is built from")
+		}
+		compiled = append(compiled, &concatStep{column: concat.Column, from: concat.From, separator: concat.Separator})
+	}
+	if split := configured.Split; split != nil {
+		operations++
+		if split.Column == "" || len(split.Into) == 0 || split.Separator == "" {
+			return nil, fmt.Errorf("split needs a column, a separator and the columns it is split into")
+		}
+		compiled = append(compiled, &splitStep{column: split.Column, into: split.Into, separator: split.Separator})
+	}
+	if replace := configured.Replace; replace != nil {
+		operations++
+		pattern, err := regexp.Compile(replace.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of replace on column %s, %v", replace.Column, err)
+		}
+		compiled = append(compiled, &replaceStep{column: replace.Column, pattern: pattern, with: replace.With})
+	}
+	if derive := configured.Derive; derive != nil {
+		operations++
+		if derive.Column == "" || derive.Template == "" {
+			return nil, fmt.Errorf("derive needs a column and a template")
+		}
+		compiled = append(compiled, &deriveStep{column: derive.Column, template: derive.Template})
+	}
+
+	if operations != 1 {
+		return nil, fmt.Errorf("a step sets exactly one of rename, drop, cast, default, concat, split, replace or derive, got %d", operations)
+	}
+	return compiled, nil
+}
+
+// transforming a batch of rows, the rows are copied so the batch read from the source is left as it is.
+// Returning the rows transformed and the errors of the rows that could not be, keyed by their index in rows
+func (p *Pipeline) Apply(rows []map[string]interface{}) ([]map[string]interface{}, map[int]error) {
+	if p == nil || len(p.steps) == 0 {
+		return rows, nil
+	}
+
+	transformed := make([]map[string]interface{}, 0, len(rows))
+	var failed map[int]error
+	for i, row := range rows {
+		copied := make(map[string]interface{}, len(row))
+		for col, value := range row {
+			copied[col] = value
+		}
+		if err := p.ApplyRow(copied); err != nil {
+			if failed == nil {
+				failed = make(map[int]error)
+			}
+			failed[i] = err
+			continue
+		}
+		transformed = append(transformed, copied)
+	}
+	return transformed, failed
+}
+
+// transforming a single row in place
+func (p *Pipeline) ApplyRow(row map[string]interface{}) error {
+	if p == nil {
+		return nil
+	}
+	for _, s := range p.steps {
+		if err := s.apply(row); err != nil {
+			return fmt.Errorf("failed to transform row of table %s, %v", p.table, err)
+		}
+	}
+	return nil
+}
+
+// returning a copy of a translated schema with the columns the transformed rows have
+func (p *Pipeline) TransformSchema(schema *database.TableSchema) *database.TableSchema {
+	if p == nil || schema == nil {
+		return schema
+	}
+	transformed := *schema
+	transformed.Columns = append([]database.ColumnDefinition{}, schema.Columns...)
+	transformed.PrimaryKey = append([]string{}, schema.PrimaryKey...)
+	for _, s := range p.steps {
+		s.schema(&transformed)
+	}
+	return &transformed
+}
+
+// returning the names columns have after the transformations, false when one of them is dropped
+func (p *Pipeline) RenameColumns(columns []string) ([]string, bool) {
+	if p == nil {
+		return columns, true
+	}
+	renamed := make([]string, len(columns))
+	for i, column := range columns {
+		for _, s := range p.steps {
+			var kept bool
+			if column, kept = s.rename(column); !kept {
+				return nil, false
+			}
+		}
+		renamed[i] = column
+	}
+	return renamed, true
+}
+
+// renaming a column
+type renameStep struct {
+	from, to string
+}
+
+func (s *renameStep) apply(row map[string]interface{}) error {
+	if value, ok := row[s.from]; ok {
+		delete(row, s.from)
+		row[s.to] = value
+	}
+	return nil
+}
+
+func (s *renameStep) schema(schema *database.TableSchema) {
+	for i := range schema.Columns {
+		if schema.Columns[i].Name == s.from {
+			schema.Columns[i].Name = s.to
+		}
+	}
+	for i, column := range schema.PrimaryKey {
+		if column == s.from {
+			schema.PrimaryKey[i] = s.to
+		}
+	}
+}
+
+func (s *renameStep) rename(column string) (string, bool) {
+	if column == s.from {
+		return s.to, true
+	}
+	return column, true
+}
+
+// removing columns
+type dropStep struct {
+	columns []string
+}
+
+func (s *dropStep) apply(row map[string]interface{}) error {
+	for _, column := range s.columns {
+		delete(row, column)
+	}
+	return nil
+}
+
+func (s *dropStep) schema(schema *database.TableSchema) {
+	columns := schema.Columns[:0]
+	for _, column := range schema.Columns {
+		if !s.drops(column.Name) {
+			columns = append(columns, column)
+		}
+	}
+	schema.Columns = columns
+
+	//a key with a dropped column no longer identifies the rows
+	for _, column := range schema.PrimaryKey {
+		if s.drops(column) {
+			schema.PrimaryKey = nil
+			break
+		}
+	}
+}
+
+func (s *dropStep) rename(column string) (string, bool) {
+	return column, !s.drops(column)
+}
+
+func (s *dropStep) drops(column string) bool {
+	for _, dropped := range s.columns {
+		if dropped == column {
+			return true
+		}
+	}
+	return false
+}
+
+// converting the values of a column to a string, int, float or bool
+type castStep struct {
+	column string
+	kind   string
+}
+
+func (s *castStep) apply(row map[string]interface{}) error {
+	value, ok := row[s.column]
+	if !ok || value == nil {
+		return nil
+	}
+	converted, err := castValue(value, s.kind)
+	if err != nil {
+		return fmt.Errorf("cannot cast %v of column %s to %s, %v", value, s.column, s.kind, err)
+	}
+	row[s.column] = converted
+	return nil
+}
+
+func (s *castStep) schema(schema *database.TableSchema) {
+	setColumnType(schema, s.column, s.kind)
+}
+
+func (s *castStep) rename(column string) (string, bool) {
+	return column, true
+}
+
+// replacing NULL or missing values of a column
+type defaultStep struct {
+	column string
+	value  interface{}
+}
+
+func (s *defaultStep) apply(row map[string]interface{}) error {
+	if row[s.column] == nil {
+		row[s.column] = s.value
+	}
+	return nil
+}
+
+func (s *defaultStep) schema(schema *database.TableSchema) {}
+
+func (s *defaultStep) rename(column string) (string, bool) {
+	return column, true
+}
+
+// joining the values of columns into a new column, NULL values are left out
+type concatStep struct {
+	column    string
+	from      []string
+	separator string
+}
+
+func (s *concatStep) apply(row map[string]interface{}) error {
+	parts := make([]string, 0, len(s.from))
+	for _, column := range s.from {
+		if value := row[column]; value != nil {
+			parts = append(parts, stringValue(value))
+		}
+	}
+	if len(parts) == 0 {
+		row[s.column] = nil
+		return nil
+	}
+	row[s.column] = strings.Join(parts, s.separator)
+	return nil
+}
+
+func (s *concatStep) schema(schema *database.TableSchema) {
+	addColumn(schema, s.column, "string")
+}
+
+func (s *concatStep) rename(column string) (string, bool) {
+	return column, true
+}
+
+// splitting the value of a column into new columns, the last one keeps the rest of the value
+type splitStep struct {
+	column    string
+	into      []string
+	separator string
+}
+
+func (s *splitStep) apply(row map[string]interface{}) error {
+	var parts []string
+	if value := row[s.column]; value != nil {
+		parts = strings.SplitN(stringValue(value), s.separator, len(s.into))
+	}
+	for i, column := range s.into {
+		if i < len(parts) {
+			row[column] = parts[i]
+		} else {
+			row[column] = nil
+		}
+	}
+	return nil
+}
+
+func (s *splitStep) schema(schema *database.TableSchema) {
+	for _, column := range s.into {
+		addColumn(schema, column, "string")
+	}
+}
+
+func (s *splitStep) rename(column string) (string, bool) {
+	return column, true
+}
+
+// replacing the matches of a pattern in the string values of a column
+type replaceStep struct {
+	column  string
+	pattern *regexp.Regexp
+	with    string
+}
+
+func (s *replaceStep) apply(row map[string]interface{}) error {
+	switch value := row[s.column].(type) {
+	case string:
+		row[s.column] = s.pattern.ReplaceAllString(value, s.with)
+	case []byte:
+		row[s.column] = s.pattern.ReplaceAllString(string(value), s.with)
+	}
+	return nil
+}
+
+func (s *replaceStep) schema(schema *database.TableSchema) {}
+
+func (s *replaceStep) rename(column string) (string, bool) {
+	return column, true
+}
+
+// placeholders of a derive template
+var templateColumn = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// computing a column from a template, NULL values are replaced with empty strings
+type deriveStep struct {
+	column   string
+	template string
+}
+
+func (s *deriveStep) apply(row map[string]interface{}) error {
+	row[s.column] = templateColumn.ReplaceAllStringFunc(s.template, func(placeholder string) string {
+		value := row[placeholder[1:len(placeholder)-1]]
+		if value == nil {
+			return ""
+		}
+		return stringValue(value)
+	})
+	return nil
+}
+
+func (s *deriveStep) schema(schema *database.TableSchema) {
+	addColumn(schema, s.column, "string")
+}
+
+func (s *deriveStep) rename(column string) (string, bool) {
+	return column, true
+}
+
+// converting a value to the Go type of a cast
+func castValue(value interface{}, kind string) (interface{}, error) {
+	switch kind {
+	case "string":
+		return stringValue(value), nil
+	case "int":
+		switch v := value.(type) {
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case float32:
+			return floatToInt(float64(v))
+		case float64:
+			return floatToInt(v)
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		default:
+			return strconv.ParseInt(strings.TrimSpace(stringValue(value)), 10, 64)
+		}
+	case "float":
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		default:
+			return strconv.ParseFloat(strings.TrimSpace(stringValue(value)), 64)
+		}
+	case "bool":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case int:
+			return v != 0, nil
+		case int64:
+			return v != 0, nil
+		default:
+			return strconv.ParseBool(strings.TrimSpace(stringValue(value)))
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", kind)
+}
+
+// converting a float without a fraction to an int
+func floatToInt(value float64) (interface{}, error) {
+	if value != float64(int64(value)) {
+		return nil, fmt.Errorf("value has a fraction")
+	}
+	return int64(value), nil
+}
+
+// formatting a value as a string, bytes are taken as text
+func stringValue(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// returning the column type of a kind of value in the dialect of a schema, documents keep the kind
+func kindType(dialect, kind string) string {
+	if types, ok := kindTypes[dialect]; ok {
+		return types[kind]
+	}
+	return kind
+}
+
+// changing the type of a column of a schema
+func setColumnType(schema *database.TableSchema, column, kind string) {
+	for i := range schema.Columns {
+		if schema.Columns[i].Name == column {
+			schema.Columns[i].DataType = kindType(schema.Dialect, kind)
+			schema.Columns[i].ColumnType = schema.Columns[i].DataType
+			schema.Columns[i].Default = nil
+			schema.Columns[i].AutoIncrement = false
+		}
+	}
+}
+
+// adding a nullable column to a schema unless it has one with the name already
+func addColumn(schema *database.TableSchema, column, kind string) {
+	for _, existing := range schema.Columns {
+		if existing.Name == column {
+			return
+		}
+	}
+	dataType := kindType(schema.Dialect, kind)
+	schema.Columns = append(schema.Columns, database.ColumnDefinition{Name: column, DataType: dataType, ColumnType: dataType, Nullable: true})
+}
+
+// returning the keys of a map in order, so the steps of a map run in the same order every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
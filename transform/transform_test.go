@@ -0,0 +1,117 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"gopkg.in/yaml.v3"
+)
+
+const testTransforms = `
+users:
+  - rename: {first_name: given_name}
+  - drop: [password_hash]
+  - cast: {age: int}
+  - default: {country: unknown}
+  - concat: {column: full_name, from: [given_name, last_name], separator: " "}
+  - split: {column: email, into: [mailbox, domain], separator: "@"}
+  - replace: {column: phone, pattern: "[^0-9]", with: ""}
+  - derive: {column: label, template: "{full_name} <{email}>"}
+`
+
+func compileTestTransforms(t *testing.T) *Pipeline {
+	var configured map[string][]config.TransformStep
+	if err := yaml.Unmarshal([]byte(testTransforms), &configured); err != nil {
+		t.Fatalf("Failed to parse transforms, %v", err)
+	}
+	tables, err := Compile(configured)
+	if err != nil {
+		t.Fatalf("Failed to compile transforms, %v", err)
+	}
+	return tables.For("USERS")
+}
+
+func TestPipelineTransformsRows(t *testing.T) {
+	pipeline := compileTestTransforms(t)
+	rows := []map[string]interface{}{
+		{"_source_table": "users", "id": 1, "first_name": "Susheel", "last_name": "Sathyaraj", "password_hash": "x", "age": "34", "country": nil, "email": "susheel@example.com", "phone": "+49 (151) 234"},
+		{"_source_table": "users", "id": 2, "first_name": "Ana", "last_name": nil, "age": "unknown", "email": "ana@example.com"},
+	}
+
+	transformed, failed := pipeline.Apply(rows)
+	if len(transformed) != 1 || len(failed) != 1 || failed[1] == nil {
+		t.Fatalf("Expected the second row to fail its cast, got %d rows and errors %v", len(transformed), failed)
+	}
+
+	expected := map[string]interface{}{
+		"_source_table": "users", "id": 1, "given_name": "Susheel", "last_name": "Sathyaraj", "age": int64(34), "country": "unknown",
+		"full_name": "Susheel Sathyaraj", "email": "susheel@example.com", "mailbox": "susheel", "domain": "example.com",
+		"phone": "49151234", "label": "Susheel Sathyaraj <susheel@example.com>",
+	}
+	if fmt.Sprint(transformed[0]) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, transformed[0])
+	}
+	if _, ok := rows[0]["given_name"]; ok {
+		t.Errorf("Expected the source rows to be left unchanged, got %v", rows[0])
+	}
+}
+
+func TestPipelineTransformsSchema(t *testing.T) {
+	pipeline := compileTestTransforms(t)
+	schema := &database.TableSchema{
+		Name:    "users",
+		Dialect: "postgresql",
+		Columns: []database.ColumnDefinition{
+			{Name: "id", DataType: "INTEGER"},
+			{Name: "first_name", DataType: "VARCHAR(50)"},
+			{Name: "last_name", DataType: "VARCHAR(50)"},
+			{Name: "password_hash", DataType: "TEXT"},
+			{Name: "age", DataType: "VARCHAR(3)"},
+			{Name: "email", DataType: "TEXT"},
+		},
+		PrimaryKey: []string{"id"},
+	}
+
+	transformed := pipeline.TransformSchema(schema)
+	var columns []string
+	for _, column := range transformed.Columns {
+		columns = append(columns, column.Name+" "+column.DataType)
+	}
+	expected := "id INTEGER, given_name VARCHAR(50), last_name VARCHAR(50), age BIGINT, email TEXT, full_name TEXT, mailbox TEXT, domain TEXT, label TEXT"
+	if strings.Join(columns, ", ") != expected {
+		t.Errorf("Expected columns %s, got %s", expected, strings.Join(columns, ", "))
+	}
+	if len(schema.Columns) != 6 || schema.Columns[1].Name != "first_name" {
+		t.Errorf("Expected the source schema to be left unchanged, got %v", schema.Columns)
+	}
+
+	if renamed, ok := pipeline.RenameColumns([]string{"first_name", "last_name"}); !ok || fmt.Sprint(renamed) != "[given_name last_name]" {
+		t.Errorf("Expected the renamed index columns, got %v %v", renamed, ok)
+	}
+	if _, ok := pipeline.RenameColumns([]string{"password_hash"}); ok {
+		t.Errorf("Expected a dropped column to be reported")
+	}
+}
+
+func TestCompileRejectsInvalidSteps(t *testing.T) {
+	testCases := []struct {
+		description string
+		step        config.TransformStep
+	}{
+		{"no operation", config.TransformStep{}},
+		{"two operations", config.TransformStep{Drop: []string{"a"}, Cast: map[string]string{"b": "int"}}},
+		{"unsupported cast", config.TransformStep{Cast: map[string]string{"b": "decimal"}}},
+		{"invalid pattern", config.TransformStep{Replace: &config.ReplaceTransform{Column: "a", Pattern: "("}}},
+		{"split without separator", config.TransformStep{Split: &config.SplitTransform{Column: "a", Into: []string{"b"}}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if _, err := NewPipeline("users", []config.TransformStep{tc.step}); err == nil {
+				t.Errorf("Expected an error for %s", tc.description)
+			}
+		})
+	}
+}
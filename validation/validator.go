@@ -1,12 +1,15 @@
 package validation
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/SusheelSathyaraj/DataMigrationTool/database"
+	"github.com/SusheelSathyaraj/DataMigrationTool/transform"
 )
 
 // Represents the result of the validation check
@@ -16,6 +19,7 @@ type ValidationResult struct {
 	ErrorMessage string
 	RowCount     int64
 	SampleData   []map[string]interface{}
+	KeyColumns   []string //primary key of the source table, the samples are compared on it
 	TimeStamp    time.Time
 }
 
@@ -23,7 +27,9 @@ type ValidationResult struct {
 type MigrationVaildator struct {
 	SourceClient database.DatabaseClient
 	TargetClient database.DatabaseClient
-	SampleSize   int //no. of rows to sample for validation
+	SampleSize   int                   //no. of rows to sample for validation
+	Mapping      *database.NameMapping //target names of the source tables and columns, nil when they are the same
+	Transforms   transform.Tables      //transformations of the migrated rows, applied to the source samples before comparing
 }
 
 // Creating a new validator instance
@@ -48,7 +54,7 @@ func (m *MigrationVaildator) PreMigrationValidation(tables []string) ([]Validati
 		}
 
 		//checking if table is present and getting the row count
-		sourceData, err := m.SourceClient.FetchAllData([]string{table})
+		rowCount, sampleData, err := m.countAndSample(m.SourceClient, table)
 		if err != nil {
 			result.IsValid = false
 			result.ErrorMessage = fmt.Sprintf("Failed to fetch data from the source table %s:%v", table, err)
@@ -56,15 +62,10 @@ func (m *MigrationVaildator) PreMigrationValidation(tables []string) ([]Validati
 			continue
 		}
 
-		result.RowCount = int64(len(sourceData))
+		result.RowCount = rowCount
 		result.IsValid = true
-
-		//getting samples for validation
-		sampleSize := m.SampleSize
-		if len(sourceData) < sampleSize {
-			sampleSize = len(sourceData)
-		}
-		result.SampleData = sourceData[:sampleSize]
+		result.SampleData = sampleData
+		result.KeyColumns = primaryKeyColumns(m.SourceClient, table)
 
 		log.Printf("Pre-Validation: Table %s contains %d rows", table, result.RowCount)
 		results = append(results, result)
@@ -90,16 +91,17 @@ func (m *MigrationVaildator) PostMigationValidation(tables []string, preValidati
 			TimeStamp: time.Now(),
 		}
 
-		//getting target data
-		targetData, err := m.TargetClient.FetchAllData([]string{table})
+		//getting target row count and samples
+		targetTable := m.Mapping.TargetTable(table)
+		rowCount, sampleData, err := m.countAndSample(m.TargetClient, targetTable)
 		if err != nil {
 			result.IsValid = false
-			result.ErrorMessage = fmt.Sprintf("Failed to fetch data from target table %s, %v", table, err)
+			result.ErrorMessage = fmt.Sprintf("Failed to fetch data from target table %s, %v", targetTable, err)
 			results = append(results, result)
 			continue
 		}
 
-		result.RowCount = int64(len(targetData))
+		result.RowCount = rowCount
 
 		//comparing with source data count
 		preResult, exists := preResultMap[table]
@@ -118,15 +120,13 @@ func (m *MigrationVaildator) PostMigationValidation(tables []string, preValidati
 		}
 
 		//sample data validation
-		if len(targetData) > 0 {
-			sampleSize := m.SampleSize
-			if len(targetData) < sampleSize {
-				sampleSize = len(targetData)
-			}
-			result.SampleData = targetData[:sampleSize]
+		if len(sampleData) > 0 {
+			result.SampleData = sampleData
 
 			//Validating sample data integrity
-			if err := m.validateSampleDataIntegrity(preResult.SampleData, result.SampleData); err != nil {
+			//comparing the source rows transformed like the migrated ones and under their target column names
+			expected, keyColumns := m.expectedSample(table, preResult)
+			if err := m.validateSampleDataIntegrity(expected, result.SampleData, keyColumns); err != nil {
 				result.IsValid = false
 				result.ErrorMessage = fmt.Sprintf("Data integrity Validation failed, %v ", err)
 				results = append(results, result)
@@ -140,13 +140,68 @@ func (m *MigrationVaildator) PostMigationValidation(tables []string, preValidati
 	return results, nil
 }
 
-// comparing sample data from source and target
-func (m *MigrationVaildator) validateSampleDataIntegrity(sourceData, targetData []map[string]interface{}) error {
+// streaming through a table to count its rows, keeping only the first SampleSize rows in memory
+func (m *MigrationVaildator) countAndSample(client database.DatabaseClient, table string) (int64, []map[string]interface{}, error) {
+	iterator, err := database.OpenRowIterator(context.Background(), client, table, database.DefaultStreamBatchSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer iterator.Close()
+
+	var rowCount int64
+	sampleData := make([]map[string]interface{}, 0)
+	for iterator.Next() {
+		batch := iterator.Batch()
+		rowCount += int64(len(batch))
+
+		if remaining := m.SampleSize - len(sampleData); remaining > 0 {
+			if len(batch) < remaining {
+				remaining = len(batch)
+			}
+			sampleData = append(sampleData, batch[:remaining]...)
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		return rowCount, nil, err
+	}
+	return rowCount, sampleData, nil
+}
+
+// returning the primary key of a source table, nil when the client cannot tell
+func primaryKeyColumns(client database.DatabaseClient, table string) []string {
+	keyed, ok := client.(database.RollbackClient)
+	if !ok {
+		return nil
+	}
+	keyColumns, err := keyed.PrimaryKeyColumns(table)
+	if err != nil {
+		return nil
+	}
+	return keyColumns
+}
+
+// returning the source samples of a table as they are expected in the target, transformed and mapped, with
+// the target names of the primary key. Rows failing the transformations are left out, they are dead-lettered
+func (m *MigrationVaildator) expectedSample(table string, preResult ValidationResult) ([]map[string]interface{}, []string) {
+	pipeline := m.Transforms.For(table)
+	transformed, _ := pipeline.Apply(preResult.SampleData)
+
+	var keyColumns []string
+	if renamed, ok := pipeline.RenameColumns(preResult.KeyColumns); ok && len(renamed) > 0 {
+		keyColumns = m.Mapping.TargetColumns(table, renamed)
+	}
+	return m.Mapping.MapRows(transformed), keyColumns
+}
+
+// comparing sample data from source and target, on the key columns when they are known,
+// otherwise on the columns both rows have
+func (m *MigrationVaildator) validateSampleDataIntegrity(sourceData, targetData []map[string]interface{}, keyColumns []string) error {
 	if len(sourceData) == 0 && len(targetData) == 0 {
 		return nil
 	}
 
-	if len(sourceData) != len(targetData) {
+	//the target sample may hold more rows when source rows of the sample were dead-lettered
+	if len(sourceData) > len(targetData) {
 		return fmt.Errorf("sample data length mismatch, source:%d, target:%d", len(sourceData), len(targetData))
 	}
 
@@ -175,24 +230,45 @@ func (m *MigrationVaildator) validateSampleDataIntegrity(sourceData, targetData
 			}
 		}
 
-		//comparing key fields (assuming first non-metadata field is primary key)
-		var primaryKey string
-		for k := range cleanSourceRow {
-			primaryKey = k
-			break
+		if len(keyColumns) > 0 {
+			//the target row is the one with the same key, the samples need not be in the same order
+			if _, found := findRowByKey(targetData, keyColumns, cleanSourceRow); !found {
+				return fmt.Errorf("primary key mismatch in row %d: source key %v not found in the target sample", i, keyValues(cleanSourceRow, keyColumns))
+			}
+			continue
 		}
-		if primaryKey != "" {
-			sourceVal := cleanSourceRow[primaryKey]
-			targetVal := cleanTargetRow[primaryKey]
 
-			if !compareValues(sourceVal, targetVal) {
-				return fmt.Errorf("primary key mismatch in row %d: source: %v, target:%v", i, sourceVal, targetVal)
+		//without a key, comparing the columns both rows have
+		for column, sourceVal := range cleanSourceRow {
+			targetVal, ok := cleanTargetRow[column]
+			if ok && !compareValues(sourceVal, targetVal) {
+				return fmt.Errorf("value mismatch in row %d, column %s: source: %v, target:%v", i, column, sourceVal, targetVal)
 			}
 		}
 	}
 	return nil
 }
 
+// returning the values of the key columns of a row
+func keyValues(row map[string]interface{}, keyColumns []string) []string {
+	values := make([]string, len(keyColumns))
+	for i, column := range keyColumns {
+		values[i] = fmt.Sprintf("%v", row[column])
+	}
+	return values
+}
+
+// finding the row with the key values of another row
+func findRowByKey(rows []map[string]interface{}, keyColumns []string, keyRow map[string]interface{}) (map[string]interface{}, bool) {
+	key := strings.Join(keyValues(keyRow, keyColumns), "\x00")
+	for _, row := range rows {
+		if strings.Join(keyValues(row, keyColumns), "\x00") == key {
+			return row, true
+		}
+	}
+	return nil, false
+}
+
 // comparing two values handling type conversion
 func compareValues(v1, v2 interface{}) bool {
 	if v1 == nil && v2 == nil {
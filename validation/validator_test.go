@@ -6,6 +6,9 @@ import (
 	"math"
 	"testing"
 	"time"
+
+	"github.com/SusheelSathyaraj/DataMigrationTool/config"
+	"github.com/SusheelSathyaraj/DataMigrationTool/transform"
 )
 
 // mockdatabase for testing
@@ -156,6 +159,47 @@ func TestPostMigrationValidation(t *testing.T) {
 	}
 }
 
+func TestPostMigrationValidationComparesTransformedSamples(t *testing.T) {
+	sourceClient := NewMockDatabaseClient()
+	targetClient := NewMockDatabaseClient()
+	sourceClient.AddMockData("users", []map[string]interface{}{
+		{"id": 1, "name": "Susheel"},
+		{"id": 2, "name": "Sathyaraj"},
+	})
+	//the key was renamed by the transformations, the rows come back from the target in another order
+	targetClient.AddMockData("users", []map[string]interface{}{
+		{"user_id": 2, "name": "Sathyaraj"},
+		{"user_id": 1, "name": "Susheel"},
+	})
+
+	pipeline, err := transform.NewPipeline("users", []config.TransformStep{{Rename: map[string]string{"id": "user_id"}}})
+	if err != nil {
+		t.Fatalf("Failed to compile transforms, %v", err)
+	}
+	validator := NewMigrationValidator(sourceClient, targetClient)
+	validator.Transforms = transform.Tables{"users": pipeline}
+
+	preResults, err := validator.PreMigrationValidation([]string{"users"})
+	if err != nil {
+		t.Fatalf("Pre-Validation failed, %v", err)
+	}
+	preResults[0].KeyColumns = []string{"id"}
+
+	postResults, _ := validator.PostMigationValidation([]string{"users"}, preResults)
+	if !postResults[0].IsValid {
+		t.Errorf("Expected the transformed samples to match, got %s", postResults[0].ErrorMessage)
+	}
+
+	targetClient.AddMockData("users", []map[string]interface{}{
+		{"user_id": 1, "name": "Susheel"},
+		{"user_id": 3, "name": "Sathyaraj"},
+	})
+	postResults, _ = validator.PostMigationValidation([]string{"users"}, preResults)
+	if postResults[0].IsValid {
+		t.Error("Expected a missing key to fail the validation")
+	}
+}
+
 func TestPostMigrationValidationRowCountMismatch(t *testing.T) {
 	sourceClient := NewMockDatabaseClient()
 	targetClient := NewMockDatabaseClient()
@@ -300,7 +344,7 @@ func TestValidateSampleDataIntegrity(t *testing.T) {
 		{"id": 2, "name": "Sathyaraj", "_source_table": "users"},
 	}
 
-	err := validator.validateSampleDataIntegrity(sourceData, targetData)
+	err := validator.validateSampleDataIntegrity(sourceData, targetData, []string{"id"})
 	if err != nil {
 		t.Errorf("Expected no error for matching data, got %v", err)
 	}
@@ -311,7 +355,7 @@ func TestValidateSampleDataIntegrity(t *testing.T) {
 		{"id": 3, "name": "Sathyaraj", "_source_table": "users"},
 	}
 
-	err = validator.validateSampleDataIntegrity(sourceData, targetDataMismatch)
+	err = validator.validateSampleDataIntegrity(sourceData, targetDataMismatch, []string{"id"})
 	if err == nil {
 		t.Errorf("Expected error for mismatched data, got nil")
 	}